@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+)
+
+// AtomicSinker is implemented by sinks able to apply several metrics
+// atomically, so concurrent readers never see part of them. The sink
+// given to fn must not be used after fn returns.
+type AtomicSinker interface {
+	Atomically(fn func(s Sinker))
+}
+
+// batchOp is the type of a metric accumulated on a Batch
+type batchOp int
+
+const (
+	batchGauge batchOp = iota
+	batchKey
+	batchCounter
+	batchCounterFloat64
+	batchSample
+)
+
+type batchEntry struct {
+	op     batchOp
+	key    []string
+	val    float64
+	labels []Label
+}
+
+// Batch accumulates related metrics which are submitted together on
+// Commit, ex: the duration, rows and errors of a database query.
+// It is safe for concurrent use.
+type Batch struct {
+	mu      sync.Mutex
+	entries []batchEntry
+}
+
+// SetGauge adds a gauge value to the batch
+func (b *Batch) SetGauge(key []string, val float32) {
+	b.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels adds a gauge value with labels to the batch
+func (b *Batch) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	b.add(batchGauge, key, float64(val), labels)
+}
+
+// EmitKey adds a key value metric to the batch
+func (b *Batch) EmitKey(key []string, val float32) {
+	b.add(batchKey, key, float64(val), nil)
+}
+
+// IncrCounter adds a counter increment to the batch
+func (b *Batch) IncrCounter(key []string, val float32) {
+	b.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels adds a counter increment with labels to the batch
+func (b *Batch) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	b.add(batchCounter, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 adds a float64 counter increment to the batch
+func (b *Batch) IncrCounterFloat64(key []string, val float64) {
+	b.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 adds a float64 counter increment with labels to the batch
+func (b *Batch) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	b.add(batchCounterFloat64, key, val, labels)
+}
+
+// AddSample adds a sample to the batch
+func (b *Batch) AddSample(key []string, val float32) {
+	b.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample with labels to the batch
+func (b *Batch) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	b.add(batchSample, key, float64(val), labels)
+}
+
+// Len returns the number of metrics in the batch
+func (b *Batch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.entries)
+}
+
+// Reset discards all the metrics of the batch
+func (b *Batch) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = nil
+}
+
+// Commit submits all the metrics of the batch to the sink, emptying the
+// batch. Sinks implementing AtomicSinker apply them atomically. When the
+// sink implements FallibleSinker, every metric is submitted and the first
+// failure is returned.
+func (b *Batch) Commit(sink Sinker) error {
+	if sink == nil {
+		return errors.New("metrics: batch committed to a nil sink")
+	}
+
+	b.mu.Lock()
+	entries := b.entries
+	b.entries = nil
+	b.mu.Unlock()
+
+	var err error
+	apply := func(s Sinker) {
+		err = applyBatch(s, entries)
+	}
+	if as, ok := sink.(AtomicSinker); ok {
+		as.Atomically(apply)
+	} else {
+		apply(sink)
+	}
+	return err
+}
+
+// add buffers a call, copying its key and labels as the caller may
+// reuse them before the batch is committed
+func (b *Batch) add(op batchOp, key []string, val float64, labels []Label) {
+	e := batchEntry{op: op, key: append([]string(nil), key...), val: val}
+	if len(labels) > 0 {
+		e.labels = append([]Label(nil), labels...)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, e)
+}
+
+// applyBatch submits the entries to the sink, returning the
+// first failure when the sink implements FallibleSinker. Float64
+// counters have no fallible variant and never fail.
+func applyBatch(s Sinker, entries []batchEntry) error {
+	fs, fallible := s.(FallibleSinker)
+
+	var firstErr error
+	for _, e := range entries {
+		var err error
+		val := float32(e.val)
+		switch {
+		case e.op == batchCounterFloat64:
+			AsFloat64Sink(s).IncrCounterWithLabelsFloat64(e.key, e.val, e.labels)
+		case fallible && e.op == batchGauge:
+			err = fs.TrySetGaugeWithLabels(e.key, val, e.labels)
+		case fallible && e.op == batchKey:
+			err = fs.TryEmitKey(e.key, val)
+		case fallible && e.op == batchCounter:
+			err = fs.TryIncrCounterWithLabels(e.key, val, e.labels)
+		case fallible && e.op == batchSample:
+			err = fs.TryAddSampleWithLabels(e.key, val, e.labels)
+		case e.op == batchGauge:
+			s.SetGaugeWithLabels(e.key, val, e.labels)
+		case e.op == batchKey:
+			s.EmitKey(e.key, val)
+		case e.op == batchCounter:
+			s.IncrCounterWithLabels(e.key, val, e.labels)
+		case e.op == batchSample:
+			s.AddSampleWithLabels(e.key, val, e.labels)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
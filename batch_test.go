@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+type MockAtomicSink struct {
+	MockSink
+	atomic int
+}
+
+func (m *MockAtomicSink) Atomically(fn func(s Sinker)) {
+	m.atomic++
+	fn(&m.MockSink)
+}
+
+func fillBatch(b *Batch) {
+	l := []Label{{"a", "b"}}
+	b.SetGauge([]string{"gauge"}, 1)
+	b.SetGaugeWithLabels([]string{"gauge"}, 2, l)
+	b.IncrCounter([]string{"counter"}, 3)
+	b.IncrCounterWithLabels([]string{"counter"}, 4, l)
+	b.AddSample([]string{"sample"}, 5)
+	b.AddSampleWithLabels([]string{"sample"}, 6, l)
+}
+
+func TestBatch_Commit(t *testing.T) {
+	b := &Batch{}
+	fillBatch(b)
+	if b.Len() != 6 {
+		t.Fatalf("bad len %d", b.Len())
+	}
+
+	m := &MockSink{}
+	if err := b.Commit(m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.keys[2], []string{"counter"}) || !reflect.DeepEqual(m.labels[3], []Label{{"a", "b"}}) {
+		t.Fatalf("bad metrics %v %v", m.keys, m.labels)
+	}
+	if b.Len() != 0 {
+		t.Fatalf("batch must be emptied on commit")
+	}
+}
+
+func TestBatch_CopiesKeyAndLabels(t *testing.T) {
+	b := &Batch{}
+	key := []string{"counter"}
+	labels := []Label{{"a", "b"}}
+	b.IncrCounterWithLabels(key, 1, labels)
+	key[0], labels[0].Value = "modified", "modified"
+
+	m := &MockSink{}
+	if err := b.Commit(m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(m.keys[0], []string{"counter"}) || !reflect.DeepEqual(m.labels[0], []Label{{"a", "b"}}) {
+		t.Fatalf("bad metrics %v %v", m.keys, m.labels)
+	}
+}
+
+func TestBatch_CommitNilSink(t *testing.T) {
+	b := &Batch{}
+	if err := b.Commit(nil); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestBatch_CommitAtomic(t *testing.T) {
+	b := &Batch{}
+	fillBatch(b)
+
+	m := &MockAtomicSink{}
+	if err := b.Commit(m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if m.atomic != 1 || len(m.vals) != 6 {
+		t.Fatalf("batch must be applied in a single atomic call %d %v", m.atomic, m.vals)
+	}
+}
+
+func TestBatch_CommitFallible(t *testing.T) {
+	b := &Batch{}
+	fillBatch(b)
+
+	m := &MockFallibleSink{err: ErrQueueFull}
+	if err := b.Commit(m); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull got %v", err)
+	}
+}
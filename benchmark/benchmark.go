@@ -0,0 +1,103 @@
+// Package benchmark provides standard benchmarks to compare sinks under
+// the same workloads, ex:
+//
+//	func BenchmarkStatsd(b *testing.B) {
+//		sink, _ := statsd.NewSink("localhost:8125")
+//		defer sink.Shutdown()
+//		benchmark.RunSuite(b, sink)
+//	}
+package benchmark
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// cardinality is the number of label combinations of the high
+// cardinality workload
+const cardinality = 10000
+
+// RunSuite runs the benchmarks of the suite on the sink, as sub-benchmarks:
+//   - Serial: counter increments from a single goroutine
+//   - Parallel: counter increments from GOMAXPROCS goroutines
+//   - Mixed: gauges, counters and samples with labels, in turn
+//   - HighCardinality: counter increments over 10000 label combinations
+func RunSuite(b *testing.B, sink metrics.Sinker) {
+	b.Run("Serial", func(b *testing.B) {
+		Serial(b, sink)
+	})
+	b.Run("Parallel", func(b *testing.B) {
+		Parallel(b, sink)
+	})
+	b.Run("Mixed", func(b *testing.B) {
+		Mixed(b, sink)
+	})
+	b.Run("HighCardinality", func(b *testing.B) {
+		HighCardinality(b, sink)
+	})
+}
+
+// Serial benchmarks counter increments from a single goroutine
+func Serial(b *testing.B, sink metrics.Sinker) {
+	key := []string{"benchmark", "serial"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink.IncrCounter(key, 1)
+	}
+}
+
+// Parallel benchmarks counter increments from GOMAXPROCS goroutines
+func Parallel(b *testing.B, sink metrics.Sinker) {
+	key := []string{"benchmark", "parallel"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			sink.IncrCounter(key, 1)
+		}
+	})
+}
+
+// Mixed benchmarks a workload of gauges, counters and samples with labels
+func Mixed(b *testing.B, sink metrics.Sinker) {
+	gauge := []string{"benchmark", "mixed", "gauge"}
+	counter := []string{"benchmark", "mixed", "counter"}
+	sample := []string{"benchmark", "mixed", "sample"}
+	labels := []metrics.Label{{Name: "method", Value: "GET"}, {Name: "code", Value: "200"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		switch i % 3 {
+		case 0:
+			sink.SetGaugeWithLabels(gauge, float32(i), labels)
+		case 1:
+			sink.IncrCounterWithLabels(counter, 1, labels)
+		default:
+			sink.AddSampleWithLabels(sample, float32(i%100), labels)
+		}
+	}
+}
+
+// HighCardinality benchmarks counter increments over many label combinations
+func HighCardinality(b *testing.B, sink metrics.Sinker) {
+	key := []string{"benchmark", "cardinality"}
+	combinations := make([][]metrics.Label, cardinality)
+	for i := range combinations {
+		combinations[i] = []metrics.Label{
+			{Name: "user", Value: fmt.Sprintf("user-%d", i)},
+			{Name: "shard", Value: fmt.Sprintf("%d", i%16)},
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink.IncrCounterWithLabels(key, 1, combinations[i%cardinality])
+	}
+}
@@ -0,0 +1,21 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/inmem"
+)
+
+func BenchmarkBlackhole(b *testing.B) {
+	RunSuite(b, &metrics.BlackholeSink{})
+}
+
+func BenchmarkInmem(b *testing.B) {
+	RunSuite(b, inmem.NewSink(10*time.Second, time.Minute))
+}
+
+func BenchmarkShardedInmem(b *testing.B) {
+	RunSuite(b, inmem.NewShardedSink(16, 10*time.Second, time.Minute))
+}
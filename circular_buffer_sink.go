@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// MetricRecord is a metric call retained by a CircularBufferSink
+type MetricRecord struct {
+	Time   time.Time
+	Type   MetricType
+	Key    []string
+	Value  float64
+	Labels []Label
+}
+
+// CircularBufferSink forwards every call to the inner sink, retaining
+// the last records in a ring buffer, to be inspected when an error
+// occurs. The buffer does not alter what the inner sink receives.
+type CircularBufferSink struct {
+	inner Sinker
+
+	mu      sync.Mutex
+	records []MetricRecord
+	next    int
+	full    bool
+}
+
+// NewCircularBufferSink creates a CircularBufferSink retaining up to
+// capacity records, a capacity lower than 1 retains a single record
+func NewCircularBufferSink(inner Sinker, capacity int) *CircularBufferSink {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &CircularBufferSink{
+		inner:   inner,
+		records: make([]MetricRecord, capacity),
+	}
+}
+
+// DumpBuffer returns the retained records, oldest first
+func (s *CircularBufferSink) DumpBuffer() []MetricRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.full {
+		return append([]MetricRecord(nil), s.records[:s.next]...)
+	}
+	dump := make([]MetricRecord, 0, len(s.records))
+	dump = append(dump, s.records[s.next:]...)
+	return append(dump, s.records[:s.next]...)
+}
+
+// SetGauge sets a value on a gauge
+func (s *CircularBufferSink) SetGauge(key []string, val float32) {
+	s.record(GaugeMetric, key, float64(val), nil)
+	s.inner.SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *CircularBufferSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.record(GaugeMetric, key, float64(val), labels)
+	s.inner.SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *CircularBufferSink) EmitKey(key []string, val float32) {
+	s.record(KeyMetric, key, float64(val), nil)
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *CircularBufferSink) IncrCounter(key []string, val float32) {
+	s.record(CounterMetric, key, float64(val), nil)
+	s.inner.IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *CircularBufferSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.record(CounterMetric, key, float64(val), labels)
+	s.inner.IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *CircularBufferSink) IncrCounterFloat64(key []string, val float64) {
+	s.record(CounterMetric, key, val, nil)
+	AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *CircularBufferSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	s.record(CounterMetric, key, val, labels)
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *CircularBufferSink) AddSample(key []string, val float32) {
+	s.record(SampleMetric, key, float64(val), nil)
+	s.inner.AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *CircularBufferSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.record(SampleMetric, key, float64(val), labels)
+	s.inner.AddSampleWithLabels(key, val, labels)
+}
+
+// Stores a record, overwriting the oldest one when the buffer is full.
+// The key and labels are copied, callers may reuse their slices.
+func (s *CircularBufferSink) record(typ MetricType, key []string, val float64, labels []Label) {
+	r := MetricRecord{
+		Time:  time.Now(),
+		Type:  typ,
+		Key:   append([]string(nil), key...),
+		Value: val,
+	}
+	if len(labels) > 0 {
+		r.Labels = append([]Label(nil), labels...)
+	}
+
+	s.mu.Lock()
+	s.records[s.next] = r
+	s.next++
+	if s.next == len(s.records) {
+		s.next = 0
+		s.full = true
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCircularBufferSink(t *testing.T) {
+	m := &MockSink{}
+	s := NewCircularBufferSink(m, 3)
+
+	if dump := s.DumpBuffer(); len(dump) != 0 {
+		t.Fatalf("bad dump %v", dump)
+	}
+
+	emitAll(s)
+
+	// The inner sink receives every call
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+
+	// Only the last three are retained, oldest first
+	dump := s.DumpBuffer()
+	if len(dump) != 3 {
+		t.Fatalf("bad dump %v", dump)
+	}
+	for i, expected := range []struct {
+		typ MetricType
+		key string
+		val float64
+	}{
+		{CounterMetric, "counter", 5},
+		{SampleMetric, "sample", 6},
+		{SampleMetric, "sample", 7},
+	} {
+		r := dump[i]
+		if r.Type != expected.typ || r.Key[0] != expected.key || r.Value != expected.val {
+			t.Fatalf("bad record %d %v", i, r)
+		}
+		if r.Time.IsZero() {
+			t.Fatalf("record %d has no time", i)
+		}
+	}
+	if !reflect.DeepEqual(dump[2].Labels, []Label{{"a", "b"}}) {
+		t.Fatalf("bad labels %v", dump[2].Labels)
+	}
+	if dump[1].Labels != nil {
+		t.Fatalf("bad labels %v", dump[1].Labels)
+	}
+}
+
+func TestCircularBufferSink_Partial(t *testing.T) {
+	s := NewCircularBufferSink(&BlackholeSink{}, 3)
+	s.SetGauge([]string{"a"}, 1)
+	s.IncrCounterFloat64([]string{"b"}, 0.5)
+
+	dump := s.DumpBuffer()
+	if len(dump) != 2 || dump[0].Key[0] != "a" || dump[1].Value != 0.5 {
+		t.Fatalf("bad dump %v", dump)
+	}
+
+	// The dump is a copy
+	dump[0].Value = 42
+	if s.DumpBuffer()[0].Value != 1 {
+		t.Fatalf("dump must not alias the buffer")
+	}
+}
+
+func TestCircularBufferSink_CopiesKey(t *testing.T) {
+	s := NewCircularBufferSink(&BlackholeSink{}, 0)
+	key := []string{"a"}
+	s.SetGauge(key, 1)
+	key[0] = "b"
+
+	if dump := s.DumpBuffer(); len(dump) != 1 || dump[0].Key[0] != "a" {
+		t.Fatalf("bad dump %v", dump)
+	}
+}
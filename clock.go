@@ -0,0 +1,23 @@
+package metrics
+
+import "time"
+
+// Clock provides the current time to the sinks aggregating over time,
+// so tests can control it, ex: with a MockClock of providers/testing
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+}
+
+// SystemClock is the Clock of the system time
+type SystemClock struct{}
+
+// Now returns the current local time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Since returns the time elapsed since t
+func (SystemClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Metric is a value reported by a Collector
+type Metric struct {
+	Type   MetricType
+	Key    []string
+	Value  float64
+	Labels []Label
+}
+
+// Collector is implemented by subsystems which report their own metrics
+// when polled, ex: a connection pool reporting its size. Collect sends
+// the current metrics on the channel and returns, it must not close it.
+type Collector interface {
+	Collect(ch chan<- Metric)
+}
+
+// CollectorSink polls the registered collectors at every interval,
+// forwarding the collected metrics to the inner sink. The metrics sent
+// to the CollectorSink itself are forwarded as they are.
+type CollectorSink struct {
+	inner    Sinker
+	interval time.Duration
+
+	mu         sync.Mutex
+	collectors []Collector
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewCollectorSink creates a CollectorSink polling its collectors every interval
+func NewCollectorSink(inner Sinker, interval time.Duration) (*CollectorSink, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("collect interval must be positive, got %s", interval)
+	}
+
+	s := &CollectorSink{
+		inner:    inner,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Register adds a collector, polled from the next interval on
+func (s *CollectorSink) Register(c Collector) {
+	s.mu.Lock()
+	s.collectors = append(s.collectors, c)
+	s.mu.Unlock()
+}
+
+// Collect polls every registered collector now, forwarding their metrics
+func (s *CollectorSink) Collect() {
+	s.mu.Lock()
+	collectors := make([]Collector, len(s.collectors))
+	copy(collectors, s.collectors)
+	s.mu.Unlock()
+
+	for _, c := range collectors {
+		ch := make(chan Metric)
+		go func(c Collector) {
+			defer close(ch)
+			c.Collect(ch)
+		}(c)
+
+		for m := range ch {
+			s.forward(m)
+		}
+	}
+}
+
+// Shutdown stops polling the collectors
+func (s *CollectorSink) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+		s.wg.Wait()
+	})
+}
+
+// SetGauge sets a value on a gauge
+func (s *CollectorSink) SetGauge(key []string, val float32) {
+	s.inner.SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *CollectorSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.inner.SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *CollectorSink) EmitKey(key []string, val float32) {
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *CollectorSink) IncrCounter(key []string, val float32) {
+	s.inner.IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *CollectorSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.inner.IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *CollectorSink) IncrCounterFloat64(key []string, val float64) {
+	AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *CollectorSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *CollectorSink) AddSample(key []string, val float32) {
+	s.inner.AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *CollectorSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.inner.AddSampleWithLabels(key, val, labels)
+}
+
+// run polls the collectors every interval until the sink is shut down
+func (s *CollectorSink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Collect()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// forward sends a collected metric to the inner sink following its type
+func (s *CollectorSink) forward(m Metric) {
+	switch m.Type {
+	case GaugeMetric:
+		s.inner.SetGaugeWithLabels(m.Key, float32(m.Value), m.Labels)
+	case CounterMetric:
+		AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(m.Key, m.Value, m.Labels)
+	case SampleMetric:
+		s.inner.AddSampleWithLabels(m.Key, float32(m.Value), m.Labels)
+	case KeyMetric:
+		s.inner.EmitKey(m.Key, float32(m.Value))
+	}
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type poolCollector struct {
+	size float64
+}
+
+func (c *poolCollector) Collect(ch chan<- Metric) {
+	l := []Label{{"pool", "main"}}
+	ch <- Metric{Type: GaugeMetric, Key: []string{"pool", "size"}, Value: c.size, Labels: l}
+	ch <- Metric{Type: CounterMetric, Key: []string{"pool", "acquired"}, Value: 2.5, Labels: l}
+	ch <- Metric{Type: SampleMetric, Key: []string{"pool", "wait"}, Value: 3}
+	ch <- Metric{Type: KeyMetric, Key: []string{"pool", "key"}, Value: 4}
+}
+
+type signalCollector chan struct{}
+
+func (c signalCollector) Collect(ch chan<- Metric) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+func TestCollectorSink_Collect(t *testing.T) {
+	m := &MockFloat64Sink{}
+	s, err := NewCollectorSink(m, time.Hour)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.Register(&poolCollector{size: 10})
+	s.Collect()
+
+	expected := [][]string{{"pool", "size"}, {"pool", "acquired"}, {"pool", "wait"}, {"pool", "key"}}
+	if !reflect.DeepEqual(m.keys, expected) {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+	if !reflect.DeepEqual(m.vals, []float32{10, 3, 4}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.vals64, []float64{2.5}) {
+		t.Fatalf("bad float64 vals %v", m.vals64)
+	}
+	if m.labels[0][0].Value != "main" || m.labels[1][0].Value != "main" {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestCollectorSink_Interval(t *testing.T) {
+	s, err := NewCollectorSink(&BlackholeSink{}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	c := make(signalCollector)
+	s.Register(c)
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		t.Fatalf("collector was not polled")
+	}
+}
+
+func TestCollectorSink_Forward(t *testing.T) {
+	m := &MockSink{}
+	s, err := NewCollectorSink(m, time.Hour)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	emitAll(s)
+	if len(m.keys) != 7 {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+}
+
+func TestNewCollectorSink_BadInterval(t *testing.T) {
+	if _, err := NewCollectorSink(&BlackholeSink{}, 0); err == nil {
+		t.Fatalf("expected interval error")
+	}
+}
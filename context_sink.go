@@ -0,0 +1,120 @@
+package metrics
+
+import "context"
+
+// ContextSinker is implemented by sinks which record metrics on a value
+// carried by the context of the call, ex: the active tracing segment
+type ContextSinker interface {
+	SetGaugeWithContext(ctx context.Context, key []string, val float32, labels []Label)
+	EmitKeyWithContext(ctx context.Context, key []string, val float32)
+	IncrCounterWithContext(ctx context.Context, key []string, val float64, labels []Label)
+	AddSampleWithContext(ctx context.Context, key []string, val float32, labels []Label)
+}
+
+// ContextSink binds a context to a ContextSinker, so the metrics sent
+// to it are recorded with that context, ex: for the duration of a request
+type ContextSink struct {
+	inner ContextSinker
+	ctx   context.Context
+}
+
+// NewContextSink creates a ContextSink
+func NewContextSink(inner ContextSinker, ctx context.Context) *ContextSink {
+	return &ContextSink{inner: inner, ctx: ctx}
+}
+
+// WithContext binds a context to any sink: sinks implementing ContextSinker
+// receive it, ex: the statsd sink, which gives up waiting for room in its
+// queue once it is done, see AsContextSinker for the other sinks
+func WithContext(ctx context.Context, s Sinker) *ContextSink {
+	return NewContextSink(AsContextSinker(s), ctx)
+}
+
+// AsContextSinker returns the sink itself if it implements ContextSinker,
+// or an adapter otherwise. The adapter drops the metrics sent with a
+// context already done, the sink not being able to observe it.
+func AsContextSinker(s Sinker) ContextSinker {
+	if cs, ok := s.(ContextSinker); ok {
+		return cs
+	}
+	return &contextAdapter{s}
+}
+
+// Context returns the context bound to the sink
+func (s *ContextSink) Context() context.Context {
+	return s.ctx
+}
+
+// SetGauge sets a value on a gauge
+func (s *ContextSink) SetGauge(key []string, val float32) {
+	s.inner.SetGaugeWithContext(s.ctx, key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *ContextSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.inner.SetGaugeWithContext(s.ctx, key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *ContextSink) EmitKey(key []string, val float32) {
+	s.inner.EmitKeyWithContext(s.ctx, key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *ContextSink) IncrCounter(key []string, val float32) {
+	s.inner.IncrCounterWithContext(s.ctx, key, float64(val), nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *ContextSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.inner.IncrCounterWithContext(s.ctx, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *ContextSink) IncrCounterFloat64(key []string, val float64) {
+	s.inner.IncrCounterWithContext(s.ctx, key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *ContextSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	s.inner.IncrCounterWithContext(s.ctx, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *ContextSink) AddSample(key []string, val float32) {
+	s.inner.AddSampleWithContext(s.ctx, key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *ContextSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.inner.AddSampleWithContext(s.ctx, key, val, labels)
+}
+
+// contextAdapter implements ContextSinker for sinks unaware of contexts
+type contextAdapter struct {
+	inner Sinker
+}
+
+func (a *contextAdapter) SetGaugeWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	if ctx.Err() == nil {
+		a.inner.SetGaugeWithLabels(key, val, labels)
+	}
+}
+
+func (a *contextAdapter) EmitKeyWithContext(ctx context.Context, key []string, val float32) {
+	if ctx.Err() == nil {
+		a.inner.EmitKey(key, val)
+	}
+}
+
+func (a *contextAdapter) IncrCounterWithContext(ctx context.Context, key []string, val float64, labels []Label) {
+	if ctx.Err() == nil {
+		AsFloat64Sink(a.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+	}
+}
+
+func (a *contextAdapter) AddSampleWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	if ctx.Err() == nil {
+		a.inner.AddSampleWithLabels(key, val, labels)
+	}
+}
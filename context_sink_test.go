@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+)
+
+type ctxKey struct{}
+
+// MockContextSink records the value of ctxKey in the context of each call
+type MockContextSink struct {
+	MockSink
+	ctxVals []interface{}
+}
+
+func (m *MockContextSink) SetGaugeWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	m.ctxVals = append(m.ctxVals, ctx.Value(ctxKey{}))
+	m.SetGaugeWithLabels(key, val, labels)
+}
+func (m *MockContextSink) EmitKeyWithContext(ctx context.Context, key []string, val float32) {
+	m.ctxVals = append(m.ctxVals, ctx.Value(ctxKey{}))
+	m.EmitKey(key, val)
+}
+func (m *MockContextSink) IncrCounterWithContext(ctx context.Context, key []string, val float64, labels []Label) {
+	m.ctxVals = append(m.ctxVals, ctx.Value(ctxKey{}))
+	m.IncrCounterWithLabels(key, float32(val), labels)
+}
+func (m *MockContextSink) AddSampleWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	m.ctxVals = append(m.ctxVals, ctx.Value(ctxKey{}))
+	m.AddSampleWithLabels(key, val, labels)
+}
+
+func TestContextSink(t *testing.T) {
+	inner := &MockContextSink{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req")
+	s := NewContextSink(inner, ctx)
+	if s.Context() != ctx {
+		t.Fatalf("bad context")
+	}
+
+	emitAll(s)
+	s.IncrCounterFloat64([]string{"counter"}, 8)
+
+	if len(inner.vals) != 8 || inner.vals[6] != 7 || inner.vals[7] != 8 {
+		t.Fatalf("bad vals: %v", inner.vals)
+	}
+	if inner.labels[1][0].Value != "b" || inner.labels[0] != nil {
+		t.Fatalf("bad labels: %v", inner.labels)
+	}
+	for _, v := range inner.ctxVals {
+		if v != "req" {
+			t.Fatalf("bad context vals: %v", inner.ctxVals)
+		}
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	// Context sinks receive the context
+	inner := &MockContextSink{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req")
+	emitAll(WithContext(ctx, inner))
+	if len(inner.ctxVals) != 7 || inner.ctxVals[0] != "req" {
+		t.Fatalf("bad context vals: %v", inner.ctxVals)
+	}
+
+	// Other sinks are adapted
+	m := &MockSink{}
+	emitAll(WithContext(ctx, m))
+	if len(m.vals) != 7 || m.vals[6] != 7 || m.labels[1][0].Value != "b" {
+		t.Fatalf("bad vals: %v %v", m.vals, m.labels)
+	}
+
+	// Metrics sent with a done context are dropped
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	emitAll(WithContext(done, m))
+	if len(m.vals) != 7 {
+		t.Fatalf("bad vals: %v", m.vals)
+	}
+}
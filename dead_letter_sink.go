@@ -0,0 +1,104 @@
+package metrics
+
+// deadLetterKey is the counter incremented on the fallback sink
+// for every metric the primary sink failed to deliver
+var deadLetterKey = []string{"dead_letter_count"}
+
+// DeadLetterSink routes metrics to a primary sink, forwarding the metrics
+// it fails to deliver to a fallback sink instead of dropping them.
+// Failures can only be detected when the primary implements FallibleSinker,
+// otherwise every metric goes to the primary.
+type DeadLetterSink struct {
+	primary  Sinker
+	fallible FallibleSinker
+	fallback Sinker
+}
+
+// NewDeadLetterSink creates a DeadLetterSink
+func NewDeadLetterSink(primary, fallback Sinker) *DeadLetterSink {
+	s := &DeadLetterSink{
+		primary:  primary,
+		fallback: fallback,
+	}
+	s.fallible, _ = primary.(FallibleSinker)
+	return s
+}
+
+// SetGauge sets a value on a gauge
+func (s *DeadLetterSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *DeadLetterSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	if s.fallible == nil {
+		s.primary.SetGaugeWithLabels(key, val, labels)
+		return
+	}
+	if err := s.fallible.TrySetGaugeWithLabels(key, val, labels); err != nil {
+		s.fallback.SetGaugeWithLabels(key, val, labels)
+		s.deadLetter()
+	}
+}
+
+// EmitKey emits a key value metric
+func (s *DeadLetterSink) EmitKey(key []string, val float32) {
+	if s.fallible == nil {
+		s.primary.EmitKey(key, val)
+		return
+	}
+	if err := s.fallible.TryEmitKey(key, val); err != nil {
+		s.fallback.EmitKey(key, val)
+		s.deadLetter()
+	}
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *DeadLetterSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *DeadLetterSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	if s.fallible == nil {
+		s.primary.IncrCounterWithLabels(key, val, labels)
+		return
+	}
+	if err := s.fallible.TryIncrCounterWithLabels(key, val, labels); err != nil {
+		s.fallback.IncrCounterWithLabels(key, val, labels)
+		s.deadLetter()
+	}
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *DeadLetterSink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value
+// with labels. FallibleSinker does not support float64 values, so these
+// counters always go to the primary.
+func (s *DeadLetterSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	AsFloat64Sink(s.primary).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *DeadLetterSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *DeadLetterSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	if s.fallible == nil {
+		s.primary.AddSampleWithLabels(key, val, labels)
+		return
+	}
+	if err := s.fallible.TryAddSampleWithLabels(key, val, labels); err != nil {
+		s.fallback.AddSampleWithLabels(key, val, labels)
+		s.deadLetter()
+	}
+}
+
+func (s *DeadLetterSink) deadLetter() {
+	s.fallback.IncrCounter(deadLetterKey, 1)
+}
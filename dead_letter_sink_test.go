@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+type MockFallibleSink struct {
+	MockSink
+	err error
+}
+
+func (m *MockFallibleSink) TrySetGaugeWithLabels(key []string, val float32, labels []Label) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.SetGaugeWithLabels(key, val, labels)
+	return nil
+}
+func (m *MockFallibleSink) TryEmitKey(key []string, val float32) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.EmitKey(key, val)
+	return nil
+}
+func (m *MockFallibleSink) TryIncrCounterWithLabels(key []string, val float32, labels []Label) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.IncrCounterWithLabels(key, val, labels)
+	return nil
+}
+func (m *MockFallibleSink) TryAddSampleWithLabels(key []string, val float32, labels []Label) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.AddSampleWithLabels(key, val, labels)
+	return nil
+}
+
+func emitAll(s Sinker) {
+	l := []Label{{"a", "b"}}
+	s.SetGauge([]string{"gauge"}, 1)
+	s.SetGaugeWithLabels([]string{"gauge"}, 2, l)
+	s.EmitKey([]string{"key"}, 3)
+	s.IncrCounter([]string{"counter"}, 4)
+	s.IncrCounterWithLabels([]string{"counter"}, 5, l)
+	s.AddSample([]string{"sample"}, 6)
+	s.AddSampleWithLabels([]string{"sample"}, 7, l)
+}
+
+func TestDeadLetterSink_Primary(t *testing.T) {
+	primary := &MockFallibleSink{}
+	fallback := &MockSink{}
+	emitAll(NewDeadLetterSink(primary, fallback))
+
+	if !reflect.DeepEqual(primary.vals, []float32{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("bad primary vals %v", primary.vals)
+	}
+	if len(fallback.keys) != 0 {
+		t.Fatalf("fallback must not be used %v", fallback.keys)
+	}
+}
+
+func TestDeadLetterSink_Fallback(t *testing.T) {
+	primary := &MockFallibleSink{err: ErrQueueFull}
+	fallback := &MockSink{}
+	emitAll(NewDeadLetterSink(primary, fallback))
+
+	if len(primary.keys) != 0 {
+		t.Fatalf("primary must have dropped %v", primary.keys)
+	}
+
+	// Every metric is followed by the dead letter counter
+	if len(fallback.keys) != 14 {
+		t.Fatalf("bad fallback keys %v", fallback.keys)
+	}
+	for i := 0; i < len(fallback.keys); i += 2 {
+		if fallback.vals[i] != float32(i/2+1) {
+			t.Fatalf("bad fallback val %v", fallback.vals[i])
+		}
+		if !reflect.DeepEqual(fallback.keys[i+1], []string{"dead_letter_count"}) || fallback.vals[i+1] != 1 {
+			t.Fatalf("bad dead letter counter %v %v", fallback.keys[i+1], fallback.vals[i+1])
+		}
+	}
+	if !reflect.DeepEqual(fallback.labels[2], []Label{{"a", "b"}}) {
+		t.Fatalf("labels must be forwarded %v", fallback.labels[2])
+	}
+}
+
+func TestDeadLetterSink_NotFallible(t *testing.T) {
+	primary := &MockSink{}
+	fallback := &MockSink{}
+	emitAll(NewDeadLetterSink(primary, fallback))
+
+	if len(primary.keys) != 7 || len(fallback.keys) != 0 {
+		t.Fatalf("bad routing %v %v", primary.keys, fallback.keys)
+	}
+}
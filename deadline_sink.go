@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// DeadlineSink bounds the time each call to an inner ContextSinker may
+// take, by passing it a context with a deadline derived from the context
+// of the call. Unlike a write deadline on the connection, the deadline
+// also covers the time the inner sink takes to serialize the metric.
+type DeadlineSink struct {
+	inner   ContextSinker
+	timeout time.Duration
+}
+
+// NewSinkWithDeadline creates a DeadlineSink giving each call the given
+// timeout, a timeout lower than or equal to 0 means no deadline
+func NewSinkWithDeadline(inner ContextSinker, timeout time.Duration) *DeadlineSink {
+	return &DeadlineSink{inner: inner, timeout: timeout}
+}
+
+// SetGaugeWithContext sets a value on a gauge with labels
+func (s *DeadlineSink) SetGaugeWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	ctx, cancel := s.withDeadline(ctx)
+	defer cancel()
+	s.inner.SetGaugeWithContext(ctx, key, val, labels)
+}
+
+// EmitKeyWithContext emits a key value metric
+func (s *DeadlineSink) EmitKeyWithContext(ctx context.Context, key []string, val float32) {
+	ctx, cancel := s.withDeadline(ctx)
+	defer cancel()
+	s.inner.EmitKeyWithContext(ctx, key, val)
+}
+
+// IncrCounterWithContext increases the value of a counter by a given value with labels
+func (s *DeadlineSink) IncrCounterWithContext(ctx context.Context, key []string, val float64, labels []Label) {
+	ctx, cancel := s.withDeadline(ctx)
+	defer cancel()
+	s.inner.IncrCounterWithContext(ctx, key, val, labels)
+}
+
+// AddSampleWithContext adds a sample metrics with labels
+func (s *DeadlineSink) AddSampleWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	ctx, cancel := s.withDeadline(ctx)
+	defer cancel()
+	s.inner.AddSampleWithContext(ctx, key, val, labels)
+}
+
+// withDeadline derives the context of a call to the inner sink, the
+// deadline of the parent is kept if it is earlier
+func (s *DeadlineSink) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// MockDeadlineSink records the deadline of the context of each call
+type MockDeadlineSink struct {
+	MockContextSink
+	deadlines []time.Time
+	ctxs      []context.Context
+}
+
+func (m *MockDeadlineSink) record(ctx context.Context) {
+	deadline, _ := ctx.Deadline()
+	m.deadlines = append(m.deadlines, deadline)
+	m.ctxs = append(m.ctxs, ctx)
+}
+func (m *MockDeadlineSink) SetGaugeWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	m.record(ctx)
+	m.MockContextSink.SetGaugeWithContext(ctx, key, val, labels)
+}
+func (m *MockDeadlineSink) EmitKeyWithContext(ctx context.Context, key []string, val float32) {
+	m.record(ctx)
+	m.MockContextSink.EmitKeyWithContext(ctx, key, val)
+}
+func (m *MockDeadlineSink) IncrCounterWithContext(ctx context.Context, key []string, val float64, labels []Label) {
+	m.record(ctx)
+	m.MockContextSink.IncrCounterWithContext(ctx, key, val, labels)
+}
+func (m *MockDeadlineSink) AddSampleWithContext(ctx context.Context, key []string, val float32, labels []Label) {
+	m.record(ctx)
+	m.MockContextSink.AddSampleWithContext(ctx, key, val, labels)
+}
+
+func TestDeadlineSink(t *testing.T) {
+	inner := &MockDeadlineSink{}
+	s := NewSinkWithDeadline(inner, time.Minute)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req")
+
+	start := time.Now()
+	emitAll(NewContextSink(s, ctx))
+
+	if len(inner.vals) != 7 || inner.vals[6] != 7 {
+		t.Fatalf("bad vals: %v", inner.vals)
+	}
+	for i, deadline := range inner.deadlines {
+		if deadline.Before(start.Add(time.Minute)) || deadline.After(time.Now().Add(time.Minute)) {
+			t.Fatalf("bad deadline: %v", deadline)
+		}
+		// The context is canceled once the call returns
+		if inner.ctxs[i].Err() != context.Canceled {
+			t.Fatalf("bad context err: %v", inner.ctxs[i].Err())
+		}
+		if inner.ctxVals[i] != "req" {
+			t.Fatalf("bad context vals: %v", inner.ctxVals)
+		}
+	}
+
+	// The earlier deadline of the parent is kept
+	parent, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	want, _ := parent.Deadline()
+	s.IncrCounterWithContext(parent, []string{"counter"}, 1, nil)
+	if got := inner.deadlines[7]; !got.Equal(want) {
+		t.Fatalf("bad deadline: %v, expected %v", got, want)
+	}
+}
+
+func TestDeadlineSink_NoTimeout(t *testing.T) {
+	inner := &MockDeadlineSink{}
+	s := NewSinkWithDeadline(inner, 0)
+
+	s.AddSampleWithContext(context.Background(), []string{"sample"}, 1, nil)
+	if !inner.deadlines[0].IsZero() || inner.ctxs[0] != context.Background() {
+		t.Fatalf("unexpected deadline: %v", inner.deadlines[0])
+	}
+}
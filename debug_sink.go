@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DebugSink forwards every call to the inner sink, first writing it
+// along with the file and line of its caller, one call per line:
+//
+//	handler.go:42 IncrCounterWithLabels(http.requests, 1, [code=200])
+//
+// It is meant to find out why a metric does not reach the backend.
+type DebugSink struct {
+	inner Sinker
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewDebugSink creates a DebugSink writing the calls to w, ex: os.Stderr
+func NewDebugSink(inner Sinker, w io.Writer) *DebugSink {
+	return &DebugSink{inner: inner, w: w}
+}
+
+// SetGauge sets a value on a gauge
+func (s *DebugSink) SetGauge(key []string, val float32) {
+	s.trace("SetGauge", key, float64(val), nil)
+	s.inner.SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *DebugSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.trace("SetGaugeWithLabels", key, float64(val), labels)
+	s.inner.SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *DebugSink) EmitKey(key []string, val float32) {
+	s.trace("EmitKey", key, float64(val), nil)
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *DebugSink) IncrCounter(key []string, val float32) {
+	s.trace("IncrCounter", key, float64(val), nil)
+	s.inner.IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *DebugSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.trace("IncrCounterWithLabels", key, float64(val), labels)
+	s.inner.IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *DebugSink) IncrCounterFloat64(key []string, val float64) {
+	s.trace("IncrCounterFloat64", key, val, nil)
+	AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *DebugSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	s.trace("IncrCounterWithLabelsFloat64", key, val, labels)
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *DebugSink) AddSample(key []string, val float32) {
+	s.trace("AddSample", key, float64(val), nil)
+	s.inner.AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *DebugSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.trace("AddSampleWithLabels", key, float64(val), labels)
+	s.inner.AddSampleWithLabels(key, val, labels)
+}
+
+// trace writes a call, it must be called by the sink methods only: the
+// caller is found by skipping runtime.Callers, trace and the sink method
+func (s *DebugSink) trace(method string, key []string, val float64, labels []Label) {
+	location := "unknown"
+	pc := make([]uintptr, 1)
+	if runtime.Callers(3, pc) > 0 {
+		frame, _ := runtime.CallersFrames(pc).Next()
+		location = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+	}
+
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label.Name + "=" + label.Value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "%s %s(%s, %v, [%s])\n", location, method, strings.Join(key, "."), val, strings.Join(pairs, " "))
+}
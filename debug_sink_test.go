@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDebugSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	inner := &MockSink{}
+	s := NewDebugSink(inner, buf)
+
+	_, _, line, _ := runtime.Caller(0)
+	s.IncrCounterWithLabels([]string{"http", "requests"}, 1, []Label{{"code", "200"}, {"method", "GET"}})
+	s.AddSample([]string{"latency"}, 0.5)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	expected := []string{
+		fmt.Sprintf("debug_sink_test.go:%d IncrCounterWithLabels(http.requests, 1, [code=200 method=GET])", line+1),
+		fmt.Sprintf("debug_sink_test.go:%d AddSample(latency, 0.5, [])", line+2),
+	}
+	if len(lines) != len(expected) || lines[0] != expected[0] || lines[1] != expected[1] {
+		t.Fatalf("bad output %q, expected %q", lines, expected)
+	}
+
+	// Every call is forwarded
+	buf.Reset()
+	emitAll(s)
+	s.IncrCounterFloat64([]string{"counter"}, 8)
+	if len(inner.vals) != 10 || inner.vals[9] != 8 {
+		t.Fatalf("bad vals: %v", inner.vals)
+	}
+	if n := strings.Count(buf.String(), "dead_letter_sink_test.go:"); n != 7 {
+		t.Fatalf("bad output %s", buf.String())
+	}
+}
@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DecoratedSink decorates the metrics sent to an inner sink following
+// the given SinkOptions, which allows to add behaviour to any sink.
+// Calls without labels are promoted to their *WithLabels variant,
+// so the injected labels are applied to every metric but key/value
+// pairs, which do not support labels.
+type DecoratedSink struct {
+	inner     Sinker
+	labels    []Label
+	trim      bool
+	maxLabels int
+
+	warned sync.Map
+}
+
+// SinkOption is used to configure a DecoratedSink
+type SinkOption func(*DecoratedSink)
+
+// NewDecoratedSink creates a DecoratedSink
+func NewDecoratedSink(inner Sinker, opts ...SinkOption) *DecoratedSink {
+	s := &DecoratedSink{inner: inner}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.trim {
+		s.labels = trimLabelValues(s.labels)
+	}
+	return s
+}
+
+// WithHostname injects a "host" label holding the hostname
+func WithHostname() SinkOption {
+	return WithHostnameLabel("host")
+}
+
+// WithHostnameLabel injects a label with the given name holding the
+// hostname. The hostname is read once, when creating the sink.
+func WithHostnameLabel(labelName string) SinkOption {
+	return func(s *DecoratedSink) {
+		name, err := os.Hostname()
+		if err != nil || name == "" {
+			return
+		}
+		s.addLabel(labelName, name)
+	}
+}
+
+// WithServiceName injects a "service" label holding the service name
+func WithServiceName(serviceName string) SinkOption {
+	return func(s *DecoratedSink) {
+		s.addLabel("service", serviceName)
+	}
+}
+
+// WithVersion injects a "version" label holding the given version
+func WithVersion(version string) SinkOption {
+	return func(s *DecoratedSink) {
+		s.addLabel("version", version)
+	}
+}
+
+// WithVersionFromBuildInfo injects a "version" label holding the main
+// module version, as embedded in the binary build info. No label is
+// injected when the build info is not available.
+func WithVersionFromBuildInfo() SinkOption {
+	return func(s *DecoratedSink) {
+		info, ok := debug.ReadBuildInfo()
+		if !ok || info.Main.Version == "" {
+			return
+		}
+		s.addLabel("version", info.Main.Version)
+	}
+}
+
+// WithK8sMetadata injects the "k8s_pod", "k8s_namespace" and "k8s_node"
+// labels, read from the MY_POD_NAME, MY_NAMESPACE and MY_NODE_NAME
+// environment variables, which are usually set using the Kubernetes
+// downward API. Labels are not injected for unset variables.
+func WithK8sMetadata() SinkOption {
+	return func(s *DecoratedSink) {
+		for _, m := range k8sMetadata {
+			if value := os.Getenv(m.env); value != "" {
+				s.addLabel(m.label, value)
+			}
+		}
+	}
+}
+
+// WithTrimLabelValues removes the leading and trailing whitespace of
+// every label value, so " production" and "production" are the same series
+func WithTrimLabelValues() SinkOption {
+	return func(s *DecoratedSink) {
+		s.trim = true
+	}
+}
+
+// WithMaxLabelCount truncates the labels of the calls over n labels,
+// injected ones included, as backends such as Prometheus degrade with
+// many labels. The labels kept are the first n sorted by name. Each
+// truncation increments the "go_metrics.labels_truncated_total" counter
+// of the inner sink, and is logged once per key and n. Labels are not
+// limited when n is not positive.
+func WithMaxLabelCount(n int) SinkOption {
+	return func(s *DecoratedSink) {
+		s.maxLabels = n
+	}
+}
+
+// WithGlobalPrefix prepends the dot-separated prefix to every key,
+// ex: "myapp.production" turns "requests" into "myapp.production.requests".
+// The inner sink is wrapped in a PrefixSink.
+func WithGlobalPrefix(prefix string) SinkOption {
+	return func(s *DecoratedSink) {
+		if len(splitPrefix(prefix)) > 0 {
+			s.inner = NewPrefixSink(s.inner, prefix)
+		}
+	}
+}
+
+// labelsTruncatedKey is the counter incremented on each truncation
+var labelsTruncatedKey = []string{"go_metrics", "labels_truncated_total"}
+
+// k8sMetadata maps the downward API environment variables to labels
+var k8sMetadata = []struct {
+	env   string
+	label string
+}{
+	{"MY_POD_NAME", "k8s_pod"},
+	{"MY_NAMESPACE", "k8s_namespace"},
+	{"MY_NODE_NAME", "k8s_node"},
+}
+
+// SetGauge sets a value on a gauge
+func (s *DecoratedSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *DecoratedSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.inner.SetGaugeWithLabels(key, val, s.decorateLabels(key, labels))
+}
+
+// EmitKey emits a key value metric
+func (s *DecoratedSink) EmitKey(key []string, val float32) {
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *DecoratedSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *DecoratedSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.inner.IncrCounterWithLabels(key, val, s.decorateLabels(key, labels))
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *DecoratedSink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *DecoratedSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, s.decorateLabels(key, labels))
+}
+
+// AddSample adds a sample metrics
+func (s *DecoratedSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *DecoratedSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.inner.AddSampleWithLabels(key, val, s.decorateLabels(key, labels))
+}
+
+// addLabel adds a label to be injected, replacing any previous label
+// with the same name
+func (s *DecoratedSink) addLabel(name, value string) {
+	for i, label := range s.labels {
+		if label.Name == name {
+			s.labels[i].Value = value
+			return
+		}
+	}
+	s.labels = append(s.labels, Label{Name: name, Value: value})
+}
+
+// decorateLabels merges the call labels into the injected labels with
+// MergeLabels, as LabelSink does, so labels provided on the call take
+// precedence over the injected ones, then limits their count
+func (s *DecoratedSink) decorateLabels(key []string, labels []Label) []Label {
+	if s.trim {
+		labels = trimLabelValues(labels)
+	}
+	if len(s.labels) > 0 {
+		labels = MergeLabels(s.labels, labels)
+	}
+	if s.maxLabels > 0 && len(labels) > s.maxLabels {
+		return s.limitLabels(key, labels)
+	}
+	return labels
+}
+
+// truncationWarning identifies a truncation warning, logged once per
+// key and maximum label count
+type truncationWarning struct {
+	key       string
+	maxLabels int
+}
+
+// limitLabels returns a copy of the labels holding the first ones sorted
+// by name, counting and logging the truncation
+func (s *DecoratedSink) limitLabels(key []string, labels []Label) []Label {
+	sorted := make([]Label, len(labels))
+	copy(sorted, labels)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	s.inner.IncrCounter(labelsTruncatedKey, 1)
+	name := strings.Join(key, ".")
+	warning := truncationWarning{key: name, maxLabels: s.maxLabels}
+	if _, warned := s.warned.LoadOrStore(warning, struct{}{}); !warned {
+		log.Printf("[WARN] Truncating the %d labels of %s to %d", len(labels), name, s.maxLabels)
+	}
+	return sorted[:s.maxLabels]
+}
+
+// trimLabelValues returns the labels with their values trimmed,
+// copying them only when a value has to be changed
+func trimLabelValues(labels []Label) []Label {
+	for i, label := range labels {
+		if trimmed := strings.TrimSpace(label.Value); trimmed != label.Value {
+			copied := make([]Label, len(labels))
+			copy(copied, labels)
+			for j := i; j < len(copied); j++ {
+				copied[j].Value = strings.TrimSpace(copied[j].Value)
+			}
+			return copied
+		}
+	}
+	return labels
+}
@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoratedSink_NoOptions(t *testing.T) {
+	m := &MockSink{}
+	s := NewDecoratedSink(m)
+
+	l := []Label{{"a", "b"}}
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, l)
+	s.IncrCounter([]string{"counter"}, 2)
+
+	if !reflect.DeepEqual(m.labels[0], l) || m.labels[1] != nil {
+		t.Fatalf("labels must be forwarded as they are %v", m.labels)
+	}
+}
+
+func TestDecoratedSink_WithHostname(t *testing.T) {
+	hostname, _ := os.Hostname()
+	host := Label{"host", hostname}
+
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithHostname())
+	emitAll(s)
+
+	expected := [][]Label{
+		{host},
+		{host, {"a", "b"}},
+		nil,
+		{host},
+		{host, {"a", "b"}},
+		{host},
+		{host, {"a", "b"}},
+	}
+	if !reflect.DeepEqual(m.labels, expected) {
+		t.Fatalf("expected %v got %v", expected, m.labels)
+	}
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+}
+
+func TestDecoratedSink_WithHostnameLabel(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithHostnameLabel("node"))
+	s.AddSample([]string{"sample"}, 1)
+	s.AddSampleWithLabels([]string{"sample"}, 1, []Label{{"node", "override"}})
+
+	if !reflect.DeepEqual(m.labels[0], []Label{{"node", hostname}}) {
+		t.Fatalf("bad labels %v", m.labels[0])
+	}
+	if !reflect.DeepEqual(m.labels[1], []Label{{"node", "override"}}) {
+		t.Fatalf("call labels must take precedence %v", m.labels[1])
+	}
+}
+
+func TestDecoratedSink_WithServiceName(t *testing.T) {
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithServiceName("api"))
+	s.SetGauge([]string{"gauge"}, 1)
+	s.IncrCounterWithLabels([]string{"counter"}, 1, []Label{{"a", "b"}})
+
+	if !reflect.DeepEqual(m.labels[0], []Label{{"service", "api"}}) {
+		t.Fatalf("bad labels %v", m.labels[0])
+	}
+	if !reflect.DeepEqual(m.labels[1], []Label{{"service", "api"}, {"a", "b"}}) {
+		t.Fatalf("bad labels %v", m.labels[1])
+	}
+}
+
+func TestDecoratedSink_WithVersion(t *testing.T) {
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithVersion("v1.0.0"))
+	s.AddSample([]string{"sample"}, 1)
+
+	if !reflect.DeepEqual(m.labels[0], []Label{{"version", "v1.0.0"}}) {
+		t.Fatalf("bad labels %v", m.labels[0])
+	}
+}
+
+func TestDecoratedSink_WithVersionFromBuildInfo(t *testing.T) {
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithVersion("v1.0.0"), WithVersionFromBuildInfo())
+	s.AddSample([]string{"sample"}, 1)
+
+	// Test binaries may not embed the main module version, in which
+	// case the previous version is kept
+	if len(m.labels[0]) != 1 || m.labels[0][0].Name != "version" || m.labels[0][0].Value == "" {
+		t.Fatalf("bad labels %v", m.labels[0])
+	}
+}
+
+func TestDecoratedSink_WithK8sMetadata(t *testing.T) {
+	os.Setenv("MY_POD_NAME", "api-1")
+	os.Setenv("MY_NAMESPACE", "default")
+	os.Unsetenv("MY_NODE_NAME")
+	defer os.Unsetenv("MY_POD_NAME")
+	defer os.Unsetenv("MY_NAMESPACE")
+
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithK8sMetadata())
+	s.IncrCounter([]string{"counter"}, 1)
+
+	expected := []Label{{"k8s_pod", "api-1"}, {"k8s_namespace", "default"}}
+	if !reflect.DeepEqual(m.labels[0], expected) {
+		t.Fatalf("expected %v got %v", expected, m.labels[0])
+	}
+}
+
+func TestDecoratedSink_WithTrimLabelValues(t *testing.T) {
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithServiceName(" api\n"), WithTrimLabelValues())
+
+	l := []Label{{"env", " production "}, {"a", "b"}}
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, l)
+	s.IncrCounterWithLabelsFloat64([]string{"counter"}, 2, []Label{{"a", "b"}})
+
+	expected := [][]Label{
+		{{"service", "api"}, {"env", "production"}, {"a", "b"}},
+		{{"service", "api"}, {"a", "b"}},
+	}
+	if !reflect.DeepEqual(m.labels, expected) {
+		t.Fatalf("expected %v got %v", expected, m.labels)
+	}
+	if l[0].Value != " production " {
+		t.Fatalf("call labels must not be modified")
+	}
+}
+
+func TestTrimLabelValues(t *testing.T) {
+	l := []Label{{"a", "b"}}
+	if trimmed := trimLabelValues(l); &trimmed[0] != &l[0] {
+		t.Fatalf("labels without whitespace must not be copied")
+	}
+	if trimmed := trimLabelValues([]Label{{"a", "b"}, {"c", "\td "}}); !reflect.DeepEqual(trimmed, []Label{{"a", "b"}, {"c", "d"}}) {
+		t.Fatalf("bad labels %v", trimmed)
+	}
+}
+
+func TestDecoratedSink_WithGlobalPrefix(t *testing.T) {
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithGlobalPrefix("myapp.production."))
+	if _, ok := s.inner.(*PrefixSink); !ok {
+		t.Fatalf("expected a PrefixSink, got %T", s.inner)
+	}
+	emitAll(s)
+	s.IncrCounterFloat64([]string{"counter"}, 8)
+
+	for i, key := range m.keys {
+		if len(key) != 3 || key[0] != "myapp" || key[1] != "production" {
+			t.Fatalf("bad key %d %v", i, key)
+		}
+	}
+
+	m = &MockSink{}
+	s = NewDecoratedSink(m, WithGlobalPrefix(""))
+	if s.inner != Sinker(m) {
+		t.Fatalf("expected the inner sink, got %T", s.inner)
+	}
+	s.SetGauge([]string{"gauge"}, 1)
+	if !reflect.DeepEqual(m.keys[0], []string{"gauge"}) {
+		t.Fatalf("bad key %v", m.keys[0])
+	}
+}
+
+func TestDecoratedSink_SameLabelsAsLabelSink(t *testing.T) {
+	fixed := []Label{{"service", "api"}, {"version", "v1"}}
+	call := []Label{{"a", "b"}, {"version", "v2"}, {"a", "c"}}
+
+	decorated, labelled := &MockSink{}, &MockSink{}
+	NewDecoratedSink(decorated, WithServiceName("api"), WithVersion("v1")).IncrCounterWithLabels([]string{"counter"}, 1, call)
+	NewLabelSink(labelled, fixed).IncrCounterWithLabels([]string{"counter"}, 1, call)
+
+	expected := []Label{{"service", "api"}, {"version", "v2"}, {"a", "c"}}
+	if !reflect.DeepEqual(decorated.labels[0], expected) || !reflect.DeepEqual(labelled.labels[0], expected) {
+		t.Fatalf("bad labels %v %v", decorated.labels[0], labelled.labels[0])
+	}
+}
+
+func TestDecoratedSink_WithMaxLabelCount(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithServiceName("api"), WithMaxLabelCount(2))
+	labels := []Label{{"c", "3"}, {"a", "1"}}
+	s.IncrCounterWithLabels([]string{"counter"}, 1, labels)
+	s.AddSampleWithLabels([]string{"counter"}, 2, labels)
+	s.SetGaugeWithLabels([]string{"gauge"}, 3, []Label{{"a", "1"}})
+
+	if len(m.keys) != 5 {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+	for _, i := range []int{0, 2} {
+		if !reflect.DeepEqual(m.keys[i], labelsTruncatedKey) || m.vals[i] != 1 {
+			t.Fatalf("bad truncation counter %d %v", i, m.keys[i])
+		}
+	}
+	for _, i := range []int{1, 3} {
+		if !reflect.DeepEqual(m.labels[i], []Label{{"a", "1"}, {"c", "3"}}) {
+			t.Fatalf("bad labels %d %v", i, m.labels[i])
+		}
+	}
+	if !reflect.DeepEqual(m.labels[4], []Label{{"service", "api"}, {"a", "1"}}) {
+		t.Fatalf("labels under the limit must not be truncated %v", m.labels[4])
+	}
+	if labels[0].Name != "c" {
+		t.Fatalf("labels of the caller modified %v", labels)
+	}
+
+	// Warned once per key and count
+	if n := strings.Count(buf.String(), "Truncating the 3 labels of counter to 2"); n != 1 {
+		t.Fatalf("bad warnings %q", buf.String())
+	}
+}
+
+func TestDecoratedSink_WithMaxLabelCount_NoDefault(t *testing.T) {
+	m := &MockSink{}
+	s := NewDecoratedSink(m, WithMaxLabelCount(0))
+
+	labels := make([]Label, 50)
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, labels)
+	if len(m.keys) != 1 || len(m.labels[0]) != 50 {
+		t.Fatalf("labels must not be limited %v", m.keys)
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import "sync"
+
+// DelayedSink buffers every metric call until Activate provides the sink
+// to replay them to, subsequent calls being forwarded to it directly. It
+// allows emitting metrics during startup, before the sink is configured.
+// The buffer is unbounded, every call is retained until then.
+type DelayedSink struct {
+	mu    sync.RWMutex
+	inner Sinker
+	batch Batch
+}
+
+// NewDelayedSink creates a DelayedSink
+func NewDelayedSink() *DelayedSink {
+	return &DelayedSink{}
+}
+
+// Activate replays the buffered calls to the inner sink, which receives
+// every call from then on. Inner sinks implementing AtomicSinker receive
+// the buffered calls atomically, and the first failure is returned for
+// FallibleSinker inner sinks. The inner sink is kept even when the replay
+// fails, the buffer being emptied regardless.
+func (s *DelayedSink) Activate(inner Sinker) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.batch.Commit(inner)
+	s.inner = inner
+	return err
+}
+
+// DropBuffered discards the buffered calls without replaying them
+func (s *DelayedSink) DropBuffered() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batch.Reset()
+}
+
+// Buffered returns the number of buffered calls
+func (s *DelayedSink) Buffered() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.batch.Len()
+}
+
+// SetGauge sets a value on a gauge
+func (s *DelayedSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *DelayedSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.inner == nil {
+		s.batch.SetGaugeWithLabels(key, val, labels)
+		return
+	}
+	s.inner.SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *DelayedSink) EmitKey(key []string, val float32) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.inner == nil {
+		s.batch.EmitKey(key, val)
+		return
+	}
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *DelayedSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *DelayedSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.inner == nil {
+		s.batch.IncrCounterWithLabels(key, val, labels)
+		return
+	}
+	s.inner.IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *DelayedSink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *DelayedSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.inner == nil {
+		s.batch.IncrCounterWithLabelsFloat64(key, val, labels)
+		return
+	}
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *DelayedSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *DelayedSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.inner == nil {
+		s.batch.AddSampleWithLabels(key, val, labels)
+		return
+	}
+	s.inner.AddSampleWithLabels(key, val, labels)
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDelayedSink_Activate(t *testing.T) {
+	s := NewDelayedSink()
+	emitAll(s)
+	if s.Buffered() != 7 {
+		t.Fatalf("bad buffered %d", s.Buffered())
+	}
+
+	m := &MockSink{}
+	if err := s.Activate(m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if s.Buffered() != 0 {
+		t.Fatalf("bad buffered %d", s.Buffered())
+	}
+
+	// Forwarded directly once activated
+	s.SetGauge([]string{"gauge"}, 8)
+	if len(m.vals) != 8 || m.vals[7] != 8 || s.Buffered() != 0 {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+}
+
+func TestDelayedSink_ReusedSlices(t *testing.T) {
+	s := NewDelayedSink()
+
+	// Startup code rewrites the same slices before the sink is activated
+	key := []string{"startup", ""}
+	labels := []Label{{"phase", ""}}
+	for _, phase := range []string{"config", "listen"} {
+		key[1], labels[0].Value = phase, phase
+		s.SetGaugeWithLabels(key, 1, labels)
+	}
+
+	m := &MockSink{}
+	if err := s.Activate(m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i, phase := range []string{"config", "listen"} {
+		if !reflect.DeepEqual(m.keys[i], []string{"startup", phase}) || !reflect.DeepEqual(m.labels[i], []Label{{"phase", phase}}) {
+			t.Fatalf("bad metric %d %v %v", i, m.keys[i], m.labels[i])
+		}
+	}
+}
+
+func TestDelayedSink_DropBuffered(t *testing.T) {
+	s := NewDelayedSink()
+	emitAll(s)
+	s.DropBuffered()
+
+	m := &MockSink{}
+	if err := s.Activate(m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(m.vals) != 0 {
+		t.Fatalf("dropped calls must not be replayed %v", m.vals)
+	}
+}
+
+func TestDelayedSink_Float64(t *testing.T) {
+	s := NewDelayedSink()
+	s.IncrCounterFloat64([]string{"counter"}, 0.1)
+
+	m := &MockFloat64Sink{}
+	if err := s.Activate(m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.IncrCounterWithLabelsFloat64([]string{"counter"}, 1e-9, []Label{{"a", "b"}})
+	if !reflect.DeepEqual(m.vals64, []float64{0.1, 1e-9}) {
+		t.Fatalf("bad vals %v", m.vals64)
+	}
+}
+
+func TestDelayedSink_ActivateNil(t *testing.T) {
+	s := NewDelayedSink()
+	emitAll(s)
+	if err := s.Activate(nil); err == nil {
+		t.Fatalf("expected nil sink error")
+	}
+}
+
+func TestDelayedSink_ActivateFailure(t *testing.T) {
+	s := NewDelayedSink()
+	emitAll(s)
+
+	m := &MockFallibleSink{err: errors.New("unavailable")}
+	if err := s.Activate(m); err == nil {
+		t.Fatalf("expected replay error")
+	}
+	if n := s.Buffered(); n != 0 {
+		t.Fatalf("bad buffered %d", n)
+	}
+
+	m.err = nil
+	n := len(m.keys)
+	s.SetGauge([]string{"gauge"}, 1)
+	if len(m.keys) != n+1 || s.Buffered() != 0 {
+		t.Fatalf("expected forwarded call, got %v", m.keys)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+// MetricDescriptor is the description of a metric returned by a DescribableSink
+type MetricDescriptor = Descriptor
+
+// DescribableSink is implemented by sinks able to describe the metrics
+// they receive, so tooling can list the metrics of a service along with
+// their types, units and help texts, ex: to generate dashboards
+type DescribableSink interface {
+	Describe(key []string) (MetricDescriptor, bool)
+	AllDescriptors() []MetricDescriptor
+}
+
+// Describe returns the descriptor of the key when the sink implements
+// DescribableSink, otherwise the zero value and false
+func Describe(s Sinker, key []string) (MetricDescriptor, bool) {
+	if ds, ok := s.(DescribableSink); ok {
+		return ds.Describe(key)
+	}
+	return MetricDescriptor{}, false
+}
+
+// AllDescriptors returns every descriptor of the sink when it implements
+// DescribableSink, otherwise nil
+func AllDescriptors(s Sinker) []MetricDescriptor {
+	if ds, ok := s.(DescribableSink); ok {
+		return ds.AllDescriptors()
+	}
+	return nil
+}
+
+// DescribedSink forwards every call to the inner sink, describing the
+// metrics from the descriptors declared on a Registry
+type DescribedSink struct {
+	inner    Sinker
+	registry *Registry
+}
+
+// NewDescribedSink creates a DescribedSink reading the given registry,
+// the DefaultRegistry when nil
+func NewDescribedSink(inner Sinker, registry *Registry) *DescribedSink {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	return &DescribedSink{inner: inner, registry: registry}
+}
+
+// Describe returns the descriptor registered for the key
+func (s *DescribedSink) Describe(key []string) (MetricDescriptor, bool) {
+	return s.registry.Lookup(key)
+}
+
+// AllDescriptors returns all the registered descriptors, sorted by name
+func (s *DescribedSink) AllDescriptors() []MetricDescriptor {
+	return s.registry.Descriptors()
+}
+
+// SetGauge sets a value on a gauge
+func (s *DescribedSink) SetGauge(key []string, val float32) {
+	s.inner.SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *DescribedSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.inner.SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *DescribedSink) EmitKey(key []string, val float32) {
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *DescribedSink) IncrCounter(key []string, val float32) {
+	s.inner.IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *DescribedSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.inner.IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *DescribedSink) IncrCounterFloat64(key []string, val float64) {
+	AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *DescribedSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *DescribedSink) AddSample(key []string, val float32) {
+	s.inner.AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *DescribedSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.inner.AddSampleWithLabels(key, val, labels)
+}
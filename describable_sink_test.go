@@ -0,0 +1,45 @@
+package metrics
+
+import "testing"
+
+func TestDescribedSink(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Descriptor{Name: []string{"counter"}, Type: CounterMetric, Help: "Counts"})
+	r.Register(Descriptor{Name: []string{"gauge"}, Type: GaugeMetric})
+
+	m := &MockSink{}
+	s := NewDescribedSink(m, r)
+	emitAll(s)
+	if len(m.keys) != 7 {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+
+	desc, ok := Describe(s, []string{"counter"})
+	if !ok || desc.Type != CounterMetric || desc.Help != "Counts" {
+		t.Fatalf("bad descriptor %#v", desc)
+	}
+	if _, ok := Describe(s, []string{"missing"}); ok {
+		t.Fatalf("unexpected descriptor")
+	}
+
+	descs := AllDescriptors(s)
+	if len(descs) != 2 || descs[0].Name[0] != "counter" {
+		t.Fatalf("bad descriptors %v", descs)
+	}
+}
+
+func TestDescribe_NotDescribable(t *testing.T) {
+	desc, ok := Describe(&MockSink{}, []string{"counter"})
+	if ok || desc.Name != nil {
+		t.Fatalf("bad descriptor %#v", desc)
+	}
+	if AllDescriptors(&MockSink{}) != nil {
+		t.Fatalf("expected no descriptors")
+	}
+}
+
+func TestNewDescribedSink_DefaultRegistry(t *testing.T) {
+	if NewDescribedSink(&MockSink{}, nil).registry != DefaultRegistry {
+		t.Fatalf("should use the default registry")
+	}
+}
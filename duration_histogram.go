@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultDurationBuckets are the histogram buckets used by DurationHistogram,
+// optimised for HTTP request latencies
+var DefaultDurationBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// DurationHistogram observes durations into cumulative buckets.
+// Each observation increments the "bucket" counter of every bucket
+// the duration fits in (labeled with "le", its upper bound in seconds),
+// the "sum" counter with the duration in seconds and the "count" counter.
+type DurationHistogram struct {
+	sink   Sinker
+	key    []string
+	labels []Label
+
+	buckets []time.Duration
+	bounds  []string
+}
+
+// HistogramOption is used to configure a DurationHistogram
+type HistogramOption func(*DurationHistogram)
+
+// WithBuckets overrides the default buckets of a DurationHistogram
+func WithBuckets(buckets []time.Duration) HistogramOption {
+	return func(h *DurationHistogram) {
+		h.buckets = buckets
+	}
+}
+
+// NewDurationHistogram creates a DurationHistogram emitting to the given
+// sink. The key and labels are copied, callers may reuse their slices.
+func NewDurationHistogram(sink Sinker, key []string, labels []Label, opts ...HistogramOption) *DurationHistogram {
+	h := &DurationHistogram{
+		sink:    sink,
+		key:     append([]string(nil), key...),
+		labels:  append([]Label(nil), labels...),
+		buckets: DefaultDurationBuckets,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	// Keep our own sorted copy, callers may reuse their slice
+	buckets := make([]time.Duration, len(h.buckets))
+	copy(buckets, h.buckets)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	h.buckets = buckets
+
+	h.bounds = make([]string, len(buckets))
+	for i, b := range buckets {
+		h.bounds[i] = formatSeconds(b)
+	}
+	return h
+}
+
+// Buckets returns the upper bounds of the histogram buckets
+func (h *DurationHistogram) Buckets() []time.Duration {
+	buckets := make([]time.Duration, len(h.buckets))
+	copy(buckets, h.buckets)
+	return buckets
+}
+
+// Observe records a duration on the histogram
+func (h *DurationHistogram) Observe(d time.Duration) {
+	bucketKey := h.subKey("bucket")
+
+	for i, b := range h.buckets {
+		if d <= b {
+			h.sink.IncrCounterWithLabels(bucketKey, 1, h.bucketLabels(h.bounds[i]))
+		}
+	}
+	h.sink.IncrCounterWithLabels(bucketKey, 1, h.bucketLabels("+Inf"))
+
+	h.sink.IncrCounterWithLabels(h.subKey("sum"), float32(d.Seconds()), h.labels)
+	h.sink.IncrCounterWithLabels(h.subKey("count"), 1, h.labels)
+}
+
+// ObserveSince records the duration elapsed since start
+func (h *DurationHistogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+func (h *DurationHistogram) subKey(name string) []string {
+	key := make([]string, len(h.key), len(h.key)+1)
+	copy(key, h.key)
+	return append(key, name)
+}
+
+func (h *DurationHistogram) bucketLabels(bound string) []Label {
+	labels := make([]Label, len(h.labels), len(h.labels)+1)
+	copy(labels, h.labels)
+	return append(labels, Label{Name: "le", Value: bound})
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
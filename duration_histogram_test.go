@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDurationHistogram_DefaultBuckets(t *testing.T) {
+	m := &MockSink{}
+	h := NewDurationHistogram(m, []string{"http", "latency"}, nil)
+
+	if !reflect.DeepEqual(h.Buckets(), DefaultDurationBuckets) {
+		t.Fatalf("bad buckets %v", h.Buckets())
+	}
+
+	h.Observe(3 * time.Second)
+
+	// 5s and 10s buckets, +Inf, sum and count
+	if len(m.keys) != 5 {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+
+	expectedBounds := []string{"5", "10", "+Inf"}
+	for i, bound := range expectedBounds {
+		if !reflect.DeepEqual(m.keys[i], []string{"http", "latency", "bucket"}) {
+			t.Fatalf("bad key %v", m.keys[i])
+		}
+		if !reflect.DeepEqual(m.labels[i], []Label{{"le", bound}}) {
+			t.Fatalf("bad labels %v", m.labels[i])
+		}
+		if m.vals[i] != 1 {
+			t.Fatalf("bad val %v", m.vals[i])
+		}
+	}
+
+	if !reflect.DeepEqual(m.keys[3], []string{"http", "latency", "sum"}) || m.vals[3] != 3 {
+		t.Fatalf("bad sum %v %v", m.keys[3], m.vals[3])
+	}
+	if !reflect.DeepEqual(m.keys[4], []string{"http", "latency", "count"}) || m.vals[4] != 1 {
+		t.Fatalf("bad count %v %v", m.keys[4], m.vals[4])
+	}
+}
+
+func TestDurationHistogram_WithBuckets(t *testing.T) {
+	m := &MockSink{}
+	labels := []Label{{"route", "/"}}
+	buckets := []time.Duration{time.Second, 100 * time.Millisecond}
+	h := NewDurationHistogram(m, []string{"latency"}, labels, WithBuckets(buckets))
+
+	if !reflect.DeepEqual(h.Buckets(), []time.Duration{100 * time.Millisecond, time.Second}) {
+		t.Fatalf("buckets must be sorted %v", h.Buckets())
+	}
+
+	h.Observe(50 * time.Millisecond)
+
+	expectedLabels := [][]Label{
+		{{"route", "/"}, {"le", "0.1"}},
+		{{"route", "/"}, {"le", "1"}},
+		{{"route", "/"}, {"le", "+Inf"}},
+		labels,
+		labels,
+	}
+	if !reflect.DeepEqual(m.labels, expectedLabels) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+
+	// Base labels must not be modified by the bucket label
+	if len(labels) != 1 {
+		t.Fatalf("labels were modified %v", labels)
+	}
+}
+
+func TestDurationHistogram_CopiesKeyAndLabels(t *testing.T) {
+	m := &MockSink{}
+	key := []string{"latency"}
+	labels := []Label{{"route", "/"}}
+	h := NewDurationHistogram(m, key, labels, WithBuckets(nil))
+
+	key[0] = "other"
+	labels[0].Value = "/other"
+	h.Observe(time.Second)
+
+	if !reflect.DeepEqual(m.keys[0], []string{"latency", "bucket"}) {
+		t.Fatalf("bad key %v", m.keys[0])
+	}
+	if !reflect.DeepEqual(m.labels[0], []Label{{"route", "/"}, {"le", "+Inf"}}) {
+		t.Fatalf("bad labels %v", m.labels[0])
+	}
+}
@@ -0,0 +1,407 @@
+// Package endpoint creates sinks from URLs, allowing to configure the
+// metrics backend of an application with a single string, ex:
+// "statsd://localhost:8125?prefix=myapp.production"
+package endpoint
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/graphite"
+	"github.com/hugoluchessi/go-metrics/providers/inmem"
+	"github.com/hugoluchessi/go-metrics/providers/prometheus"
+	"github.com/hugoluchessi/go-metrics/providers/statsd"
+)
+
+const (
+	// DefaultInmemInterval is the aggregation interval of inmem sinks
+	// created without the "interval" query parameter
+	DefaultInmemInterval = 10 * time.Second
+
+	// DefaultInmemRetain is the retention of inmem sinks created
+	// without the "retain" query parameter
+	DefaultInmemRetain = time.Minute
+)
+
+// SinkURLFactoryFunc creates a sink from its URL
+type SinkURLFactoryFunc func(u *url.URL) (metrics.Sinker, error)
+
+// sinkRegistry maps the URL schemes to their sink factories
+var (
+	sinkRegistry = map[string]SinkURLFactoryFunc{
+		"statsd":     NewStatsdSinkFromURL,
+		"statsite":   NewStatsiteSinkFromURL,
+		"inmem":      NewInmemSinkFromURL,
+		"prometheus": NewPrometheusSinkFromURL,
+		"graphite":   NewGraphiteSinkFromURL,
+	}
+	sinkRegistryLock sync.RWMutex
+)
+
+// The fanout factory creates its sinks from the registry, so it is
+// registered at init to avoid an initialization cycle
+func init() {
+	sinkRegistry["fanout"] = NewFanoutSinkFromURL
+}
+
+// RegisterSink registers the factory of the sinks of the given URL scheme,
+// so NewSinkFromURL creates them, ex: from the init function of a package
+// providing a sink. The "prefix" query parameter is handled for the
+// factory. It returns an error if the scheme is already taken.
+func RegisterSink(scheme string, fn SinkURLFactoryFunc) error {
+	if scheme == "" || fn == nil {
+		return fmt.Errorf("a scheme and a factory must be provided")
+	}
+
+	sinkRegistryLock.Lock()
+	defer sinkRegistryLock.Unlock()
+	if _, ok := sinkRegistry[scheme]; ok {
+		return fmt.Errorf("sink scheme %q is already registered", scheme)
+	}
+	sinkRegistry[scheme] = func(u *url.URL) (metrics.Sinker, error) {
+		sink, err := fn(u)
+		if err != nil {
+			return nil, err
+		}
+		return withPrefix(sink, u), nil
+	}
+	return nil
+}
+
+// NewSinkFromURL creates a sink from the given URL, its scheme selecting
+// the sink. Every sink supports the "prefix" query parameter, a dot-separated
+// prefix prepended to all keys.
+func NewSinkFromURL(urlStr string) (metrics.Sinker, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	sinkRegistryLock.RLock()
+	factory, ok := sinkRegistry[u.Scheme]
+	sinkRegistryLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unrecognized sink name: %q", u.Scheme)
+	}
+	return factory(u)
+}
+
+// NewStatsdSinkFromURL creates a statsd sink sending to the URL host,
+// ex: "statsd://localhost:8125". The "transport" query parameter selects
+// "udp", the default, or "tcp". The "queue_size", "drop_on_full" and
+// "write_timeout" parameters configure the queue, metrics are dropped
+// when it is full unless drop_on_full is false. The "format" parameter
+// selects the tag format, "statsd", the default, "dogstatsd" or
+// "telegraf", "sample_rate" sets the samples rate and "flush_interval"
+// how often the buffered metrics are sent, ex: "50ms". The "dial_timeout"
+// and "conn_write_timeout" parameters bound the connection and the writes
+// to the server. With "tls=1" the TCP connection is encrypted, "tls_ca"
+// being the PEM file of the certificate authorities trusted instead of the
+// system ones, and "tls_cert" and "tls_key" the PEM files of the client
+// certificate and its key.
+func NewStatsdSinkFromURL(u *url.URL) (metrics.Sinker, error) {
+	cfg, err := statsdConfig(u.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	sink, err := statsd.NewSinkWithConfig(u.Host, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return withPrefix(sink, u), nil
+}
+
+// NewStatsiteSinkFromURL creates a sink sending to the statsite server
+// at the URL host, ex: "statsite://localhost:8125?transport=udp". Statsite
+// accepts the statsd protocol, so metrics are sent by the statsd sink,
+// configured by the parameters of NewStatsdSinkFromURL. The transport
+// defaults to "tcp" though, and over "udp" each metric is sent in its
+// own datagram.
+func NewStatsiteSinkFromURL(u *url.URL) (metrics.Sinker, error) {
+	cfg, err := statsdConfig(u.Query())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Transport == "" {
+		cfg.Transport = "tcp"
+	}
+	cfg.DatagramPerMetric = true
+
+	sink, err := statsd.NewSinkWithConfig(u.Host, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return withPrefix(sink, u), nil
+}
+
+// Returns the statsd sink config of the URL query parameters
+func statsdConfig(params url.Values) (statsd.SinkConfig, error) {
+	cfg := statsd.SinkConfig{
+		Transport:  params.Get("transport"),
+		DropOnFull: true,
+	}
+
+	var err error
+	if cfg.TagFormat, err = tagFormatParam(params, "format"); err != nil {
+		return cfg, err
+	}
+	if cfg.QueueSize, err = intParam(params, "queue_size", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.DropOnFull, err = boolParam(params, "drop_on_full", true); err != nil {
+		return cfg, err
+	}
+	if cfg.WriteTimeout, err = durationParam(params, "write_timeout", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.SampleRate, err = floatParam(params, "sample_rate", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.FlushInterval, err = durationParam(params, "flush_interval", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.DialTimeout, err = durationParam(params, "dial_timeout", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.ConnWriteTimeout, err = durationParam(params, "conn_write_timeout", 0); err != nil {
+		return cfg, err
+	}
+	cfg.TLSConfig, err = tlsParams(params)
+	return cfg, err
+}
+
+// NewInmemSinkFromURL creates an inmem sink, configured by the "interval"
+// and "retain" query parameters, ex: "inmem://?interval=10s&retain=5m".
+// The retention must not be shorter than the interval.
+func NewInmemSinkFromURL(u *url.URL) (metrics.Sinker, error) {
+	params := u.Query()
+
+	interval, err := durationParam(params, "interval", DefaultInmemInterval)
+	if err != nil {
+		return nil, err
+	}
+	retain, err := durationParam(params, "retain", DefaultInmemRetain)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 || retain <= 0 {
+		return nil, fmt.Errorf("inmem interval and retain must be positive")
+	}
+	if retain < interval {
+		return nil, fmt.Errorf("inmem retain must not be shorter than the interval")
+	}
+
+	return withPrefix(inmem.NewSink(interval, retain), u), nil
+}
+
+// NewPrometheusSinkFromURL creates a Prometheus sink, configured by the
+// "expiration" and "buckets" query parameters, ex: "prometheus://?buckets=0.1,1".
+// Its metrics are exposed by the handler of the *prometheus.Sink, the sink
+// is only wrapped when the "prefix" query parameter is set.
+func NewPrometheusSinkFromURL(u *url.URL) (metrics.Sinker, error) {
+	sink, err := prometheus.NewSinkFromURL(u)
+	if err != nil {
+		return nil, err
+	}
+	return withPrefix(sink, u), nil
+}
+
+// NewGraphiteSinkFromURL creates a sink writing to the Carbon server at
+// the URL host, ex: "graphite://localhost:2003?flush_interval=1s". The
+// "flush_interval" parameter sets how often the buffered metrics are sent,
+// "reconnect_base" and "reconnect_max" the backoff between reconnects.
+func NewGraphiteSinkFromURL(u *url.URL) (metrics.Sinker, error) {
+	params := u.Query()
+
+	flushInterval, err := durationParam(params, "flush_interval", graphite.DefaultFlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	reconnectBase, err := durationParam(params, "reconnect_base", graphite.DefaultReconnectBase)
+	if err != nil {
+		return nil, err
+	}
+	reconnectMax, err := durationParam(params, "reconnect_max", graphite.DefaultReconnectMax)
+	if err != nil {
+		return nil, err
+	}
+
+	sink, err := graphite.NewSink(u.Host,
+		graphite.WithFlushInterval(flushInterval),
+		graphite.WithReconnectBackoff(reconnectBase, reconnectMax),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return withPrefix(sink, u), nil
+}
+
+// NewFanoutSinkFromURL creates a FanoutSink sending to the sinks of the
+// comma-separated URLs of the "sinks" query parameter, which may also be
+// repeated. The sub URLs must be query escaped,
+// ex: "fanout://?sinks=statsd://localhost:8125,inmem://%3Finterval%3D1s"
+func NewFanoutSinkFromURL(u *url.URL) (metrics.Sinker, error) {
+	var fanout metrics.FanoutSink
+	for _, param := range u.Query()["sinks"] {
+		for _, sinkURL := range splitURLs(param) {
+			if sinkURL == "" {
+				continue
+			}
+			// Sinks already created are shut down on error
+			sink, err := NewSinkFromURL(sinkURL)
+			if err != nil {
+				fanout.Shutdown()
+				return nil, fmt.Errorf("bad fanout sink %q: %s", sinkURL, err)
+			}
+			fanout = append(fanout, sink)
+		}
+	}
+	if len(fanout) == 0 {
+		return nil, fmt.Errorf("fanout requires at least one sink in the 'sinks' parameter")
+	}
+	return withPrefix(fanout, u), nil
+}
+
+// splitURLs splits comma-separated URLs. A comma only separates URLs
+// when followed by a scheme, so the URLs may hold commas themselves.
+func splitURLs(s string) []string {
+	var urls []string
+	for _, part := range strings.Split(s, ",") {
+		if len(urls) > 0 && !strings.Contains(part, "://") {
+			urls[len(urls)-1] += "," + part
+			continue
+		}
+		urls = append(urls, part)
+	}
+	return urls
+}
+
+// withPrefix wraps the sink prepending the "prefix" query parameter to
+// all keys, the sink is returned as is when it is not set
+func withPrefix(sink metrics.Sinker, u *url.URL) metrics.Sinker {
+	prefix := u.Query().Get("prefix")
+	if prefix == "" {
+		return sink
+	}
+	return metrics.NewPrefixSink(sink, prefix)
+}
+
+// durationParam parses the named query parameter as a duration
+func durationParam(params url.Values, name string, def time.Duration) (time.Duration, error) {
+	value := params.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad '%s' parameter %q: %s", name, value, err)
+	}
+	return d, nil
+}
+
+// intParam parses the named query parameter as an integer
+func intParam(params url.Values, name string, def int) (int, error) {
+	value := params.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad '%s' parameter %q: %s", name, value, err)
+	}
+	return i, nil
+}
+
+// floatParam parses the named query parameter as a float
+func floatParam(params url.Values, name string, def float64) (float64, error) {
+	value := params.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad '%s' parameter %q: %s", name, value, err)
+	}
+	return f, nil
+}
+
+// tagFormatParam parses the named query parameter as a statsd tag format
+func tagFormatParam(params url.Values, name string) (statsd.TagFormat, error) {
+	switch value := params.Get(name); value {
+	case "", "statsd":
+		return statsd.TagFormatNone, nil
+	case "dogstatsd":
+		return statsd.TagFormatDogStatsD, nil
+	case "telegraf":
+		return statsd.TagFormatTelegraf, nil
+	default:
+		return 0, fmt.Errorf("bad '%s' parameter %q, must be statsd, dogstatsd or telegraf", name, value)
+	}
+}
+
+// tlsParams creates the TLS config enabled by the "tls" query parameter,
+// nil when it is not set. "tls_ca" is the PEM file of the certificate
+// authorities trusted instead of the system ones, "tls_cert" and "tls_key"
+// the PEM files of the client certificate and its key.
+func tlsParams(params url.Values) (*tls.Config, error) {
+	enabled, err := boolParam(params, "tls", false)
+	if err != nil {
+		return nil, err
+	}
+	ca, cert, key := params.Get("tls_ca"), params.Get("tls_cert"), params.Get("tls_key")
+	if !enabled {
+		if ca != "" || cert != "" || key != "" {
+			return nil, fmt.Errorf("TLS parameters require the 'tls' parameter")
+		}
+		return nil, nil
+	}
+
+	cfg := &tls.Config{}
+	if ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("bad 'tls_ca' parameter: %s", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("bad 'tls_ca' parameter: no certificate found in %q", ca)
+		}
+	}
+	if cert != "" || key != "" {
+		if cert == "" || key == "" {
+			return nil, fmt.Errorf("'tls_cert' and 'tls_key' parameters must be set together")
+		}
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("bad 'tls_cert' or 'tls_key' parameter: %s", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// boolParam parses the named query parameter as a boolean
+func boolParam(params url.Values, name string, def bool) (bool, error) {
+	value := params.Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("bad '%s' parameter %q: %s", name, value, err)
+	}
+	return b, nil
+}
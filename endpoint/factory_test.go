@@ -0,0 +1,247 @@
+package endpoint
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/graphite"
+	"github.com/hugoluchessi/go-metrics/providers/inmem"
+	"github.com/hugoluchessi/go-metrics/providers/prometheus"
+	"github.com/hugoluchessi/go-metrics/providers/statsd"
+)
+
+func TestNewSinkFromURL(t *testing.T) {
+	for _, tc := range []struct {
+		url    string
+		expect string
+	}{
+		{"statsd://localhost:8125", "*statsd.Sink"},
+		{"statsite://localhost:8125", "*statsd.Sink"},
+		{"statsite://localhost:8125?transport=tcp", "*statsd.Sink"},
+		{"statsd://localhost:8125?queue_size=10&drop_on_full=false&write_timeout=5ms", "*statsd.Sink"},
+		{"statsd://localhost:8125?format=dogstatsd&sample_rate=0.1", "*statsd.Sink"},
+		{"statsd://localhost:8125?format=telegraf", "*statsd.Sink"},
+		{"statsite://localhost:8125?flush_interval=50ms", "*statsd.Sink"},
+		{"statsd://localhost:8125?dial_timeout=1s&conn_write_timeout=2s", "*statsd.Sink"},
+		{"statsite://localhost:8125?tls=1", "*statsd.Sink"},
+		{"statsd://[::1]:8125", "*statsd.Sink"},
+		{"statsite://[::1]:8125", "*statsd.Sink"},
+		{"inmem://?interval=1s&retain=2s", "*inmem.Sink"},
+		{"prometheus://?buckets=0.1,1", "*prometheus.Sink"},
+		{"graphite://localhost:2003?flush_interval=1s&reconnect_base=1s&reconnect_max=1m", "*graphite.Sink"},
+		{"statsd://localhost:8125?prefix=myapp.production", "*metrics.PrefixSink"},
+	} {
+		sink, err := NewSinkFromURL(tc.url)
+		if err != nil {
+			t.Fatalf("bad %s: %s", tc.url, err)
+		}
+		switch s := sink.(type) {
+		case *statsd.Sink:
+			if tc.expect != "*statsd.Sink" {
+				t.Fatalf("bad sink type for %s: %T", tc.url, sink)
+			}
+			s.Shutdown()
+		case *graphite.Sink:
+			if tc.expect != "*graphite.Sink" {
+				t.Fatalf("bad sink type for %s: %T", tc.url, sink)
+			}
+			s.Shutdown()
+		case *inmem.Sink:
+			if tc.expect != "*inmem.Sink" {
+				t.Fatalf("bad sink type for %s: %T", tc.url, sink)
+			}
+		case *prometheus.Sink:
+			if tc.expect != "*prometheus.Sink" {
+				t.Fatalf("bad sink type for %s: %T", tc.url, sink)
+			}
+		case *metrics.PrefixSink:
+			if tc.expect != "*metrics.PrefixSink" {
+				t.Fatalf("bad sink type for %s: %T", tc.url, sink)
+			}
+			s.Shutdown()
+		default:
+			t.Fatalf("bad sink type for %s: %T", tc.url, sink)
+		}
+	}
+}
+
+func TestNewSinkFromURL_PrefixShutdown(t *testing.T) {
+	list, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	sink, err := NewSinkFromURL("statsd://" + list.LocalAddr().String() + "?prefix=x")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	prefixed, ok := sink.(*metrics.PrefixSink)
+	if !ok {
+		t.Fatalf("bad sink type %T", sink)
+	}
+	if _, ok := prefixed.Unwrap().(*statsd.Sink); !ok {
+		t.Fatalf("bad inner sink type %T", prefixed.Unwrap())
+	}
+
+	// Shutting down flushes the queued metric of the statsd sink
+	sink.IncrCounter([]string{"counter"}, 1)
+	if err := prefixed.Shutdown(); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	list.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := list.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "x.counter:1.000000|c\n" {
+		t.Fatalf("bad packet %q %v", buf[:n], err)
+	}
+}
+
+func TestNewStatsiteSinkFromURL_TCP(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	sink, err := NewSinkFromURL("statsite://" + list.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	sink.IncrCounter([]string{"counter"}, 1)
+	sink.(*statsd.Sink).Shutdown()
+
+	list.(*net.TCPListener).SetDeadline(time.Now().Add(3 * time.Second))
+	conn, err := list.Accept()
+	if err != nil {
+		t.Fatalf("statsite sink did not connect over tcp: %s", err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "counter:1.000000|c\n" {
+		t.Fatalf("bad line %q %v", line, err)
+	}
+}
+
+func TestNewSinkFromURL_Errors(t *testing.T) {
+	for _, urlStr := range []string{
+		"unknown://localhost",
+		"statsd://localhost",
+		"statsite://localhost:8125?transport=sctp",
+		"statsd://localhost:8125?queue_size=-1",
+		"statsd://localhost:8125?queue_size=a",
+		"statsd://localhost:8125?drop_on_full=a",
+		"statsd://localhost:8125?write_timeout=a",
+		"statsd://localhost:8125?format=graphite",
+		"statsd://localhost:8125?sample_rate=a",
+		"statsd://localhost:8125?sample_rate=2",
+		"statsd://localhost:8125?flush_interval=a",
+		"statsd://localhost:8125?dial_timeout=a",
+		"statsd://localhost:8125?conn_write_timeout=a",
+		"statsite://localhost:8125?flush_interval=-50ms",
+		"statsd://localhost:8125?tls=a",
+		"statsd://localhost:8125?tls_ca=ca.pem",
+		"statsd://localhost:8125?tls=1&tls_ca=/nonexistent/ca.pem",
+		"statsd://localhost:8125?tls=1&tls_cert=cert.pem",
+		"statsd://localhost:8125?tls=1&tls_cert=/nonexistent/cert.pem&tls_key=/nonexistent/key.pem",
+		"statsd://localhost:8125?tls=1&transport=udp",
+		"statsd://localhost:8125?tls=1&tls_ca=factory_test.go",
+		"inmem://?interval=foo",
+		"inmem://?retain=-1s",
+		"inmem://?interval=10s&retain=5s",
+		"prometheus://?buckets=a",
+		"graphite://",
+		"graphite://localhost?flush_interval=a",
+		"graphite://localhost?reconnect_base=2m&reconnect_max=1m",
+		"://bad",
+	} {
+		if _, err := NewSinkFromURL(urlStr); err == nil {
+			t.Fatalf("expected error for %s", urlStr)
+		}
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	inm := inmem.NewSink(time.Second, 2*time.Second)
+	u, _ := url.Parse("inmem://?prefix=myapp.production")
+	withPrefix(inm, u).SetGauge([]string{"foo"}, 42)
+
+	data := inm.Data()
+	if _, ok := data[0].Gauges["myapp.production.foo"]; !ok {
+		t.Fatalf("bad gauges: %v", data[0].Gauges)
+	}
+
+	u, _ = url.Parse("inmem://")
+	if withPrefix(inm, u) != metrics.Sinker(inm) {
+		t.Fatalf("sink should not be wrapped without prefix")
+	}
+}
+
+func TestNewFanoutSinkFromURL(t *testing.T) {
+	sink, err := NewSinkFromURL("fanout://?sinks=statsd://localhost:8125,inmem://%3Finterval%3D1s&sinks=prometheus://%3Fbuckets%3D0.1,1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	fanout, ok := sink.(metrics.FanoutSink)
+	if !ok || len(fanout) != 3 {
+		t.Fatalf("bad sink %T %v", sink, sink)
+	}
+	defer fanout.Shutdown()
+
+	if _, ok := fanout[0].(*statsd.Sink); !ok {
+		t.Fatalf("bad sink type %T", fanout[0])
+	}
+	if _, err := NewSinkFromURL("fanout://"); err == nil {
+		t.Fatalf("expected error without sinks")
+	}
+	if _, err := NewSinkFromURL("fanout://?sinks=statsd://localhost:8125,unknown://"); err == nil {
+		t.Fatalf("expected error for unknown sink")
+	}
+}
+
+func TestSplitURLs(t *testing.T) {
+	urls := splitURLs("statsd://localhost:8125,prometheus://?buckets=0.1,1,inmem://")
+	expected := []string{"statsd://localhost:8125", "prometheus://?buckets=0.1,1", "inmem://"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Fatalf("bad urls %v", urls)
+	}
+}
+
+func TestRegisterSink(t *testing.T) {
+	m := &metrics.BlackholeSink{}
+	err := RegisterSink("blackhole", func(u *url.URL) (metrics.Sinker, error) {
+		return m, nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() {
+		sinkRegistryLock.Lock()
+		delete(sinkRegistry, "blackhole")
+		sinkRegistryLock.Unlock()
+	}()
+
+	sink, err := NewSinkFromURL("blackhole://")
+	if err != nil || sink != m {
+		t.Fatalf("bad sink %v: %v", sink, err)
+	}
+	sink, err = NewSinkFromURL("blackhole://?prefix=myapp")
+	if _, ok := sink.(*metrics.PrefixSink); err != nil || !ok {
+		t.Fatalf("bad sink %T: %v", sink, err)
+	}
+
+	if err := RegisterSink("statsd", NewStatsdSinkFromURL); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := RegisterSink("", NewStatsdSinkFromURL); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := RegisterSink("nil", nil); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,18 @@
+package metrics
+
+// ExemplarSink is implemented by sinks able to attach an exemplar to a
+// sample, usually the traceID and spanID of the request it measures, so
+// dashboards can link a latency spike to its trace
+type ExemplarSink interface {
+	AddSampleWithExemplar(key []string, val float32, exemplar map[string]string)
+}
+
+// AddSampleWithExemplar adds a sample with the exemplar when the sink
+// implements ExemplarSink, otherwise the exemplar is ignored
+func AddSampleWithExemplar(s Sinker, key []string, val float32, exemplar map[string]string) {
+	if es, ok := s.(ExemplarSink); ok {
+		es.AddSampleWithExemplar(key, val, exemplar)
+		return
+	}
+	s.AddSample(key, val)
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+type MockExemplarSink struct {
+	MockSink
+	exemplars []map[string]string
+}
+
+func (m *MockExemplarSink) AddSampleWithExemplar(key []string, val float32, exemplar map[string]string) {
+	m.AddSample(key, val)
+	m.exemplars = append(m.exemplars, exemplar)
+}
+
+func TestAddSampleWithExemplar(t *testing.T) {
+	exemplar := map[string]string{"traceID": "abc"}
+
+	m := &MockExemplarSink{}
+	AddSampleWithExemplar(m, []string{"sample"}, 1, exemplar)
+	if !reflect.DeepEqual(m.exemplars, []map[string]string{exemplar}) {
+		t.Fatalf("bad exemplars %v", m.exemplars)
+	}
+
+	// Sinks without exemplar support receive the sample alone
+	plain := &MockSink{}
+	AddSampleWithExemplar(plain, []string{"sample"}, 1, exemplar)
+	if !reflect.DeepEqual(plain.vals, []float32{1}) {
+		t.Fatalf("bad vals %v", plain.vals)
+	}
+}
+
+func TestFanoutSink_AddSampleWithExemplar(t *testing.T) {
+	m1 := &MockExemplarSink{}
+	m2 := &MockSink{}
+	FanoutSink{m1, m2}.AddSampleWithExemplar([]string{"sample"}, 2, map[string]string{"traceID": "abc"})
+
+	if len(m1.exemplars) != 1 || !reflect.DeepEqual(m2.vals, []float32{2}) {
+		t.Fatalf("bad fanout %v %v", m1.exemplars, m2.vals)
+	}
+}
+
+func TestMetricService_AddSampleWithExemplar(t *testing.T) {
+	m := &MockExemplarSink{}
+	conf := &MetricServiceConfig{ServiceName: "service", EnableServiceName: true}
+	NewMetricService(conf, m).AddSampleWithExemplar([]string{"sample"}, 3, map[string]string{"traceID": "abc"})
+
+	if !reflect.DeepEqual(m.keys[0], []string{"service", "sample"}) || len(m.exemplars) != 1 {
+		t.Fatalf("bad sample %v %v", m.keys, m.exemplars)
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import "strings"
+
+// MultiError holds the errors returned by the members of a FanoutSink
+type MultiError []error
+
+// Error joins the messages of the errors
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// errorOrNil returns nil when no error was collected, so the result can
+// be compared to nil by the callers
+func (e MultiError) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// TrySetGaugeWithLabels sets a value on a gauge with labels on every sink,
+// returning the errors of the FallibleSinker members as a MultiError
+func (fh FanoutSink) TrySetGaugeWithLabels(key []string, val float32, labels []Label) error {
+	var errs MultiError
+	for _, s := range fh {
+		if fs, ok := s.(FallibleSinker); ok {
+			if err := fs.TrySetGaugeWithLabels(key, val, labels); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		s.SetGaugeWithLabels(key, val, labels)
+	}
+	return errs.errorOrNil()
+}
+
+// TryEmitKey emits a key value metric on every sink, returning the
+// errors of the FallibleSinker members as a MultiError
+func (fh FanoutSink) TryEmitKey(key []string, val float32) error {
+	var errs MultiError
+	for _, s := range fh {
+		if fs, ok := s.(FallibleSinker); ok {
+			if err := fs.TryEmitKey(key, val); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		s.EmitKey(key, val)
+	}
+	return errs.errorOrNil()
+}
+
+// TryIncrCounterWithLabels increases the value of a counter with labels on
+// every sink, returning the errors of the FallibleSinker members as a MultiError
+func (fh FanoutSink) TryIncrCounterWithLabels(key []string, val float32, labels []Label) error {
+	var errs MultiError
+	for _, s := range fh {
+		if fs, ok := s.(FallibleSinker); ok {
+			if err := fs.TryIncrCounterWithLabels(key, val, labels); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		s.IncrCounterWithLabels(key, val, labels)
+	}
+	return errs.errorOrNil()
+}
+
+// TryAddSampleWithLabels adds a sample with labels on every sink,
+// returning the errors of the FallibleSinker members as a MultiError
+func (fh FanoutSink) TryAddSampleWithLabels(key []string, val float32, labels []Label) error {
+	var errs MultiError
+	for _, s := range fh {
+		if fs, ok := s.(FallibleSinker); ok {
+			if err := fs.TryAddSampleWithLabels(key, val, labels); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		s.AddSampleWithLabels(key, val, labels)
+	}
+	return errs.errorOrNil()
+}
+
+// Shutdown shuts down every sink having a Shutdown method, returning the
+// errors of those returning one as a MultiError
+func (fh FanoutSink) Shutdown() error {
+	var errs MultiError
+	for _, s := range fh {
+		switch sd := s.(type) {
+		case interface{ Shutdown() error }:
+			if err := sd.Shutdown(); err != nil {
+				errs = append(errs, err)
+			}
+		case interface{ Shutdown() }:
+			sd.Shutdown()
+		}
+	}
+	return errs.errorOrNil()
+}
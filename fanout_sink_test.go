@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+)
+
+type MockShutdownSink struct {
+	MockSink
+	shutdown bool
+	err      error
+}
+
+func (m *MockShutdownSink) Shutdown() error {
+	m.shutdown = true
+	return m.err
+}
+
+type MockVoidShutdownSink struct {
+	MockSink
+	shutdown bool
+}
+
+func (m *MockVoidShutdownSink) Shutdown() {
+	m.shutdown = true
+}
+
+func TestFanoutSink_Try(t *testing.T) {
+	err1, err2 := errors.New("queue full"), errors.New("closed")
+	ok := &MockFallibleSink{}
+	plain := &MockSink{}
+	fh := FanoutSink{&MockFallibleSink{err: err1}, ok, plain, &MockFallibleSink{err: err2}}
+
+	l := []Label{{"a", "b"}}
+	for _, try := range []func() error{
+		func() error { return fh.TrySetGaugeWithLabels([]string{"gauge"}, 1, l) },
+		func() error { return fh.TryEmitKey([]string{"key"}, 2) },
+		func() error { return fh.TryIncrCounterWithLabels([]string{"counter"}, 3, l) },
+		func() error { return fh.TryAddSampleWithLabels([]string{"sample"}, 4, l) },
+	} {
+		err := try()
+		errs, isMulti := err.(MultiError)
+		if !isMulti || len(errs) != 2 || errs[0] != err1 || errs[1] != err2 {
+			t.Fatalf("bad error %v", err)
+		}
+		if err.Error() != "queue full; closed" {
+			t.Fatalf("bad message %s", err)
+		}
+	}
+
+	// Every member receives the metrics, errors do not short-circuit
+	if len(ok.vals) != 4 || len(plain.vals) != 4 {
+		t.Fatalf("bad vals %v %v", ok.vals, plain.vals)
+	}
+	if len(plain.labels[0]) != 1 || len(ok.labels[3]) != 1 {
+		t.Fatalf("bad labels %v %v", plain.labels, ok.labels)
+	}
+
+	if err := (FanoutSink{ok, plain}).TryEmitKey([]string{"key"}, 1); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestFanoutSink_Shutdown(t *testing.T) {
+	failing := &MockShutdownSink{err: errors.New("flush failed")}
+	succeeding := &MockShutdownSink{}
+	void := &MockVoidShutdownSink{}
+	fh := FanoutSink{failing, &MockSink{}, succeeding, void}
+
+	err := fh.Shutdown()
+	if err == nil || err.Error() != "flush failed" {
+		t.Fatalf("bad error %v", err)
+	}
+	if !failing.shutdown || !succeeding.shutdown || !void.shutdown {
+		t.Fatalf("every sink must be shut down")
+	}
+
+	if err := (FanoutSink{succeeding, void}).Shutdown(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import "strings"
+
+// FilterConfig holds the patterns of the keys a FilterSink forwards.
+// A pattern matches the leading parts of the key joined with ".", where
+// "*" matches a single part and "**" any number of parts, ex: "db.*.time"
+// matches "db.query.time.p99" and "**.debug" matches "http.server.debug".
+type FilterConfig struct {
+	// Allow lists the patterns of the forwarded keys, every key not
+	// denied is forwarded when empty
+	Allow []string
+
+	// Deny lists the patterns of the dropped keys, checked before Allow
+	Deny []string
+}
+
+// FilterSink forwards to an inner sink only the metrics whose key is
+// allowed by a FilterConfig, to suppress noisy or high cardinality
+// metrics without changing the code emitting them
+type FilterSink struct {
+	inner Sinker
+	allow [][]string
+	deny  [][]string
+}
+
+// NewFilterSink creates a FilterSink, forwarding every metric when the
+// config has no patterns
+func NewFilterSink(inner Sinker, config FilterConfig) *FilterSink {
+	return &FilterSink{
+		inner: inner,
+		allow: splitPatterns(config.Allow),
+		deny:  splitPatterns(config.Deny),
+	}
+}
+
+// SetGauge sets a value on a gauge
+func (s *FilterSink) SetGauge(key []string, val float32) {
+	if s.allowed(key) {
+		s.inner.SetGauge(key, val)
+	}
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *FilterSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	if s.allowed(key) {
+		s.inner.SetGaugeWithLabels(key, val, labels)
+	}
+}
+
+// EmitKey emits a key value metric
+func (s *FilterSink) EmitKey(key []string, val float32) {
+	if s.allowed(key) {
+		s.inner.EmitKey(key, val)
+	}
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *FilterSink) IncrCounter(key []string, val float32) {
+	if s.allowed(key) {
+		s.inner.IncrCounter(key, val)
+	}
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *FilterSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	if s.allowed(key) {
+		s.inner.IncrCounterWithLabels(key, val, labels)
+	}
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *FilterSink) IncrCounterFloat64(key []string, val float64) {
+	if s.allowed(key) {
+		AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+	}
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *FilterSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	if s.allowed(key) {
+		AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+	}
+}
+
+// AddSample adds a sample metrics
+func (s *FilterSink) AddSample(key []string, val float32) {
+	if s.allowed(key) {
+		s.inner.AddSample(key, val)
+	}
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *FilterSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	if s.allowed(key) {
+		s.inner.AddSampleWithLabels(key, val, labels)
+	}
+}
+
+// allowed checks the key against the deny patterns, then the allow ones
+func (s *FilterSink) allowed(key []string) bool {
+	if len(s.allow) == 0 && len(s.deny) == 0 {
+		return true
+	}
+
+	parts := strings.Split(strings.Join(key, "."), ".")
+	for _, pattern := range s.deny {
+		if matchPrefix(pattern, parts) {
+			return false
+		}
+	}
+	if len(s.allow) == 0 {
+		return true
+	}
+	for _, pattern := range s.allow {
+		if matchPrefix(pattern, parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPatterns splits the patterns on ".", ignoring the empty ones
+func splitPatterns(patterns []string) [][]string {
+	var split [][]string
+	for _, pattern := range patterns {
+		if pattern != "" {
+			split = append(split, strings.Split(pattern, "."))
+		}
+	}
+	return split
+}
+
+// matchPrefix checks whether the pattern matches the leading key parts
+func matchPrefix(pattern, parts []string) bool {
+	for i, p := range pattern {
+		switch {
+		case p == "**":
+			for j := 0; j <= len(parts); j++ {
+				if matchPrefix(pattern[i+1:], parts[j:]) {
+					return true
+				}
+			}
+			return false
+		case len(parts) == 0:
+			return false
+		case p != "*" && p != parts[0]:
+			return false
+		}
+		parts = parts[1:]
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterSink(t *testing.T) {
+	m := &MockSink{}
+	s := NewFilterSink(m, FilterConfig{
+		Allow: []string{"gauge", "counter", "http.*.latency"},
+		Deny:  []string{"counter.debug", "**.internal"},
+	})
+	emitAll(s)
+	s.IncrCounterFloat64([]string{"counter", "debug"}, 8)
+	s.IncrCounterWithLabelsFloat64([]string{"http.server", "latency", "p99"}, 9, nil)
+	s.AddSample([]string{"http", "server", "internal"}, 10)
+	s.SetGauge([]string{"gauge", "internal"}, 11)
+
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 4, 5, 9}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.labels[1], []Label{{"a", "b"}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+
+	m = &MockSink{}
+	emitAll(NewFilterSink(m, FilterConfig{}))
+	if len(m.vals) != 7 {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+
+	m = &MockSink{}
+	emitAll(NewFilterSink(m, FilterConfig{Deny: []string{"sample"}}))
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+}
+
+func TestMatchPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"db", "db.query.time", true},
+		{"db", "dbx.query", false},
+		{"db.query.time", "db.query", false},
+		{"db.*.time", "db.query.time.p99", true},
+		{"db.*.time", "db.query.rows", false},
+		{"*", "anything", true},
+		{"**", "any.thing", true},
+		{"**.debug", "debug", true},
+		{"**.debug", "http.server.debug.count", true},
+		{"**.debug", "http.server.count", false},
+		{"http.**.errors", "http.errors", true},
+		{"http.**.errors", "http.server.v2.errors", true},
+		{"http.**.errors", "grpc.server.errors", false},
+	} {
+		patterns := splitPatterns([]string{tc.pattern})
+		if match := matchPrefix(patterns[0], splitPatterns([]string{tc.key})[0]); match != tc.match {
+			t.Fatalf("bad match %q %q: %v", tc.pattern, tc.key, match)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"log"
+	"sync"
+)
+
+// Float64Sink is implemented by sinks which are able to increment
+// counters with float64 precision, useful for rates and durations
+// where float32 precision is not enough
+type Float64Sink interface {
+	IncrCounterFloat64(key []string, val float64)
+	IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label)
+}
+
+// precisionWarning makes the precision loss warning logged only once
+var precisionWarning sync.Once
+
+// AsFloat64Sink returns the sink itself when it implements Float64Sink,
+// otherwise an adapter narrowing the values to float32. The adapter logs
+// a warning the first time a value loses precision.
+func AsFloat64Sink(s Sinker) Float64Sink {
+	if fs, ok := s.(Float64Sink); ok {
+		return fs
+	}
+	return &float64Adapter{sink: s}
+}
+
+// float64Adapter implements Float64Sink for sinks which do not support it
+type float64Adapter struct {
+	sink Sinker
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (a *float64Adapter) IncrCounterFloat64(key []string, val float64) {
+	a.sink.IncrCounter(key, narrow(key, val))
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (a *float64Adapter) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	a.sink.IncrCounterWithLabels(key, narrow(key, val), labels)
+}
+
+// narrow converts the value to float32, warning on precision loss
+func narrow(key []string, val float64) float32 {
+	narrowed := float32(val)
+	if float64(narrowed) != val {
+		precisionWarning.Do(func() {
+			log.Printf("[WARN] Sink does not support float64 counters, %v value %v narrowed to %v", key, val, narrowed)
+		})
+	}
+	return narrowed
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type MockFloat64Sink struct {
+	MockSink
+	vals64 []float64
+}
+
+func (m *MockFloat64Sink) IncrCounterFloat64(key []string, val float64) {
+	m.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+func (m *MockFloat64Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	m.keys = append(m.keys, key)
+	m.vals64 = append(m.vals64, val)
+	m.labels = append(m.labels, labels)
+}
+
+func TestAsFloat64Sink(t *testing.T) {
+	m := &MockFloat64Sink{}
+	if AsFloat64Sink(m) != Float64Sink(m) {
+		t.Fatalf("Float64Sink implementations must be returned as they are")
+	}
+
+	AsFloat64Sink(m).IncrCounterFloat64([]string{"counter"}, 0.1)
+	if !reflect.DeepEqual(m.vals64, []float64{0.1}) {
+		t.Fatalf("bad vals %v", m.vals64)
+	}
+}
+
+func TestAsFloat64Sink_Fallback(t *testing.T) {
+	m := &MockSink{}
+	s := AsFloat64Sink(m)
+	s.IncrCounterFloat64([]string{"counter"}, 0.1)
+	s.IncrCounterWithLabelsFloat64([]string{"counter"}, 2, []Label{{"a", "b"}})
+
+	if !reflect.DeepEqual(m.vals, []float32{0.1, 2}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.labels, [][]Label{nil, {{"a", "b"}}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestFanoutSink_IncrCounterFloat64(t *testing.T) {
+	m1 := &MockSink{}
+	m2 := &MockFloat64Sink{}
+	fh := FanoutSink{m1, m2}
+	fh.IncrCounterFloat64([]string{"counter"}, 0.1)
+
+	if !reflect.DeepEqual(m1.vals, []float32{0.1}) {
+		t.Fatalf("bad vals %v", m1.vals)
+	}
+	if !reflect.DeepEqual(m2.vals64, []float64{0.1}) {
+		t.Fatalf("bad vals %v", m2.vals64)
+	}
+}
+
+func TestMultiLevelSink_IncrCounterFloat64(t *testing.T) {
+	m := &MockFloat64Sink{}
+	s, err := NewMultiLevelSink(AggregationTier{Window: time.Hour, Sink: m})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	a, b := 0.1, 0.2
+	s.IncrCounterFloat64([]string{"counter"}, a)
+	s.IncrCounterFloat64([]string{"counter"}, b)
+	s.Flush()
+
+	if !reflect.DeepEqual(m.vals64, []float64{a + b}) {
+		t.Fatalf("bad vals %v", m.vals64)
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import "sync"
+
+// GaugeDeltaSink is implemented by sinks able to change the value of a
+// gauge by a delta, ex: the statsd "+n" and "-n" gauge syntax, so
+// concurrent processes can update the same gauge
+type GaugeDeltaSink interface {
+	IncrGauge(key []string, val float32)
+	IncrGaugeWithLabels(key []string, val float32, labels []Label)
+	DecrGauge(key []string, val float32)
+	DecrGaugeWithLabels(key []string, val float32, labels []Label)
+}
+
+// AsGaugeDeltaSink returns the sink itself when it implements
+// GaugeDeltaSink, otherwise an adapter setting the gauges to the sum of
+// the deltas it applied. This is a non-atomic approximation: the adapter
+// only knows the values it set, not the values set on the sink directly
+// or by other adapters, so the same adapter must be kept for a sink.
+func AsGaugeDeltaSink(s Sinker) GaugeDeltaSink {
+	if ds, ok := s.(GaugeDeltaSink); ok {
+		return ds
+	}
+	return &gaugeDeltaAdapter{sink: s, gauges: make(map[string]float32)}
+}
+
+// gaugeDeltaAdapter implements GaugeDeltaSink for sinks which do not support it
+type gaugeDeltaAdapter struct {
+	sink Sinker
+
+	mu     sync.Mutex
+	gauges map[string]float32
+}
+
+// IncrGauge increases the value of a gauge by a given value
+func (a *gaugeDeltaAdapter) IncrGauge(key []string, val float32) {
+	a.IncrGaugeWithLabels(key, val, nil)
+}
+
+// IncrGaugeWithLabels increases the value of a gauge by a given value with labels
+func (a *gaugeDeltaAdapter) IncrGaugeWithLabels(key []string, val float32, labels []Label) {
+	k := gaugeKey(key, labels)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.gauges[k] += val
+	a.sink.SetGaugeWithLabels(key, a.gauges[k], labels)
+}
+
+// DecrGauge decreases the value of a gauge by a given value
+func (a *gaugeDeltaAdapter) DecrGauge(key []string, val float32) {
+	a.IncrGaugeWithLabels(key, -val, nil)
+}
+
+// DecrGaugeWithLabels decreases the value of a gauge by a given value with labels
+func (a *gaugeDeltaAdapter) DecrGaugeWithLabels(key []string, val float32, labels []Label) {
+	a.IncrGaugeWithLabels(key, -val, labels)
+}
+
+// gaugeKey identifies a gauge by its key and labels
+func gaugeKey(key []string, labels []Label) string {
+	return registryKey(key) + "\x02" + labelFingerprint(labels)
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAsGaugeDeltaSink(t *testing.T) {
+	m := &MockSink{}
+	s := AsGaugeDeltaSink(m)
+	l := []Label{{"a", "b"}}
+
+	s.IncrGauge([]string{"gauge"}, 2)
+	s.IncrGaugeWithLabels([]string{"gauge"}, 5, l)
+	s.DecrGauge([]string{"gauge"}, 3)
+	s.DecrGaugeWithLabels([]string{"gauge"}, 1, l)
+	s.IncrGauge([]string{"other"}, 1)
+
+	if !reflect.DeepEqual(m.vals, []float32{2, 5, -1, 4, 1}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.labels[3], l) || m.labels[2] != nil {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+
+	ds := &MockGaugeDeltaSink{}
+	if AsGaugeDeltaSink(ds) != GaugeDeltaSink(ds) {
+		t.Fatalf("expected the sink itself")
+	}
+}
+
+func TestMetricService_GaugeDelta(t *testing.T) {
+	m := &MockSink{}
+	met := NewMetricService(&MetricServiceConfig{ServiceName: "service"}, m)
+	met.IncrGauge([]string{"gauge"}, 2)
+	met.DecrGauge([]string{"gauge"}, 0.5)
+
+	if !reflect.DeepEqual(m.vals, []float32{2, 1.5}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+
+	ds := &MockGaugeDeltaSink{}
+	met = NewMetricService(&MetricServiceConfig{ServiceName: "service"}, ds)
+	met.IncrGaugeWithLabels([]string{"gauge"}, 2, []Label{{"a", "b"}})
+	met.DecrGaugeWithLabels([]string{"gauge"}, 1, nil)
+	if !reflect.DeepEqual(ds.deltas, []float32{2, -1}) {
+		t.Fatalf("bad deltas %v", ds.deltas)
+	}
+}
+
+// MockGaugeDeltaSink records the gauge deltas
+type MockGaugeDeltaSink struct {
+	MockSink
+	deltas []float32
+}
+
+func (m *MockGaugeDeltaSink) IncrGauge(key []string, val float32) {
+	m.IncrGaugeWithLabels(key, val, nil)
+}
+
+func (m *MockGaugeDeltaSink) IncrGaugeWithLabels(key []string, val float32, labels []Label) {
+	m.deltas = append(m.deltas, val)
+}
+
+func (m *MockGaugeDeltaSink) DecrGauge(key []string, val float32) {
+	m.IncrGaugeWithLabels(key, -val, nil)
+}
+
+func (m *MockGaugeDeltaSink) DecrGaugeWithLabels(key []string, val float32, labels []Label) {
+	m.IncrGaugeWithLabels(key, -val, labels)
+}
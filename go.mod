@@ -4,7 +4,9 @@ go 1.12
 
 require (
 	github.com/DataDog/datadog-go v0.0.0-20180822151419-281ae9f2d895
-	github.com/prometheus/client_golang v0.9.2
-	github.com/prometheus/client_model v0.0.0-20180712105110-5c3871d89910
+	github.com/golang/snappy v0.0.1
+	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/tv42/httpunix v0.0.0-20150427012821-b75d8614f926 // indirect
+	golang.org/x/time v0.3.0
 )
@@ -1,7 +1,109 @@
 package metrics
 
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
 // Label is used to decorate metrics with custom contextual data
 type Label struct {
 	Name  string
 	Value string
 }
+
+// LabelsFromMap creates labels from the map, sorted by name so the
+// same map always gives the same labels
+func LabelsFromMap(m map[string]string) []Label {
+	labels := make([]Label, 0, len(m))
+	for name, value := range m {
+		labels = append(labels, Label{Name: name, Value: value})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+// LabelsToMap creates a map from the labels, the last value wins when
+// a name is repeated
+func LabelsToMap(labels []Label) map[string]string {
+	m := make(map[string]string, len(labels))
+	for _, label := range labels {
+		m[label.Name] = label.Value
+	}
+	return m
+}
+
+// MergeLabels returns the labels of a followed by the labels of b, in
+// their order of first appearance. When a name is repeated the last
+// value wins, so the labels of b override the labels of a.
+func MergeLabels(a, b []Label) []Label {
+	merged := make([]Label, 0, len(a)+len(b))
+	index := make(map[string]int, len(a)+len(b))
+	for _, labels := range [][]Label{a, b} {
+		for _, label := range labels {
+			if i, ok := index[label.Name]; ok {
+				merged[i].Value = label.Value
+				continue
+			}
+			index[label.Name] = len(merged)
+			merged = append(merged, label)
+		}
+	}
+	return merged
+}
+
+// LabelValueEncoder is used to serialize arbitrary values into label values
+type LabelValueEncoder interface {
+	Encode(v interface{}) string
+}
+
+// LabelWithValue creates a label serializing the value with the given encoder.
+// When no encoder is provided FmtEncoder is used.
+func LabelWithValue(name string, v interface{}, enc LabelValueEncoder) Label {
+	if enc == nil {
+		enc = FmtEncoder{}
+	}
+	return Label{Name: name, Value: enc.Encode(v)}
+}
+
+// StringEncoder encodes strings as they are, values implementing
+// fmt.Stringer or error using their string representation, and falls
+// back to FmtEncoder for any other value
+type StringEncoder struct{}
+
+// Encode serializes a value into a label value
+func (StringEncoder) Encode(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case fmt.Stringer:
+		return s.String()
+	case error:
+		return s.Error()
+	default:
+		return FmtEncoder{}.Encode(v)
+	}
+}
+
+// JSONEncoder encodes values as JSON, falling back to FmtEncoder
+// for values which can not be marshalled
+type JSONEncoder struct{}
+
+// Encode serializes a value into a label value
+func (JSONEncoder) Encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return FmtEncoder{}.Encode(v)
+	}
+	return string(b)
+}
+
+// FmtEncoder encodes values using the fmt package default format (%v)
+type FmtEncoder struct{}
+
+// Encode serializes a value into a label value
+func (FmtEncoder) Encode(v interface{}) string {
+	return fmt.Sprintf("%v", v)
+}
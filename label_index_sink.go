@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// labelIndexCacheSize bounds the number of label sets cached by a
+// LabelIndexSink, once reached new combinations are indexed on each call
+const labelIndexCacheSize = 4096
+
+// LabelSet holds labels sorted by name and indexed for O(1) lookups.
+// It is immutable, and may be shared between calls.
+type LabelSet struct {
+	sorted []Label
+	index  map[string]string
+}
+
+// NewLabelSet indexes the labels, the last value wins when a name is repeated
+func NewLabelSet(labels []Label) *LabelSet {
+	index := make(map[string]string, len(labels))
+	for _, label := range labels {
+		index[label.Name] = label.Value
+	}
+
+	sorted := make([]Label, 0, len(index))
+	for name, value := range index {
+		sorted = append(sorted, Label{Name: name, Value: value})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return &LabelSet{sorted: sorted, index: index}
+}
+
+// Get returns the value of the label with the given name
+func (s *LabelSet) Get(name string) (string, bool) {
+	value, ok := s.index[name]
+	return value, ok
+}
+
+// Labels returns the labels sorted by name, the slice must not be modified
+func (s *LabelSet) Labels() []Label {
+	return s.sorted
+}
+
+// Len returns the number of labels
+func (s *LabelSet) Len() int {
+	return len(s.sorted)
+}
+
+// LabelSetSinker is implemented by sinks which look labels up by name,
+// they receive indexed labels from a LabelIndexSink
+type LabelSetSinker interface {
+	SetGaugeWithLabelSet(key []string, val float32, labels *LabelSet)
+	IncrCounterWithLabelSet(key []string, val float64, labels *LabelSet)
+	AddSampleWithLabelSet(key []string, val float32, labels *LabelSet)
+}
+
+// LabelIndexSink indexes the labels of every call once, for inner sinks
+// processing many labels. Inner sinks implementing LabelSetSinker receive
+// the LabelSet, others the labels sorted by name and without duplicates.
+// Label sets are cached, so recurring combinations are sorted only once.
+type LabelIndexSink struct {
+	inner Sinker
+
+	mu    sync.RWMutex
+	cache map[string]*LabelSet
+}
+
+// NewLabelIndexSink creates a LabelIndexSink
+func NewLabelIndexSink(inner Sinker) *LabelIndexSink {
+	return &LabelIndexSink{
+		inner: inner,
+		cache: make(map[string]*LabelSet),
+	}
+}
+
+// SetGauge sets a value on a gauge
+func (s *LabelIndexSink) SetGauge(key []string, val float32) {
+	s.inner.SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *LabelIndexSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	set := s.labelSet(labels)
+	if ls, ok := s.inner.(LabelSetSinker); ok {
+		ls.SetGaugeWithLabelSet(key, val, set)
+		return
+	}
+	s.inner.SetGaugeWithLabels(key, val, set.Labels())
+}
+
+// EmitKey emits a key value metric
+func (s *LabelIndexSink) EmitKey(key []string, val float32) {
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *LabelIndexSink) IncrCounter(key []string, val float32) {
+	s.inner.IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *LabelIndexSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	set := s.labelSet(labels)
+	if ls, ok := s.inner.(LabelSetSinker); ok {
+		ls.IncrCounterWithLabelSet(key, float64(val), set)
+		return
+	}
+	s.inner.IncrCounterWithLabels(key, val, set.Labels())
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *LabelIndexSink) IncrCounterFloat64(key []string, val float64) {
+	AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *LabelIndexSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	set := s.labelSet(labels)
+	if ls, ok := s.inner.(LabelSetSinker); ok {
+		ls.IncrCounterWithLabelSet(key, val, set)
+		return
+	}
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, set.Labels())
+}
+
+// AddSample adds a sample metrics
+func (s *LabelIndexSink) AddSample(key []string, val float32) {
+	s.inner.AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *LabelIndexSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	set := s.labelSet(labels)
+	if ls, ok := s.inner.(LabelSetSinker); ok {
+		ls.AddSampleWithLabelSet(key, val, set)
+		return
+	}
+	s.inner.AddSampleWithLabels(key, val, set.Labels())
+}
+
+// Returns the cached LabelSet of the labels, indexing them when missing
+func (s *LabelIndexSink) labelSet(labels []Label) *LabelSet {
+	fingerprint := labelFingerprint(labels)
+
+	s.mu.RLock()
+	set, ok := s.cache[fingerprint]
+	s.mu.RUnlock()
+	if ok {
+		return set
+	}
+
+	set = NewLabelSet(labels)
+	s.mu.Lock()
+	if len(s.cache) < labelIndexCacheSize {
+		s.cache[fingerprint] = set
+	}
+	s.mu.Unlock()
+	return set
+}
+
+// labelFingerprint identifies the labels in their given order,
+// "\x00" and "\x01" can not be part of label names or values
+func labelFingerprint(labels []Label) string {
+	size := 0
+	for _, label := range labels {
+		size += len(label.Name) + len(label.Value) + 2
+	}
+
+	var b strings.Builder
+	b.Grow(size)
+	for _, label := range labels {
+		b.WriteString(label.Name)
+		b.WriteByte(0)
+		b.WriteString(label.Value)
+		b.WriteByte(1)
+	}
+	return b.String()
+}
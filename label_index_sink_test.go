@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+// MockLabelSetSink records the label sets received
+type MockLabelSetSink struct {
+	MockSink
+	sets []*LabelSet
+}
+
+func (m *MockLabelSetSink) SetGaugeWithLabelSet(key []string, val float32, labels *LabelSet) {
+	m.sets = append(m.sets, labels)
+}
+
+func (m *MockLabelSetSink) IncrCounterWithLabelSet(key []string, val float64, labels *LabelSet) {
+	m.sets = append(m.sets, labels)
+}
+
+func (m *MockLabelSetSink) AddSampleWithLabelSet(key []string, val float32, labels *LabelSet) {
+	m.sets = append(m.sets, labels)
+}
+
+func TestLabelSet(t *testing.T) {
+	set := NewLabelSet([]Label{{"b", "1"}, {"a", "2"}, {"b", "3"}})
+
+	if !reflect.DeepEqual(set.Labels(), []Label{{"a", "2"}, {"b", "3"}}) {
+		t.Fatalf("bad labels %v", set.Labels())
+	}
+	if v, ok := set.Get("b"); !ok || v != "3" {
+		t.Fatalf("bad value %s", v)
+	}
+	if _, ok := set.Get("c"); ok {
+		t.Fatalf("unexpected label c")
+	}
+	if set.Len() != 2 {
+		t.Fatalf("bad len %d", set.Len())
+	}
+}
+
+func TestLabelIndexSink(t *testing.T) {
+	m := &MockSink{}
+	emitAll(NewLabelIndexSink(m))
+
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.labels[1], []Label{{"a", "b"}}) || m.labels[0] != nil {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestLabelIndexSink_Sorted(t *testing.T) {
+	m := &MockSink{}
+	s := NewLabelIndexSink(m)
+	s.IncrCounterWithLabels([]string{"counter"}, 1, []Label{{"z", "1"}, {"a", "2"}})
+
+	if !reflect.DeepEqual(m.labels[0], []Label{{"a", "2"}, {"z", "1"}}) {
+		t.Fatalf("bad labels %v", m.labels[0])
+	}
+}
+
+func TestLabelIndexSink_LabelSetSinker(t *testing.T) {
+	m := &MockLabelSetSink{}
+	s := NewLabelIndexSink(m)
+
+	labels := []Label{{"b", "1"}, {"a", "2"}}
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, labels)
+	s.IncrCounterWithLabelsFloat64([]string{"counter"}, 2, labels)
+	s.AddSampleWithLabels([]string{"sample"}, 3, []Label{{"a", "2"}, {"b", "1"}})
+
+	if len(m.sets) != 3 || len(m.vals) != 0 {
+		t.Fatalf("label sets must be used %v %v", m.sets, m.vals)
+	}
+	// Same labels in the same order share the cached set
+	if m.sets[0] != m.sets[1] {
+		t.Fatalf("label set must be cached")
+	}
+	if v, _ := m.sets[2].Get("a"); v != "2" {
+		t.Fatalf("bad label set %v", m.sets[2].Labels())
+	}
+}
+
+func TestLabelFingerprint(t *testing.T) {
+	a := labelFingerprint([]Label{{"a", "bc"}})
+	b := labelFingerprint([]Label{{"ab", "c"}})
+	if a == b {
+		t.Fatalf("fingerprints must differ")
+	}
+}
+
+func BenchmarkLabelIndexSink(b *testing.B) {
+	labels := make([]Label, 0, 12)
+	for _, name := range []string{"l", "k", "j", "i", "h", "g", "f", "e", "d", "c", "b", "a"} {
+		labels = append(labels, Label{Name: name, Value: "value"})
+	}
+	s := NewLabelIndexSink(&BlackholeSink{})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.IncrCounterWithLabels([]string{"counter"}, 1, labels)
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import "fmt"
+
+// LabelSink wraps a sink, adding fixed labels to every metric, ex: the
+// host, region and environment, so they need not be passed on each call.
+// The labels are merged with MergeLabels, the labels of the call overriding
+// the fixed labels with the same name. EmitKey has no labels, the label
+// values are appended to its key instead, as the statsd sink flattens labels.
+type LabelSink struct {
+	inner  Sinker
+	labels []Label
+}
+
+// NewLabelSink creates a LabelSink adding the labels, it panics when a
+// label name is empty, as the labels are set at startup
+func NewLabelSink(base Sinker, labels []Label) *LabelSink {
+	for i, label := range labels {
+		if label.Name == "" {
+			panic(fmt.Sprintf("metrics: label %d has an empty name", i))
+		}
+	}
+	return &LabelSink{
+		inner:  base,
+		labels: append([]Label(nil), labels...),
+	}
+}
+
+// SetGauge sets a value on a gauge
+func (s *LabelSink) SetGauge(key []string, val float32) {
+	s.inner.SetGaugeWithLabels(key, val, s.withLabels(nil))
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *LabelSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.inner.SetGaugeWithLabels(key, val, s.withLabels(labels))
+}
+
+// EmitKey emits a key value metric
+func (s *LabelSink) EmitKey(key []string, val float32) {
+	flat := make([]string, 0, len(key)+len(s.labels))
+	flat = append(flat, key...)
+	for _, label := range s.labels {
+		flat = append(flat, label.Value)
+	}
+	s.inner.EmitKey(flat, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *LabelSink) IncrCounter(key []string, val float32) {
+	s.inner.IncrCounterWithLabels(key, val, s.withLabels(nil))
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *LabelSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.inner.IncrCounterWithLabels(key, val, s.withLabels(labels))
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *LabelSink) IncrCounterFloat64(key []string, val float64) {
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, s.withLabels(nil))
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *LabelSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, s.withLabels(labels))
+}
+
+// AddSample adds a sample metrics
+func (s *LabelSink) AddSample(key []string, val float32) {
+	s.inner.AddSampleWithLabels(key, val, s.withLabels(nil))
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *LabelSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.inner.AddSampleWithLabels(key, val, s.withLabels(labels))
+}
+
+// withLabels merges the labels of a call into a copy of the fixed labels,
+// so the inner sinks never share the fixed labels
+func (s *LabelSink) withLabels(labels []Label) []Label {
+	return MergeLabels(s.labels, labels)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelSink(t *testing.T) {
+	m := &MockSink{}
+	s := NewLabelSink(m, []Label{{"host", "web1"}, {"env", "prod"}})
+	emitAll(s)
+
+	fixed := []Label{{"host", "web1"}, {"env", "prod"}}
+	withCall := []Label{{"host", "web1"}, {"env", "prod"}, {"a", "b"}}
+	expected := [][]Label{fixed, withCall, nil, fixed, withCall, fixed, withCall}
+	if !reflect.DeepEqual(m.labels, expected) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+	if !reflect.DeepEqual(m.keys[2], []string{"key", "web1", "prod"}) {
+		t.Fatalf("bad key %v", m.keys[2])
+	}
+
+	// The labels of the caller are not modified
+	labels := make([]Label, 1, 4)
+	labels[0] = Label{"a", "b"}
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, labels)
+	if labels[:2][1].Name != "" {
+		t.Fatalf("caller labels modified %v", labels[:2])
+	}
+
+	// The labels of the call win, and the fixed labels are not shared
+	m = &MockSink{}
+	s = NewLabelSink(m, fixed)
+	s.IncrCounterWithLabels([]string{"counter"}, 1, []Label{{"env", "dev"}})
+	s.IncrCounter([]string{"counter"}, 1)
+	if !reflect.DeepEqual(m.labels[0], []Label{{"host", "web1"}, {"env", "dev"}}) {
+		t.Fatalf("bad labels %v", m.labels[0])
+	}
+	m.labels[1][0].Value = "modified"
+	if s.labels[0].Value != "web1" {
+		t.Fatalf("fixed labels shared with the inner sink")
+	}
+
+	f := &MockFloat64Sink{}
+	NewLabelSink(f, fixed).IncrCounterFloat64([]string{"counter"}, 8)
+	if !reflect.DeepEqual(f.vals64, []float64{8}) || !reflect.DeepEqual(f.labels[0], fixed) {
+		t.Fatalf("bad float64 counter %v %v", f.vals64, f.labels)
+	}
+}
+
+func TestNewLabelSink_EmptyName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for empty label name")
+		}
+	}()
+	NewLabelSink(&MockSink{}, []Label{{"", "web1"}})
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var LabelWithValueTests = []struct {
+	Value    interface{}
+	Encoder  LabelValueEncoder
+	Expected string
+}{
+	{"value", nil, "value"},
+	{42, nil, "42"},
+	{[]int{1, 2}, FmtEncoder{}, "[1 2]"},
+	{"value", StringEncoder{}, "value"},
+	{time.Second, StringEncoder{}, "1s"},
+	{errors.New("failed"), StringEncoder{}, "failed"},
+	{3.5, StringEncoder{}, "3.5"},
+	{map[string]int{"a": 1}, JSONEncoder{}, `{"a":1}`},
+	{"value", JSONEncoder{}, `"value"`},
+	{func() {}, JSONEncoder{}, ""},
+}
+
+func TestLabelWithValue(t *testing.T) {
+	for _, tt := range LabelWithValueTests {
+		l := LabelWithValue("name", tt.Value, tt.Encoder)
+		if l.Name != "name" {
+			t.Fatalf("bad name %s", l.Name)
+		}
+
+		// Functions can't be marshalled, they fallback to their address
+		if tt.Expected == "" {
+			if l.Value == "" {
+				t.Fatalf("expected fallback value for %v", tt.Value)
+			}
+			continue
+		}
+		if l.Value != tt.Expected {
+			t.Fatalf("expected %s got %s", tt.Expected, l.Value)
+		}
+	}
+}
+
+func TestLabelsFromMap(t *testing.T) {
+	labels := LabelsFromMap(map[string]string{"b": "2", "a": "1", "c": "3"})
+	expected := []Label{{"a", "1"}, {"b", "2"}, {"c", "3"}}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("bad labels %v", labels)
+	}
+	if labels := LabelsFromMap(nil); len(labels) != 0 {
+		t.Fatalf("bad labels %v", labels)
+	}
+}
+
+func TestLabelsToMap(t *testing.T) {
+	m := LabelsToMap([]Label{{"a", "1"}, {"b", "2"}, {"a", "3"}})
+	expected := map[string]string{"a": "3", "b": "2"}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("bad map %v", m)
+	}
+}
+
+func TestMergeLabels(t *testing.T) {
+	merged := MergeLabels(
+		[]Label{{"a", "1"}, {"b", "2"}, {"a", "3"}},
+		[]Label{{"c", "4"}, {"b", "5"}},
+	)
+	expected := []Label{{"a", "3"}, {"b", "5"}, {"c", "4"}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("bad labels %v", merged)
+	}
+	if merged := MergeLabels(nil, nil); len(merged) != 0 {
+		t.Fatalf("bad labels %v", merged)
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+// LabelledCounter is a counter handle bound to a key and labels
+type LabelledCounter struct {
+	sink   Sinker
+	key    []string
+	labels []Label
+}
+
+// NewLabelledCounter creates a counter handle emitting to the given sink
+func NewLabelledCounter(sink Sinker, key []string, labels []Label) *LabelledCounter {
+	return &LabelledCounter{sink: sink, key: copyKey(key), labels: copyLabels(labels)}
+}
+
+// Add increases the value of the counter by a given value
+func (c *LabelledCounter) Add(val float32) {
+	c.sink.IncrCounterWithLabels(c.key, val, c.labels)
+}
+
+// LabelledGauge is a gauge handle bound to a key and labels
+type LabelledGauge struct {
+	sink   Sinker
+	key    []string
+	labels []Label
+}
+
+// NewLabelledGauge creates a gauge handle emitting to the given sink
+func NewLabelledGauge(sink Sinker, key []string, labels []Label) *LabelledGauge {
+	return &LabelledGauge{sink: sink, key: copyKey(key), labels: copyLabels(labels)}
+}
+
+// Set sets the value of the gauge
+func (g *LabelledGauge) Set(val float32) {
+	g.sink.SetGaugeWithLabels(g.key, val, g.labels)
+}
+
+// LabelledSample is a sample handle bound to a key and labels
+type LabelledSample struct {
+	sink   Sinker
+	key    []string
+	labels []Label
+}
+
+// NewLabelledSample creates a sample handle emitting to the given sink
+func NewLabelledSample(sink Sinker, key []string, labels []Label) *LabelledSample {
+	return &LabelledSample{sink: sink, key: copyKey(key), labels: copyLabels(labels)}
+}
+
+// Add adds a sample
+func (s *LabelledSample) Add(val float32) {
+	s.sink.AddSampleWithLabels(s.key, val, s.labels)
+}
+
+// copyKey copies a key so handles are not affected by callers reusing it
+func copyKey(key []string) []string {
+	if key == nil {
+		return nil
+	}
+	c := make([]string, len(key))
+	copy(c, key)
+	return c
+}
+
+// copyLabels copies labels so handles are not affected by callers reusing them
+func copyLabels(labels []Label) []Label {
+	if labels == nil {
+		return nil
+	}
+	c := make([]Label, len(labels))
+	copy(c, labels)
+	return c
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelledCounter(t *testing.T) {
+	m := &MockSink{}
+	k := []string{"test"}
+	l := []Label{{"a", "b"}}
+	c := NewLabelledCounter(m, k, l)
+
+	// Handles must not be affected by changes to the passed slices
+	k[0] = "changed"
+	l[0].Value = "changed"
+
+	c.Add(1)
+	c.Add(2)
+
+	if !reflect.DeepEqual(m.keys, [][]string{{"test"}, {"test"}}) {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+	if !reflect.DeepEqual(m.vals, []float32{1, 2}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.labels[0], []Label{{"a", "b"}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestLabelledGauge(t *testing.T) {
+	m := &MockSink{}
+	l := []Label{{"a", "b"}}
+	g := NewLabelledGauge(m, []string{"test"}, l)
+	g.Set(42)
+
+	if !reflect.DeepEqual(m.keys[0], []string{"test"}) || m.vals[0] != 42 {
+		t.Fatalf("bad gauge %v %v", m.keys, m.vals)
+	}
+	if !reflect.DeepEqual(m.labels[0], l) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestLabelledSample(t *testing.T) {
+	m := &MockSink{}
+	s := NewLabelledSample(m, []string{"test"}, nil)
+	s.Add(3)
+
+	if !reflect.DeepEqual(m.keys[0], []string{"test"}) || m.vals[0] != 3 {
+		t.Fatalf("bad sample %v %v", m.keys, m.vals)
+	}
+	if m.labels[0] != nil {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// labelledVec holds the key and label names shared by the Vec types,
+// and the labels of the label value combinations in use
+type labelledVec struct {
+	sink        Sinker
+	key         []string
+	names       []string
+	constLabels []Label
+
+	mu     sync.RWMutex
+	combos map[string][]Label
+}
+
+// VecOption is used to configure a GaugeVec, CounterVec or SampleVec
+type VecOption func(*labelledVec)
+
+// WithConstLabels adds labels with fixed values to every metric,
+// they precede the labels whose values are given on each use
+func WithConstLabels(labels []Label) VecOption {
+	return func(v *labelledVec) {
+		v.constLabels = append(v.constLabels, labels...)
+	}
+}
+
+func (v *labelledVec) init(sink Sinker, key []string, labelNames []string, opts []VecOption) {
+	v.sink = sink
+	v.key = copyKey(key)
+	v.names = copyKey(labelNames)
+	v.combos = make(map[string][]Label)
+	for _, opt := range opts {
+		opt(v)
+	}
+}
+
+// labels pairs the label names with the given values, which must match
+// in number, following the const labels. The labels of each combination
+// are tracked, so they are built once until Reset is called.
+func (v *labelledVec) labels(values []string) ([]Label, error) {
+	if len(values) != len(v.names) {
+		return nil, fmt.Errorf("metrics: %d label values given for %d label names %v", len(values), len(v.names), v.names)
+	}
+
+	combo := strings.Join(values, "\x00")
+	v.mu.RLock()
+	labels, ok := v.combos[combo]
+	v.mu.RUnlock()
+	if ok {
+		return labels, nil
+	}
+
+	labels = make([]Label, len(v.constLabels), len(v.constLabels)+len(values))
+	copy(labels, v.constLabels)
+	for i, value := range values {
+		labels = append(labels, Label{Name: v.names[i], Value: value})
+	}
+
+	v.mu.Lock()
+	v.combos[combo] = labels
+	v.mu.Unlock()
+	return labels, nil
+}
+
+// Reset stops tracking the label value combinations used so far, so
+// those no longer in use are released. The handles already returned
+// keep working. Sinks are not affected: they may keep reporting the
+// series until they expire them, ex: the Prometheus sink, or need the
+// series to be deleted explicitly.
+func (v *labelledVec) Reset() {
+	v.mu.Lock()
+	v.combos = make(map[string][]Label)
+	v.mu.Unlock()
+}
+
+// Len returns the number of label value combinations tracked
+func (v *labelledVec) Len() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return len(v.combos)
+}
+
+// mustLabels is like labels, but panics when the values do not match
+func (v *labelledVec) mustLabels(values []string) []Label {
+	labels, err := v.labels(values)
+	if err != nil {
+		panic(err)
+	}
+	return labels
+}
+
+// GaugeVec binds a gauge key to a fixed set of label names,
+// the label values being given on each use
+type GaugeVec struct {
+	labelledVec
+}
+
+// NewGaugeVec creates a GaugeVec emitting to the given sink
+func NewGaugeVec(sink Sinker, key []string, labelNames []string, opts ...VecOption) *GaugeVec {
+	v := &GaugeVec{}
+	v.init(sink, key, labelNames, opts)
+	return v
+}
+
+// With returns the gauge with the given label values, in the order of
+// the label names. It panics if the number of values does not match.
+func (v *GaugeVec) With(labelValues ...string) *LabelledGauge {
+	return &LabelledGauge{sink: v.sink, key: v.key, labels: v.mustLabels(labelValues)}
+}
+
+// TryWith is like With, but returns an error if the number of values does not match
+func (v *GaugeVec) TryWith(labelValues ...string) (*LabelledGauge, error) {
+	labels, err := v.labels(labelValues)
+	if err != nil {
+		return nil, err
+	}
+	return &LabelledGauge{sink: v.sink, key: v.key, labels: labels}, nil
+}
+
+// CounterVec binds a counter key to a fixed set of label names,
+// the label values being given on each use
+type CounterVec struct {
+	labelledVec
+}
+
+// NewCounterVec creates a CounterVec emitting to the given sink
+func NewCounterVec(sink Sinker, key []string, labelNames []string, opts ...VecOption) *CounterVec {
+	v := &CounterVec{}
+	v.init(sink, key, labelNames, opts)
+	return v
+}
+
+// With returns the counter with the given label values, in the order of
+// the label names. It panics if the number of values does not match.
+func (v *CounterVec) With(labelValues ...string) *LabelledCounter {
+	return &LabelledCounter{sink: v.sink, key: v.key, labels: v.mustLabels(labelValues)}
+}
+
+// TryWith is like With, but returns an error if the number of values does not match
+func (v *CounterVec) TryWith(labelValues ...string) (*LabelledCounter, error) {
+	labels, err := v.labels(labelValues)
+	if err != nil {
+		return nil, err
+	}
+	return &LabelledCounter{sink: v.sink, key: v.key, labels: labels}, nil
+}
+
+// SampleVec binds a sample key to a fixed set of label names,
+// the label values being given on each use
+type SampleVec struct {
+	labelledVec
+}
+
+// NewSampleVec creates a SampleVec emitting to the given sink
+func NewSampleVec(sink Sinker, key []string, labelNames []string, opts ...VecOption) *SampleVec {
+	v := &SampleVec{}
+	v.init(sink, key, labelNames, opts)
+	return v
+}
+
+// With returns the sample with the given label values, in the order of
+// the label names. It panics if the number of values does not match.
+func (v *SampleVec) With(labelValues ...string) *LabelledSample {
+	return &LabelledSample{sink: v.sink, key: v.key, labels: v.mustLabels(labelValues)}
+}
+
+// TryWith is like With, but returns an error if the number of values does not match
+func (v *SampleVec) TryWith(labelValues ...string) (*LabelledSample, error) {
+	labels, err := v.labels(labelValues)
+	if err != nil {
+		return nil, err
+	}
+	return &LabelledSample{sink: v.sink, key: v.key, labels: labels}, nil
+}
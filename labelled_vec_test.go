@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelledVec(t *testing.T) {
+	m := &MockSink{}
+	names := []string{"method", "code"}
+	gauges := NewGaugeVec(m, []string{"gauge"}, names)
+	counters := NewCounterVec(m, []string{"counter"}, names)
+	samples := NewSampleVec(m, []string{"sample"}, names)
+
+	// Vecs must not be affected by changes to the passed slices
+	names[0] = "changed"
+
+	gauges.With("GET", "200").Set(1)
+	counters.With("POST", "201").Add(2)
+	samples.With("PUT", "500").Add(3)
+
+	if !reflect.DeepEqual(m.keys, [][]string{{"gauge"}, {"counter"}, {"sample"}}) {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.labels[1], []Label{{"method", "POST"}, {"code", "201"}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestLabelledVec_Mismatch(t *testing.T) {
+	m := &MockSink{}
+	v := NewCounterVec(m, []string{"counter"}, []string{"method", "code"})
+
+	if _, err := v.TryWith("GET"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := NewGaugeVec(m, nil, nil).TryWith("GET"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if c, err := NewSampleVec(m, nil, []string{"a"}).TryWith("b"); err != nil || c == nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	v.With("GET", "200", "extra")
+}
+
+func TestLabelledVec_ConstLabels(t *testing.T) {
+	m := &MockSink{}
+	env := []Label{{"env", "prod"}}
+	v := NewGaugeVec(m, []string{"gauge"}, []string{"method"}, WithConstLabels(env))
+
+	// The vec must not be affected by changes to the passed slice
+	env[0].Value = "changed"
+
+	v.With("GET").Set(1)
+	v.With("POST").Set(2)
+
+	if !reflect.DeepEqual(m.labels[0], []Label{{"env", "prod"}, {"method", "GET"}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+	if !reflect.DeepEqual(m.labels[1], []Label{{"env", "prod"}, {"method", "POST"}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestLabelledVec_Reset(t *testing.T) {
+	m := &MockSink{}
+	v := NewCounterVec(m, []string{"jobs"}, []string{"job"})
+
+	c := v.With("a")
+	v.With("b").Add(1)
+	v.With("a").Add(1)
+	if v.Len() != 2 {
+		t.Fatalf("bad len %d", v.Len())
+	}
+
+	v.Reset()
+	if v.Len() != 0 {
+		t.Fatalf("bad len %d", v.Len())
+	}
+
+	// Handles keep working after a reset
+	c.Add(2)
+	v.With("c").Add(3)
+	if !reflect.DeepEqual(m.vals, []float32{1, 1, 2, 3}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.labels[2], []Label{{"job", "a"}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+	if v.Len() != 1 {
+		t.Fatalf("bad len %d", v.Len())
+	}
+
+	NewGaugeVec(m, nil, nil).Reset()
+	NewSampleVec(m, nil, nil).Reset()
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -11,6 +12,9 @@ type MetricService struct {
 	MetricServiceConfig
 	lastNumGC uint32
 	Sink      Sinker
+
+	deltaOnce sync.Once
+	deltaSink GaugeDeltaSink
 }
 
 const (
@@ -45,6 +49,38 @@ func (m *MetricService) SetGaugeWithLabels(key []string, val float32, labels []L
 	m.Sink.SetGaugeWithLabels(k, val, labels)
 }
 
+// IncrGauge increases the value of a gauge by a given value
+func (m *MetricService) IncrGauge(key []string, val float32) {
+	m.IncrGaugeWithLabels(key, val, nil)
+}
+
+// IncrGaugeWithLabels increases the value of a gauge by a given value with
+// labels, see AsGaugeDeltaSink for sinks without delta gauges
+func (m *MetricService) IncrGaugeWithLabels(key []string, val float32, labels []Label) {
+	k := m.getKey(key, gaugeType)
+	m.gaugeDeltaSink().IncrGaugeWithLabels(k, val, labels)
+}
+
+// DecrGauge decreases the value of a gauge by a given value
+func (m *MetricService) DecrGauge(key []string, val float32) {
+	m.DecrGaugeWithLabels(key, val, nil)
+}
+
+// DecrGaugeWithLabels decreases the value of a gauge by a given value with labels
+func (m *MetricService) DecrGaugeWithLabels(key []string, val float32, labels []Label) {
+	k := m.getKey(key, gaugeType)
+	m.gaugeDeltaSink().DecrGaugeWithLabels(k, val, labels)
+}
+
+// gaugeDeltaSink returns the delta gauge sink, created once so the
+// adapter of the sinks without delta gauges keeps their values
+func (m *MetricService) gaugeDeltaSink() GaugeDeltaSink {
+	m.deltaOnce.Do(func() {
+		m.deltaSink = AsGaugeDeltaSink(m.Sink)
+	})
+	return m.deltaSink
+}
+
 // EmitKey emits a key value metric
 func (m *MetricService) EmitKey(key []string, val float32) {
 	k := m.getKey(key, keyType)
@@ -62,6 +98,17 @@ func (m *MetricService) IncrCounterWithLabels(key []string, val float32, labels
 	m.Sink.IncrCounterWithLabels(k, val, labels)
 }
 
+// IncrCounterFloat64 increases the value of a counter by a given float64 value
+func (m *MetricService) IncrCounterFloat64(key []string, val float64) {
+	m.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given float64 value with labels
+func (m *MetricService) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	k := m.getKey(key, counterType)
+	AsFloat64Sink(m.Sink).IncrCounterWithLabelsFloat64(k, val, labels)
+}
+
 // AddSample adds a sample metrics
 func (m *MetricService) AddSample(key []string, val float32) {
 	m.AddSampleWithLabels(key, val, nil)
@@ -73,6 +120,13 @@ func (m *MetricService) AddSampleWithLabels(key []string, val float32, labels []
 	m.Sink.AddSampleWithLabels(k, val, labels)
 }
 
+// AddSampleWithExemplar adds a sample metrics with an exemplar,
+// ignored when the sink does not implement ExemplarSink
+func (m *MetricService) AddSampleWithExemplar(key []string, val float32, exemplar map[string]string) {
+	k := m.getKey(key, sampleType)
+	AddSampleWithExemplar(m.Sink, k, val, exemplar)
+}
+
 // MeasureSince measure time since the start time until now
 func (m *MetricService) MeasureSince(key []string, start time.Time) {
 	m.MeasureSinceWithLabels(key, start, nil)
@@ -0,0 +1,306 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// reservoirSize is the maximum number of samples retained per key
+	// on each aggregation window
+	reservoirSize = 1028
+)
+
+// AggregationTier pairs an aggregation window with the sink which
+// receives the metrics aggregated during that window
+type AggregationTier struct {
+	Window time.Duration
+	Sink   Sinker
+}
+
+// MultiLevelSink aggregates metrics over multiple windows, flushing
+// each window to its own sink. It can be used to store metrics in
+// different resolutions (ex: 1s raw, 1m aggregated, 1h aggregated).
+//
+// Gauges and key/value pairs retain the last value, counters are summed
+// and samples are reservoir sampled.
+type MultiLevelSink struct {
+	dropped int64 // accessed atomically, kept first for alignment
+	stopped int32 // accessed atomically, 1 once shut down
+
+	tiers []*aggregationTier
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type aggregationTier struct {
+	window  time.Duration
+	sink    Sinker
+	float64 Float64Sink
+
+	mu       sync.Mutex
+	gauges   map[string]*aggregatedValue
+	points   map[string]*aggregatedValue
+	counters map[string]*aggregatedValue
+	samples  map[string]*aggregatedValue
+}
+
+// aggregatedValue holds the aggregation of a key during a window
+type aggregatedValue struct {
+	key    []string
+	labels []Label
+
+	// value is the last value for gauges and points, and the sum for counters
+	value float64
+
+	// seen is the number of observed samples, used for reservoir sampling
+	seen      int
+	reservoir []float32
+}
+
+// NewMultiLevelSink creates a MultiLevelSink flushing each tier
+// to its sink every tier window
+func NewMultiLevelSink(tiers ...AggregationTier) (*MultiLevelSink, error) {
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("at least one aggregation tier must be provided")
+	}
+
+	s := &MultiLevelSink{
+		stopCh: make(chan struct{}),
+	}
+
+	for _, t := range tiers {
+		if t.Window <= 0 {
+			return nil, fmt.Errorf("aggregation window must be positive, got %s", t.Window)
+		}
+		if t.Sink == nil {
+			return nil, fmt.Errorf("aggregation tier %s has no sink", t.Window)
+		}
+		s.tiers = append(s.tiers, newAggregationTier(t.Window, t.Sink))
+	}
+
+	for _, t := range s.tiers {
+		s.wg.Add(1)
+		go s.run(t)
+	}
+	return s, nil
+}
+
+func newAggregationTier(window time.Duration, sink Sinker) *aggregationTier {
+	t := &aggregationTier{
+		window:  window,
+		sink:    sink,
+		float64: AsFloat64Sink(sink),
+	}
+	t.reset()
+	return t
+}
+
+// Flush forces all tiers to flush their aggregated metrics
+func (s *MultiLevelSink) Flush() {
+	for _, t := range s.tiers {
+		t.flush()
+	}
+}
+
+// Shutdown stops the periodic flushes, flushing any pending aggregation.
+// The metrics recorded afterwards are dropped, counted in DroppedTotal,
+// the first one being logged.
+func (s *MultiLevelSink) Shutdown() {
+	s.stopOnce.Do(func() {
+		atomic.StoreInt32(&s.stopped, 1)
+		close(s.stopCh)
+		s.wg.Wait()
+	})
+}
+
+// DroppedTotal returns the number of metrics recorded after Shutdown
+func (s *MultiLevelSink) DroppedTotal() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// SetGauge sets a value on a gauge
+func (s *MultiLevelSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *MultiLevelSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	if s.dropAfterShutdown(key) {
+		return
+	}
+	hash := aggregationHash(key, labels)
+	for _, t := range s.tiers {
+		t.mu.Lock()
+		t.value(t.gauges, hash, key, labels).value = float64(val)
+		t.mu.Unlock()
+	}
+}
+
+// EmitKey emits a key value metric
+func (s *MultiLevelSink) EmitKey(key []string, val float32) {
+	if s.dropAfterShutdown(key) {
+		return
+	}
+	hash := aggregationHash(key, nil)
+	for _, t := range s.tiers {
+		t.mu.Lock()
+		t.value(t.points, hash, key, nil).value = float64(val)
+		t.mu.Unlock()
+	}
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *MultiLevelSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *MultiLevelSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *MultiLevelSink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *MultiLevelSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	if s.dropAfterShutdown(key) {
+		return
+	}
+	hash := aggregationHash(key, labels)
+	for _, t := range s.tiers {
+		t.mu.Lock()
+		t.value(t.counters, hash, key, labels).value += val
+		t.mu.Unlock()
+	}
+}
+
+// AddSample adds a sample metrics
+func (s *MultiLevelSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *MultiLevelSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	if s.dropAfterShutdown(key) {
+		return
+	}
+	hash := aggregationHash(key, labels)
+	for _, t := range s.tiers {
+		t.mu.Lock()
+		t.value(t.samples, hash, key, labels).sample(val)
+		t.mu.Unlock()
+	}
+}
+
+// dropAfterShutdown reports whether the sink was shut down, counting the
+// dropped metric and logging the first one
+func (s *MultiLevelSink) dropAfterShutdown(key []string) bool {
+	if atomic.LoadInt32(&s.stopped) == 0 {
+		return false
+	}
+	if atomic.AddInt64(&s.dropped, 1) == 1 {
+		log.Printf("[WARN] Dropping metric %v recorded after the multi level sink shut down", key)
+	}
+	return true
+}
+
+// run periodically flushes a tier until the sink is shut down
+func (s *MultiLevelSink) run(t *aggregationTier) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(t.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-s.stopCh:
+			t.flush()
+			return
+		}
+	}
+}
+
+// value returns the aggregated value for a hash, creating it if needed
+// with copies of the key and labels, which the caller may reuse. The tier
+// lock must be held.
+func (t *aggregationTier) value(values map[string]*aggregatedValue, hash string, key []string, labels []Label) *aggregatedValue {
+	v, ok := values[hash]
+	if !ok {
+		v = &aggregatedValue{key: append([]string(nil), key...)}
+		if len(labels) > 0 {
+			v.labels = append([]Label(nil), labels...)
+		}
+		values[hash] = v
+	}
+	return v
+}
+
+func (t *aggregationTier) reset() {
+	t.gauges = make(map[string]*aggregatedValue)
+	t.points = make(map[string]*aggregatedValue)
+	t.counters = make(map[string]*aggregatedValue)
+	t.samples = make(map[string]*aggregatedValue)
+}
+
+// flush emits the current aggregation to the tier sink and starts a new one
+func (t *aggregationTier) flush() {
+	t.mu.Lock()
+	gauges, points, counters, samples := t.gauges, t.points, t.counters, t.samples
+	t.reset()
+	t.mu.Unlock()
+
+	for _, v := range gauges {
+		t.sink.SetGaugeWithLabels(v.key, float32(v.value), v.labels)
+	}
+	for _, v := range points {
+		t.sink.EmitKey(v.key, float32(v.value))
+	}
+	for _, v := range counters {
+		t.float64.IncrCounterWithLabelsFloat64(v.key, v.value, v.labels)
+	}
+	for _, v := range samples {
+		for _, sample := range v.reservoir {
+			t.sink.AddSampleWithLabels(v.key, sample, v.labels)
+		}
+	}
+}
+
+// sample adds a sample to the reservoir using reservoir sampling, so
+// every observed sample has the same probability of being retained
+func (v *aggregatedValue) sample(val float32) {
+	v.seen++
+	if len(v.reservoir) < reservoirSize {
+		v.reservoir = append(v.reservoir, val)
+		return
+	}
+
+	if r := rand.Intn(v.seen); r < reservoirSize {
+		v.reservoir[r] = val
+	}
+}
+
+// aggregationHash identifies a key along with its labels, each part being
+// prefixed by its length so no two keys or labels give the same hash
+func aggregationHash(key []string, labels []Label) string {
+	buf := &bytes.Buffer{}
+	for _, part := range key {
+		fmt.Fprintf(buf, "%d:%s", len(part), part)
+	}
+	for _, label := range labels {
+		fmt.Fprintf(buf, ";%d:%s%d:%s", len(label.Name), label.Name, len(label.Value), label.Value)
+	}
+	return buf.String()
+}
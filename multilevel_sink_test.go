@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultiLevelSink_New(t *testing.T) {
+	if _, err := NewMultiLevelSink(); err == nil {
+		t.Fatalf("expected error with no tiers")
+	}
+	if _, err := NewMultiLevelSink(AggregationTier{Window: 0, Sink: &MockSink{}}); err == nil {
+		t.Fatalf("expected error with zero window")
+	}
+	if _, err := NewMultiLevelSink(AggregationTier{Window: time.Second}); err == nil {
+		t.Fatalf("expected error with nil sink")
+	}
+}
+
+func TestMultiLevelSink_Gauge(t *testing.T) {
+	m1 := &MockSink{}
+	m2 := &MockSink{}
+	s, _ := NewMultiLevelSink(
+		AggregationTier{Window: time.Hour, Sink: m1},
+		AggregationTier{Window: 2 * time.Hour, Sink: m2},
+	)
+	defer s.Shutdown()
+
+	k := []string{"test"}
+	l := []Label{{"a", "b"}}
+	s.SetGaugeWithLabels(k, 1, l)
+	s.SetGaugeWithLabels(k, 2, l)
+	s.SetGaugeWithLabels(k, 3, l)
+	s.Flush()
+
+	for _, m := range []*MockSink{m1, m2} {
+		if len(m.vals) != 1 {
+			t.Fatalf("expected a single gauge, got %v", m.vals)
+		}
+		if !reflect.DeepEqual(m.keys[0], k) {
+			t.Fatalf("key not equal")
+		}
+		if m.vals[0] != 3 {
+			t.Fatalf("expected last value, got %v", m.vals[0])
+		}
+		if !reflect.DeepEqual(m.labels[0], l) {
+			t.Fatalf("labels not equal")
+		}
+	}
+
+	// Flushing again must not emit the previous window
+	s.Flush()
+	if len(m1.vals) != 1 {
+		t.Fatalf("unexpected values %v", m1.vals)
+	}
+}
+
+func TestMultiLevelSink_Hash(t *testing.T) {
+	m := &MockSink{}
+	s, _ := NewMultiLevelSink(AggregationTier{Window: time.Hour, Sink: m})
+	defer s.Shutdown()
+
+	// Keys and labels are not confused once joined
+	s.IncrCounter([]string{"a", "b"}, 1)
+	s.IncrCounter([]string{"a.b"}, 1)
+	s.IncrCounterWithLabels([]string{"c"}, 1, []Label{{"d", "e=f"}})
+	s.IncrCounterWithLabels([]string{"c"}, 1, []Label{{"d=e", "f"}})
+
+	// The key and labels of the caller may be reused
+	key := []string{"reused"}
+	labels := []Label{{"g", "h"}}
+	s.SetGaugeWithLabels(key, 1, labels)
+	key[0], labels[0].Value = "modified", "modified"
+	s.Flush()
+
+	if len(m.vals) != 5 {
+		t.Fatalf("expected 5 series, got %v", m.keys)
+	}
+	if !reflect.DeepEqual(m.keys[0], []string{"reused"}) || !reflect.DeepEqual(m.labels[0], []Label{{"g", "h"}}) {
+		t.Fatalf("bad gauge %v %v", m.keys[0], m.labels[0])
+	}
+}
+
+func TestMultiLevelSink_Counter(t *testing.T) {
+	m := &MockSink{}
+	s, _ := NewMultiLevelSink(AggregationTier{Window: time.Hour, Sink: m})
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"test"}, 1)
+	s.IncrCounter([]string{"test"}, 2)
+	s.IncrCounterWithLabels([]string{"test"}, 5, []Label{{"a", "b"}})
+	s.Flush()
+
+	if len(m.vals) != 2 {
+		t.Fatalf("expected two counters, got %v", m.vals)
+	}
+	for i, l := range m.labels {
+		if l == nil && m.vals[i] != 3 {
+			t.Fatalf("expected sum, got %v", m.vals[i])
+		}
+		if l != nil && m.vals[i] != 5 {
+			t.Fatalf("expected sum, got %v", m.vals[i])
+		}
+	}
+}
+
+func TestMultiLevelSink_Sample(t *testing.T) {
+	m := &MockSink{}
+	s, _ := NewMultiLevelSink(AggregationTier{Window: time.Hour, Sink: m})
+	defer s.Shutdown()
+
+	s.AddSample([]string{"test"}, 3)
+	s.AddSample([]string{"test"}, 1)
+	s.AddSample([]string{"test"}, 2)
+	s.Flush()
+
+	vals := append([]float32{}, m.vals...)
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	if !reflect.DeepEqual(vals, []float32{1, 2, 3}) {
+		t.Fatalf("bad samples %v", vals)
+	}
+}
+
+func TestMultiLevelSink_SampleReservoir(t *testing.T) {
+	m := &MockSink{}
+	s, _ := NewMultiLevelSink(AggregationTier{Window: time.Hour, Sink: m})
+	defer s.Shutdown()
+
+	for i := 0; i < 2*reservoirSize; i++ {
+		s.AddSample([]string{"test"}, float32(i))
+	}
+	s.Flush()
+
+	if len(m.vals) != reservoirSize {
+		t.Fatalf("expected %d samples, got %d", reservoirSize, len(m.vals))
+	}
+}
+
+func TestMultiLevelSink_Shutdown(t *testing.T) {
+	m := &MockSink{}
+	s, _ := NewMultiLevelSink(AggregationTier{Window: time.Hour, Sink: m})
+
+	s.EmitKey([]string{"test"}, 1)
+	s.EmitKey([]string{"test"}, 2)
+	s.Shutdown()
+	s.Shutdown()
+
+	if len(m.vals) != 1 || m.vals[0] != 2 {
+		t.Fatalf("expected pending key flush on shutdown, got %v", m.vals)
+	}
+}
+
+func TestMultiLevelSink_AfterShutdown(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := &MockSink{}
+	s, _ := NewMultiLevelSink(AggregationTier{Window: time.Hour, Sink: m})
+	s.Shutdown()
+
+	s.SetGauge([]string{"gauge"}, 1)
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4)
+	s.Flush()
+
+	if len(m.vals) != 0 {
+		t.Fatalf("metrics recorded after shutdown must be dropped, got %v", m.vals)
+	}
+	if s.DroppedTotal() != 4 {
+		t.Fatalf("bad dropped total %d", s.DroppedTotal())
+	}
+	if n := strings.Count(buf.String(), "recorded after the multi level sink shut down"); n != 1 {
+		t.Fatalf("bad log %s", buf.String())
+	}
+}
+
+func TestMultiLevelSink_Interval(t *testing.T) {
+	m := &MockSink{}
+	s, _ := NewMultiLevelSink(AggregationTier{Window: 10 * time.Millisecond, Sink: m})
+
+	s.IncrCounter([]string{"test"}, 1)
+	time.Sleep(30 * time.Millisecond)
+	s.Shutdown()
+
+	if len(m.vals) != 1 || m.vals[0] != 1 {
+		t.Fatalf("expected periodic flush, got %v", m.vals)
+	}
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import "strings"
+
+// PrefixSink prepends a fixed prefix to the key of every metric sent to
+// an inner sink, ex: "myapp.production" turns the "requests" key into
+// "myapp.production.requests". The prefix parts are prepended as distinct
+// key parts, so each sink joins them with its own separator.
+type PrefixSink struct {
+	inner  Sinker
+	prefix []string
+}
+
+// NewPrefixSink creates a PrefixSink, the prefix is split on "."
+// and its empty parts are ignored
+func NewPrefixSink(inner Sinker, prefix string) *PrefixSink {
+	return &PrefixSink{inner: inner, prefix: splitPrefix(prefix)}
+}
+
+// Unwrap returns the inner sink
+func (s *PrefixSink) Unwrap() Sinker {
+	return s.inner
+}
+
+// Shutdown shuts down the inner sink when it has a Shutdown method,
+// returning its error if it returns one
+func (s *PrefixSink) Shutdown() error {
+	switch sd := s.inner.(type) {
+	case interface{ Shutdown() error }:
+		return sd.Shutdown()
+	case interface{ Shutdown() }:
+		sd.Shutdown()
+	}
+	return nil
+}
+
+// SetGauge sets a value on a gauge
+func (s *PrefixSink) SetGauge(key []string, val float32) {
+	s.inner.SetGauge(s.prefixKey(key), val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *PrefixSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.inner.SetGaugeWithLabels(s.prefixKey(key), val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *PrefixSink) EmitKey(key []string, val float32) {
+	s.inner.EmitKey(s.prefixKey(key), val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *PrefixSink) IncrCounter(key []string, val float32) {
+	s.inner.IncrCounter(s.prefixKey(key), val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *PrefixSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.inner.IncrCounterWithLabels(s.prefixKey(key), val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *PrefixSink) IncrCounterFloat64(key []string, val float64) {
+	AsFloat64Sink(s.inner).IncrCounterFloat64(s.prefixKey(key), val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *PrefixSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(s.prefixKey(key), val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *PrefixSink) AddSample(key []string, val float32) {
+	s.inner.AddSample(s.prefixKey(key), val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *PrefixSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.inner.AddSampleWithLabels(s.prefixKey(key), val, labels)
+}
+
+// prefixKey returns a new key made of the prefix and the key parts
+func (s *PrefixSink) prefixKey(key []string) []string {
+	return prependKey(s.prefix, key)
+}
+
+// splitPrefix splits a dot-separated prefix, ignoring its empty parts
+func splitPrefix(prefix string) []string {
+	var parts []string
+	for _, part := range strings.Split(prefix, ".") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// prependKey returns a new key made of the prefix and the key parts,
+// or the key itself if there is no prefix
+func prependKey(prefix, key []string) []string {
+	if len(prefix) == 0 {
+		return key
+	}
+
+	prefixed := make([]string, 0, len(prefix)+len(key))
+	prefixed = append(prefixed, prefix...)
+	return append(prefixed, key...)
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPrefixSink(t *testing.T) {
+	m := &MockSink{}
+	s := NewPrefixSink(m, "myapp..production")
+	emitAll(s)
+	s.IncrCounterFloat64([]string{"counter"}, 8)
+
+	if len(m.keys) != 8 {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+	for i, key := range m.keys {
+		if len(key) != 3 || key[0] != "myapp" || key[1] != "production" {
+			t.Fatalf("bad key %d %v", i, key)
+		}
+	}
+	if !reflect.DeepEqual(m.labels[1], []Label{{"a", "b"}}) || m.labels[0] != nil {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+
+	// The key passed by the caller must not be modified
+	key := make([]string, 1, 4)
+	key[0] = "gauge"
+	s.SetGauge(key, 1)
+	if key[0] != "gauge" || !reflect.DeepEqual(m.keys[8], []string{"myapp", "production", "gauge"}) {
+		t.Fatalf("bad key %v %v", key, m.keys[8])
+	}
+
+	m = &MockSink{}
+	NewPrefixSink(m, "").SetGauge([]string{"gauge"}, 1)
+	if !reflect.DeepEqual(m.keys[0], []string{"gauge"}) {
+		t.Fatalf("bad key %v", m.keys[0])
+	}
+}
+
+func TestPrefixSink_Shutdown(t *testing.T) {
+	failing := &MockShutdownSink{err: errors.New("flush failed")}
+	s := NewPrefixSink(failing, "myapp")
+	if err := s.Shutdown(); err == nil || !failing.shutdown {
+		t.Fatalf("expected the inner shutdown error, got %v", err)
+	}
+	if s.Unwrap() != Sinker(failing) {
+		t.Fatalf("bad inner sink %T", s.Unwrap())
+	}
+
+	void := &MockVoidShutdownSink{}
+	if err := NewPrefixSink(void, "myapp").Shutdown(); err != nil || !void.shutdown {
+		t.Fatalf("expected the inner sink shut down, got %v", err)
+	}
+	if err := NewPrefixSink(&MockSink{}, "myapp").Shutdown(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// FanoutSink shuts down the prefixed members
+	void = &MockVoidShutdownSink{}
+	if err := (FanoutSink{NewPrefixSink(void, "myapp")}).Shutdown(); err != nil || !void.shutdown {
+		t.Fatalf("expected the prefixed member shut down, got %v", err)
+	}
+}
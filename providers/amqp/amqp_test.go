@@ -0,0 +1,171 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type fakeBroker struct {
+	mu       sync.Mutex
+	dials    int
+	failures int
+	messages []amqp.Publishing
+	keys     []string
+}
+
+func (b *fakeBroker) dial(url string) (publisher, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dials++
+	return &fakePublisher{broker: b}, nil
+}
+
+func (b *fakeBroker) published() []amqp.Publishing {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]amqp.Publishing(nil), b.messages...)
+}
+
+type fakePublisher struct {
+	broker *fakeBroker
+}
+
+func (p *fakePublisher) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	p.broker.mu.Lock()
+	defer p.broker.mu.Unlock()
+	if p.broker.failures > 0 {
+		p.broker.failures--
+		return errors.New("connection reset")
+	}
+	p.broker.messages = append(p.broker.messages, msg)
+	p.broker.keys = append(p.broker.keys, exchange+"/"+key)
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	return nil
+}
+
+func withBroker(t *testing.T, b *fakeBroker) {
+	orig := dial
+	dial = b.dial
+	t.Cleanup(func() { dial = orig })
+}
+
+func decode(t *testing.T, msg amqp.Publishing) []event {
+	var events []event
+	if err := json.Unmarshal(msg.Body, &events); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return events
+}
+
+func TestSink(t *testing.T) {
+	b := &fakeBroker{}
+	withBroker(t, b)
+
+	s, err := NewSink("amqp://localhost", "metrics", "app", WithBatchSize(3), WithDurable())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s.SetGaugeWithLabels([]string{"foo", "bar"}, 1.5, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.AddSample([]string{"sample"}, 3)
+	s.EmitKey([]string{"key"}, 4)
+	s.Shutdown()
+
+	messages := b.published()
+	if len(messages) != 2 {
+		t.Fatalf("bad messages: %d", len(messages))
+	}
+	if b.keys[0] != "metrics/app" {
+		t.Fatalf("bad routing: %s", b.keys[0])
+	}
+	if messages[0].DeliveryMode != amqp.Persistent || messages[0].ContentType != "application/json" {
+		t.Fatalf("bad message: %v", messages[0])
+	}
+
+	events := decode(t, messages[0])
+	if len(events) != 3 {
+		t.Fatalf("bad batch: %v", events)
+	}
+	if events[0].Name != "foo.bar" || events[0].Type != "gauge" || events[0].Value != 1.5 || events[0].Labels["a"] != "b" {
+		t.Fatalf("bad event: %v", events[0])
+	}
+	if events[1].Type != "counter" || events[2].Type != "sample" {
+		t.Fatalf("bad events: %v", events)
+	}
+	if events = decode(t, messages[1]); len(events) != 1 || events[0].Type != "key" {
+		t.Fatalf("bad events: %v", events)
+	}
+}
+
+func TestSink_Reconnect(t *testing.T) {
+	b := &fakeBroker{failures: 1}
+	withBroker(t, b)
+
+	s, err := NewSink("amqp://localhost", "metrics", "app",
+		WithFlushInterval(10*time.Millisecond), WithReconnectDelay(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s.IncrCounter([]string{"counter"}, 1)
+
+	deadline := time.Now().Add(time.Second)
+	for len(b.published()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.Shutdown()
+
+	messages := b.published()
+	if len(messages) != 1 || len(decode(t, messages[0])) != 1 {
+		t.Fatalf("bad messages: %v", messages)
+	}
+	if b.dials != 2 {
+		t.Fatalf("bad dials: %d", b.dials)
+	}
+	if s.Dropped() != 0 {
+		t.Fatalf("bad dropped: %d", s.Dropped())
+	}
+}
+
+func TestNewSink_Errors(t *testing.T) {
+	if _, err := NewSink("http://localhost", "metrics", "app"); err == nil {
+		t.Fatalf("expected url error")
+	}
+	if _, err := NewSink("amqp://localhost", "metrics", "app", WithBatchSize(0)); err == nil {
+		t.Fatalf("expected batch size error")
+	}
+}
+
+func TestSink_UnencodableValue(t *testing.T) {
+	b := &fakeBroker{}
+	withBroker(t, b)
+
+	s, err := NewSink("amqp://localhost", "metrics", "app", WithReconnectDelay(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s.AddSample([]string{"sample"}, float32(math.NaN()))
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Shutdown()
+
+	messages := b.published()
+	if len(messages) != 1 {
+		t.Fatalf("bad messages: %v", messages)
+	}
+	if events := decode(t, messages[0]); len(events) != 1 || events[0].Name != "counter" || events[0].Value != 1 {
+		t.Fatalf("bad events: %v", events)
+	}
+	if s.Dropped() != 1 {
+		t.Fatalf("bad dropped: %d", s.Dropped())
+	}
+}
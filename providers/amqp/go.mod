@@ -0,0 +1,12 @@
+module github.com/hugoluchessi/go-metrics/providers/amqp
+
+go 1.21
+
+require (
+	github.com/hugoluchessi/go-metrics v0.0.0
+	github.com/rabbitmq/amqp091-go v1.10.0
+)
+
+require golang.org/x/time v0.3.0 // indirect
+
+replace github.com/hugoluchessi/go-metrics => ../..
@@ -0,0 +1,214 @@
+package appsignal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultEndpoint is the AppSignal Push API metrics endpoint
+	DefaultEndpoint = "https://push.appsignal.com/1/metrics"
+
+	gaugeType        = "gauge"
+	counterType      = "counter"
+	distributionType = "distribution"
+)
+
+// Sink provides a MetricSink that pushes metrics to AppSignal's
+// Push API, encoded as newline delimited JSON. Labels become tags.
+type Sink struct {
+	endpoint string
+	config   push.Config
+	client   *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithEndpoint overrides the Push API endpoint
+func WithEndpoint(endpoint string) Option {
+	return func(s *Sink) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// metric is the AppSignal representation of a metric
+type metric struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"metric_type"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// NewSink is used to create a new Sink
+func NewSink(pushAPIKey, appName, environment string, opts ...Option) (*Sink, error) {
+	if pushAPIKey == "" {
+		return nil, fmt.Errorf("push api key must be provided")
+	}
+
+	s := &Sink{
+		endpoint: DefaultEndpoint,
+		config: push.Config{
+			Name:        "appsignal",
+			ContentType: "application/x-ndjson",
+			Encode:      encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("api_key", pushAPIKey)
+	q.Set("name", appName)
+	q.Set("environment", environment)
+	u.RawQuery = q.Encode()
+	s.config.URL = u.String()
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+// since AppSignal has no key/value metric type
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(distributionType, key, float64(val), labels)
+}
+
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	m := metric{
+		Name:      s.flattenKey(key),
+		Type:      metricType,
+		Value:     val,
+		Timestamp: time.Now().Unix(),
+	}
+	if len(labels) > 0 {
+		m.Tags = make(map[string]string, len(labels))
+		for _, label := range labels {
+			m.Tags[label.Name] = label.Value
+		}
+	}
+	s.client.Push(m)
+}
+
+// Flattens the key for formatting, removes spaces
+func (s *Sink) flattenKey(parts []string) string {
+	return strings.Replace(strings.Join(parts, "."), " ", "_", -1)
+}
+
+// encode formats the metrics as newline delimited JSON
+func encode(records []interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,148 @@
+package appsignal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_MissingKey(t *testing.T) {
+	if _, err := NewSink("", "app", "production"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestFlattenKey(t *testing.T) {
+	s := &Sink{}
+	if flat := s.flattenKey([]string{"a b", "c"}); flat != "a_b.c" {
+		t.Fatalf("bad flat %s", flat)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var query url.Values
+	var body []byte
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("key", "app", "production", WithEndpoint(srv.URL), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4)
+	s.Flush()
+
+	if query.Get("api_key") != "key" || query.Get("name") != "app" || query.Get("environment") != "production" {
+		t.Fatalf("bad query %v", query)
+	}
+	if contentType != "application/x-ndjson" {
+		t.Fatalf("bad content type %s", contentType)
+	}
+
+	expected := []metric{
+		{Name: "gauge.val", Type: "gauge", Value: 1, Tags: map[string]string{"a": "b"}},
+		{Name: "key", Type: "gauge", Value: 2},
+		{Name: "counter", Type: "counter", Value: 3},
+		{Name: "sample", Type: "distribution", Value: 4},
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	i := 0
+	for ; scanner.Scan(); i++ {
+		var m metric
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			t.Fatalf("bad line %s: %v", scanner.Text(), err)
+		}
+		if m.Timestamp == 0 {
+			t.Fatalf("missing timestamp")
+		}
+		e := expected[i]
+		if m.Name != e.Name || m.Type != e.Type || m.Value != e.Value || len(m.Tags) != len(e.Tags) {
+			t.Fatalf("expected %v got %v", e, m)
+		}
+		for k, v := range e.Tags {
+			if m.Tags[k] != v {
+				t.Fatalf("bad tags %v", m.Tags)
+			}
+		}
+	}
+	if i != len(expected) {
+		t.Fatalf("expected %d lines got %d", len(expected), i)
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("key", "app", "production", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("key", "app", "production", WithEndpoint(srv.URL),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
+
+func TestSink_UnreachableEndpoint(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	// Nothing listens on the closed listener address
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	list.Close()
+
+	s, err := NewSink("secret-key", "app", "production",
+		WithEndpoint("http://"+list.Addr().String()), WithFlushInterval(time.Hour), WithMaxRetries(-1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if out := buf.String(); !strings.Contains(out, "Error pushing metrics to appsignal") || strings.Contains(out, "secret-key") {
+		t.Fatalf("bad log %q", out)
+	}
+}
@@ -0,0 +1,209 @@
+package cloudflare
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultBaseURL is the Cloudflare API base URL
+	DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+	gaugeType   = "gauge"
+	keyType     = "kv"
+	counterType = "counter"
+	sampleType  = "sample"
+)
+
+// Sink provides a MetricSink that pushes metrics as events to a
+// Cloudflare Workers Analytics Engine dataset. Events are indexed by
+// the metric name, the first blob holds the metric type and the
+// following ones the labels as name=value, sorted by name. The first
+// double holds the value and the second the unix timestamp.
+type Sink struct {
+	baseURL string
+	config  push.Config
+	client  *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithBaseURL overrides the Cloudflare API base URL
+func WithBaseURL(baseURL string) Option {
+	return func(s *Sink) {
+		s.baseURL = baseURL
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// event is an Analytics Engine data point
+type event struct {
+	Indexes []string  `json:"indexes"`
+	Blobs   []string  `json:"blobs"`
+	Doubles []float64 `json:"doubles"`
+}
+
+// NewSink is used to create a new Sink
+func NewSink(accountID, datasetName, apiToken string, opts ...Option) (*Sink, error) {
+	if accountID == "" || datasetName == "" {
+		return nil, fmt.Errorf("account id and dataset name must be provided")
+	}
+	if apiToken == "" {
+		return nil, fmt.Errorf("api token must be provided")
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+apiToken)
+
+	s := &Sink{
+		baseURL: DefaultBaseURL,
+		config: push.Config{
+			Name:        "cloudflare",
+			ContentType: "application/json",
+			Header:      header,
+			Encode:      encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.config.URL = fmt.Sprintf("%s/accounts/%s/analytics_engine/datasets/%s/events",
+		strings.TrimSuffix(s.baseURL, "/"), url.PathEscape(accountID), url.PathEscape(datasetName))
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(keyType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(sampleType, key, float64(val), labels)
+}
+
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	blobs := make([]string, 0, len(labels)+1)
+	blobs = append(blobs, metricType)
+
+	sorted := make([]metrics.Label, len(labels))
+	copy(sorted, labels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, label := range sorted {
+		blobs = append(blobs, label.Name+"="+label.Value)
+	}
+
+	s.client.Push(event{
+		Indexes: []string{s.flattenKey(key)},
+		Blobs:   blobs,
+		Doubles: []float64{val, float64(time.Now().Unix())},
+	})
+}
+
+// Flattens the key for formatting, removes spaces
+func (s *Sink) flattenKey(parts []string) string {
+	return strings.Replace(strings.Join(parts, "."), " ", "_", -1)
+}
+
+// encode formats the events as a JSON array
+func encode(records []interface{}) ([]byte, error) {
+	return json.Marshal(records)
+}
@@ -0,0 +1,74 @@
+package cloudflare
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestNewSink_Validation(t *testing.T) {
+	if _, err := NewSink("", "dataset", "token"); err == nil {
+		t.Fatalf("expected error without account")
+	}
+	if _, err := NewSink("account", "dataset", ""); err == nil {
+		t.Fatalf("expected error without token")
+	}
+}
+
+func TestSink(t *testing.T) {
+	var path, auth string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("account", "dataset", "token", WithBaseURL(srv.URL+"/client/v4"), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "z", Value: "1"}, {Name: "a", Value: "2"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.AddSample([]string{"sample"}, 3)
+	s.Flush()
+
+	if path != "/client/v4/accounts/account/analytics_engine/datasets/dataset/events" {
+		t.Fatalf("bad path %s", path)
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("bad authorization %s", auth)
+	}
+
+	var events []event
+	if err := json.Unmarshal(body, &events); err != nil {
+		t.Fatalf("bad body %s: %v", body, err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("bad events %v", events)
+	}
+
+	expected := []event{
+		{Indexes: []string{"gauge.val"}, Blobs: []string{"gauge", "a=2", "z=1"}, Doubles: []float64{1}},
+		{Indexes: []string{"counter"}, Blobs: []string{"counter"}, Doubles: []float64{2}},
+		{Indexes: []string{"sample"}, Blobs: []string{"sample"}, Doubles: []float64{3}},
+	}
+	for i, e := range expected {
+		got := events[i]
+		if !reflect.DeepEqual(got.Indexes, e.Indexes) || !reflect.DeepEqual(got.Blobs, e.Blobs) {
+			t.Fatalf("expected %v got %v", e, got)
+		}
+		if len(got.Doubles) != 2 || got.Doubles[0] != e.Doubles[0] || got.Doubles[1] == 0 {
+			t.Fatalf("bad doubles %v", got.Doubles)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/hugoluchessi/go-metrics/providers/cloudwatch/logs
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.30.3
+	github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs v1.37.3
+	github.com/hugoluchessi/go-metrics v0.0.0
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.6.3 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.15 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.15 // indirect
+	github.com/aws/smithy-go v1.20.3 // indirect
+	golang.org/x/time v0.3.0 // indirect
+)
+
+replace github.com/hugoluchessi/go-metrics => ../../..
@@ -0,0 +1,347 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+const (
+	// DefaultFlushInterval is the interval pending events are sent at
+	DefaultFlushInterval = 5 * time.Second
+
+	// DefaultQueueSize is the number of events buffered before dropping
+	DefaultQueueSize = 4096
+
+	// maxBatchEvents is the maximum number of events of a PutLogEvents call
+	maxBatchEvents = 10000
+
+	// maxBatchBytes is the maximum size of a PutLogEvents call, each event
+	// counting its message length plus eventOverhead bytes
+	maxBatchBytes = 1048576
+	eventOverhead = 26
+
+	// maxBatchSpan is the maximum time span between the events of a call
+	maxBatchSpan = 24 * time.Hour
+
+	gaugeType   = "gauge"
+	counterType = "counter"
+	sampleType  = "sample"
+)
+
+// API is the part of the CloudWatch Logs client used by the Sink,
+// satisfied by *cloudwatchlogs.Client
+type API interface {
+	PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error)
+	CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error)
+}
+
+// Sink provides a MetricSink that writes metrics as structured JSON log
+// events to a CloudWatch Logs stream, to be queried with Logs Insights,
+// ex: "stats sum(value) by bin(1m) | filter name = 'api.requests'".
+// Labels are nested under "labels", queried as labels.<name>.
+type Sink struct {
+	api     API
+	group   string
+	stream  string
+	timeout time.Duration
+
+	flushInterval time.Duration
+	queueSize     int
+	createStream  bool
+
+	queue    chan types.InputLogEvent
+	flushCh  chan chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithFlushInterval sets the interval events are sent at,
+// defaults to DefaultFlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.flushInterval = interval
+	}
+}
+
+// WithQueueSize sets the number of events buffered, defaults to DefaultQueueSize
+func WithQueueSize(size int) Option {
+	return func(s *Sink) {
+		s.queueSize = size
+	}
+}
+
+// WithCreateLogStream creates the log stream when creating the Sink,
+// an already existing stream is not an error. The log group must exist.
+func WithCreateLogStream() Option {
+	return func(s *Sink) {
+		s.createStream = true
+	}
+}
+
+// WithTimeout sets the timeout of each API call, defaults to 10 seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Sink) {
+		s.timeout = timeout
+	}
+}
+
+// event is the JSON representation of a metric in the log events
+type event struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NewSink is used to create a new Sink writing to the given log group and
+// stream, using a client such as cloudwatchlogs.NewFromConfig(cfg)
+func NewSink(api API, logGroup, logStream string, opts ...Option) (*Sink, error) {
+	if api == nil {
+		return nil, fmt.Errorf("cloudwatch logs client must be provided")
+	}
+	if logGroup == "" || logStream == "" {
+		return nil, fmt.Errorf("log group and log stream must be provided")
+	}
+
+	s := &Sink{
+		api:           api,
+		group:         logGroup,
+		stream:        logStream,
+		timeout:       10 * time.Second,
+		flushInterval: DefaultFlushInterval,
+		queueSize:     DefaultQueueSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.flushInterval <= 0 {
+		return nil, fmt.Errorf("flush interval must be positive, got %s", s.flushInterval)
+	}
+	if s.queueSize <= 0 {
+		return nil, fmt.Errorf("queue size must be positive, got %d", s.queueSize)
+	}
+
+	if s.createStream {
+		if err := s.createLogStream(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.queue = make(chan types.InputLogEvent, s.queueSize)
+	s.flushCh = make(chan chan struct{})
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+// Flush sends all the queued events, blocking until they are sent
+func (s *Sink) Flush() {
+	done := make(chan struct{})
+	select {
+	case s.flushCh <- done:
+		<-done
+	case <-s.doneCh:
+	}
+}
+
+// Shutdown sends the queued events and stops sending
+func (s *Sink) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+	<-s.doneCh
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, written as a gauge
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(sampleType, key, float64(val), labels)
+}
+
+// Encodes the metric and queues it, dropping it if the queue is full
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	e := event{
+		Name:  strings.Join(key, "."),
+		Type:  metricType,
+		Value: val,
+	}
+	if len(labels) > 0 {
+		e.Labels = make(map[string]string, len(labels))
+		for _, label := range labels {
+			e.Labels[label.Name] = label.Value
+		}
+	}
+
+	message, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[ERR] Error encoding metric %s for CloudWatch Logs! Err: %s", e.Name, err)
+		return
+	}
+
+	select {
+	case s.queue <- types.InputLogEvent{
+		Message:   aws.String(string(message)),
+		Timestamp: aws.Int64(time.Now().UnixNano() / int64(time.Millisecond)),
+	}:
+	default:
+	}
+}
+
+func (s *Sink) run() {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var pending []types.InputLogEvent
+	drain := func() {
+		for {
+			select {
+			case e := <-s.queue:
+				pending = append(pending, e)
+			default:
+				s.send(pending)
+				pending = nil
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case e := <-s.queue:
+			pending = append(pending, e)
+			if len(pending) >= maxBatchEvents {
+				s.send(pending)
+				pending = nil
+			}
+		case <-ticker.C:
+			s.send(pending)
+			pending = nil
+		case done := <-s.flushCh:
+			drain()
+			close(done)
+		case <-s.stopCh:
+			drain()
+			return
+		}
+	}
+}
+
+// send writes the events, split in batches within the PutLogEvents limits
+func (s *Sink) send(events []types.InputLogEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	// Events of a call must be in chronological order
+	sort.SliceStable(events, func(i, j int) bool {
+		return *events[i].Timestamp < *events[j].Timestamp
+	})
+
+	for _, batch := range batches(events) {
+		if err := s.put(batch); err != nil {
+			log.Printf("[ERR] Error writing %d metrics to CloudWatch Logs! Err: %s", len(batch), err)
+		}
+	}
+}
+
+// batches splits the sorted events in batches within the PutLogEvents
+// limits on the number of events, size and time span
+func batches(events []types.InputLogEvent) [][]types.InputLogEvent {
+	var result [][]types.InputLogEvent
+	start, size := 0, 0
+	for i, e := range events {
+		eventSize := len(*e.Message) + eventOverhead
+		span := time.Duration(*e.Timestamp-*events[start].Timestamp) * time.Millisecond
+		if i > start && (i-start >= maxBatchEvents || size+eventSize > maxBatchBytes || span >= maxBatchSpan) {
+			result = append(result, events[start:i])
+			start, size = i, 0
+		}
+		size += eventSize
+	}
+	return append(result, events[start:])
+}
+
+func (s *Sink) put(batch []types.InputLogEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	_, err := s.api.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(s.group),
+		LogStreamName: aws.String(s.stream),
+		LogEvents:     batch,
+	})
+	return err
+}
+
+func (s *Sink) createLogStream() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	_, err := s.api.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(s.group),
+		LogStreamName: aws.String(s.stream),
+	})
+	var exists *types.ResourceAlreadyExistsException
+	if err != nil && !errors.As(err, &exists) {
+		return fmt.Errorf("error creating log stream %s: %s", s.stream, err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// fakeAPI records the PutLogEvents calls
+type fakeAPI struct {
+	mu        sync.Mutex
+	puts      []*cloudwatchlogs.PutLogEventsInput
+	created   []string
+	createErr error
+}
+
+func (f *fakeAPI) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts = append(f.puts, params)
+	return &cloudwatchlogs.PutLogEventsOutput{}, nil
+}
+
+func (f *fakeAPI) CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created = append(f.created, *params.LogStreamName)
+	return &cloudwatchlogs.CreateLogStreamOutput{}, f.createErr
+}
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink(nil, "group", "stream"); err == nil {
+		t.Fatalf("expected error for missing client")
+	}
+	if _, err := NewSink(&fakeAPI{}, "", "stream"); err == nil {
+		t.Fatalf("expected error for missing group")
+	}
+	if _, err := NewSink(&fakeAPI{}, "group", "stream", WithFlushInterval(0)); err == nil {
+		t.Fatalf("expected error for invalid flush interval")
+	}
+}
+
+func TestNewSink_CreateLogStream(t *testing.T) {
+	api := &fakeAPI{createErr: &types.ResourceAlreadyExistsException{}}
+	s, err := NewSink(api, "group", "stream", WithCreateLogStream())
+	if err != nil {
+		t.Fatalf("existing stream must not fail: %v", err)
+	}
+	s.Shutdown()
+	if len(api.created) != 1 || api.created[0] != "stream" {
+		t.Fatalf("bad created %v", api.created)
+	}
+
+	api = &fakeAPI{createErr: errors.New("denied")}
+	if _, err := NewSink(api, "group", "stream", WithCreateLogStream()); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestSink(t *testing.T) {
+	api := &fakeAPI{}
+	s, err := NewSink(api, "group", "stream", WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounterFloat64([]string{"counter"}, 0.1)
+	s.AddSample([]string{"sample"}, 4)
+	s.Flush()
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.puts) != 1 {
+		t.Fatalf("bad puts %d", len(api.puts))
+	}
+	put := api.puts[0]
+	if *put.LogGroupName != "group" || *put.LogStreamName != "stream" {
+		t.Fatalf("bad destination %s %s", *put.LogGroupName, *put.LogStreamName)
+	}
+
+	expected := []event{
+		{Name: "gauge.val", Type: "gauge", Value: 1, Labels: map[string]string{"a": "b"}},
+		{Name: "key", Type: "gauge", Value: 2},
+		{Name: "counter", Type: "counter", Value: 0.1},
+		{Name: "sample", Type: "sample", Value: 4},
+	}
+	if len(put.LogEvents) != len(expected) {
+		t.Fatalf("bad events %v", put.LogEvents)
+	}
+	for i, e := range expected {
+		var got event
+		if err := json.Unmarshal([]byte(*put.LogEvents[i].Message), &got); err != nil {
+			t.Fatalf("bad message %s", *put.LogEvents[i].Message)
+		}
+		if got.Name != e.Name || got.Type != e.Type || got.Value != e.Value || got.Labels["a"] != e.Labels["a"] {
+			t.Fatalf("expected %v got %v", e, got)
+		}
+		if *put.LogEvents[i].Timestamp == 0 {
+			t.Fatalf("missing timestamp")
+		}
+	}
+}
+
+func TestSink_ShutdownFlushes(t *testing.T) {
+	api := &fakeAPI{}
+	s, _ := NewSink(api, "group", "stream", WithFlushInterval(time.Hour))
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Shutdown()
+	s.Shutdown()
+
+	if len(api.puts) != 1 || len(api.puts[0].LogEvents) != 1 {
+		t.Fatalf("pending events must be sent on shutdown %v", api.puts)
+	}
+}
+
+func newEvent(message string, ts int64) types.InputLogEvent {
+	return types.InputLogEvent{Message: aws.String(message), Timestamp: aws.Int64(ts)}
+}
+
+func TestBatches(t *testing.T) {
+	// Split on size
+	big := strings.Repeat("x", maxBatchBytes/2)
+	events := []types.InputLogEvent{newEvent(big, 1), newEvent(big, 2), newEvent("small", 3)}
+	if b := batches(events); len(b) != 2 || len(b[0]) != 1 || len(b[1]) != 2 {
+		t.Fatalf("bad size batches %v", b)
+	}
+
+	// Split on count
+	events = make([]types.InputLogEvent, maxBatchEvents+1)
+	for i := range events {
+		events[i] = newEvent("e", int64(i))
+	}
+	if b := batches(events); len(b) != 2 || len(b[0]) != maxBatchEvents {
+		t.Fatalf("bad count batches %d", len(b))
+	}
+
+	// Split on time span
+	day := int64(maxBatchSpan / time.Millisecond)
+	events = []types.InputLogEvent{newEvent("a", 0), newEvent("b", day-1), newEvent("c", day)}
+	if b := batches(events); len(b) != 2 || len(b[0]) != 2 {
+		t.Fatalf("bad span batches %v", b)
+	}
+}
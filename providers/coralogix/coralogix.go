@@ -0,0 +1,121 @@
+package coralogix
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics/providers/opentelemetry/sdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const (
+	// DefaultEndpoint is the Coralogix OTLP ingress endpoint
+	DefaultEndpoint = "ingress.coralogix.com:443"
+
+	// DefaultExportInterval is the interval metrics are exported at
+	DefaultExportInterval = 10 * time.Second
+
+	// apiKeyHeader is the gRPC metadata holding the API key
+	apiKeyHeader = "CX-Api-Key"
+)
+
+// Sink provides a MetricSink that sends metrics to Coralogix using
+// OTLP over gRPC. Metrics are recorded on an OpenTelemetry SDK meter
+// provider, see the providers/opentelemetry/sdk package, and tagged
+// with the Coralogix application and subsystem names.
+type Sink struct {
+	*sdk.Sink
+
+	provider *sdkmetric.MeterProvider
+}
+
+type config struct {
+	endpoint       string
+	insecure       bool
+	exportInterval time.Duration
+}
+
+// Option is used to configure a Sink
+type Option func(*config)
+
+// WithEndpoint overrides the OTLP endpoint, as host:port, ex: to use
+// the ingress of another Coralogix domain
+func WithEndpoint(endpoint string) Option {
+	return func(c *config) {
+		c.endpoint = endpoint
+	}
+}
+
+// WithInsecure disables TLS on the gRPC connection
+func WithInsecure() Option {
+	return func(c *config) {
+		c.insecure = true
+	}
+}
+
+// WithExportInterval sets the interval metrics are exported at
+func WithExportInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.exportInterval = interval
+	}
+}
+
+// NewSink is used to create a new Sink. The API key is sent on the
+// CX-Api-Key metadata of every gRPC request.
+func NewSink(apiKey, appName, subsystem string, opts ...Option) (*Sink, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("api key must be provided")
+	}
+	if appName == "" || subsystem == "" {
+		return nil, fmt.Errorf("application and subsystem names must be provided")
+	}
+
+	c := &config{
+		endpoint:       DefaultEndpoint,
+		exportInterval: DefaultExportInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	exporterOpts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(c.endpoint),
+		otlpmetricgrpc.WithHeaders(map[string]string{apiKeyHeader: apiKey}),
+	}
+	if c.insecure {
+		exporterOpts = append(exporterOpts, otlpmetricgrpc.WithInsecure())
+	}
+	exporter, err := otlpmetricgrpc.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", appName),
+		attribute.String("cx.application.name", appName),
+		attribute.String("cx.subsystem.name", subsystem),
+	)
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(c.exportInterval))),
+	)
+
+	return &Sink{
+		Sink:     sdk.NewSink(provider),
+		provider: provider,
+	}, nil
+}
+
+// Flush exports all the pending metrics
+func (s *Sink) Flush() error {
+	return s.provider.ForceFlush(context.Background())
+}
+
+// Shutdown is used to export the pending metrics and stop exporting
+func (s *Sink) Shutdown() error {
+	return s.provider.Shutdown(context.Background())
+}
@@ -0,0 +1,84 @@
+package coralogix
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type collector struct {
+	collectorpb.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	md       metadata.MD
+	requests []*collectorpb.ExportMetricsServiceRequest
+}
+
+func (c *collector) Export(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.md, _ = metadata.FromIncomingContext(ctx)
+	c.requests = append(c.requests, req)
+	return &collectorpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("", "app", "api"); err == nil {
+		t.Fatalf("expected error for missing api key")
+	}
+	if _, err := NewSink("key", "", "api"); err == nil {
+		t.Fatalf("expected error for missing application name")
+	}
+}
+
+func TestSink(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	c := &collector{}
+	srv := grpc.NewServer()
+	collectorpb.RegisterMetricsServiceServer(srv, c)
+	go srv.Serve(list)
+	defer srv.Stop()
+
+	s, err := NewSink("key", "app", "api", WithEndpoint(list.Addr().String()), WithInsecure())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	s.IncrCounter([]string{"counter"}, 1)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key := c.md.Get("cx-api-key"); len(key) != 1 || key[0] != "key" {
+		t.Fatalf("bad api key %v", c.md)
+	}
+	if len(c.requests) == 0 {
+		t.Fatalf("no metrics exported")
+	}
+
+	rm := c.requests[0].ResourceMetrics[0]
+	attrs := make(map[string]string)
+	for _, kv := range rm.Resource.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	if attrs["cx.application.name"] != "app" || attrs["cx.subsystem.name"] != "api" {
+		t.Fatalf("bad resource %v", attrs)
+	}
+	if name := rm.ScopeMetrics[0].Metrics[0].Name; name != "counter" {
+		t.Fatalf("bad metric %s", name)
+	}
+}
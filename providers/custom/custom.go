@@ -0,0 +1,384 @@
+// Package custom is an annotated example of a third-party sink. It sends
+// metrics as text lines ("name|type|value|label=value,...") over TCP and is
+// meant to be copied as the starting point of a new provider: every part of
+// it shows a practice the providers of this repository follow.
+package custom
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/endpoint"
+)
+
+const (
+	// DefaultQueueSize is the number of lines buffered before dropping
+	DefaultQueueSize = 4096
+
+	// DefaultFlushInterval is how often buffered lines are written
+	DefaultFlushInterval = 100 * time.Millisecond
+
+	// DefaultReconnectDelay is how long to wait before reconnecting
+	// after a dial or write error
+	DefaultReconnectDelay = 5 * time.Second
+
+	// DefaultShutdownTimeout bounds how long Shutdown waits for the
+	// queued lines to be written
+	DefaultShutdownTimeout = 5 * time.Second
+)
+
+// Sink is an example MetricSink. Calls never block: the metric is
+// formatted on the caller goroutine and handed over to a background
+// goroutine through a buffered queue, which owns the connection.
+type Sink struct {
+	dropped uint64 // accessed atomically, kept first for alignment
+
+	addr string
+
+	// Settings, only written by the options before the goroutine starts
+	queueSize       int
+	flushInterval   time.Duration
+	reconnectDelay  time.Duration
+	shutdownTimeout time.Duration
+	dial            func(addr string) (net.Conn, error)
+
+	// closed guards the queue: sending on a closed channel panics, so
+	// pushes hold the read lock and Shutdown the write lock
+	mu     sync.RWMutex
+	closed bool
+	queue  chan string
+	done   chan struct{}
+}
+
+// Option is used to configure a Sink. Functional options keep NewSink
+// backwards compatible when settings are added.
+type Option func(*Sink)
+
+// WithQueueSize sets the number of lines buffered, defaults to DefaultQueueSize
+func WithQueueSize(size int) Option {
+	return func(s *Sink) {
+		s.queueSize = size
+	}
+}
+
+// WithFlushInterval sets how often buffered lines are written,
+// defaults to DefaultFlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.flushInterval = interval
+	}
+}
+
+// WithReconnectDelay sets the delay before reconnecting,
+// defaults to DefaultReconnectDelay
+func WithReconnectDelay(delay time.Duration) Option {
+	return func(s *Sink) {
+		s.reconnectDelay = delay
+	}
+}
+
+// WithShutdownTimeout sets how long Shutdown waits for the queue to be
+// written, defaults to DefaultShutdownTimeout
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(s *Sink) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// WithDialer overrides how connections are opened, defaults to a TCP dial.
+// Accepting the dependency as an option keeps the sink easy to test.
+func WithDialer(dial func(addr string) (net.Conn, error)) Option {
+	return func(s *Sink) {
+		s.dial = dial
+	}
+}
+
+// NewSink creates a Sink sending metrics to the given host:port address.
+// Invalid settings are reported here rather than on the first metric.
+func NewSink(addr string, opts ...Option) (*Sink, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid custom address %q: %s", addr, err)
+	}
+
+	s := &Sink{
+		addr:            addr,
+		queueSize:       DefaultQueueSize,
+		flushInterval:   DefaultFlushInterval,
+		reconnectDelay:  DefaultReconnectDelay,
+		shutdownTimeout: DefaultShutdownTimeout,
+		dial: func(addr string) (net.Conn, error) {
+			return net.Dial("tcp", addr)
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.queueSize <= 0 {
+		return nil, fmt.Errorf("queue size must be positive, got %d", s.queueSize)
+	}
+	if s.flushInterval <= 0 {
+		return nil, fmt.Errorf("flush interval must be positive, got %s", s.flushInterval)
+	}
+
+	s.queue = make(chan string, s.queueSize)
+	s.done = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+// Registering the factory at init makes endpoint.NewSinkFromURL create the
+// sink for the "custom" scheme once this package is imported. The factory
+// must return a metrics.Sinker, so NewSinkFromURL is wrapped.
+func init() {
+	err := endpoint.RegisterSink("custom", func(u *url.URL) (metrics.Sinker, error) {
+		return NewSinkFromURL(u)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewSinkFromURL creates a Sink from a URL such as
+// "custom://host:port?queue_size=1024&flush_interval=1s"
+func NewSinkFromURL(u *url.URL) (*Sink, error) {
+	var opts []Option
+	query := u.Query()
+
+	if v := query.Get("queue_size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid queue_size %q: %s", v, err)
+		}
+		opts = append(opts, WithQueueSize(size))
+	}
+	if v := query.Get("flush_interval"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flush_interval %q: %s", v, err)
+		}
+		opts = append(opts, WithFlushInterval(interval))
+	}
+	if v := query.Get("reconnect_delay"); v != "" {
+		delay, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reconnect_delay %q: %s", v, err)
+		}
+		opts = append(opts, WithReconnectDelay(delay))
+	}
+
+	return NewSink(u.Host, opts...)
+}
+
+// Shutdown stops the sink, writing the queued lines for up to the
+// shutdown timeout. It is safe to call more than once, metrics sent
+// afterwards are dropped.
+func (s *Sink) Shutdown() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(s.shutdownTimeout):
+		log.Printf("[ERR] Timed out writing the queued metrics on shutdown")
+	}
+}
+
+// Dropped returns the number of metrics dropped because the queue was full
+// or the sink was shut down
+func (s *Sink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, "g", formatFloat32(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(key, "kv", formatFloat32(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, "c", formatFloat32(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value.
+// Implementing metrics.Float64Sink avoids the float32 narrowing.
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(key, "c", strconv.FormatFloat(val, 'f', -1, 64), labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, "ms", formatFloat32(val), labels)
+}
+
+// Formats the metric and queues it, dropping it instead of blocking the
+// caller when the queue is full or the sink is shut down
+func (s *Sink) push(key []string, kind, val string, labels []metrics.Label) {
+	line := format(key, kind, val, labels)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+
+	select {
+	case s.queue <- line:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Formats a metric as a "name|type|value|label=value,..." line
+func format(key []string, kind, val string, labels []metrics.Label) string {
+	var b strings.Builder
+	b.WriteString(sanitize(strings.Join(key, ".")))
+	b.WriteByte('|')
+	b.WriteString(kind)
+	b.WriteByte('|')
+	b.WriteString(val)
+	b.WriteByte('|')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(sanitize(label.Name))
+		b.WriteByte('=')
+		b.WriteString(sanitize(label.Value))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// Formats a float32 value with the shortest representation, so 0.1 is
+// not written as 0.10000000149011612
+func formatFloat32(val float32) string {
+	return strconv.FormatFloat(float64(val), 'f', -1, 32)
+}
+
+// Replaces the characters of the line protocol found in names and values
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', ',', '=', ' ', '\n':
+			return '_'
+		default:
+			return r
+		}
+	}, s)
+}
+
+// Owns the connection: writes the queued lines, flushing them at every
+// interval, and reconnects after errors until the queue is closed
+func (s *Sink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		conn, err := s.dial(s.addr)
+		if err != nil {
+			log.Printf("[ERR] Error connecting to custom sink! Err: %s", err)
+		} else {
+			closed := s.write(conn, ticker.C)
+			conn.Close()
+			if closed {
+				return
+			}
+		}
+
+		if !s.wait() {
+			return
+		}
+	}
+}
+
+// Writes to the connection until an error or the queue is closed,
+// returning true on the latter
+func (s *Sink) write(conn net.Conn, tick <-chan time.Time) bool {
+	w := bufio.NewWriter(conn)
+	for {
+		select {
+		case line, ok := <-s.queue:
+			if !ok {
+				// Graceful shutdown, write what was buffered
+				if err := w.Flush(); err != nil {
+					log.Printf("[ERR] Error flushing to custom sink! Err: %s", err)
+				}
+				return true
+			}
+			if _, err := w.WriteString(line); err != nil {
+				log.Printf("[ERR] Error writing to custom sink! Err: %s", err)
+				return false
+			}
+
+		case <-tick:
+			if w.Buffered() == 0 {
+				continue
+			}
+			if err := w.Flush(); err != nil {
+				log.Printf("[ERR] Error flushing to custom sink! Err: %s", err)
+				return false
+			}
+		}
+	}
+}
+
+// Waits for the reconnect delay, dropping the queued lines meanwhile so
+// callers are never blocked. Returns false if the queue was closed.
+func (s *Sink) wait() bool {
+	timer := time.NewTimer(s.reconnectDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-s.queue:
+			if !ok {
+				return false
+			}
+			atomic.AddUint64(&s.dropped, 1)
+		case <-timer.C:
+			return true
+		}
+	}
+}
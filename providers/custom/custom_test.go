@@ -0,0 +1,260 @@
+package custom
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/endpoint"
+)
+
+// listen starts a test server, sending every line received on the returned channel
+func listen(t *testing.T) (net.Listener, <-chan string) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+
+	lines := make(chan string, 64)
+	go func() {
+		for {
+			conn, err := list.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}(conn)
+		}
+	}()
+	return list, lines
+}
+
+// expect reads the next line, failing if it differs from want
+func expect(t *testing.T, lines <-chan string, want string) {
+	select {
+	case line := <-lines:
+		if line != want {
+			t.Fatalf("bad line %q, want %q", line, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	labels := []metrics.Label{{Name: "a b", Value: "c|d"}, {Name: "e", Value: "f=g"}}
+	line := format([]string{"foo", "bar baz"}, "g", formatFloat32(0.1), labels)
+	if line != "foo.bar_baz|g|0.1|a_b=c_d,e=f_g\n" {
+		t.Fatalf("bad line %q", line)
+	}
+
+	if line := format([]string{"foo"}, "c", "1", nil); line != "foo|c|1|\n" {
+		t.Fatalf("bad line %q", line)
+	}
+}
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("localhost"); err == nil {
+		t.Fatalf("expected address error")
+	}
+	if _, err := NewSink("localhost:1", WithQueueSize(0)); err == nil {
+		t.Fatalf("expected queue size error")
+	}
+	if _, err := NewSink("localhost:1", WithFlushInterval(0)); err == nil {
+		t.Fatalf("expected flush interval error")
+	}
+}
+
+func TestSink(t *testing.T) {
+	list, lines := listen(t)
+	defer list.Close()
+
+	s, err := NewSink(list.Addr().String(), WithFlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	labels := []metrics.Label{{Name: "a", Value: "b"}}
+	s.SetGauge([]string{"gauge"}, 1)
+	s.SetGaugeWithLabels([]string{"gauge"}, 2, labels)
+	s.EmitKey([]string{"key"}, 3)
+	s.IncrCounter([]string{"counter"}, 4)
+	s.IncrCounterWithLabels([]string{"counter"}, 5, labels)
+	s.IncrCounterFloat64([]string{"counter"}, 0.1)
+	s.IncrCounterWithLabelsFloat64([]string{"counter"}, 1e-9, labels)
+	s.AddSample([]string{"sample"}, 6)
+	s.AddSampleWithLabels([]string{"sample"}, 7.5, labels)
+
+	expect(t, lines, "gauge|g|1|")
+	expect(t, lines, "gauge|g|2|a=b")
+	expect(t, lines, "key|kv|3|")
+	expect(t, lines, "counter|c|4|")
+	expect(t, lines, "counter|c|5|a=b")
+	expect(t, lines, "counter|c|0.1|")
+	expect(t, lines, "counter|c|0.000000001|a=b")
+	expect(t, lines, "sample|ms|6|")
+	expect(t, lines, "sample|ms|7.5|a=b")
+}
+
+func TestSink_ImplementsFloat64Sink(t *testing.T) {
+	var _ metrics.Float64Sink = &Sink{}
+}
+
+func TestSink_Reconnect(t *testing.T) {
+	list, lines := listen(t)
+	defer list.Close()
+
+	// The first dial fails, the sink must retry after the delay
+	var mu sync.Mutex
+	dials := 0
+	dial := func(addr string) (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials == 1 {
+			return nil, errors.New("refused")
+		}
+		return net.Dial("tcp", addr)
+	}
+
+	s, err := NewSink(list.Addr().String(),
+		WithDialer(dial),
+		WithReconnectDelay(50*time.Millisecond),
+		WithFlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	// Keep sending until a metric goes through the second connection
+	deadline := time.After(3 * time.Second)
+	for {
+		s.IncrCounter([]string{"counter"}, 1)
+		select {
+		case line := <-lines:
+			if line != "counter|c|1|" {
+				t.Fatalf("bad line %q", line)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if dials != 2 {
+				t.Fatalf("bad dials %d", dials)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("timed out waiting for reconnect")
+		}
+	}
+}
+
+func TestSink_ShutdownFlushes(t *testing.T) {
+	list, lines := listen(t)
+	defer list.Close()
+
+	// Flush interval longer than the test, only Shutdown writes the lines
+	s, err := NewSink(list.Addr().String(), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+
+	s.SetGauge([]string{"gauge"}, 1)
+	s.SetGauge([]string{"gauge"}, 2)
+	s.Shutdown()
+
+	expect(t, lines, "gauge|g|1|")
+	expect(t, lines, "gauge|g|2|")
+
+	// Shutting down twice is safe and later metrics are dropped
+	s.Shutdown()
+	s.SetGauge([]string{"gauge"}, 3)
+	if dropped := s.Dropped(); dropped != 1 {
+		t.Fatalf("bad dropped %d", dropped)
+	}
+}
+
+func TestSink_FullQueue(t *testing.T) {
+	// The dialer blocks, so nothing is dequeued until unblock is closed
+	unblock := make(chan struct{})
+	dial := func(addr string) (net.Conn, error) {
+		<-unblock
+		return nil, errors.New("refused")
+	}
+
+	s, err := NewSink("localhost:1", WithDialer(dial), WithQueueSize(2))
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer func() {
+		close(unblock)
+		s.Shutdown()
+	}()
+
+	for i := 0; i < 5; i++ {
+		s.IncrCounter([]string{"counter"}, 1)
+	}
+	if dropped := s.Dropped(); dropped != 3 {
+		t.Fatalf("bad dropped %d", dropped)
+	}
+}
+
+func TestNewSinkFromURL(t *testing.T) {
+	u, _ := url.Parse("custom://localhost:1?queue_size=16&flush_interval=1s&reconnect_delay=2s")
+	s, err := NewSinkFromURL(u)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	if s.addr != "localhost:1" {
+		t.Fatalf("bad addr %s", s.addr)
+	}
+	if cap(s.queue) != 16 {
+		t.Fatalf("bad queue size %d", cap(s.queue))
+	}
+	if s.flushInterval != time.Second {
+		t.Fatalf("bad flush interval %s", s.flushInterval)
+	}
+	if s.reconnectDelay != 2*time.Second {
+		t.Fatalf("bad reconnect delay %s", s.reconnectDelay)
+	}
+
+	for _, raw := range []string{
+		"custom://localhost:1?queue_size=x",
+		"custom://localhost:1?flush_interval=x",
+		"custom://localhost:1?reconnect_delay=x",
+		"custom://localhost",
+	} {
+		u, _ := url.Parse(raw)
+		if _, err := NewSinkFromURL(u); err == nil {
+			t.Fatalf("expected error for %s", raw)
+		}
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	sink, err := endpoint.NewSinkFromURL("custom://localhost:1?queue_size=16")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	s, ok := sink.(*Sink)
+	if !ok {
+		t.Fatalf("bad sink type %T", sink)
+	}
+	defer s.Shutdown()
+
+	if cap(s.queue) != 16 {
+		t.Fatalf("bad queue size %d", cap(s.queue))
+	}
+}
@@ -110,6 +110,18 @@ func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics
 	s.client.Count(flatKey, int64(val), tags, rate)
 }
 
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	flatKey, tags := s.getFlatkeyAndCombinedLabels(key, labels)
+	rate := 1.0
+	s.client.Count(flatKey, int64(val), tags, rate)
+}
+
 // AddSample adds a sample metrics
 func (s *Sink) AddSample(key []string, val float32) {
 	s.AddSampleWithLabels(key, val, nil)
@@ -148,3 +148,15 @@ func assertServerMatchesExpected(t *testing.T, server *net.UDPConn, buf []byte,
 		t.Fatalf("Line %s does not match expected: %s", string(msg), expected)
 	}
 }
+
+func TestMetricSink_IPv6(t *testing.T) {
+	server, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback, Port: 7255})
+	if err != nil {
+		t.Skipf("IPv6 is not available: %s", err)
+	}
+	defer server.Close()
+
+	dog := mockNewSink("[::1]:7255", EmptyTags, HostnameDisabled)
+	dog.IncrCounter([]string{"count", "me"}, float32(3))
+	assertServerMatchesExpected(t, server, make([]byte, 1024), "count.me:3|c")
+}
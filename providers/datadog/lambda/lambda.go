@@ -0,0 +1,138 @@
+package lambda
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+const (
+	gaugeType     = "gauge"
+	countType     = "count"
+	histogramType = "histogram"
+)
+
+// Sink provides a MetricSink that writes metrics in the Datadog
+// Lambda log format, one metric per line:
+//
+//	MONITORING|<timestamp>|<value>|<type>|<name>|#tag:val,tag2:val
+//
+// The Datadog log forwarder parses these lines from the function
+// logs, so the default writer is os.Stdout
+type Sink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithWriter sets the writer metrics are written to, defaults to os.Stdout
+func WithWriter(w io.Writer) Option {
+	return func(s *Sink) {
+		s.w = w
+	}
+}
+
+// NewSink is used to create a new Sink
+func NewSink(opts ...Option) *Sink {
+	s := &Sink{w: os.Stdout}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.write(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey is not implemented since Datadog does not provide a metric type that holds an
+// arbitrary number of values
+func (s *Sink) EmitKey(key []string, val float32) {
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.write(countType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.write(countType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.write(histogramType, key, float64(val), labels)
+}
+
+// Writes a metric line. Each line is written with a single call, so
+// lines are not split on the function logs.
+func (s *Sink) write(metricType string, key []string, val float64, labels []metrics.Label) {
+	line := fmt.Sprintf("MONITORING|%d|%s|%s|%s", time.Now().Unix(),
+		strconv.FormatFloat(val, 'f', -1, 64), metricType, s.flattenKey(key))
+
+	if len(labels) > 0 {
+		tags := make([]string, 0, len(labels))
+		for _, label := range labels {
+			name := strings.Map(sanitize, label.Name)
+			value := strings.Map(sanitize, label.Value)
+			if value != "" {
+				tags = append(tags, name+":"+value)
+			} else {
+				tags = append(tags, name)
+			}
+		}
+		line += "|#" + strings.Join(tags, ",")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := io.WriteString(s.w, line+"\n"); err != nil {
+		log.Printf("[ERR] Error writing Datadog Lambda metric! Err: %s", err)
+	}
+}
+
+func (s *Sink) flattenKey(parts []string) string {
+	joined := strings.Join(parts, ".")
+	return strings.Map(sanitize, joined)
+}
+
+// sanitize replaces the characters used as separators on the format
+func sanitize(r rune) rune {
+	switch r {
+	case '|', ',', ':', ' ', '\n':
+		return '_'
+	default:
+		return r
+	}
+}
@@ -0,0 +1,48 @@
+package lambda
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestFlattenKey(t *testing.T) {
+	s := NewSink()
+	if flat := s.flattenKey([]string{"a b", "c|d"}); flat != "a_b.c_d" {
+		t.Fatalf("bad flat %s", flat)
+	}
+}
+
+func TestSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewSink(WithWriter(buf))
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1.5, []metrics.Label{{Name: "a", Value: "b"}, {Name: "c", Value: ""}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4)
+
+	expected := []string{
+		"1.5|gauge|gauge.val|#a:b,c",
+		"3|count|counter",
+		"4|histogram|sample",
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("bad lines %q", lines)
+	}
+	for i, line := range lines {
+		var ts int64
+		var rest string
+		if _, err := fmt.Sscanf(line, "MONITORING|%d|%s", &ts, &rest); err != nil || ts == 0 {
+			t.Fatalf("bad line %s", line)
+		}
+		if rest != expected[i] {
+			t.Fatalf("expected %s got %s", expected[i], rest)
+		}
+	}
+}
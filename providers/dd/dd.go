@@ -0,0 +1,260 @@
+// Package dd provides a sink pushing metrics to the Datadog API v2
+// series endpoint, without an agent
+package dd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultSite is the Datadog site metrics are pushed to
+	DefaultSite = "datadoghq.com"
+
+	// seriesPath is the path of the API v2 series endpoint
+	seriesPath = "/api/v2/series"
+
+	// apiKeyHeader is the header holding the API key
+	apiKeyHeader = "DD-API-KEY"
+)
+
+// The intake types of the API v2 series
+const (
+	countType = 1
+	rateType  = 2
+	gaugeType = 3
+)
+
+// Sink provides a MetricSink that pushes metrics to the Datadog API v2,
+// as series of a single point. Gauges, key values and samples are sent as
+// gauges and counters as counts of their increments, or rates per second
+// with WithRateCounters. Labels become "name:value" tags.
+type Sink struct {
+	host         string
+	rateCounters bool
+	config       push.Config
+	client       *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithHost sets the host resource of the series, defaults to the
+// hostname of the machine. An empty host sends no host resource.
+func WithHost(host string) Option {
+	return func(s *Sink) {
+		s.host = host
+	}
+}
+
+// WithRateCounters sends the counters as rates per second over the
+// flush interval, instead of counts
+func WithRateCounters() Option {
+	return func(s *Sink) {
+		s.rateCounters = true
+	}
+}
+
+// WithURL overrides the URL of the series endpoint, ex: to push through
+// a proxy
+func WithURL(url string) Option {
+	return func(s *Sink) {
+		s.config.URL = url
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// point is a value of a series at a time, in seconds
+type point struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// resource is an entity the series relates to, ex: a host
+type resource struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// series is the API v2 representation of a metric
+type series struct {
+	Metric    string     `json:"metric"`
+	Type      int        `json:"type"`
+	Interval  int64      `json:"interval,omitempty"`
+	Points    []point    `json:"points"`
+	Tags      []string   `json:"tags,omitempty"`
+	Resources []resource `json:"resources,omitempty"`
+}
+
+// NewSink is used to create a new Sink pushing to the given Datadog site,
+// ex: "datadoghq.com" or "datadoghq.eu", an empty site meaning DefaultSite,
+// authenticated with the API key
+func NewSink(apiKey, site string, opts ...Option) (*Sink, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("api key must be provided")
+	}
+	if site == "" {
+		site = DefaultSite
+	}
+
+	host, _ := os.Hostname()
+	s := &Sink{
+		host: host,
+		config: push.Config{
+			Name:          "datadog",
+			URL:           "https://api." + site + seriesPath,
+			ContentType:   "application/json",
+			Header:        http.Header{apiKeyHeader: {apiKey}},
+			FlushInterval: push.DefaultFlushInterval,
+			Encode:        encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// The interval of counts and rates is the flush interval
+	if s.config.FlushInterval <= 0 {
+		s.config.FlushInterval = push.DefaultFlushInterval
+	}
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+// since Datadog has no key/value metric type
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	if s.rateCounters {
+		s.push(rateType, key, val/s.config.FlushInterval.Seconds(), labels)
+		return
+	}
+	s.push(countType, key, val, labels)
+}
+
+// AddSample adds a sample metrics, sent as a gauge
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels, sent as a gauge
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+func (s *Sink) push(metricType int, key []string, val float64, labels []metrics.Label) {
+	m := series{
+		Metric: s.flattenKey(key),
+		Type:   metricType,
+		Points: []point{{Timestamp: time.Now().Unix(), Value: val}},
+	}
+	if metricType != gaugeType {
+		m.Interval = int64(s.config.FlushInterval / time.Second)
+	}
+	for _, label := range labels {
+		m.Tags = append(m.Tags, label.Name+":"+label.Value)
+	}
+	if s.host != "" {
+		m.Resources = []resource{{Name: s.host, Type: "host"}}
+	}
+	s.client.Push(m)
+}
+
+// Flattens the key for formatting, removes spaces
+func (s *Sink) flattenKey(parts []string) string {
+	return strings.Replace(strings.Join(parts, "."), " ", "_", -1)
+}
+
+// encode formats the metrics as a series payload
+func encode(records []interface{}) ([]byte, error) {
+	return json.Marshal(struct {
+		Series []interface{} `json:"series"`
+	}{records})
+}
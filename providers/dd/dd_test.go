@@ -0,0 +1,148 @@
+package dd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("", "datadoghq.eu"); err == nil {
+		t.Fatalf("expected error for missing api key")
+	}
+}
+
+func TestNewSink_Site(t *testing.T) {
+	s, err := NewSink("key", "datadoghq.eu")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+	if s.config.URL != "https://api.datadoghq.eu/api/v2/series" {
+		t.Fatalf("bad url %s", s.config.URL)
+	}
+
+	s, err = NewSink("key", "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+	if s.config.URL != "https://api.datadoghq.com/api/v2/series" {
+		t.Fatalf("bad url %s", s.config.URL)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var apiKey string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("DD-API-KEY")
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("secret", "", WithURL(srv.URL), WithHost("web-1"), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4)
+	s.Flush()
+
+	if apiKey != "secret" {
+		t.Fatalf("bad api key %q", apiKey)
+	}
+
+	var got struct {
+		Series []series `json:"series"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("bad body %s: %v", body, err)
+	}
+
+	host := []resource{{Name: "web-1", Type: "host"}}
+	expected := []series{
+		{Metric: "gauge.val", Type: gaugeType, Tags: []string{"a:b"}, Resources: host},
+		{Metric: "key", Type: gaugeType, Resources: host},
+		{Metric: "counter", Type: countType, Interval: 3600, Resources: host},
+		{Metric: "sample", Type: gaugeType, Resources: host},
+	}
+	if len(got.Series) != len(expected) {
+		t.Fatalf("expected %d series got %d", len(expected), len(got.Series))
+	}
+	for i, m := range got.Series {
+		if len(m.Points) != 1 || m.Points[0].Value != float64(i+1) || m.Points[0].Timestamp == 0 {
+			t.Fatalf("bad points %v", m.Points)
+		}
+		m.Points = nil
+		if !reflect.DeepEqual(m, expected[i]) {
+			t.Fatalf("bad series %#v, expected %#v", m, expected[i])
+		}
+	}
+}
+
+func TestSink_RateCounters(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("secret", "", WithURL(srv.URL), WithHost(""), WithRateCounters(), WithFlushInterval(10*time.Second))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 5)
+	s.Flush()
+
+	var got struct {
+		Series []series `json:"series"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("bad body %s: %v", body, err)
+	}
+	m := got.Series[0]
+	if m.Type != rateType || m.Interval != 10 || m.Points[0].Value != 0.5 || m.Resources != nil {
+		t.Fatalf("bad series %#v", m)
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("secret", "", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("secret", "", WithURL(srv.URL), WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
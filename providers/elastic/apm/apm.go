@@ -0,0 +1,246 @@
+package apm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultServiceName is the service name reported when none is set
+	DefaultServiceName = "go-metrics"
+
+	// intakePath is the APM Server events intake endpoint
+	intakePath = "/intake/v2/events"
+
+	agentName = "go-metrics"
+
+	gaugeType   = "gauge"
+	counterType = "counter"
+)
+
+// Sink provides a MetricSink that pushes metrics to an Elastic APM
+// Server as metricset events, using the NDJSON intake format.
+// Labels become metricset tags.
+type Sink struct {
+	service string
+	config  push.Config
+	client  *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithServiceName sets the service name reported on the events
+// metadata, defaults to DefaultServiceName
+func WithServiceName(name string) Option {
+	return func(s *Sink) {
+		s.service = name
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// metadata is the first event of every intake request
+type metadata struct {
+	Metadata struct {
+		Service struct {
+			Name  string `json:"name"`
+			Agent struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"agent"`
+		} `json:"service"`
+	} `json:"metadata"`
+}
+
+// event wraps a metricset on the intake format
+type event struct {
+	Metricset metricset `json:"metricset"`
+}
+
+// metricset is the Elastic APM representation of a set of metrics
+// sharing the same tags, timestamp in microseconds
+type metricset struct {
+	Timestamp int64             `json:"timestamp"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Samples   map[string]sample `json:"samples"`
+}
+
+type sample struct {
+	Type  string  `json:"type,omitempty"`
+	Value float64 `json:"value"`
+}
+
+// NewSink is used to create a new Sink pushing to the given APM Server,
+// authenticated with the API key when provided
+func NewSink(apmServerURL, apiKey string, opts ...Option) (*Sink, error) {
+	if apmServerURL == "" {
+		return nil, fmt.Errorf("apm server url must be provided")
+	}
+
+	s := &Sink{
+		service: DefaultServiceName,
+		config: push.Config{
+			Name:        "elastic-apm",
+			URL:         strings.TrimSuffix(apmServerURL, "/") + intakePath,
+			ContentType: "application/x-ndjson",
+			Header:      http.Header{},
+		},
+	}
+	if apiKey != "" {
+		s.config.Header.Set("Authorization", "ApiKey "+apiKey)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.config.Encode = s.encode
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+// since Elastic APM has no key/value metric type
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics, sent as a gauge since
+// histograms must be aggregated before sending them to APM Server
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	m := metricset{
+		Timestamp: time.Now().UnixNano() / int64(time.Microsecond),
+		Samples: map[string]sample{
+			s.flattenKey(key): {Type: metricType, Value: val},
+		},
+	}
+	if len(labels) > 0 {
+		m.Tags = make(map[string]string, len(labels))
+		for _, label := range labels {
+			m.Tags[label.Name] = label.Value
+		}
+	}
+	s.client.Push(event{Metricset: m})
+}
+
+// Flattens the key for formatting, removes spaces
+func (s *Sink) flattenKey(parts []string) string {
+	return strings.Replace(strings.Join(parts, "."), " ", "_", -1)
+}
+
+// encode formats the metricsets as newline delimited JSON,
+// preceded by the metadata event required by the intake API
+func (s *Sink) encode(records []interface{}) ([]byte, error) {
+	var meta metadata
+	meta.Metadata.Service.Name = s.service
+	meta.Metadata.Service.Agent.Name = agentName
+	meta.Metadata.Service.Agent.Version = "unknown"
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	if err := enc.Encode(meta); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,113 @@
+package apm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_MissingURL(t *testing.T) {
+	if _, err := NewSink("", "key"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestSink(t *testing.T) {
+	var path, auth string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL+"/", "key", WithServiceName("api"), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.Flush()
+
+	if path != "/intake/v2/events" {
+		t.Fatalf("bad path %s", path)
+	}
+	if auth != "ApiKey key" {
+		t.Fatalf("bad auth %s", auth)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	if !scanner.Scan() {
+		t.Fatalf("missing metadata")
+	}
+	var meta metadata
+	if err := json.Unmarshal(scanner.Bytes(), &meta); err != nil || meta.Metadata.Service.Name != "api" {
+		t.Fatalf("bad metadata %s", scanner.Text())
+	}
+
+	expected := []struct {
+		name, typ string
+		val       float64
+		tags      map[string]string
+	}{
+		{"gauge.val", "gauge", 1, map[string]string{"a": "b"}},
+		{"counter", "counter", 2, nil},
+	}
+	for _, e := range expected {
+		if !scanner.Scan() {
+			t.Fatalf("missing metricset %s", e.name)
+		}
+		var ev event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("bad line %s: %v", scanner.Text(), err)
+		}
+		m := ev.Metricset
+		if m.Timestamp == 0 || m.Samples[e.name].Type != e.typ || m.Samples[e.name].Value != e.val {
+			t.Fatalf("bad metricset %s", scanner.Text())
+		}
+		if len(m.Tags) != len(e.tags) || m.Tags["a"] != e.tags["a"] {
+			t.Fatalf("bad tags %v", m.Tags)
+		}
+	}
+	if scanner.Scan() {
+		t.Fatalf("unexpected line %s", scanner.Text())
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("http://localhost", "key", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, "key", WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
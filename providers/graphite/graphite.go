@@ -0,0 +1,189 @@
+// Package graphite provides a sink writing metrics to Graphite with the
+// Carbon plaintext protocol over TCP
+package graphite
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/linewriter"
+)
+
+const (
+	// DefaultPort is the port of the Carbon plaintext receiver
+	DefaultPort = "2003"
+
+	// DefaultFlushInterval is the period of inactivity after which the
+	// buffered lines are written, so they do not get stuck in the buffer
+	DefaultFlushInterval = linewriter.DefaultFlushInterval
+
+	// DefaultReconnectBase is the delay before the first reconnect, doubled
+	// after each consecutive failure up to DefaultReconnectMax
+	DefaultReconnectBase = linewriter.DefaultReconnectBase
+	DefaultReconnectMax  = linewriter.DefaultReconnectMax
+
+	// TimerSuffix is appended to the path of the samples
+	TimerSuffix = ".timer"
+)
+
+// Sink provides a MetricSink writing metrics to Carbon as plaintext lines:
+//
+//	http.requests.200 1.000000 1556813561
+//
+// The path is the key and the label values joined with '.', as the statsd
+// sink does without tags, samples getting the TimerSuffix. Lines are
+// buffered and written over a TCP connection, reopened with a backoff after
+// errors. Metrics pushed while the queue is full are dropped.
+type Sink struct {
+	addr string
+
+	flushInterval time.Duration
+	reconnectBase time.Duration
+	reconnectMax  time.Duration
+
+	writer *linewriter.Writer
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithFlushInterval sets the period of inactivity after which the
+// buffered lines are written, defaults to DefaultFlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.flushInterval = interval
+	}
+}
+
+// WithReconnectBackoff sets the delay before reconnecting after an error,
+// doubled after each consecutive failure up to max. Defaults to
+// DefaultReconnectBase and DefaultReconnectMax.
+func WithReconnectBackoff(base, max time.Duration) Option {
+	return func(s *Sink) {
+		s.reconnectBase = base
+		s.reconnectMax = max
+	}
+}
+
+// NewSink is used to create a new Sink writing to the Carbon server at the
+// address, ex: "localhost:2003", the port defaulting to DefaultPort
+func NewSink(addr string, opts ...Option) (*Sink, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("graphite address must be provided")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), DefaultPort)
+	}
+
+	s := &Sink{
+		addr:          addr,
+		flushInterval: DefaultFlushInterval,
+		reconnectBase: DefaultReconnectBase,
+		reconnectMax:  DefaultReconnectMax,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.flushInterval <= 0 {
+		return nil, fmt.Errorf("flush interval must be positive, got %s", s.flushInterval)
+	}
+	if s.reconnectBase <= 0 || s.reconnectMax < s.reconnectBase {
+		return nil, fmt.Errorf("invalid reconnect backoff %s up to %s", s.reconnectBase, s.reconnectMax)
+	}
+
+	writer, err := linewriter.New(linewriter.Config{
+		Name:          "graphite",
+		Addr:          addr,
+		FlushInterval: s.flushInterval,
+		ReconnectBase: s.reconnectBase,
+		ReconnectMax:  s.reconnectMax,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.writer = writer
+	return s, nil
+}
+
+// Shutdown is used to stop writing to Carbon, it blocks until the queued
+// metrics are sent. No metric must be pushed after shutting down.
+func (s *Sink) Shutdown() {
+	s.writer.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.pushMetric(key, labels, "", float64(val))
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.pushMetric(key, nil, "", float64(val))
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.pushMetric(key, labels, "", val)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.pushMetric(key, labels, TimerSuffix, float64(val))
+}
+
+// Does a non-blocking push to the metrics queue
+func (s *Sink) pushMetric(key []string, labels []metrics.Label, suffix string, val float64) {
+	s.writer.Push(formatLine(time.Now(), flattenKeyLabels(key, labels)+suffix, val))
+}
+
+// Flattens the key and the label values into a metric path, replacing
+// the whitespace which separates the fields of a line
+func flattenKeyLabels(parts []string, labels []metrics.Label) string {
+	joined := make([]string, 0, len(parts)+len(labels))
+	joined = append(joined, parts...)
+	for _, label := range labels {
+		joined = append(joined, label.Value)
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n':
+			return '_'
+		default:
+			return r
+		}
+	}, strings.Join(joined, "."))
+}
+
+// Formats a metric as a plaintext line, timestamped in seconds
+func formatLine(t time.Time, path string, val float64) string {
+	return path + " " + strconv.FormatFloat(val, 'f', 6, 64) + " " + strconv.FormatInt(t.Unix(), 10) + "\n"
+}
@@ -0,0 +1,144 @@
+package graphite
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink(""); err == nil {
+		t.Fatalf("expected error for missing address")
+	}
+	if _, err := NewSink("localhost", WithFlushInterval(0)); err == nil {
+		t.Fatalf("expected error for zero flush interval")
+	}
+	if _, err := NewSink("localhost", WithReconnectBackoff(time.Second, time.Millisecond)); err == nil {
+		t.Fatalf("expected error for reconnect max below base")
+	}
+}
+
+func TestNewSink_DefaultPort(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"localhost":      "localhost:2003",
+		"localhost:2004": "localhost:2004",
+		"[::1]":          "[::1]:2003",
+	} {
+		s, err := NewSink(addr)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		s.Shutdown()
+		if s.addr != expected {
+			t.Fatalf("bad addr %s for %s", s.addr, addr)
+		}
+	}
+}
+
+func TestFlattenKeyLabels(t *testing.T) {
+	path := flattenKeyLabels([]string{"http", "requests"}, []metrics.Label{{Name: "method", Value: "GET"}, {Name: "host", Value: "web 1"}})
+	if path != "http.requests.GET.web_1" {
+		t.Fatalf("bad path %s", path)
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	if line := formatLine(time.Unix(1556813561, 5), "a.b", 1.5); line != "a.b 1.500000 1556813561\n" {
+		t.Fatalf("bad line %q", line)
+	}
+}
+
+// listen accepts a single connection, sending its lines on the channel
+func listen(list net.Listener) chan string {
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := list.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+func TestSink(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer list.Close()
+	lines := listen(list)
+
+	s, err := NewSink(list.Addr().String(), WithFlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "host", Value: "web1"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4.5)
+	s.Shutdown()
+
+	for _, expected := range []string{
+		"gauge.val.web1 1.000000 ",
+		"key 2.000000 ",
+		"counter 3.000000 ",
+		"sample.timer 4.500000 ",
+	} {
+		select {
+		case line := <-lines:
+			if !regexp.MustCompile("^" + regexp.QuoteMeta(expected) + `\d+$`).MatchString(line) {
+				t.Fatalf("bad line %q, expected %q", line, expected)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+func TestSink_Reconnect(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	addr := list.Addr().String()
+	list.Close()
+
+	// Connecting fails until the server is listening
+	s, err := NewSink(addr, WithFlushInterval(10*time.Millisecond), WithReconnectBackoff(10*time.Millisecond, 20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	time.Sleep(30 * time.Millisecond)
+	list, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen again on %s: %v", addr, err)
+	}
+	defer list.Close()
+	lines := listen(list)
+
+	deadline := time.After(3 * time.Second)
+	for {
+		s.IncrCounter([]string{"counter"}, 1)
+		select {
+		case line := <-lines:
+			if !regexp.MustCompile(`^counter 1.000000 \d+$`).MatchString(line) {
+				t.Fatalf("bad line %q", line)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("timed out waiting for the reconnect")
+		}
+	}
+}
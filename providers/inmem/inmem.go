@@ -30,6 +30,21 @@ type Sink struct {
 	intervalLock sync.RWMutex
 
 	rateDenom float64
+
+	clock metrics.Clock
+
+	watchers watchers
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithClock sets the clock the intervals and update times are read
+// from, defaults to metrics.SystemClock
+func WithClock(c metrics.Clock) Option {
+	return func(i *Sink) {
+		i.clock = c
+	}
 }
 
 // IntervalMetrics stores the aggregated metrics
@@ -54,6 +69,10 @@ type IntervalMetrics struct {
 	// Samples maps the key to an AggregateSample,
 	// which has the rolled up view of a sample
 	Samples map[string]SampledValue
+
+	// pointKeys maps the Points keys to the key parts they were
+	// emitted with
+	pointKeys map[string][]string
 }
 
 // NewIntervalMetrics creates a new IntervalMetrics for a given interval
@@ -77,6 +96,18 @@ type AggregateSample struct {
 	Min         float64   // Minimum value
 	Max         float64   // Maximum value
 	LastUpdated time.Time `json:"-"` // When value was last updated
+
+	// The percentiles of the sample values, computed once the
+	// interval is finished
+	P50 float64
+	P95 float64
+	P99 float64
+
+	// values holds the sample values for percentiles, at most
+	// maxSampleValues of them picked uniformly, sorted is set once
+	// they are sorted in place
+	values []float64
+	sorted bool
 }
 
 // Stddev computes a Stddev of the values
@@ -99,6 +130,11 @@ func (a *AggregateSample) Mean() float64 {
 
 // Ingest is used to update a sample
 func (a *AggregateSample) Ingest(v float64, rateDenom float64) {
+	a.ingest(v, rateDenom, time.Now())
+}
+
+// ingest updates a sample at the given time
+func (a *AggregateSample) ingest(v float64, rateDenom float64, now time.Time) {
 	a.Count++
 	a.Sum += v
 	a.SumSq += (v * v)
@@ -109,7 +145,7 @@ func (a *AggregateSample) Ingest(v float64, rateDenom float64) {
 		a.Max = v
 	}
 	a.Rate = float64(a.Sum) / rateDenom
-	a.LastUpdated = time.Now()
+	a.LastUpdated = now
 }
 
 func (a *AggregateSample) String() string {
@@ -125,13 +161,21 @@ func (a *AggregateSample) String() string {
 
 // NewSink is used to construct a new in-memory sink.
 // Uses an aggregation interval and maximum retention period.
-func NewSink(interval, retain time.Duration) *Sink {
+func NewSink(interval, retain time.Duration, opts ...Option) *Sink {
 	rateTimeUnit := time.Second
 	i := &Sink{
 		interval:     interval,
 		retain:       retain,
 		maxIntervals: int(retain / interval),
 		rateDenom:    float64(interval.Nanoseconds()) / float64(rateTimeUnit.Nanoseconds()),
+		clock:        metrics.SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	// At least the current interval is kept, when retain is shorter
+	if i.maxIntervals < 1 {
+		i.maxIntervals = 1
 	}
 	i.intervals = make([]*IntervalMetrics, 0, i.maxIntervals)
 	return i
@@ -144,23 +188,19 @@ func (i *Sink) SetGauge(key []string, val float32) {
 
 // SetGaugeWithLabels sets a value on a gauge with labels
 func (i *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
-	k, name := i.flattenKeyLabels(key, labels)
 	intv := i.getInterval()
-
 	intv.Lock()
-	defer intv.Unlock()
-	intv.Gauges[k] = GaugeValue{Name: name, Value: val, Labels: labels}
+	i.setGauge(intv, key, val, labels)
+	intv.Unlock()
+	i.notifyWatchers(key, val)
 }
 
 // EmitKey emits a key value metric
 func (i *Sink) EmitKey(key []string, val float32) {
-	k := i.flattenKey(key)
 	intv := i.getInterval()
-
 	intv.Lock()
 	defer intv.Unlock()
-	vals := intv.Points[k]
-	intv.Points[k] = append(vals, val)
+	i.emitKey(intv, key, val)
 }
 
 // IncrCounter increases the value of a counter by a given value
@@ -170,69 +210,230 @@ func (i *Sink) IncrCounter(key []string, val float32) {
 
 // IncrCounterWithLabels increases the value of a counter by a given value with labels
 func (i *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
-	k, name := i.flattenKeyLabels(key, labels)
+	i.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (i *Sink) IncrCounterFloat64(key []string, val float64) {
+	i.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (i *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
 	intv := i.getInterval()
+	intv.Lock()
+	defer intv.Unlock()
+	i.incrCounter(intv, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (i *Sink) AddSample(key []string, val float32) {
+	i.AddSampleWithLabels(key, val, nil)
+}
 
+// AddSampleWithLabels adds a sample metrics with labels
+func (i *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	intv := i.getInterval()
 	intv.Lock()
 	defer intv.Unlock()
+	i.addSample(intv, key, val, labels)
+}
+
+// Atomically calls fn holding the current interval lock, so the metrics
+// emitted on the given sink are all visible at once to Data readers.
+// It implements metrics.AtomicSinker.
+func (i *Sink) Atomically(fn func(s metrics.Sinker)) {
+	locked := &lockedSink{sink: i, intv: i.getInterval()}
+	defer locked.notifyWatchers()
+
+	locked.intv.Lock()
+	defer locked.intv.Unlock()
+	fn(locked)
+}
+
+// The following methods aggregate on an interval, its lock must be held
+
+func (i *Sink) setGauge(intv *IntervalMetrics, key []string, val float32, labels []metrics.Label) {
+	k, name := i.flattenKeyLabels(key, labels)
+	intv.Gauges[k] = GaugeValue{Name: name, Value: val, Labels: labels, key: copyKey(key)}
+}
 
+func (i *Sink) emitKey(intv *IntervalMetrics, key []string, val float32) {
+	k := i.flattenKey(key)
+	vals, ok := intv.Points[k]
+	if !ok {
+		if intv.pointKeys == nil {
+			intv.pointKeys = make(map[string][]string)
+		}
+		intv.pointKeys[k] = copyKey(key)
+	}
+	intv.Points[k] = append(vals, val)
+}
+
+func (i *Sink) incrCounter(intv *IntervalMetrics, key []string, val float64, labels []metrics.Label) {
+	k, name := i.flattenKeyLabels(key, labels)
 	agg, ok := intv.Counters[k]
 	if !ok {
 		agg = SampledValue{
 			Name:            name,
 			AggregateSample: &AggregateSample{},
 			Labels:          labels,
+			key:             copyKey(key),
 		}
 		intv.Counters[k] = agg
 	}
-	agg.Ingest(float64(val), i.rateDenom)
-}
-
-// AddSample adds a sample metrics
-func (i *Sink) AddSample(key []string, val float32) {
-	i.AddSampleWithLabels(key, val, nil)
+	agg.ingest(val, i.rateDenom, i.clock.Now())
 }
 
-// AddSampleWithLabels adds a sample metrics with labels
-func (i *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+func (i *Sink) addSample(intv *IntervalMetrics, key []string, val float32, labels []metrics.Label) {
 	k, name := i.flattenKeyLabels(key, labels)
-	intv := i.getInterval()
-
-	intv.Lock()
-	defer intv.Unlock()
-
 	agg, ok := intv.Samples[k]
 	if !ok {
 		agg = SampledValue{
 			Name:            name,
 			AggregateSample: &AggregateSample{},
 			Labels:          labels,
+			key:             copyKey(key),
 		}
 		intv.Samples[k] = agg
 	}
-	agg.Ingest(float64(val), i.rateDenom)
+	agg.ingest(float64(val), i.rateDenom, i.clock.Now())
+	agg.keep(float64(val))
 }
 
-// Data is used to retrieve all the aggregated metrics
+// lockedSink aggregates on an interval whose lock is already held
+type lockedSink struct {
+	sink *Sink
+	intv *IntervalMetrics
+
+	// Gauges set, their watchers being called once the lock is released
+	// so they can emit on the sink
+	gauges []watchedGauge
+}
+
+// watchedGauge is a gauge value waiting for its watchers to be called
+type watchedGauge struct {
+	key []string
+	val float32
+}
+
+// notifyWatchers calls the watchers of the gauges set, the interval
+// lock must not be held
+func (l *lockedSink) notifyWatchers() {
+	for _, g := range l.gauges {
+		l.sink.notifyWatchers(g.key, g.val)
+	}
+	l.gauges = nil
+}
+
+func (l *lockedSink) SetGauge(key []string, val float32) {
+	l.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels queues the call of the watchers until the interval
+// lock is released
+func (l *lockedSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	l.sink.setGauge(l.intv, key, val, labels)
+	l.gauges = append(l.gauges, watchedGauge{key: copyKey(key), val: val})
+}
+
+func (l *lockedSink) EmitKey(key []string, val float32) {
+	l.sink.emitKey(l.intv, key, val)
+}
+
+func (l *lockedSink) IncrCounter(key []string, val float32) {
+	l.sink.incrCounter(l.intv, key, float64(val), nil)
+}
+
+func (l *lockedSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	l.sink.incrCounter(l.intv, key, float64(val), labels)
+}
+
+func (l *lockedSink) IncrCounterFloat64(key []string, val float64) {
+	l.sink.incrCounter(l.intv, key, val, nil)
+}
+
+func (l *lockedSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	l.sink.incrCounter(l.intv, key, val, labels)
+}
+
+func (l *lockedSink) AddSample(key []string, val float32) {
+	l.sink.addSample(l.intv, key, val, nil)
+}
+
+func (l *lockedSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	l.sink.addSample(l.intv, key, val, labels)
+}
+
+// Data is used to retrieve all the aggregated metrics, the intervals
+// older than the retention being pruned first.
 // Intervals may be in use, and a read lock should be acquired
 func (i *Sink) Data() []*IntervalMetrics {
 	// Get the current interval, forces creation
 	i.getInterval()
 
-	i.intervalLock.RLock()
-	defer i.intervalLock.RUnlock()
+	i.intervalLock.Lock()
+	defer i.intervalLock.Unlock()
+
+	i.prune()
+	return i.copyIntervals()
+}
 
+// Retain returns how long the intervals are kept
+func (i *Sink) Retain() time.Duration {
+	return i.retain
+}
+
+// Prune removes the intervals started before the retention, without
+// waiting for the next interval to be created
+func (i *Sink) Prune() {
+	i.intervalLock.Lock()
+	defer i.intervalLock.Unlock()
+
+	i.prune()
+}
+
+// prune removes the intervals started before the retention, always
+// keeping the current one. The interval write lock must be held.
+func (i *Sink) prune() {
+	cutoff := i.clock.Now().Add(-i.retain)
+	n := 0
+	for n < len(i.intervals)-1 && i.intervals[n].Interval.Before(cutoff) {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	copy(i.intervals, i.intervals[n:])
+	for k := len(i.intervals) - n; k < len(i.intervals); k++ {
+		i.intervals[k] = nil
+	}
+	i.intervals = i.intervals[:len(i.intervals)-n]
+}
+
+// Snapshot returns a copy of the current interval, which is not updated
+// afterwards and can be read without racing with the sink
+func (i *Sink) Snapshot() *IntervalMetrics {
+	return copyInterval(i.getInterval())
+}
+
+// copyIntervals copies the intervals, making its own copy of the
+// current one. The interval lock must be held.
+func (i *Sink) copyIntervals() []*IntervalMetrics {
 	n := len(i.intervals)
 	intervals := make([]*IntervalMetrics, n)
 
 	copy(intervals[:n-1], i.intervals[:n-1])
-	current := i.intervals[n-1]
+	intervals[n-1] = copyInterval(i.intervals[n-1])
+	return intervals
+}
 
-	// make its own copy for current interval
-	intervals[n-1] = &IntervalMetrics{}
-	copyCurrent := intervals[n-1]
+// copyInterval copies an interval still being updated, under its read lock
+func copyInterval(current *IntervalMetrics) *IntervalMetrics {
+	copyCurrent := &IntervalMetrics{}
 	current.RLock()
-	*copyCurrent = *current
+	defer current.RUnlock()
+	copyCurrent.Interval = current.Interval
 
 	copyCurrent.Gauges = make(map[string]GaugeValue, len(current.Gauges))
 	for k, v := range current.Gauges {
@@ -243,17 +444,31 @@ func (i *Sink) Data() []*IntervalMetrics {
 	for k, v := range current.Points {
 		copyCurrent.Points[k] = v
 	}
+	if current.pointKeys != nil {
+		copyCurrent.pointKeys = make(map[string][]string, len(current.pointKeys))
+		for k, v := range current.pointKeys {
+			copyCurrent.pointKeys[k] = v
+		}
+	}
 	copyCurrent.Counters = make(map[string]SampledValue, len(current.Counters))
 	for k, v := range current.Counters {
-		copyCurrent.Counters[k] = v
+		copyCurrent.Counters[k] = copySampledValue(v)
 	}
 	copyCurrent.Samples = make(map[string]SampledValue, len(current.Samples))
 	for k, v := range current.Samples {
-		copyCurrent.Samples[k] = v
+		copyCurrent.Samples[k] = copySampledValue(v)
 	}
-	current.RUnlock()
+	return copyCurrent
+}
 
-	return intervals
+// copySampledValue copies the aggregate of a value still being updated
+func copySampledValue(v SampledValue) SampledValue {
+	agg := *v.AggregateSample
+	if agg.values != nil {
+		agg.values = append([]float64(nil), agg.values...)
+	}
+	v.AggregateSample = &agg
+	return v
 }
 
 func (i *Sink) getExistingInterval(intv time.Time) *IntervalMetrics {
@@ -268,13 +483,28 @@ func (i *Sink) getExistingInterval(intv time.Time) *IntervalMetrics {
 }
 
 func (i *Sink) createInterval(intv time.Time) *IntervalMetrics {
+	current, finished := i.appendInterval(intv)
+	if finished != nil {
+		finished.finish()
+	}
+	return current
+}
+
+// appendInterval returns the interval to write to, and the interval it
+// replaces as current, if any
+func (i *Sink) appendInterval(intv time.Time) (*IntervalMetrics, *IntervalMetrics) {
 	i.intervalLock.Lock()
 	defer i.intervalLock.Unlock()
 
 	// Check for an existing interval
 	n := len(i.intervals)
 	if n > 0 && i.intervals[n-1].Interval == intv {
-		return i.intervals[n-1]
+		return i.intervals[n-1], nil
+	}
+
+	var finished *IntervalMetrics
+	if n > 0 {
+		finished = i.intervals[n-1]
 	}
 
 	// Add the current interval
@@ -287,18 +517,23 @@ func (i *Sink) createInterval(intv time.Time) *IntervalMetrics {
 		copy(i.intervals[0:], i.intervals[n-i.maxIntervals:])
 		i.intervals = i.intervals[:i.maxIntervals]
 	}
-	return current
+	return current, finished
 }
 
 // getInterval returns the current interval to write to
 func (i *Sink) getInterval() *IntervalMetrics {
-	intv := time.Now().Truncate(i.interval)
+	intv := i.clock.Now().Truncate(i.interval)
 	if m := i.getExistingInterval(intv); m != nil {
 		return m
 	}
 	return i.createInterval(intv)
 }
 
+// copyKey copies the key parts, so the caller may reuse its slice
+func copyKey(key []string) []string {
+	return append([]string(nil), key...)
+}
+
 // Flattens the key for formatting, removes spaces
 func (i *Sink) flattenKey(parts []string) string {
 	buf := &bytes.Buffer{}
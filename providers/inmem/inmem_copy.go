@@ -0,0 +1,98 @@
+package inmem
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// CopyTo replays the retained intervals starting at or after since into
+// the given sink, in chronological order, ex: when switching from the
+// in-memory sink to a real backend. Metrics are replayed with the key
+// parts they were emitted with. Gauges are replayed as their last value,
+// points as they were emitted and counters as a single increment of their
+// sum. Samples are replayed as their kept values, which are all of them
+// unless more than maxSampleValues were added in the interval.
+func (i *Sink) CopyTo(sink metrics.Sinker, since time.Time) error {
+	if sink == nil {
+		return errors.New("inmem: cannot copy to a nil sink")
+	}
+	return copyIntervals(sink, i.Data(), since)
+}
+
+// CopyTo replays the retained intervals starting at or after since into
+// the given sink, see Sink.CopyTo
+func (s *ShardedSink) CopyTo(sink metrics.Sinker, since time.Time) error {
+	if sink == nil {
+		return errors.New("inmem: cannot copy to a nil sink")
+	}
+	return copyIntervals(sink, s.Data(), since)
+}
+
+func copyIntervals(sink metrics.Sinker, intervals []*IntervalMetrics, since time.Time) error {
+	counters := metrics.AsFloat64Sink(sink)
+	for _, intv := range intervals {
+		if intv.Interval.Before(since) {
+			continue
+		}
+
+		intv.RLock()
+		for _, k := range sortedKeys(intv.Gauges) {
+			g := intv.Gauges[k]
+			sink.SetGaugeWithLabels(keyParts(g.key, g.Name), g.Value, g.Labels)
+		}
+		for _, k := range sortedKeys(intv.Points) {
+			key := keyParts(intv.pointKeys[k], k)
+			for _, val := range intv.Points[k] {
+				sink.EmitKey(key, val)
+			}
+		}
+		for _, k := range sortedKeys(intv.Counters) {
+			c := intv.Counters[k]
+			counters.IncrCounterWithLabelsFloat64(keyParts(c.key, c.Name), c.Sum, c.Labels)
+		}
+		for _, k := range sortedKeys(intv.Samples) {
+			s := intv.Samples[k]
+			key := keyParts(s.key, s.Name)
+			for _, val := range s.values {
+				sink.AddSampleWithLabels(key, float32(val), s.Labels)
+			}
+		}
+		intv.RUnlock()
+	}
+	return nil
+}
+
+// keyParts returns the key parts a value was emitted with, or its
+// flattened name for values not created by the sink
+func keyParts(key []string, name string) []string {
+	if key == nil {
+		return []string{name}
+	}
+	return key
+}
+
+// sortedKeys returns the keys of the map in order, so metrics are replayed
+// deterministically. The map values must be GaugeValue, []float32 or
+// SampledValue.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]GaugeValue:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string][]float32:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]SampledValue:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package inmem
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+type record struct {
+	method string
+	key    []string
+	val    float32
+	labels []metrics.Label
+}
+
+type recordingSink struct {
+	records []record
+}
+
+func (r *recordingSink) add(method string, key []string, val float32, labels []metrics.Label) {
+	r.records = append(r.records, record{method, key, val, labels})
+}
+func (r *recordingSink) SetGauge(key []string, val float32) { r.add("gauge", key, val, nil) }
+func (r *recordingSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	r.add("gauge", key, val, labels)
+}
+func (r *recordingSink) EmitKey(key []string, val float32)     { r.add("kv", key, val, nil) }
+func (r *recordingSink) IncrCounter(key []string, val float32) { r.add("counter", key, val, nil) }
+func (r *recordingSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	r.add("counter", key, val, labels)
+}
+func (r *recordingSink) AddSample(key []string, val float32) { r.add("sample", key, val, nil) }
+func (r *recordingSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	r.add("sample", key, val, labels)
+}
+
+func TestInmemSink_CopyTo(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+	l := []metrics.Label{{Name: "a", Value: "b"}}
+
+	inm.SetGaugeWithLabels([]string{"foo", "gauge"}, 1, l)
+	inm.EmitKey([]string{"foo", "key"}, 2)
+	inm.EmitKey([]string{"foo", "key"}, 3)
+	inm.IncrCounter([]string{"foo", "counter"}, 4)
+	inm.IncrCounter([]string{"foo", "counter"}, 5)
+	for _, v := range []float32{1, 2, 3, 10} {
+		inm.AddSampleWithLabels([]string{"foo", "sample"}, v, l)
+	}
+
+	r := &recordingSink{}
+	if err := inm.CopyTo(r, time.Time{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []record{
+		{"gauge", []string{"foo", "gauge"}, 1, l},
+		{"kv", []string{"foo", "key"}, 2, nil},
+		{"kv", []string{"foo", "key"}, 3, nil},
+		{"counter", []string{"foo", "counter"}, 9, nil},
+		{"sample", []string{"foo", "sample"}, 1, l},
+		{"sample", []string{"foo", "sample"}, 2, l},
+		{"sample", []string{"foo", "sample"}, 3, l},
+		{"sample", []string{"foo", "sample"}, 10, l},
+	}
+	if !reflect.DeepEqual(r.records, expected) {
+		t.Fatalf("expected %v got %v", expected, r.records)
+	}
+}
+
+func TestInmemSink_CopyToKeyParts(t *testing.T) {
+	inm := NewShardedSink(2, time.Minute, time.Hour)
+
+	// The key parts are kept apart and unsanitized, even if the caller
+	// reuses its slice
+	key := []string{"foo bar", "baz"}
+	inm.EmitKey(key, 1)
+	inm.AddSample(key, 2)
+	key[1] = "mutated"
+
+	r := &recordingSink{}
+	if err := inm.CopyTo(r, time.Time{}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []record{
+		{"kv", []string{"foo bar", "baz"}, 1, nil},
+		{"sample", []string{"foo bar", "baz"}, 2, nil},
+	}
+	if !reflect.DeepEqual(r.records, expected) {
+		t.Fatalf("expected %v got %v", expected, r.records)
+	}
+}
+
+func TestInmemSink_CopyToSince(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+	inm.IncrCounter([]string{"counter"}, 1)
+
+	r := &recordingSink{}
+	if err := inm.CopyTo(r, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(r.records) != 0 {
+		t.Fatalf("intervals before since must be skipped %v", r.records)
+	}
+
+	if err := inm.CopyTo(nil, time.Time{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -26,6 +26,9 @@ type GaugeValue struct {
 
 	Labels        []metrics.Label   `json:"-"`
 	DisplayLabels map[string]string `json:"Labels"`
+
+	// key holds the key parts the value was emitted with
+	key []string
 }
 
 // PointValue
@@ -44,12 +47,17 @@ type SampledValue struct {
 
 	Labels        []metrics.Label   `json:"-"`
 	DisplayLabels map[string]string `json:"Labels"`
+
+	// key holds the key parts the value was emitted with
+	key []string
 }
 
 // DisplayMetrics returns a summary of the metrics from the most recent finished interval.
 func (i *Sink) DisplayMetrics(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
-	data := i.Data()
+	return displayMetrics(i.Data())
+}
 
+func displayMetrics(data []*IntervalMetrics) (interface{}, error) {
 	var interval *IntervalMetrics
 	n := len(data)
 	switch {
@@ -57,10 +65,10 @@ func (i *Sink) DisplayMetrics(resp http.ResponseWriter, req *http.Request) (inte
 		return nil, fmt.Errorf("no metric intervals have been initialized yet")
 	case n == 1:
 		// Show the current interval if it's all we have
-		interval = i.intervals[0]
+		interval = data[0]
 	default:
 		// Show the most recent finished interval if we have one
-		interval = i.intervals[n-2]
+		interval = data[n-2]
 	}
 
 	summary := MetricsSummary{
@@ -5,11 +5,13 @@ import (
 	"time"
 
 	"github.com/hugoluchessi/go-metrics"
+	mtesting "github.com/hugoluchessi/go-metrics/providers/testing"
 )
 
 func TestDisplayMetrics(t *testing.T) {
 	interval := 10 * time.Millisecond
-	inm := NewSink(interval, 50*time.Millisecond)
+	clock := mtesting.NewMockClock(time.Unix(1000, 0))
+	inm := NewSink(interval, 50*time.Millisecond, WithClock(clock))
 
 	// Add data points
 	inm.SetGauge([]string{"foo", "bar"}, 42)
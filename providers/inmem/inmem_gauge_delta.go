@@ -0,0 +1,86 @@
+package inmem
+
+import (
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// The following methods implement metrics.GaugeDeltaSink, the delta is
+// applied to the gauge of the current interval under its lock. A gauge
+// not set yet in the current interval starts from its last value in the
+// retained intervals, or 0.
+
+// IncrGauge increases the value of a gauge by a given value
+func (i *Sink) IncrGauge(key []string, val float32) {
+	i.IncrGaugeWithLabels(key, val, nil)
+}
+
+// IncrGaugeWithLabels increases the value of a gauge by a given value with labels
+func (i *Sink) IncrGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	intv := i.getInterval()
+	k, name := i.flattenKeyLabels(key, labels)
+	last := i.lastGauge(intv, k)
+
+	intv.Lock()
+	g, ok := intv.Gauges[k]
+	if !ok {
+		g = GaugeValue{Name: name, Value: last, Labels: labels, key: copyKey(key)}
+	}
+	g.Value += val
+	intv.Gauges[k] = g
+	intv.Unlock()
+	i.notifyWatchers(key, g.Value)
+}
+
+// DecrGauge decreases the value of a gauge by a given value
+func (i *Sink) DecrGauge(key []string, val float32) {
+	i.IncrGaugeWithLabels(key, -val, nil)
+}
+
+// DecrGaugeWithLabels decreases the value of a gauge by a given value with labels
+func (i *Sink) DecrGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	i.IncrGaugeWithLabels(key, -val, labels)
+}
+
+// lastGauge returns the last value of the gauge in the intervals
+// preceding the given one, or 0
+func (i *Sink) lastGauge(intv *IntervalMetrics, k string) float32 {
+	i.intervalLock.RLock()
+	previous := make([]*IntervalMetrics, 0, len(i.intervals))
+	for _, m := range i.intervals {
+		if m == intv {
+			break
+		}
+		previous = append(previous, m)
+	}
+	i.intervalLock.RUnlock()
+
+	for j := len(previous) - 1; j >= 0; j-- {
+		previous[j].RLock()
+		g, ok := previous[j].Gauges[k]
+		previous[j].RUnlock()
+		if ok {
+			return g.Value
+		}
+	}
+	return 0
+}
+
+// IncrGauge increases the value of a gauge by a given value
+func (s *ShardedSink) IncrGauge(key []string, val float32) {
+	s.shard(key, nil).IncrGaugeWithLabels(key, val, nil)
+}
+
+// IncrGaugeWithLabels increases the value of a gauge by a given value with labels
+func (s *ShardedSink) IncrGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.shard(key, labels).IncrGaugeWithLabels(key, val, labels)
+}
+
+// DecrGauge decreases the value of a gauge by a given value
+func (s *ShardedSink) DecrGauge(key []string, val float32) {
+	s.shard(key, nil).IncrGaugeWithLabels(key, -val, nil)
+}
+
+// DecrGaugeWithLabels decreases the value of a gauge by a given value with labels
+func (s *ShardedSink) DecrGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.shard(key, labels).IncrGaugeWithLabels(key, -val, labels)
+}
@@ -0,0 +1,64 @@
+package inmem
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	mtesting "github.com/hugoluchessi/go-metrics/providers/testing"
+)
+
+func TestInmemSink_GaugeDelta(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+	var _ metrics.GaugeDeltaSink = inm
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				inm.IncrGauge([]string{"gauge"}, 2)
+				inm.DecrGauge([]string{"gauge"}, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	inm.SetGaugeWithLabels([]string{"gauge"}, 10, []metrics.Label{{Name: "a", Value: "b"}})
+	inm.DecrGaugeWithLabels([]string{"gauge"}, 4, []metrics.Label{{Name: "a", Value: "b"}})
+
+	snap := inm.Snapshot()
+	if v := snap.Gauges["gauge"].Value; v != 800 {
+		t.Fatalf("bad val: %v", v)
+	}
+	if g := snap.Gauges["gauge;a=b"]; g.Value != 6 || g.Name != "gauge" {
+		t.Fatalf("bad val: %v", g)
+	}
+}
+
+func TestInmemSink_GaugeDeltaInterval(t *testing.T) {
+	clock := mtesting.NewMockClock(time.Unix(1000, 0))
+	inm := NewSink(10*time.Millisecond, 50*time.Millisecond, WithClock(clock))
+	inm.SetGauge([]string{"gauge"}, 5)
+	clock.Advance(10 * time.Millisecond)
+
+	// The delta applies to the last value of the previous interval
+	inm.IncrGauge([]string{"gauge"}, 1)
+	if v := inm.Snapshot().Gauges["gauge"].Value; v != 6 {
+		t.Fatalf("bad val: %v", v)
+	}
+}
+
+func TestShardedSink_GaugeDelta(t *testing.T) {
+	inm := NewShardedSink(4, time.Minute, time.Hour)
+	inm.IncrGauge([]string{"gauge"}, 3)
+	inm.DecrGauge([]string{"gauge"}, 1)
+	inm.IncrGaugeWithLabels([]string{"gauge"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	inm.DecrGaugeWithLabels([]string{"gauge"}, 3, []metrics.Label{{Name: "a", Value: "b"}})
+
+	snap := inm.Snapshot()
+	if snap.Gauges["gauge"].Value != 2 || snap.Gauges["gauge;a=b"].Value != -2 {
+		t.Fatalf("bad val: %v", snap.Gauges)
+	}
+}
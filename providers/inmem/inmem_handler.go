@@ -0,0 +1,153 @@
+package inmem
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// JSONVersion is the version of the JSON document served by MetricsHandler,
+// it changes only when the document is changed incompatibly
+const JSONVersion = 1
+
+// JSONMetrics is the JSON document served by MetricsHandler
+type JSONMetrics struct {
+	Version   int           `json:"version"`
+	Timestamp string        `json:"timestamp"`
+	Gauges    []JSONGauge   `json:"gauges"`
+	Points    []JSONPoints  `json:"points"`
+	Counters  []JSONCounter `json:"counters"`
+	Samples   []JSONSample  `json:"samples"`
+}
+
+// JSONGauge holds the last value of a gauge
+type JSONGauge struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  float32           `json:"value"`
+}
+
+// JSONPoints holds the values emitted for a key
+type JSONPoints struct {
+	Name   string    `json:"name"`
+	Values []float32 `json:"values"`
+}
+
+// JSONCounter holds the total of a counter
+type JSONCounter struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Count  int               `json:"count"`
+	Total  float64           `json:"total"`
+}
+
+// JSONSample holds the summary statistics of a sample, the percentiles
+// are estimated from up to 1024 values
+type JSONSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Count  int               `json:"count"`
+	Min    float64           `json:"min"`
+	Max    float64           `json:"max"`
+	Mean   float64           `json:"mean"`
+	P50    float64           `json:"p50"`
+	P95    float64           `json:"p95"`
+	P99    float64           `json:"p99"`
+}
+
+// MetricsHandler returns a handler serving the metrics of the current
+// interval as JSON, indented with the "pretty=1" query parameter
+func (i *Sink) MetricsHandler() http.Handler {
+	return metricsHandler(i.Snapshot)
+}
+
+// MetricsHandler returns a handler serving the metrics of the current
+// interval as JSON, see Sink.MetricsHandler
+func (s *ShardedSink) MetricsHandler() http.Handler {
+	return metricsHandler(s.Snapshot)
+}
+
+func metricsHandler(snapshot func() *IntervalMetrics) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		doc := jsonMetrics(snapshot())
+
+		var (
+			body []byte
+			err  error
+		)
+		if req.URL.Query().Get("pretty") == "1" {
+			body, err = json.MarshalIndent(doc, "", "  ")
+		} else {
+			body, err = json.Marshal(doc)
+		}
+		if err != nil {
+			http.Error(resp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(body)
+	})
+}
+
+// jsonMetrics builds the JSON document of an interval, sorting each metric
+// type so it is serialized in a deterministic order
+func jsonMetrics(intv *IntervalMetrics) JSONMetrics {
+	intv.RLock()
+	defer intv.RUnlock()
+
+	doc := JSONMetrics{
+		Version:   JSONVersion,
+		Timestamp: intv.Interval.UTC().Format(time.RFC3339),
+		Gauges:    make([]JSONGauge, 0, len(intv.Gauges)),
+		Points:    make([]JSONPoints, 0, len(intv.Points)),
+		Counters:  make([]JSONCounter, 0, len(intv.Counters)),
+		Samples:   make([]JSONSample, 0, len(intv.Samples)),
+	}
+
+	for _, k := range sortedKeys(intv.Gauges) {
+		g := intv.Gauges[k]
+		doc.Gauges = append(doc.Gauges, JSONGauge{
+			Name:   g.Name,
+			Labels: labelsMap(g.Labels),
+			Value:  g.Value,
+		})
+	}
+	for _, k := range sortedKeys(intv.Points) {
+		doc.Points = append(doc.Points, JSONPoints{Name: k, Values: intv.Points[k]})
+	}
+	for _, k := range sortedKeys(intv.Counters) {
+		c := intv.Counters[k]
+		doc.Counters = append(doc.Counters, JSONCounter{
+			Name:   c.Name,
+			Labels: labelsMap(c.Labels),
+			Count:  c.Count,
+			Total:  c.Sum,
+		})
+	}
+	for _, k := range sortedKeys(intv.Samples) {
+		s := intv.Samples[k]
+		doc.Samples = append(doc.Samples, JSONSample{
+			Name:   s.Name,
+			Labels: labelsMap(s.Labels),
+			Count:  s.Count,
+			Min:    s.Min,
+			Max:    s.Max,
+			Mean:   s.AggregateSample.Mean(),
+			P50:    s.Percentile(50),
+			P95:    s.Percentile(95),
+			P99:    s.Percentile(99),
+		})
+	}
+	return doc
+}
+
+func labelsMap(labels []metrics.Label) map[string]string {
+	m := make(map[string]string, len(labels))
+	for _, label := range labels {
+		m[label.Name] = label.Value
+	}
+	return m
+}
@@ -0,0 +1,88 @@
+package inmem
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+	inm.SetGaugeWithLabels([]string{"foo", "bar"}, 42, []metrics.Label{{Name: "a", Value: "b"}})
+	inm.EmitKey([]string{"foo", "bar"}, 7)
+	inm.IncrCounter([]string{"foo", "bar"}, 20)
+	inm.IncrCounter([]string{"foo", "bar"}, 22)
+	for v := 1; v <= 100; v++ {
+		inm.AddSample([]string{"foo", "bar"}, float32(v))
+	}
+
+	rec := httptest.NewRecorder()
+	inm.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("bad content type %q", ct)
+	}
+
+	var doc JSONMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if doc.Version != 1 {
+		t.Fatalf("bad version %d", doc.Version)
+	}
+	expGauges := []JSONGauge{{Name: "foo.bar", Labels: map[string]string{"a": "b"}, Value: 42}}
+	if !reflect.DeepEqual(doc.Gauges, expGauges) {
+		t.Fatalf("bad gauges %v", doc.Gauges)
+	}
+	if !reflect.DeepEqual(doc.Points, []JSONPoints{{Name: "foo.bar", Values: []float32{7}}}) {
+		t.Fatalf("bad points %v", doc.Points)
+	}
+	expCounters := []JSONCounter{{Name: "foo.bar", Labels: map[string]string{}, Count: 2, Total: 42}}
+	if !reflect.DeepEqual(doc.Counters, expCounters) {
+		t.Fatalf("bad counters %v", doc.Counters)
+	}
+	if len(doc.Samples) != 1 {
+		t.Fatalf("bad samples %v", doc.Samples)
+	}
+	sample := doc.Samples[0]
+	sample.P95 = math.Round(sample.P95*100) / 100
+	sample.P99 = math.Round(sample.P99*100) / 100
+	expSample := JSONSample{
+		Name: "foo.bar", Labels: map[string]string{}, Count: 100,
+		Min: 1, Max: 100, Mean: 50.5, P50: 50.5, P95: 95.05, P99: 99.01,
+	}
+	if !reflect.DeepEqual(sample, expSample) {
+		t.Fatalf("bad sample %v", sample)
+	}
+
+	if strings.Contains(rec.Body.String(), "\n") {
+		t.Fatalf("unexpected indentation")
+	}
+	rec = httptest.NewRecorder()
+	inm.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics?pretty=1", nil))
+	if !strings.HasPrefix(rec.Body.String(), "{\n  \"version\": 1,") {
+		t.Fatalf("bad pretty body %s", rec.Body.String())
+	}
+}
+
+func TestMetricsHandler_Sharded(t *testing.T) {
+	s := NewShardedSink(4, time.Minute, time.Hour)
+	s.IncrCounter([]string{"a"}, 1)
+	s.IncrCounter([]string{"b"}, 2)
+
+	rec := httptest.NewRecorder()
+	s.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	var doc JSONMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(doc.Counters) != 2 || doc.Counters[0].Name != "a" || doc.Counters[1].Total != 2 {
+		t.Fatalf("bad counters %v", doc.Counters)
+	}
+}
@@ -0,0 +1,95 @@
+package inmem
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// maxSampleValues is the maximum number of values kept by a sample
+const maxSampleValues = 1024
+
+// Percentile returns the p-th percentile, in [0, 100], of the values of the
+// sample with the given key, including its labels, ex: "foo.bar;a=b".
+// It returns an error if the key is unknown or the sample has no values.
+func (intv *IntervalMetrics) Percentile(key string, p float64) (float64, error) {
+	if p < 0 || p > 100 || math.IsNaN(p) {
+		return 0, fmt.Errorf("inmem: percentile must be in [0, 100], got %v", p)
+	}
+
+	intv.RLock()
+	defer intv.RUnlock()
+
+	agg, ok := intv.Samples[key]
+	if !ok {
+		return 0, fmt.Errorf("inmem: unknown sample %q", key)
+	}
+	if len(agg.values) == 0 {
+		return 0, fmt.Errorf("inmem: sample %q has no values", key)
+	}
+	return agg.Percentile(p), nil
+}
+
+// finish sorts the values of the samples of a finished interval and
+// computes their percentiles, so they are not sorted again on each read
+func (intv *IntervalMetrics) finish() {
+	intv.Lock()
+	defer intv.Unlock()
+
+	for _, agg := range intv.Samples {
+		sort.Float64s(agg.values)
+		agg.sorted = true
+		agg.P50 = percentile(agg.values, 50)
+		agg.P95 = percentile(agg.values, 95)
+		agg.P99 = percentile(agg.values, 99)
+	}
+}
+
+// keep adds a value to the kept sample values, once the maximum is
+// reached it replaces a random one, so all values are equally likely
+// to be kept. It must be called after Ingest.
+func (a *AggregateSample) keep(v float64) {
+	a.sorted = false
+	if len(a.values) < maxSampleValues {
+		a.values = append(a.values, v)
+		return
+	}
+	if n := rand.Intn(a.Count); n < maxSampleValues {
+		a.values[n] = v
+	}
+}
+
+// Percentile estimates the p-th percentile, in [0, 100], of the sample
+// values, from the values kept. It returns 0 if no value was kept.
+func (a *AggregateSample) Percentile(p float64) float64 {
+	if a.sorted {
+		return percentile(a.values, p)
+	}
+
+	sorted := make([]float64, len(a.values))
+	copy(sorted, a.values)
+	sort.Float64s(sorted)
+	return percentile(sorted, p)
+}
+
+// percentile interpolates linearly between the closest ranks of the
+// sorted values
+func percentile(sorted []float64, p float64) float64 {
+	n := len(sorted)
+	switch {
+	case n == 0:
+		return 0
+	case p <= 0:
+		return sorted[0]
+	case p >= 100:
+		return sorted[n-1]
+	}
+
+	rank := p / 100 * float64(n-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= n {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(rank-float64(lower))
+}
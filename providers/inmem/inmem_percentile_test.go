@@ -0,0 +1,90 @@
+package inmem
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	mtesting "github.com/hugoluchessi/go-metrics/providers/testing"
+)
+
+func TestIntervalMetrics_Percentile(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+	for _, v := range []float32{40, 10, 30, 20} {
+		inm.AddSampleWithLabels([]string{"foo", "bar"}, v, []metrics.Label{{Name: "a", Value: "b"}})
+	}
+	inm.IncrCounter([]string{"counter"}, 1)
+	intv := inm.Data()[0]
+
+	cases := map[float64]float64{0: 10, 25: 17.5, 50: 25, 100: 40}
+	for p, expected := range cases {
+		got, err := intv.Percentile("foo.bar;a=b", p)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if got != expected {
+			t.Fatalf("bad p%v: %v, expected %v", p, got, expected)
+		}
+	}
+
+	if _, err := intv.Percentile("foo.bar", 50); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := intv.Percentile("counter", 50); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := intv.Percentile("foo.bar;a=b", 101); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestIntervalMetrics_Finish(t *testing.T) {
+	clock := mtesting.NewMockClock(time.Unix(1000, 0))
+	inm := NewSink(10*time.Millisecond, time.Second, WithClock(clock))
+	for v := 100; v > 0; v-- {
+		inm.AddSample([]string{"foo"}, float32(v))
+	}
+
+	// Starting a new interval finishes the previous one
+	clock.Advance(10 * time.Millisecond)
+	inm.AddSample([]string{"foo"}, 1)
+	data := inm.Data()
+	finished := data[len(data)-2]
+
+	finished.RLock()
+	agg := finished.Samples["foo"].AggregateSample
+	if !agg.sorted || !sort.Float64sAreSorted(agg.values) {
+		t.Fatalf("bad values %v", agg.values)
+	}
+	if agg.P50 != percentile(agg.values, 50) || agg.P99 != percentile(agg.values, 99) {
+		t.Fatalf("bad percentiles %v %v %v", agg.P50, agg.P95, agg.P99)
+	}
+	max := agg.Max
+	finished.RUnlock()
+
+	if p, _ := finished.Percentile("foo", 100); p != max {
+		t.Fatalf("bad percentile %v", p)
+	}
+}
+
+func TestAggregateSample_Percentile(t *testing.T) {
+	a := &AggregateSample{}
+	if a.Percentile(50) != 0 {
+		t.Fatalf("bad percentile")
+	}
+
+	for v := 0; v < 10*maxSampleValues; v++ {
+		a.Ingest(float64(v), 1)
+		a.keep(float64(v))
+	}
+	if len(a.values) != maxSampleValues {
+		t.Fatalf("bad kept values %d", len(a.values))
+	}
+	if p := a.Percentile(100); p < 9*maxSampleValues {
+		t.Fatalf("bad percentile %v", p)
+	}
+	if p := a.Percentile(0); p > maxSampleValues {
+		t.Fatalf("bad percentile %v", p)
+	}
+}
@@ -0,0 +1,273 @@
+package inmem
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// ShardedSink provides a MetricSink that does in-memory aggregation like
+// Sink, but spreads the metrics over N shards, each with its own locks, to
+// reduce lock contention on concurrent writes. A metric, identified by its
+// key and labels, is always aggregated on the same shard.
+type ShardedSink struct {
+	shards []*Sink
+}
+
+// NewShardedSink is used to construct a new sharded in-memory sink.
+// Uses the number of shards, an aggregation interval and maximum
+// retention period. A number of shards lower than 1 means 1. The
+// options apply to every shard.
+func NewShardedSink(n int, interval, retain time.Duration, opts ...Option) *ShardedSink {
+	if n < 1 {
+		n = 1
+	}
+	s := &ShardedSink{shards: make([]*Sink, n)}
+	for i := range s.shards {
+		s.shards[i] = NewSink(interval, retain, opts...)
+	}
+	return s
+}
+
+// SetGauge sets a value on a gauge
+func (s *ShardedSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *ShardedSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.shard(key, labels).SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *ShardedSink) EmitKey(key []string, val float32) {
+	s.shard(key, nil).EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *ShardedSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *ShardedSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.shard(key, labels).IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *ShardedSink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *ShardedSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.shard(key, labels).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *ShardedSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *ShardedSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.shard(key, labels).AddSampleWithLabels(key, val, labels)
+}
+
+// Atomically calls fn holding the current interval lock of every shard,
+// locked in a fixed order, so the metrics emitted on the given sink are
+// all visible at once to Data readers. It implements metrics.AtomicSinker.
+func (s *ShardedSink) Atomically(fn func(s metrics.Sinker)) {
+	locked := &lockedShardedSink{sharded: s, shards: make([]*lockedSink, len(s.shards))}
+	for i, shard := range s.shards {
+		locked.shards[i] = &lockedSink{sink: shard, intv: shard.getInterval()}
+	}
+	defer func() {
+		for _, l := range locked.shards {
+			l.notifyWatchers()
+		}
+	}()
+
+	for _, l := range locked.shards {
+		l.intv.Lock()
+	}
+	defer func() {
+		for _, l := range locked.shards {
+			l.intv.Unlock()
+		}
+	}()
+	fn(locked)
+}
+
+// Data is used to retrieve all the aggregated metrics, merging the
+// shards intervals once pruned. The shards are locked in a fixed order, so the
+// result is consistent across shards.
+func (s *ShardedSink) Data() []*IntervalMetrics {
+	// Get the current intervals, forces creation
+	for _, shard := range s.shards {
+		shard.getInterval()
+	}
+
+	for _, shard := range s.shards {
+		shard.intervalLock.Lock()
+	}
+	data := make([][]*IntervalMetrics, len(s.shards))
+	for i, shard := range s.shards {
+		shard.prune()
+		data[i] = shard.copyIntervals()
+	}
+	for _, shard := range s.shards {
+		shard.intervalLock.Unlock()
+	}
+
+	return mergeIntervals(data)
+}
+
+// Retain returns how long the intervals are kept
+func (s *ShardedSink) Retain() time.Duration {
+	return s.shards[0].Retain()
+}
+
+// Prune removes the intervals of the shards started before the
+// retention, see Sink.Prune
+func (s *ShardedSink) Prune() {
+	for _, shard := range s.shards {
+		shard.Prune()
+	}
+}
+
+// Snapshot returns a copy of the current interval merging the shards,
+// see Sink.Snapshot. When the shards are snapshotted across an interval
+// boundary, only the metrics of the latest interval are returned.
+func (s *ShardedSink) Snapshot() *IntervalMetrics {
+	data := make([][]*IntervalMetrics, len(s.shards))
+	for i, shard := range s.shards {
+		data[i] = []*IntervalMetrics{shard.Snapshot()}
+	}
+	merged := mergeIntervals(data)
+	return merged[len(merged)-1]
+}
+
+// DisplayMetrics returns a summary of the metrics from the most recent finished interval.
+func (s *ShardedSink) DisplayMetrics(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	return displayMetrics(s.Data())
+}
+
+// shard returns the shard aggregating the given metric
+func (s *ShardedSink) shard(key []string, labels []metrics.Label) *Sink {
+	return s.shards[s.shardIndex(key, labels)]
+}
+
+// shardIndex returns the index of the shard aggregating the given metric
+func (s *ShardedSink) shardIndex(key []string, labels []metrics.Label) int {
+	if len(s.shards) == 1 {
+		return 0
+	}
+
+	h := fnv.New32a()
+	for _, part := range key {
+		h.Write([]byte(part))
+		h.Write([]byte{'.'})
+	}
+	for _, label := range labels {
+		h.Write([]byte(label.Name))
+		h.Write([]byte{'='})
+		h.Write([]byte(label.Value))
+		h.Write([]byte{';'})
+	}
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// lockedShardedSink aggregates on shard intervals whose locks are already held
+type lockedShardedSink struct {
+	sharded *ShardedSink
+	shards  []*lockedSink
+}
+
+func (l *lockedShardedSink) shard(key []string, labels []metrics.Label) *lockedSink {
+	return l.shards[l.sharded.shardIndex(key, labels)]
+}
+
+func (l *lockedShardedSink) SetGauge(key []string, val float32) {
+	l.shard(key, nil).SetGauge(key, val)
+}
+
+func (l *lockedShardedSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	l.shard(key, labels).SetGaugeWithLabels(key, val, labels)
+}
+
+func (l *lockedShardedSink) EmitKey(key []string, val float32) {
+	l.shard(key, nil).EmitKey(key, val)
+}
+
+func (l *lockedShardedSink) IncrCounter(key []string, val float32) {
+	l.shard(key, nil).IncrCounter(key, val)
+}
+
+func (l *lockedShardedSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	l.shard(key, labels).IncrCounterWithLabels(key, val, labels)
+}
+
+func (l *lockedShardedSink) IncrCounterFloat64(key []string, val float64) {
+	l.shard(key, nil).IncrCounterFloat64(key, val)
+}
+
+func (l *lockedShardedSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	l.shard(key, labels).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+func (l *lockedShardedSink) AddSample(key []string, val float32) {
+	l.shard(key, nil).AddSample(key, val)
+}
+
+func (l *lockedShardedSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	l.shard(key, labels).AddSampleWithLabels(key, val, labels)
+}
+
+// mergeIntervals merges the intervals of all shards by interval time.
+// Shards never hold the same metric, so their values are not combined.
+func mergeIntervals(data [][]*IntervalMetrics) []*IntervalMetrics {
+	merged := make(map[time.Time]*IntervalMetrics)
+	for _, intervals := range data {
+		for _, intv := range intervals {
+			m, ok := merged[intv.Interval]
+			if !ok {
+				m = NewIntervalMetrics(intv.Interval)
+				merged[intv.Interval] = m
+			}
+
+			intv.RLock()
+			for k, v := range intv.Gauges {
+				m.Gauges[k] = v
+			}
+			for k, v := range intv.Points {
+				m.Points[k] = v
+			}
+			for k, v := range intv.pointKeys {
+				if m.pointKeys == nil {
+					m.pointKeys = make(map[string][]string)
+				}
+				m.pointKeys[k] = v
+			}
+			for k, v := range intv.Counters {
+				m.Counters[k] = v
+			}
+			for k, v := range intv.Samples {
+				m.Samples[k] = v
+			}
+			intv.RUnlock()
+		}
+	}
+
+	intervals := make([]*IntervalMetrics, 0, len(merged))
+	for _, m := range merged {
+		intervals = append(intervals, m)
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Interval.Before(intervals[j].Interval)
+	})
+	return intervals
+}
@@ -0,0 +1,151 @@
+package inmem
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	mtesting "github.com/hugoluchessi/go-metrics/providers/testing"
+)
+
+func TestShardedSink(t *testing.T) {
+	inm := NewShardedSink(4, time.Minute, time.Hour)
+
+	l := []metrics.Label{{Name: "a", Value: "b"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := []string{"foo", fmt.Sprint(i)}
+			for j := 0; j < 100; j++ {
+				inm.IncrCounter(key, 1)
+				inm.IncrCounterWithLabels(key, 2, l)
+			}
+			inm.SetGauge(key, float32(i))
+			inm.EmitKey(key, float32(i))
+			inm.AddSample(key, float32(i))
+		}(i)
+	}
+	wg.Wait()
+
+	data := inm.Data()
+	if len(data) != 1 {
+		t.Fatalf("bad: %v", data)
+	}
+
+	intvM := data[0]
+	for i := 0; i < 8; i++ {
+		k := fmt.Sprintf("foo.%d", i)
+		if agg := intvM.Counters[k]; agg.Count != 100 || agg.Sum != 100 {
+			t.Fatalf("bad val: %v", agg)
+		}
+		if agg := intvM.Counters[k+";a=b"]; agg.Count != 100 || agg.Sum != 200 {
+			t.Fatalf("bad val: %v", agg)
+		}
+		if intvM.Gauges[k].Value != float32(i) {
+			t.Fatalf("bad val: %v", intvM.Gauges)
+		}
+		if intvM.Points[k][0] != float32(i) {
+			t.Fatalf("bad val: %v", intvM.Points)
+		}
+		if agg := intvM.Samples[k]; agg.Count != 1 {
+			t.Fatalf("bad val: %v", agg)
+		}
+	}
+}
+
+func TestShardedSink_Snapshot(t *testing.T) {
+	inm := NewShardedSink(4, time.Minute, time.Hour)
+	for i := 0; i < 8; i++ {
+		inm.IncrCounter([]string{"foo", fmt.Sprint(i)}, float32(i))
+	}
+
+	snap := inm.Snapshot()
+	inm.IncrCounter([]string{"foo", "0"}, 1)
+	if len(snap.Counters) != 8 {
+		t.Fatalf("bad counters: %v", snap.Counters)
+	}
+	for i := 0; i < 8; i++ {
+		if agg := snap.Counters[fmt.Sprintf("foo.%d", i)]; agg.Sum != float64(i) {
+			t.Fatalf("bad val: %v", agg)
+		}
+	}
+}
+
+func TestShardedSink_Shard(t *testing.T) {
+	inm := NewShardedSink(16, time.Minute, time.Hour)
+
+	key := []string{"foo", "bar"}
+	l := []metrics.Label{{Name: "a", Value: "b"}}
+	if inm.shard(key, l) != inm.shard(key, l) {
+		t.Fatalf("a metric must always go to the same shard")
+	}
+
+	if s := NewShardedSink(0, time.Minute, time.Hour); len(s.shards) != 1 {
+		t.Fatalf("bad shards %d", len(s.shards))
+	}
+}
+
+func TestMergeIntervals(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+
+	a := NewIntervalMetrics(now)
+	a.Gauges["a"] = GaugeValue{Name: "a", Value: 1}
+	b := NewIntervalMetrics(now)
+	b.Gauges["b"] = GaugeValue{Name: "b", Value: 2}
+	old := NewIntervalMetrics(now.Add(-time.Minute))
+
+	merged := mergeIntervals([][]*IntervalMetrics{{a}, {old, b}})
+	if len(merged) != 2 {
+		t.Fatalf("bad: %v", merged)
+	}
+	if !merged[0].Interval.Equal(old.Interval) {
+		t.Fatalf("intervals must be sorted")
+	}
+	if len(merged[1].Gauges) != 2 {
+		t.Fatalf("bad val: %v", merged[1].Gauges)
+	}
+}
+
+func TestShardedSink_Batch(t *testing.T) {
+	inm := NewShardedSink(4, time.Minute, time.Hour)
+
+	b := &metrics.Batch{}
+	for i := 0; i < 8; i++ {
+		b.IncrCounter([]string{"foo", fmt.Sprint(i)}, 1)
+	}
+	if err := b.Commit(inm); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if counters := inm.Data()[0].Counters; len(counters) != 8 {
+		t.Fatalf("bad val: %v", counters)
+	}
+}
+
+func TestShardedSink_Retention(t *testing.T) {
+	clock := mtesting.NewMockClock(time.Unix(1000, 0))
+	inm := NewShardedSink(2, 10*time.Second, time.Minute, WithClock(clock))
+	if inm.Retain() != time.Minute {
+		t.Fatalf("bad retain %s", inm.Retain())
+	}
+	inm.IncrCounter([]string{"a"}, 1)
+	clock.Advance(10 * time.Second)
+	inm.IncrCounter([]string{"b"}, 1)
+
+	clock.Advance(65 * time.Second)
+	inm.Prune()
+	for i, shard := range inm.shards {
+		if len(shard.intervals) > 1 {
+			t.Fatalf("bad shard %d intervals %v", i, shard.intervals)
+		}
+	}
+
+	clock.Advance(time.Minute)
+	if data := inm.Data(); len(data) != 1 || len(data[0].Counters) != 0 {
+		t.Fatalf("bad data %v", data)
+	}
+}
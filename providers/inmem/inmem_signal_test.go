@@ -1,7 +1,6 @@
 package inmem
 
 import (
-	"bytes"
 	"os"
 	"strings"
 	"syscall"
@@ -9,12 +8,24 @@ import (
 	"time"
 
 	"github.com/hugoluchessi/go-metrics"
+	mtesting "github.com/hugoluchessi/go-metrics/providers/testing"
 )
 
+// signalWriter notifies the dumps written by a Signal
+type signalWriter struct {
+	dumps chan string
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	w.dumps <- string(p)
+	return len(p), nil
+}
+
 func TestInmemSignal(t *testing.T) {
-	buf := bytes.NewBuffer(nil)
-	inm := NewSink(10*time.Millisecond, 50*time.Millisecond)
-	sig := NewSignal(inm, syscall.SIGUSR1, buf)
+	w := &signalWriter{dumps: make(chan string, 1)}
+	clock := mtesting.NewMockClock(time.Unix(1000, 0))
+	inm := NewSink(10*time.Millisecond, 50*time.Millisecond, WithClock(clock))
+	sig := NewSignal(inm, syscall.SIGUSR1, w)
 	defer sig.Stop()
 
 	inm.SetGauge([]string{"foo"}, 42)
@@ -25,17 +36,21 @@ func TestInmemSignal(t *testing.T) {
 	inm.IncrCounterWithLabels([]string{"qwer"}, 42, []metrics.Label{{Name: "a", Value: "b"}})
 	inm.AddSampleWithLabels([]string{"zxcv"}, 42, []metrics.Label{{Name: "a", Value: "b"}})
 
-	// Wait for period to end
-	time.Sleep(15 * time.Millisecond)
+	// End the period
+	clock.Advance(10 * time.Millisecond)
 
 	// Send signal!
 	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
 
 	// Wait for flush
-	time.Sleep(10 * time.Millisecond)
+	var out string
+	select {
+	case out = <-w.dumps:
+	case <-time.After(time.Second):
+		t.Fatalf("timeout")
+	}
 
 	// Check the output
-	out := string(buf.Bytes())
 	if !strings.Contains(out, "[G] 'foo': 42") {
 		t.Fatalf("bad: %v", out)
 	}
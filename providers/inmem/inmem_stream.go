@@ -0,0 +1,99 @@
+package inmem
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DefaultStreamInterval is the interval the snapshots are streamed at
+const DefaultStreamInterval = time.Second
+
+type streamConfig struct {
+	interval time.Duration
+	pushPath string
+}
+
+// StreamOption is used to configure a StreamHandler
+type StreamOption func(*streamConfig)
+
+// WithStreamInterval sets the interval the snapshots are sent at,
+// defaults to DefaultStreamInterval
+func WithStreamInterval(interval time.Duration) StreamOption {
+	return func(c *streamConfig) {
+		c.interval = interval
+	}
+}
+
+// WithPushPath sets the path of a MetricsHandler served alongside, which
+// is pushed to HTTP/2 clients as the stream starts, so dashboards get a
+// snapshot without waiting for the first event nor requesting it
+func WithPushPath(path string) StreamOption {
+	return func(c *streamConfig) {
+		c.pushPath = path
+	}
+}
+
+// StreamHandler returns a handler streaming the metrics of the current
+// interval as server-sent events, each event holding the JSON document
+// served by MetricsHandler. The stream ends when the client disconnects.
+func (i *Sink) StreamHandler(opts ...StreamOption) http.Handler {
+	return streamHandler(i.Snapshot, opts)
+}
+
+// StreamHandler returns a handler streaming the metrics of the current
+// interval as server-sent events, see Sink.StreamHandler
+func (s *ShardedSink) StreamHandler(opts ...StreamOption) http.Handler {
+	return streamHandler(s.Snapshot, opts)
+}
+
+func streamHandler(snapshot func() *IntervalMetrics, opts []StreamOption) http.Handler {
+	c := &streamConfig{interval: DefaultStreamInterval}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.interval <= 0 {
+		c.interval = DefaultStreamInterval
+	}
+
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		flusher, ok := resp.(http.Flusher)
+		if !ok {
+			http.Error(resp, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Clients may refuse pushes, the stream still serves them
+		if pusher, ok := resp.(http.Pusher); ok && c.pushPath != "" && req.ProtoMajor == 2 {
+			if err := pusher.Push(c.pushPath, nil); err != nil && err != http.ErrNotSupported {
+				log.Printf("[ERR] Error pushing %s! Err: %s", c.pushPath, err)
+			}
+		}
+
+		resp.Header().Set("Content-Type", "text/event-stream")
+		resp.Header().Set("Cache-Control", "no-cache")
+		resp.WriteHeader(http.StatusOK)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			body, err := json.Marshal(jsonMetrics(snapshot()))
+			if err != nil {
+				log.Printf("[ERR] Error encoding metrics stream! Err: %s", err)
+				return
+			}
+			if _, err := fmt.Fprintf(resp, "data: %s\n\n", body); err != nil {
+				return
+			}
+			flusher.Flush()
+
+			select {
+			case <-ticker.C:
+			case <-req.Context().Done():
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package inmem
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamHandler(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+	inm.IncrCounter([]string{"counter"}, 1)
+
+	srv := httptest.NewServer(inm.StreamHandler(WithStreamInterval(10 * time.Millisecond)))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("bad content type %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var events []JSONMetrics
+	for len(events) < 2 && scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var doc JSONMetrics
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &doc); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		events = append(events, doc)
+		inm.IncrCounter([]string{"counter"}, 1)
+	}
+	if len(events) != 2 || events[0].Counters[0].Total != 1 || events[1].Counters[0].Total != 2 {
+		t.Fatalf("bad events %v", events)
+	}
+}
+
+// pushRecorder records the pushes of a streaming handler, closing the
+// request context once the first event is written
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushes []string
+	cancel func()
+}
+
+func (r *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushes = append(r.pushes, target)
+	return nil
+}
+
+func (r *pushRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	r.cancel()
+}
+
+func TestStreamHandler_Push(t *testing.T) {
+	s := NewShardedSink(2, time.Minute, time.Hour)
+	h := s.StreamHandler(WithPushPath("/metrics"))
+
+	for _, proto := range []int{1, 2} {
+		ctx, cancel := context.WithCancel(context.Background())
+		rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+		req := httptest.NewRequest("GET", "/stream", nil).WithContext(ctx)
+		req.ProtoMajor = proto
+		h.ServeHTTP(rec, req)
+
+		if proto == 1 && len(rec.pushes) != 0 {
+			t.Fatalf("unexpected push over HTTP/1: %v", rec.pushes)
+		}
+		if proto == 2 && (len(rec.pushes) != 1 || rec.pushes[0] != "/metrics") {
+			t.Fatalf("bad pushes %v", rec.pushes)
+		}
+		if !strings.HasPrefix(rec.Body.String(), "data: {\"version\":1,") {
+			t.Fatalf("bad body %q", rec.Body.String())
+		}
+	}
+}
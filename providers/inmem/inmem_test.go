@@ -6,10 +6,12 @@ import (
 	"time"
 
 	"github.com/hugoluchessi/go-metrics"
+	mtesting "github.com/hugoluchessi/go-metrics/providers/testing"
 )
 
 func TestInmemSink(t *testing.T) {
-	inm := NewSink(10*time.Millisecond, 50*time.Millisecond)
+	clock := mtesting.NewMockClock(time.Unix(1000, 0))
+	inm := NewSink(10*time.Millisecond, 50*time.Millisecond, WithClock(clock))
 
 	data := inm.Data()
 	if len(data) < 1 {
@@ -36,7 +38,7 @@ func TestInmemSink(t *testing.T) {
 	intvM := data[0]
 	intvM.RLock()
 
-	if time.Now().Sub(intvM.Interval) > 10*time.Millisecond {
+	if clock.Since(intvM.Interval) != 0 {
 		t.Fatalf("interval too old")
 	}
 	if intvM.Gauges["foo.bar"].Value != 42 {
@@ -79,7 +81,7 @@ func TestInmemSink(t *testing.T) {
 			t.Fatalf("agg.LastUpdated is not set: %v", agg)
 		}
 
-		diff := time.Now().Sub(agg.LastUpdated).Seconds()
+		diff := clock.Since(agg.LastUpdated).Seconds()
 		if diff > 1 {
 			t.Fatalf("time diff too great: %f", diff)
 		}
@@ -96,7 +98,7 @@ func TestInmemSink(t *testing.T) {
 	intvM.RUnlock()
 
 	for i := 1; i < 10; i++ {
-		time.Sleep(10 * time.Millisecond)
+		clock.Advance(10 * time.Millisecond)
 		inm.SetGauge([]string{"foo", "bar"}, 42)
 		data = inm.Data()
 		if len(data) != min(i+1, 5) {
@@ -105,7 +107,7 @@ func TestInmemSink(t *testing.T) {
 	}
 
 	// Should not exceed 5 intervals!
-	time.Sleep(10 * time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
 	inm.SetGauge([]string{"foo", "bar"}, 42)
 	data = inm.Data()
 	if len(data) != 5 {
@@ -127,3 +129,112 @@ func duration(t *testing.T, s string) time.Duration {
 	}
 	return dur
 }
+
+func TestInmemSink_RetainShorterThanInterval(t *testing.T) {
+	inm := NewSink(10*time.Second, 5*time.Second)
+	inm.IncrCounter([]string{"foo"}, 1)
+
+	data := inm.Data()
+	if len(data) != 1 || data[0].Counters["foo"].Sum != 1 {
+		t.Fatalf("bad data: %v", data)
+	}
+}
+
+func TestInmemSink_IncrCounterFloat64(t *testing.T) {
+	inm := NewSink(time.Minute, time.Minute)
+	inm.IncrCounterFloat64([]string{"foo"}, 0.1)
+	inm.IncrCounterWithLabelsFloat64([]string{"foo"}, 0.2, []metrics.Label{{Name: "a", Value: "b"}})
+
+	data := inm.Data()
+	if agg := data[0].Counters["foo"]; agg.Sum != 0.1 {
+		t.Fatalf("bad val: %v", agg)
+	}
+	if agg := data[0].Counters["foo;a=b"]; agg.Sum != 0.2 {
+		t.Fatalf("bad val: %v", agg)
+	}
+}
+
+func TestInmemSink_Batch(t *testing.T) {
+	inm := NewSink(time.Minute, time.Minute)
+
+	b := &metrics.Batch{}
+	b.SetGauge([]string{"rows"}, 10)
+	b.IncrCounter([]string{"errors"}, 1)
+	b.AddSample([]string{"duration"}, 20)
+	if err := b.Commit(inm); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	intvM := inm.Data()[0]
+	if intvM.Gauges["rows"].Value != 10 || intvM.Counters["errors"].Sum != 1 || intvM.Samples["duration"].Sum != 20 {
+		t.Fatalf("bad val: %v %v %v", intvM.Gauges, intvM.Counters, intvM.Samples)
+	}
+}
+
+func TestInmemSink_Snapshot(t *testing.T) {
+	inm := NewSink(time.Minute, time.Minute)
+	inm.SetGauge([]string{"gauge"}, 1)
+	inm.IncrCounter([]string{"counter"}, 2)
+	inm.AddSample([]string{"sample"}, 3)
+
+	snap := inm.Snapshot()
+	if snap.Interval != inm.Data()[0].Interval {
+		t.Fatalf("bad interval %v", snap.Interval)
+	}
+
+	// Updates after the snapshot, concurrent with its reads, do not change it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			inm.SetGauge([]string{"gauge"}, 10)
+			inm.IncrCounter([]string{"counter"}, 1)
+			inm.AddSample([]string{"sample"}, 30)
+			inm.SetGauge([]string{"other"}, 1)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if snap.Gauges["gauge"].Value != 1 || snap.Counters["counter"].Sum != 2 || snap.Samples["sample"].Max != 3 {
+			t.Fatalf("bad snapshot: %v %v %v", snap.Gauges, snap.Counters, snap.Samples)
+		}
+		if _, ok := snap.Gauges["other"]; ok {
+			t.Fatalf("bad snapshot: %v", snap.Gauges)
+		}
+	}
+	<-done
+
+	if inm.Snapshot().Counters["counter"].Sum != 102 {
+		t.Fatalf("bad current interval: %v", inm.Snapshot().Counters)
+	}
+}
+
+func TestInmemSink_Retention(t *testing.T) {
+	clock := mtesting.NewMockClock(time.Unix(1000, 0))
+	inm := NewSink(10*time.Second, time.Minute, WithClock(clock))
+	if inm.Retain() != time.Minute {
+		t.Fatalf("bad retain %s", inm.Retain())
+	}
+	for i := 0; i < 3; i++ {
+		inm.IncrCounter([]string{"counter"}, 1)
+		clock.Advance(10 * time.Second)
+	}
+
+	// Pruning does not create the current interval
+	clock.Advance(35 * time.Second)
+	inm.Prune()
+	if len(inm.intervals) != 2 || inm.intervals[0].Interval != time.Unix(1010, 0) {
+		t.Fatalf("bad intervals %v", inm.intervals)
+	}
+
+	data := inm.Data()
+	if len(data) != 3 || data[2].Interval != time.Unix(1060, 0) {
+		t.Fatalf("bad data %v", data)
+	}
+
+	// The intervals are pruned when read, even without new metrics
+	clock.Advance(100 * time.Second)
+	data = inm.Data()
+	if len(data) != 1 || data[0].Interval != time.Unix(1160, 0) {
+		t.Fatalf("bad data %v", data)
+	}
+}
@@ -0,0 +1,77 @@
+package inmem
+
+import "sync"
+
+// watchers holds the functions watching gauges, by flattened key
+type watchers struct {
+	sync.RWMutex
+	nextID uint64
+	byKey  map[string]map[uint64]func(val float32)
+}
+
+// WatchKey calls fn synchronously on each SetGauge of the given key,
+// whatever its labels. Watchers must not block, as they delay the caller.
+// The returned function unregisters the watcher.
+func (i *Sink) WatchKey(key []string, fn func(val float32)) func() {
+	k := i.flattenKey(key)
+
+	i.watchers.Lock()
+	defer i.watchers.Unlock()
+	if i.watchers.byKey == nil {
+		i.watchers.byKey = make(map[string]map[uint64]func(val float32))
+	}
+	fns, ok := i.watchers.byKey[k]
+	if !ok {
+		fns = make(map[uint64]func(val float32))
+		i.watchers.byKey[k] = fns
+	}
+	id := i.watchers.nextID
+	i.watchers.nextID++
+	fns[id] = fn
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			i.watchers.Lock()
+			defer i.watchers.Unlock()
+			current := i.watchers.byKey[k]
+			delete(current, id)
+			if len(current) == 0 {
+				delete(i.watchers.byKey, k)
+			}
+		})
+	}
+}
+
+// WatchKey calls fn synchronously on each SetGauge of the given key,
+// on any of the shards. The returned function unregisters the watcher.
+func (s *ShardedSink) WatchKey(key []string, fn func(val float32)) func() {
+	unwatch := make([]func(), len(s.shards))
+	for n, shard := range s.shards {
+		unwatch[n] = shard.WatchKey(key, fn)
+	}
+	return func() {
+		for _, fn := range unwatch {
+			fn()
+		}
+	}
+}
+
+// notifyWatchers calls the watchers of the gauge key
+func (i *Sink) notifyWatchers(key []string, val float32) {
+	i.watchers.RLock()
+	if len(i.watchers.byKey) == 0 {
+		i.watchers.RUnlock()
+		return
+	}
+	fns := i.watchers.byKey[i.flattenKey(key)]
+	called := make([]func(val float32), 0, len(fns))
+	for _, fn := range fns {
+		called = append(called, fn)
+	}
+	i.watchers.RUnlock()
+
+	for _, fn := range called {
+		fn(val)
+	}
+}
@@ -0,0 +1,111 @@
+package inmem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestInmemSink_WatchKey(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+
+	var got []float32
+	unwatch := inm.WatchKey([]string{"foo", "bar"}, func(val float32) {
+		got = append(got, val)
+	})
+
+	inm.SetGauge([]string{"foo", "bar"}, 1)
+	inm.SetGaugeWithLabels([]string{"foo", "bar"}, 2, []metrics.Label{{Name: "a", Value: "b"}})
+	inm.SetGauge([]string{"foo", "baz"}, 3)
+	inm.IncrCounter([]string{"foo", "bar"}, 4)
+	inm.Atomically(func(s metrics.Sinker) {
+		s.SetGauge([]string{"foo", "bar"}, 5)
+	})
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 5 {
+		t.Fatalf("bad vals: %v", got)
+	}
+
+	unwatch()
+	unwatch()
+	inm.SetGauge([]string{"foo", "bar"}, 6)
+	if len(got) != 3 {
+		t.Fatalf("bad vals: %v", got)
+	}
+	if len(inm.watchers.byKey) != 0 {
+		t.Fatalf("bad watchers: %v", inm.watchers.byKey)
+	}
+}
+
+func TestInmemSink_WatchKeyReentrant(t *testing.T) {
+	inm := NewSink(time.Minute, time.Hour)
+
+	var unwatch func()
+	var gauges int
+	unwatch = inm.WatchKey([]string{"foo"}, func(val float32) {
+		gauges = len(inm.Data()[0].Gauges)
+		unwatch()
+	})
+
+	inm.SetGauge([]string{"foo"}, 1)
+	if gauges != 1 {
+		t.Fatalf("bad gauges: %d", gauges)
+	}
+}
+
+func TestShardedSink_WatchKey(t *testing.T) {
+	inm := NewShardedSink(4, time.Minute, time.Hour)
+
+	var got []float32
+	unwatch := inm.WatchKey([]string{"foo"}, func(val float32) {
+		got = append(got, val)
+	})
+	for i := 0; i < 4; i++ {
+		inm.SetGaugeWithLabels([]string{"foo"}, float32(i), []metrics.Label{{Name: "n", Value: string(rune('a' + i))}})
+	}
+	unwatch()
+	inm.SetGauge([]string{"foo"}, 5)
+
+	if len(got) != 4 {
+		t.Fatalf("bad vals: %v", got)
+	}
+}
+
+// Watchers emitting on the sink are called once the interval lock of a
+// batch is released, instead of deadlocking
+func TestInmemSink_WatchKeyBatch(t *testing.T) {
+	for name, sink := range map[string]interface {
+		metrics.Sinker
+		metrics.AtomicSinker
+		WatchKey(key []string, fn func(val float32)) func()
+		Data() []*IntervalMetrics
+	}{
+		"sink":    NewSink(time.Minute, time.Hour),
+		"sharded": NewShardedSink(4, time.Minute, time.Hour),
+	} {
+		sink.WatchKey([]string{"rows"}, func(val float32) {
+			sink.IncrCounter([]string{"rows", "set"}, val)
+		})
+
+		b := &metrics.Batch{}
+		b.SetGauge([]string{"rows"}, 10)
+		b.SetGauge([]string{"rows"}, 20)
+		done := make(chan error)
+		go func() {
+			done <- b.Commit(sink)
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("%s: err: %v", name, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: deadlock committing the batch", name)
+		}
+
+		intvM := sink.Data()[0]
+		if intvM.Gauges["rows"].Value != 20 || intvM.Counters["rows.set"].Sum != 30 {
+			t.Fatalf("%s: bad val: %v %v", name, intvM.Gauges, intvM.Counters)
+		}
+	}
+}
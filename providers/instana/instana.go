@@ -0,0 +1,202 @@
+package instana
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultPort is the port the Instana agent listens on
+	DefaultPort = 42699
+
+	// endpointPath is the agent custom metrics ingest endpoint
+	endpointPath = "/com.instana.plugin.customdashboard"
+
+	gaugeType   = "gauge"
+	counterType = "counter"
+	sampleType  = "sample"
+)
+
+// Sink provides a MetricSink that pushes custom metrics to the
+// Instana agent REST API, encoded as a JSON array. Labels become tags.
+type Sink struct {
+	service string
+	config  push.Config
+	client  *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// metric is the Instana representation of a custom metric
+type metric struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Value     float64           `json:"value"`
+	Service   string            `json:"service,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// NewSink is used to create a new Sink pushing to the agent at the
+// given host and port, a zero port meaning DefaultPort
+func NewSink(agentHost string, port int, service string, opts ...Option) (*Sink, error) {
+	if agentHost == "" {
+		return nil, fmt.Errorf("agent host must be provided")
+	}
+	if port == 0 {
+		port = DefaultPort
+	}
+	if port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid agent port %d", port)
+	}
+
+	s := &Sink{
+		service: service,
+		config: push.Config{
+			Name:        "instana",
+			URL:         "http://" + net.JoinHostPort(agentHost, strconv.Itoa(port)) + endpointPath,
+			ContentType: "application/json",
+			Encode:      encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+// since Instana has no key/value metric type
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(sampleType, key, float64(val), labels)
+}
+
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	m := metric{
+		Name:      s.flattenKey(key),
+		Type:      metricType,
+		Value:     val,
+		Service:   s.service,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if len(labels) > 0 {
+		m.Tags = make(map[string]string, len(labels))
+		for _, label := range labels {
+			m.Tags[label.Name] = label.Value
+		}
+	}
+	s.client.Push(m)
+}
+
+// Flattens the key for formatting, removes spaces
+func (s *Sink) flattenKey(parts []string) string {
+	return strings.Replace(strings.Join(parts, "."), " ", "_", -1)
+}
+
+// encode formats the metrics as a JSON array
+func encode(records []interface{}) ([]byte, error) {
+	return json.Marshal(records)
+}
@@ -0,0 +1,121 @@
+package instana
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("", 0, "api"); err == nil {
+		t.Fatalf("expected error for missing host")
+	}
+	if _, err := NewSink("localhost", 70000, "api"); err == nil {
+		t.Fatalf("expected error for invalid port")
+	}
+}
+
+func TestNewSink_DefaultPort(t *testing.T) {
+	s, err := NewSink("localhost", 0, "api")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	if s.config.URL != "http://localhost:42699/com.instana.plugin.customdashboard" {
+		t.Fatalf("bad url %s", s.config.URL)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var path string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	host, portStr, _ := net.SplitHostPort(u.Host)
+	port, _ := strconv.Atoi(portStr)
+
+	s, err := NewSink(host, port, "api", WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4)
+	s.Flush()
+
+	if path != "/com.instana.plugin.customdashboard" {
+		t.Fatalf("bad path %s", path)
+	}
+
+	var got []metric
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("bad body %s: %v", body, err)
+	}
+
+	expected := []metric{
+		{Name: "gauge.val", Type: "gauge", Value: 1, Tags: map[string]string{"a": "b"}},
+		{Name: "key", Type: "gauge", Value: 2},
+		{Name: "counter", Type: "counter", Value: 3},
+		{Name: "sample", Type: "sample", Value: 4},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d metrics got %d", len(expected), len(got))
+	}
+	for i, e := range expected {
+		m := got[i]
+		if m.Name != e.Name || m.Type != e.Type || m.Value != e.Value || m.Service != "api" || m.Timestamp == 0 {
+			t.Fatalf("expected %v got %v", e, m)
+		}
+		if len(m.Tags) != len(e.Tags) || m.Tags["a"] != e.Tags["a"] {
+			t.Fatalf("bad tags %v", m.Tags)
+		}
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("localhost", 0, "api", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	host, portStr, _ := net.SplitHostPort(u.Host)
+	port, _ := strconv.Atoi(portStr)
+
+	s, err := NewSink(host, port, "api", WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
@@ -0,0 +1,234 @@
+// Package linewriter provides the buffering and reconnection logic shared
+// by the sinks which write text lines over a TCP connection
+package linewriter
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQueueSize is the number of lines queued before dropping
+	DefaultQueueSize = 4096
+
+	// DefaultMaxBufferLen is the size of the buffer written at once
+	DefaultMaxBufferLen = 64 * 1024
+
+	// DefaultFlushInterval is the period of inactivity after which the
+	// buffered lines are written, so they do not get stuck in the buffer
+	DefaultFlushInterval = 100 * time.Millisecond
+
+	// DefaultReconnectBase is the delay before the first reconnect, doubled
+	// after each consecutive failure up to DefaultReconnectMax
+	DefaultReconnectBase = 500 * time.Millisecond
+	DefaultReconnectMax  = 60 * time.Second
+
+	// DefaultDialTimeout is how long connecting to the server may take
+	DefaultDialTimeout = 10 * time.Second
+
+	// DefaultWriteTimeout is how long writing to the connection may take,
+	// a write exceeding it being handled as a write error
+	DefaultWriteTimeout = 10 * time.Second
+
+	// reconnectJitter is the fraction of the reconnect delay randomly
+	// added or removed, so restarted services do not reconnect together
+	reconnectJitter = 0.1
+)
+
+// Config is used to configure a Writer, the zero durations and sizes
+// defaulting to the Default constants
+type Config struct {
+	// Name identifies the sink on logs
+	Name string
+
+	// Addr is the server address, in the host:port form
+	Addr string
+
+	QueueSize     int
+	MaxBufferLen  int
+	FlushInterval time.Duration
+	DialTimeout   time.Duration
+	WriteTimeout  time.Duration
+	ReconnectBase time.Duration
+	ReconnectMax  time.Duration
+}
+
+// Writer queues lines, writing them over a TCP connection when the buffer
+// is full or the flush interval is reached. The connection is reopened with
+// a backoff after errors, the lines pushed meanwhile being dropped.
+type Writer struct {
+	cfg Config
+
+	queue        chan string
+	doneCh       chan struct{}
+	shutdownOnce sync.Once
+}
+
+// New creates a Writer, starting its flushing goroutine
+func New(cfg Config) (*Writer, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("line writer address must be provided")
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.MaxBufferLen <= 0 {
+		cfg.MaxBufferLen = DefaultMaxBufferLen
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = DefaultDialTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.ReconnectBase <= 0 {
+		cfg.ReconnectBase = DefaultReconnectBase
+	}
+	if cfg.ReconnectMax <= 0 {
+		cfg.ReconnectMax = DefaultReconnectMax
+	}
+	if cfg.ReconnectMax < cfg.ReconnectBase {
+		return nil, fmt.Errorf("reconnect max %s is lower than the base %s", cfg.ReconnectMax, cfg.ReconnectBase)
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Addr
+	}
+
+	w := &Writer{
+		cfg:    cfg,
+		queue:  make(chan string, cfg.QueueSize),
+		doneCh: make(chan struct{}),
+	}
+	go w.flush()
+	return w, nil
+}
+
+// Push does a non-blocking push of a line to the queue,
+// the line is dropped if the queue is full
+func (w *Writer) Push(line string) {
+	select {
+	case w.queue <- line:
+	default:
+	}
+}
+
+// Shutdown stops writing, it blocks until the queued lines are sent.
+// No line must be pushed after shutting down.
+func (w *Writer) Shutdown() {
+	w.shutdownOnce.Do(func() {
+		close(w.queue)
+	})
+	<-w.doneCh
+}
+
+// Flushes the lines
+func (w *Writer) flush() {
+	defer close(w.doneCh)
+
+	var sock net.Conn
+	var err error
+	var wait <-chan time.Time
+	delay := w.cfg.ReconnectBase
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+CONNECT:
+	// Create a buffer
+	buf := bytes.NewBuffer(nil)
+
+	// Attempt to connect
+	sock, err = net.DialTimeout("tcp", w.cfg.Addr, w.cfg.DialTimeout)
+	if err != nil {
+		log.Printf("[ERR] Error connecting to %s! Err: %s", w.cfg.Name, err)
+		goto WAIT
+	}
+
+	for {
+		select {
+		case line, ok := <-w.queue:
+			// Get a line from the queue, sending the buffer
+			// once the queue is closed and drained
+			if !ok {
+				if buf.Len() > 0 {
+					if err := w.write(sock, buf.Bytes()); err != nil {
+						log.Printf("[ERR] Error flushing to %s! Err: %s", w.cfg.Name, err)
+					}
+				}
+				goto QUIT
+			}
+
+			if buf.Len() > 0 && len(line)+buf.Len() > w.cfg.MaxBufferLen {
+				err := w.write(sock, buf.Bytes())
+				buf.Reset()
+				if err != nil {
+					log.Printf("[ERR] Error writing to %s! Err: %s", w.cfg.Name, err)
+					sock.Close()
+					goto WAIT
+				}
+				delay = w.cfg.ReconnectBase
+			}
+
+			// Append to the buffer
+			buf.WriteString(line)
+
+		case <-ticker.C:
+			if buf.Len() == 0 {
+				continue
+			}
+
+			err := w.write(sock, buf.Bytes())
+			buf.Reset()
+			if err != nil {
+				log.Printf("[ERR] Error flushing to %s! Err: %s", w.cfg.Name, err)
+				sock.Close()
+				goto WAIT
+			}
+			delay = w.cfg.ReconnectBase
+		}
+	}
+
+WAIT:
+	// Wait for a while, longer after each consecutive failure
+	wait = time.After(jitter(delay))
+	if delay *= 2; delay > w.cfg.ReconnectMax {
+		delay = w.cfg.ReconnectMax
+	}
+	for {
+		select {
+		// Dequeue the lines to avoid backlog
+		case _, ok := <-w.queue:
+			if !ok {
+				sock = nil
+				goto QUIT
+			}
+		case <-wait:
+			goto CONNECT
+		}
+	}
+QUIT:
+	if sock != nil {
+		sock.Close()
+	}
+}
+
+// Writes to the connection within the write timeout
+func (w *Writer) write(sock net.Conn, b []byte) error {
+	if err := sock.SetWriteDeadline(time.Now().Add(w.cfg.WriteTimeout)); err != nil {
+		return err
+	}
+	_, err := sock.Write(b)
+	return err
+}
+
+// Randomly adds or removes up to reconnectJitter of the delay
+func jitter(delay time.Duration) time.Duration {
+	return delay + time.Duration((rand.Float64()*2-1)*reconnectJitter*float64(delay))
+}
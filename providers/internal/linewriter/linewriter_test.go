@@ -0,0 +1,126 @@
+package linewriter
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNew_Invalid(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Fatalf("expected error for missing address")
+	}
+	if _, err := New(Config{Addr: "localhost:2003", ReconnectBase: time.Second, ReconnectMax: time.Millisecond}); err == nil {
+		t.Fatalf("expected error for reconnect max below base")
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	w, err := New(Config{Addr: "localhost:2003"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	w.Shutdown()
+	if w.cfg.Name != "localhost:2003" || w.cfg.DialTimeout != DefaultDialTimeout || w.cfg.WriteTimeout != DefaultWriteTimeout || cap(w.queue) != DefaultQueueSize {
+		t.Fatalf("bad defaults %+v", w.cfg)
+	}
+}
+
+// listen accepts a single connection, sending its lines on the channel
+func listen(list net.Listener) chan string {
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := list.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+func TestWriter(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer list.Close()
+	lines := listen(list)
+
+	// A buffer of a single line forces a write per line
+	w, err := New(Config{Addr: list.Addr().String(), MaxBufferLen: 1})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	w.Push("a 1\n")
+	w.Push("b 2\n")
+	w.Shutdown()
+
+	for _, expected := range []string{"a 1", "b 2"} {
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Fatalf("bad line %q, expected %q", line, expected)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+func TestWriter_Reconnect(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	addr := list.Addr().String()
+	list.Close()
+
+	// Connecting fails until the server is listening
+	w, err := New(Config{
+		Addr:          addr,
+		FlushInterval: 10 * time.Millisecond,
+		ReconnectBase: 10 * time.Millisecond,
+		ReconnectMax:  20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer w.Shutdown()
+
+	time.Sleep(30 * time.Millisecond)
+	list, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen again on %s: %v", addr, err)
+	}
+	defer list.Close()
+	lines := listen(list)
+
+	deadline := time.After(3 * time.Second)
+	for {
+		w.Push("counter 1\n")
+		select {
+		case line := <-lines:
+			if line != "counter 1" {
+				t.Fatalf("bad line %q", line)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("timed out waiting for the reconnect")
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("bad jitter %s", d)
+		}
+	}
+}
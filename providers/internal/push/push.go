@@ -0,0 +1,314 @@
+// Package push provides the batching and delivery logic shared by
+// the sinks which push metrics to HTTP endpoints
+package push
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFlushInterval is the interval pending records are flushed at
+	DefaultFlushInterval = 10 * time.Second
+
+	// DefaultBatchSize is the maximum number of records in a single request
+	DefaultBatchSize = 500
+
+	// DefaultQueueSize is the number of records buffered before dropping
+	DefaultQueueSize = 4096
+
+	// DefaultMaxRetries is the number of retries of a failed request
+	DefaultMaxRetries = 3
+
+	// DefaultRetryBackoff is the wait before the first retry, it is
+	// doubled on each subsequent retry
+	DefaultRetryBackoff = 500 * time.Millisecond
+)
+
+// EncodeFunc encodes a batch of records into a request body
+type EncodeFunc func(records []interface{}) ([]byte, error)
+
+// RequestHook is called on every request before it is sent,
+// along with the request body
+type RequestHook func(req *http.Request, body []byte) error
+
+// Config is used to configure a Client
+type Config struct {
+	// Name identifies the sink on logs
+	Name string
+
+	URL         string
+	Method      string // Defaults to POST
+	ContentType string
+	Header      http.Header
+
+	FlushInterval time.Duration
+	BatchSize     int
+	QueueSize     int
+	MaxRetries    int // Negative disables retries
+	RetryBackoff  time.Duration
+
+	// Encode creates the request body for a batch of records
+	Encode EncodeFunc
+
+	// Hooks are applied to each request, ex: to sign it
+	Hooks []RequestHook
+
+	// Signature enables signing the request bodies with HMAC
+	Signature
+
+	HTTPClient *http.Client
+}
+
+// Client batches records, pushing them to an HTTP endpoint
+// when the batch is full or the flush interval is reached
+type Client struct {
+	cfg Config
+
+	queue    chan interface{}
+	flushCh  chan chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a Client, starting its delivery goroutine
+func New(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("push url must be provided")
+	}
+	if cfg.Encode == nil {
+		return nil, fmt.Errorf("push encoder must be provided")
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	} else if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultRetryBackoff
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.Name == "" {
+		cfg.Name = redactURL(cfg.URL)
+	}
+	if cfg.SignatureSecret != nil {
+		hook, err := HMACSignature(cfg.SignatureSecret, cfg.SignatureAlgorithm, cfg.SignatureEncoding)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Hooks = append(cfg.Hooks[:len(cfg.Hooks):len(cfg.Hooks)], hook)
+	}
+
+	c := &Client{
+		cfg:     cfg,
+		queue:   make(chan interface{}, cfg.QueueSize),
+		flushCh: make(chan chan struct{}),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go c.run()
+	return c, nil
+}
+
+// Push does a non-blocking push of a record to the queue,
+// the record is dropped if the queue is full or the client shut down
+func (c *Client) Push(record interface{}) {
+	select {
+	case <-c.stopCh:
+		return
+	default:
+	}
+
+	select {
+	case c.queue <- record:
+	default:
+	}
+}
+
+// Flush sends all the queued records, blocking until they are delivered
+func (c *Client) Flush() {
+	done := make(chan struct{})
+	select {
+	case c.flushCh <- done:
+		<-done
+	case <-c.doneCh:
+	}
+}
+
+// Shutdown flushes the queued records and stops the delivery goroutine,
+// the records pushed afterwards are dropped
+func (c *Client) Shutdown() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+	<-c.doneCh
+}
+
+func (c *Client) run() {
+	defer close(c.doneCh)
+
+	ticker := time.NewTicker(c.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]interface{}, 0, c.cfg.BatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		c.send(batch)
+		batch = make([]interface{}, 0, c.cfg.BatchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case record := <-c.queue:
+				batch = append(batch, record)
+				if len(batch) >= c.cfg.BatchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case record := <-c.queue:
+			batch = append(batch, record)
+			if len(batch) >= c.cfg.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-c.flushCh:
+			drain()
+			close(done)
+		case <-c.stopCh:
+			drain()
+			return
+		}
+	}
+}
+
+// send delivers a batch, retrying with exponential backoff on
+// network errors, rate limiting and server errors
+func (c *Client) send(batch []interface{}) {
+	body, err := c.cfg.Encode(batch)
+	if err != nil {
+		log.Printf("[ERR] Error encoding metrics for %s! Err: %s", c.cfg.Name, err)
+		return
+	}
+
+	backoff := c.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		wait, retry, err := c.do(body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= c.cfg.MaxRetries {
+			log.Printf("[ERR] Error pushing metrics to %s! Err: %s", c.cfg.Name, err)
+			return
+		}
+
+		if wait < backoff {
+			wait = backoff
+		}
+		select {
+		case <-time.After(wait):
+		case <-c.stopCh:
+			// Still deliver on shutdown, but don't keep waiting
+		}
+		backoff *= 2
+	}
+}
+
+// do executes a single request, returning how long the server asked
+// us to wait and whether the request may be retried on failure
+func (c *Client) do(body []byte) (time.Duration, bool, error) {
+	req, err := http.NewRequest(c.cfg.Method, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	for k, vs := range c.cfg.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if c.cfg.ContentType != "" {
+		req.Header.Set("Content-Type", c.cfg.ContentType)
+	}
+	for _, hook := range c.cfg.Hooks {
+		if err := hook(req, body); err != nil {
+			return 0, false, err
+		}
+	}
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		// The URL may hold credentials, ex: an API key in the query,
+		// so it is left out of the logged error
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return retryAfter(resp), true, fmt.Errorf("rate limited: %s", resp.Status)
+	case resp.StatusCode >= 500:
+		return retryAfter(resp), true, fmt.Errorf("server error: %s", resp.Status)
+	default:
+		return 0, false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
+
+// redactURL strips the credentials and the query of a URL
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "push endpoint"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// retryAfter parses the Retry-After header, in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
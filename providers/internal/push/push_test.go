@@ -0,0 +1,220 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func jsonEncode(records []interface{}) ([]byte, error) {
+	return json.Marshal(records)
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	bodies   []string
+	headers  []http.Header
+	statuses []int
+}
+
+func (r *recorder) handler(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(req.Body)
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, req.Header)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status = r.statuses[0]
+		r.statuses = r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *recorder) requests() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.bodies...)
+}
+
+func TestNew_Validation(t *testing.T) {
+	if _, err := New(Config{Encode: jsonEncode}); err == nil {
+		t.Fatalf("expected error without url")
+	}
+	if _, err := New(Config{URL: "http://localhost"}); err == nil {
+		t.Fatalf("expected error without encoder")
+	}
+}
+
+func TestClient_Flush(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	header := http.Header{}
+	header.Set("X-Key", "secret")
+	c, err := New(Config{
+		URL:           srv.URL,
+		ContentType:   "application/json",
+		Header:        header,
+		FlushInterval: time.Hour,
+		Encode:        jsonEncode,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer c.Shutdown()
+
+	c.Push(1)
+	c.Push(2)
+	c.Flush()
+
+	reqs := rec.requests()
+	if len(reqs) != 1 || reqs[0] != "[1,2]" {
+		t.Fatalf("bad requests %v", reqs)
+	}
+	if rec.headers[0].Get("X-Key") != "secret" {
+		t.Fatalf("missing header")
+	}
+	if rec.headers[0].Get("Content-Type") != "application/json" {
+		t.Fatalf("bad content type")
+	}
+}
+
+func TestClient_BatchSize(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	c, _ := New(Config{URL: srv.URL, BatchSize: 2, FlushInterval: time.Hour, Encode: jsonEncode})
+	c.Push(1)
+	c.Push(2)
+	c.Push(3)
+	c.Shutdown()
+
+	reqs := rec.requests()
+	if len(reqs) != 2 || reqs[0] != "[1,2]" || reqs[1] != "[3]" {
+		t.Fatalf("bad requests %v", reqs)
+	}
+}
+
+func TestClient_PushAfterShutdown(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	c, _ := New(Config{URL: srv.URL, FlushInterval: time.Hour, Encode: jsonEncode})
+	c.Push(1)
+	c.Shutdown()
+	c.Push(2)
+	c.Flush()
+	c.Shutdown()
+
+	if len(c.queue) != 0 {
+		t.Fatalf("records pushed after shutdown must be dropped, %d queued", len(c.queue))
+	}
+	if reqs := rec.requests(); len(reqs) != 1 || reqs[0] != "[1]" {
+		t.Fatalf("bad requests %v", reqs)
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusTooManyRequests, http.StatusInternalServerError}}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	c, _ := New(Config{
+		URL:           srv.URL,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+		Encode:        jsonEncode,
+	})
+	defer c.Shutdown()
+
+	c.Push(1)
+	c.Flush()
+
+	reqs := rec.requests()
+	if len(reqs) != 3 {
+		t.Fatalf("expected two retries, got %v", reqs)
+	}
+}
+
+func TestClient_NoRetryOnClientError(t *testing.T) {
+	rec := &recorder{statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	c, _ := New(Config{URL: srv.URL, FlushInterval: time.Hour, RetryBackoff: time.Millisecond, Encode: jsonEncode})
+	defer c.Shutdown()
+
+	c.Push(1)
+	c.Flush()
+
+	if reqs := rec.requests(); len(reqs) != 1 {
+		t.Fatalf("expected no retries, got %v", reqs)
+	}
+}
+
+func TestClient_Hooks(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	hook := func(req *http.Request, body []byte) error {
+		req.Header.Set("X-Body-Len", string(rune('0'+len(body))))
+		return nil
+	}
+	c, _ := New(Config{URL: srv.URL, FlushInterval: time.Hour, Encode: jsonEncode, Hooks: []RequestHook{hook}})
+	defer c.Shutdown()
+
+	c.Push(1)
+	c.Flush()
+
+	if rec.headers[0].Get("X-Body-Len") != "3" {
+		t.Fatalf("hook not applied %v", rec.headers[0])
+	}
+}
+
+func TestClient_RedactsURL(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	// Nothing listens on the closed listener address
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	list.Close()
+
+	c, _ := New(Config{
+		URL:           "http://user:password@" + list.Addr().String() + "/push?api_key=secret",
+		FlushInterval: time.Hour,
+		MaxRetries:    -1,
+		Encode:        jsonEncode,
+	})
+	defer c.Shutdown()
+
+	c.Push(1)
+	c.Flush()
+
+	out := buf.String()
+	if !strings.Contains(out, "Error pushing metrics to http://"+list.Addr().String()+"/push!") {
+		t.Fatalf("bad log %q", out)
+	}
+	if strings.Contains(out, "secret") || strings.Contains(out, "password") {
+		t.Fatalf("credentials logged %q", out)
+	}
+}
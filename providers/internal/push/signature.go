@@ -0,0 +1,78 @@
+package push
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+// SignatureHeader is the header holding the request body signature
+const SignatureHeader = signature.Header
+
+// Signature configures the signing of the request bodies with HMAC, it is
+// embedded in Config. The sinks expose its setters as options.
+type Signature struct {
+	// SignatureSecret enables signing, using the given algorithm and
+	// encoding. See HMACSignature.
+	SignatureSecret    []byte
+	SignatureAlgorithm string
+	SignatureEncoding  string
+}
+
+// SetHMACSignature signs the requests with an HMAC of their body, set on
+// the X-Signature header. Supported algorithms are the ones of the
+// signature package.
+func (s *Signature) SetHMACSignature(secret []byte, algorithm string) {
+	s.SignatureSecret = secret
+	s.SignatureAlgorithm = algorithm
+}
+
+// SetSignatureEncoding sets the encoding of the HMAC signature,
+// signature.Hex or signature.Base64
+func (s *Signature) SetSignatureEncoding(encoding string) {
+	s.SignatureEncoding = encoding
+}
+
+// HMACSignature creates a hook setting the HMAC of the request body on
+// the X-Signature header. Supported algorithms are sha1, sha256 and
+// sha512, defaulting to sha256. The signature is encoded as hex or base64,
+// defaulting to hex.
+func HMACSignature(secret []byte, algorithm, encoding string) (RequestHook, error) {
+	var fn func() hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "", signature.SHA256:
+		fn = sha256.New
+	case signature.SHA1:
+		fn = sha1.New
+	case signature.SHA512:
+		fn = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported hmac algorithm %q", algorithm)
+	}
+
+	var encode func([]byte) string
+	switch strings.ToLower(encoding) {
+	case "", signature.Hex:
+		encode = hex.EncodeToString
+	case signature.Base64:
+		encode = base64.StdEncoding.EncodeToString
+	default:
+		return nil, fmt.Errorf("unsupported signature encoding %q", encoding)
+	}
+
+	return func(req *http.Request, body []byte) error {
+		mac := hmac.New(fn, secret)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, encode(mac.Sum(nil)))
+		return nil
+	}, nil
+}
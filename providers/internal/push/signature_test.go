@@ -0,0 +1,62 @@
+package push
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var HMACSignatureTests = []struct {
+	Algorithm string
+	Encoding  string
+	Expected  string
+}{
+	{"", "", "dc46983557fea127b43af721467eb9b3fde2338fe3e14f51952aa8478c13d355"},
+	{"sha256", "hex", "dc46983557fea127b43af721467eb9b3fde2338fe3e14f51952aa8478c13d355"},
+	{"sha256", "base64", "3EaYNVf+oSe0OvchRn65s/3iM4/j4U9RlSqoR4wT01U="},
+	{"sha1", "hex", "a18991ff7e4513a1c2d2ee51e3a8e99ca891d9cd"},
+}
+
+func TestHMACSignature(t *testing.T) {
+	for _, tt := range HMACSignatureTests {
+		hook, err := HMACSignature([]byte("secret"), tt.Algorithm, tt.Encoding)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost", nil)
+		hook(req, []byte("body"))
+		if sig := req.Header.Get(SignatureHeader); sig != tt.Expected {
+			t.Fatalf("%s/%s: expected %s got %s", tt.Algorithm, tt.Encoding, tt.Expected, sig)
+		}
+	}
+}
+
+func TestHMACSignature_Unsupported(t *testing.T) {
+	if _, err := HMACSignature([]byte("secret"), "md4", ""); err == nil {
+		t.Fatalf("expected error for algorithm")
+	}
+	if _, err := HMACSignature([]byte("secret"), "", "base32"); err == nil {
+		t.Fatalf("expected error for encoding")
+	}
+	if _, err := New(Config{URL: "http://localhost", Encode: jsonEncode, Signature: Signature{SignatureSecret: []byte("s"), SignatureAlgorithm: "md4"}}); err == nil {
+		t.Fatalf("expected error creating client")
+	}
+}
+
+func TestClient_Signature(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+
+	c, _ := New(Config{URL: srv.URL, FlushInterval: time.Hour, Encode: jsonEncode, Signature: Signature{SignatureSecret: []byte("secret")}})
+	defer c.Shutdown()
+
+	c.Push(1)
+	c.Flush()
+
+	if rec.headers[0].Get(SignatureHeader) == "" {
+		t.Fatalf("missing signature")
+	}
+}
@@ -0,0 +1,194 @@
+// Package remotewrite encodes metrics using the Prometheus remote write
+// protocol, shared by the sinks pushing to remote write endpoints
+package remotewrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// Header holds the headers required on remote write requests
+func Header() http.Header {
+	h := http.Header{}
+	h.Set("Content-Encoding", "snappy")
+	h.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return h
+}
+
+// ContentType is the content type of remote write requests
+const ContentType = "application/x-protobuf"
+
+// Label is a time series label
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a time series value, timestamp in milliseconds
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// TimeSeries is a set of samples identified by their labels,
+// including the __name__ label holding the metric name
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Encode formats the records, which must be TimeSeries, as a snappy
+// compressed WriteRequest. Samples of the same series are merged.
+func Encode(records []interface{}) ([]byte, error) {
+	var series []*TimeSeries
+	index := make(map[string]*TimeSeries)
+	for _, r := range records {
+		ts := r.(TimeSeries)
+		hash := seriesHash(ts.Labels)
+		if existing, ok := index[hash]; ok {
+			existing.Samples = append(existing.Samples, ts.Samples...)
+			continue
+		}
+		merged := &TimeSeries{Labels: ts.Labels, Samples: append([]Sample(nil), ts.Samples...)}
+		index[hash] = merged
+		series = append(series, merged)
+	}
+	return snappy.Encode(nil, Marshal(series)), nil
+}
+
+// Marshal encodes the series as a WriteRequest protobuf message
+func Marshal(series []*TimeSeries) []byte {
+	req := &bytes.Buffer{}
+	for _, ts := range series {
+		msg := &bytes.Buffer{}
+		for _, l := range ts.Labels {
+			label := &bytes.Buffer{}
+			writeString(label, 1, l.Name)
+			writeString(label, 2, l.Value)
+			writeBytes(msg, 1, label.Bytes())
+		}
+		for _, s := range ts.Samples {
+			sample := &bytes.Buffer{}
+			writeDouble(sample, 1, s.Value)
+			writeVarint(sample, 2, uint64(s.Timestamp))
+			writeBytes(msg, 2, sample.Bytes())
+		}
+		writeBytes(req, 1, msg.Bytes())
+	}
+	return req.Bytes()
+}
+
+func writeTag(buf *bytes.Buffer, field int, wireType int) {
+	writeUvarint(buf, uint64(field<<3|wireType))
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeVarint(buf *bytes.Buffer, field int, v uint64) {
+	writeTag(buf, field, 0)
+	writeUvarint(buf, v)
+}
+
+func writeDouble(buf *bytes.Buffer, field int, v float64) {
+	writeTag(buf, field, 1)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	buf.Write(b[:])
+}
+
+func writeBytes(buf *bytes.Buffer, field int, v []byte) {
+	writeTag(buf, field, 2)
+	writeUvarint(buf, uint64(len(v)))
+	buf.Write(v)
+}
+
+func writeString(buf *bytes.Buffer, field int, v string) {
+	writeBytes(buf, field, []byte(v))
+}
+
+func seriesHash(labels []Label) string {
+	buf := &bytes.Buffer{}
+	for _, l := range labels {
+		buf.WriteString(l.Name + "=" + l.Value + ";")
+	}
+	return buf.String()
+}
+
+var forbiddenChars = regexp.MustCompile("[^a-zA-Z0-9_:]")
+
+// State converts the metrics into time series. Remote write expects
+// cumulative counters, so counters and samples are accumulated.
+// Samples are sent as <name>_sum and <name>_count counters.
+type State struct {
+	mu     sync.Mutex
+	totals map[string]float64
+}
+
+// NewState creates a State
+func NewState() *State {
+	return &State{totals: make(map[string]float64)}
+}
+
+// Gauge returns the series for a gauge value
+func (s *State) Gauge(key []string, val float64, labels []metrics.Label) TimeSeries {
+	return series(MetricName(key), val, labels)
+}
+
+// Counter accumulates the counter, returning its series
+func (s *State) Counter(key []string, val float64, labels []metrics.Label) TimeSeries {
+	name := MetricName(key) + "_total"
+	return series(name, s.add(name, val, labels), labels)
+}
+
+// Sample accumulates the sample, returning its sum and count series
+func (s *State) Sample(key []string, val float64, labels []metrics.Label) []TimeSeries {
+	name := MetricName(key)
+	return []TimeSeries{
+		series(name+"_sum", s.add(name+"_sum", val, labels), labels),
+		series(name+"_count", s.add(name+"_count", 1, labels), labels),
+	}
+}
+
+func (s *State) add(name string, val float64, labels []metrics.Label) float64 {
+	hash := seriesHash(append([]Label{{Name: name}}, convertLabels(labels)...))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totals[hash] += val
+	return s.totals[hash]
+}
+
+// MetricName flattens the key into a valid Prometheus metric name
+func MetricName(key []string) string {
+	return forbiddenChars.ReplaceAllString(strings.Join(key, "_"), "_")
+}
+
+func series(name string, val float64, labels []metrics.Label) TimeSeries {
+	l := append([]Label{{Name: "__name__", Value: name}}, convertLabels(labels)...)
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return TimeSeries{
+		Labels:  l,
+		Samples: []Sample{{Value: val, Timestamp: time.Now().UnixNano() / int64(time.Millisecond)}},
+	}
+}
+
+func convertLabels(labels []metrics.Label) []Label {
+	l := make([]Label, 0, len(labels))
+	for _, label := range labels {
+		l = append(l, Label{Name: forbiddenChars.ReplaceAllString(label.Name, "_"), Value: label.Value})
+	}
+	return l
+}
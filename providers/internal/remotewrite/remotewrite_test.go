@@ -0,0 +1,118 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// fields decodes the fields of a protobuf message, only supporting
+// the wire types used by remote write
+func fields(t *testing.T, b []byte) map[int][][]byte {
+	f := make(map[int][][]byte)
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		b = b[n:]
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			f[int(tag>>3)] = append(f[int(tag>>3)], b[:n])
+		case 1:
+			n = 8
+			f[int(tag>>3)] = append(f[int(tag>>3)], b[:n])
+		case 2:
+			l, m := binary.Uvarint(b)
+			b = b[m:]
+			n = int(l)
+			f[int(tag>>3)] = append(f[int(tag>>3)], b[:n])
+		default:
+			t.Fatalf("bad wire type %d", tag&7)
+		}
+		b = b[n:]
+	}
+	return f
+}
+
+// Decode decodes a snappy compressed WriteRequest
+func Decode(t *testing.T, body []byte) []*TimeSeries {
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var series []*TimeSeries
+	for _, tsb := range fields(t, raw)[1] {
+		ts := &TimeSeries{}
+		f := fields(t, tsb)
+		for _, lb := range f[1] {
+			lf := fields(t, lb)
+			ts.Labels = append(ts.Labels, Label{Name: string(lf[1][0]), Value: string(lf[2][0])})
+		}
+		for _, sb := range f[2] {
+			sf := fields(t, sb)
+			ts.Samples = append(ts.Samples, Sample{
+				Value:     math.Float64frombits(binary.LittleEndian.Uint64(sf[1][0])),
+				Timestamp: func() int64 { v, _ := binary.Uvarint(sf[2][0]); return int64(v) }(),
+			})
+		}
+		series = append(series, ts)
+	}
+	return series
+}
+
+func TestMetricName(t *testing.T) {
+	if name := MetricName([]string{"a b", "c.d-e"}); name != "a_b_c_d_e" {
+		t.Fatalf("bad name %s", name)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	records := []interface{}{
+		TimeSeries{Labels: []Label{{"__name__", "a"}}, Samples: []Sample{{1, 10}}},
+		TimeSeries{Labels: []Label{{"__name__", "b"}, {"x", "y"}}, Samples: []Sample{{2, 20}}},
+		TimeSeries{Labels: []Label{{"__name__", "a"}}, Samples: []Sample{{3, 30}}},
+	}
+	body, err := Encode(records)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []*TimeSeries{
+		{Labels: []Label{{"__name__", "a"}}, Samples: []Sample{{1, 10}, {3, 30}}},
+		{Labels: []Label{{"__name__", "b"}, {"x", "y"}}, Samples: []Sample{{2, 20}}},
+	}
+	if got := Decode(t, body); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v got %v", expected, got)
+	}
+}
+
+func TestState(t *testing.T) {
+	s := NewState()
+	labels := []metrics.Label{{Name: "z", Value: "1"}, {Name: "a.b", Value: "2"}}
+
+	g := s.Gauge([]string{"gauge"}, 1, labels)
+	if !reflect.DeepEqual(g.Labels, []Label{{"__name__", "gauge"}, {"a_b", "2"}, {"z", "1"}}) {
+		t.Fatalf("bad labels %v", g.Labels)
+	}
+
+	s.Counter([]string{"counter"}, 1, nil)
+	if c := s.Counter([]string{"counter"}, 2, nil); c.Samples[0].Value != 3 || c.Labels[0].Value != "counter_total" {
+		t.Fatalf("bad counter %v", c)
+	}
+	if c := s.Counter([]string{"counter"}, 2, labels); c.Samples[0].Value != 2 {
+		t.Fatalf("counters with other labels must be accumulated apart %v", c)
+	}
+
+	s.Sample([]string{"sample"}, 4, nil)
+	sample := s.Sample([]string{"sample"}, 6, nil)
+	if sample[0].Labels[0].Value != "sample_sum" || sample[0].Samples[0].Value != 10 {
+		t.Fatalf("bad sum %v", sample[0])
+	}
+	if sample[1].Labels[0].Value != "sample_count" || sample[1].Samples[0].Value != 2 {
+		t.Fatalf("bad count %v", sample[1])
+	}
+}
@@ -0,0 +1,170 @@
+package logzio
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+	"github.com/hugoluchessi/go-metrics/providers/internal/remotewrite"
+)
+
+// listenerPort is the Logz.io metrics listener port
+const listenerPort = 8053
+
+// Sink provides a MetricSink that pushes metrics to Logz.io Metrics
+// using the Prometheus remote write protocol. Labels become Prometheus
+// labels, counters are sent as cumulative <name>_total series and
+// samples as <name>_sum and <name>_count series.
+type Sink struct {
+	endpoint string
+	state    *remotewrite.State
+	config   push.Config
+	client   *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithEndpoint overrides the listener endpoint derived from the region
+func WithEndpoint(endpoint string) Option {
+	return func(s *Sink) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// ListenerURL returns the metrics listener URL of a Logz.io region,
+// "us" or an empty region being the default listener
+func ListenerURL(region string) string {
+	if region == "" || region == "us" {
+		return fmt.Sprintf("https://listener.logz.io:%d", listenerPort)
+	}
+	return fmt.Sprintf("https://listener-%s.logz.io:%d", region, listenerPort)
+}
+
+// NewSink is used to create a new Sink pushing to the listener of the
+// given region, authenticated with the metrics shipping token
+func NewSink(token string, region string, opts ...Option) (*Sink, error) {
+	if token == "" {
+		return nil, fmt.Errorf("metrics token must be provided")
+	}
+
+	s := &Sink{
+		endpoint: ListenerURL(region),
+		state:    remotewrite.NewState(),
+		config: push.Config{
+			Name:        "logzio",
+			ContentType: remotewrite.ContentType,
+			Header:      remotewrite.Header(),
+			Encode:      remotewrite.Encode,
+		},
+	}
+	s.config.Header.Set("Authorization", "Bearer "+token)
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.config.URL = s.endpoint
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.client.Push(s.state.Gauge(key, float64(val), labels))
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.client.Push(s.state.Gauge(key, float64(val), nil))
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.client.Push(s.state.Counter(key, val, labels))
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	for _, ts := range s.state.Sample(key, float64(val), labels) {
+		s.client.Push(ts)
+	}
+}
@@ -0,0 +1,85 @@
+package logzio
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_MissingToken(t *testing.T) {
+	if _, err := NewSink("", "us"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestListenerURL(t *testing.T) {
+	if u := ListenerURL("us"); u != "https://listener.logz.io:8053" {
+		t.Fatalf("bad url %s", u)
+	}
+	if u := ListenerURL("eu"); u != "https://listener-eu.logz.io:8053" {
+		t.Fatalf("bad url %s", u)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("token", "eu", WithEndpoint(srv.URL), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.AddSample([]string{"sample"}, 3)
+	s.Flush()
+
+	if header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("bad auth %s", header.Get("Authorization"))
+	}
+	if header.Get("Content-Encoding") != "snappy" || header.Get("Content-Type") != "application/x-protobuf" {
+		t.Fatalf("bad headers %v", header)
+	}
+	if raw, err := snappy.Decode(nil, body); err != nil || len(raw) == 0 {
+		t.Fatalf("bad body: %v", err)
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("token", "eu", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("token", "eu", WithEndpoint(srv.URL), WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
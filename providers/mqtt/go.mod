@@ -0,0 +1,17 @@
+module github.com/hugoluchessi/go-metrics/providers/mqtt
+
+go 1.21
+
+require (
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/hugoluchessi/go-metrics v0.0.0
+)
+
+require (
+	github.com/gorilla/websocket v1.5.0 // indirect
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/time v0.3.0 // indirect
+)
+
+replace github.com/hugoluchessi/go-metrics => ../..
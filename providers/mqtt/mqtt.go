@@ -0,0 +1,172 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+const (
+	// connectTimeout bounds the initial connection to the broker
+	connectTimeout = 10 * time.Second
+
+	// disconnectQuiesce is the time in milliseconds given to pending
+	// publications on Shutdown
+	disconnectQuiesce = 250
+
+	gaugeType   = "gauge"
+	counterType = "counter"
+	sampleType  = "sample"
+	keyType     = "key"
+)
+
+// publisher is the part of the MQTT client used by the Sink,
+// satisfied by paho.Client
+type publisher interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token
+	Disconnect(quiesce uint)
+}
+
+// Sink provides a MetricSink that publishes each metric as a JSON
+// payload to the "<prefix>/<metricType>/<key>" MQTT topic, where the
+// key parts are joined by dots. Publishing does not wait for the broker,
+// the client reconnects and, with a QoS above 0, retries in the background.
+type Sink struct {
+	client publisher
+	prefix string
+	qos    byte
+}
+
+// payload is the JSON representation of a metric in the messages
+type payload struct {
+	Value     float64           `json:"value"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// NewSink is used to create a new Sink connected to the broker,
+// ex: NewSink("tcp://localhost:1883", "device-1", "metrics", 1)
+func NewSink(brokerURL, clientID, topicPrefix string, qos byte) (*Sink, error) {
+	if qos > 2 {
+		return nil, fmt.Errorf("qos must be 0, 1 or 2, got %d", qos)
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(brokerURL).
+		SetClientID(clientID).
+		SetAutoReconnect(true)
+	client := paho.NewClient(opts)
+
+	token := client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		return nil, fmt.Errorf("timed out connecting to mqtt broker %s", brokerURL)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("error connecting to mqtt broker %s: %s", brokerURL, err)
+	}
+
+	return newSink(client, topicPrefix, qos), nil
+}
+
+// newSink creates a Sink publishing with the given client
+func newSink(client publisher, topicPrefix string, qos byte) *Sink {
+	return &Sink{
+		client: client,
+		prefix: strings.TrimSuffix(topicPrefix, "/"),
+		qos:    qos,
+	}
+}
+
+// Shutdown is used to disconnect from the broker
+func (s *Sink) Shutdown() {
+	s.client.Disconnect(disconnectQuiesce)
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.publish(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.publish(keyType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.publish(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.publish(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.publish(sampleType, key, float64(val), labels)
+}
+
+// Encodes the metric and publishes it without waiting for the broker
+func (s *Sink) publish(metricType string, key []string, val float64, labels []metrics.Label) {
+	p := payload{
+		Value:     val,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if len(labels) > 0 {
+		p.Labels = make(map[string]string, len(labels))
+		for _, label := range labels {
+			p.Labels[label.Name] = label.Value
+		}
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("[ERR] Error encoding metric %s for MQTT! Err: %s", strings.Join(key, "."), err)
+		return
+	}
+	s.client.Publish(s.topic(metricType, key), s.qos, false, body)
+}
+
+// Builds the topic of a metric, replacing the topic separator and
+// wildcards found in the key
+func (s *Sink) topic(metricType string, key []string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '+', '#':
+			return '_'
+		default:
+			return r
+		}
+	}, strings.Join(key, "."))
+
+	if s.prefix == "" {
+		return metricType + "/" + name
+	}
+	return s.prefix + "/" + metricType + "/" + name
+}
@@ -0,0 +1,80 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+type message struct {
+	topic   string
+	qos     byte
+	payload []byte
+}
+
+type fakeClient struct {
+	messages     []message
+	disconnected bool
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	c.messages = append(c.messages, message{topic, qos, payload.([]byte)})
+	return &paho.DummyToken{}
+}
+
+func (c *fakeClient) Disconnect(quiesce uint) {
+	c.disconnected = true
+}
+
+func TestSink(t *testing.T) {
+	c := &fakeClient{}
+	s := newSink(c, "edge/device-1/", 1)
+
+	s.SetGaugeWithLabels([]string{"cpu", "temp"}, 42.5, []metrics.Label{{Name: "core", Value: "0"}})
+	s.IncrCounter([]string{"packets"}, 2)
+	s.AddSample([]string{"latency"}, 3)
+	s.EmitKey([]string{"a/b", "c+#"}, 4)
+	s.Shutdown()
+
+	expect := []string{
+		"edge/device-1/gauge/cpu.temp",
+		"edge/device-1/counter/packets",
+		"edge/device-1/sample/latency",
+		"edge/device-1/key/a_b.c__",
+	}
+	if len(c.messages) != len(expect) {
+		t.Fatalf("bad messages: %v", c.messages)
+	}
+	for i, topic := range expect {
+		if c.messages[i].topic != topic || c.messages[i].qos != 1 {
+			t.Fatalf("bad message %d: %s %d", i, c.messages[i].topic, c.messages[i].qos)
+		}
+	}
+
+	var p payload
+	if err := json.Unmarshal(c.messages[0].payload, &p); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.Value != 42.5 || p.Labels["core"] != "0" || p.Timestamp == 0 {
+		t.Fatalf("bad payload: %s", c.messages[0].payload)
+	}
+	if !c.disconnected {
+		t.Fatalf("should disconnect on shutdown")
+	}
+}
+
+func TestSink_NoPrefix(t *testing.T) {
+	c := &fakeClient{}
+	newSink(c, "", 0).IncrCounterFloat64([]string{"foo"}, 1.5)
+	if c.messages[0].topic != "counter/foo" {
+		t.Fatalf("bad topic: %s", c.messages[0].topic)
+	}
+}
+
+func TestNewSink_BadQoS(t *testing.T) {
+	if _, err := NewSink("tcp://localhost:1883", "test", "metrics", 3); err == nil {
+		t.Fatalf("expected qos error")
+	}
+}
@@ -0,0 +1,112 @@
+package netdata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/statsd"
+)
+
+// Sink provides a MetricSink for the Netdata statsd plugin. Metric names
+// follow the Netdata app.chart.dimension convention: the last part of the
+// key is the dimension, the other parts, followed by the label values,
+// form the chart ID. Metrics are sent over UDP by a statsd Sink.
+type Sink struct {
+	app  string
+	sink *statsd.Sink
+}
+
+// NewSink is used to create a new Sink sending to the Netdata statsd
+// plugin at the given host:port address, grouping the charts under
+// appName. The statsd options configure the underlying transport.
+func NewSink(addr, appName string, opts ...statsd.Option) (*Sink, error) {
+	appName = sanitize(appName)
+	if appName == "" {
+		return nil, fmt.Errorf("app name must be provided")
+	}
+
+	sink, err := statsd.NewSink(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{app: appName, sink: sink}, nil
+}
+
+// Shutdown is used to stop flushing to Netdata
+func (s *Sink) Shutdown() {
+	s.sink.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.sink.SetGauge(s.name(key, nil), val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.sink.SetGauge(s.name(key, labels), val)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+// since Netdata has no key/value metric type
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.sink.SetGauge(s.name(key, nil), val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.sink.IncrCounter(s.name(key, nil), val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.sink.IncrCounter(s.name(key, labels), val)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.sink.IncrCounterFloat64(s.name(key, nil), val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.sink.IncrCounterFloat64(s.name(key, labels), val)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.sink.AddSample(s.name(key, nil), val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.sink.AddSample(s.name(key, labels), val)
+}
+
+// Returns the app, chart and dimension parts of the metric name. A key
+// with a single part is used both as the chart and the dimension.
+func (s *Sink) name(key []string, labels []metrics.Label) []string {
+	if len(key) == 0 {
+		return []string{s.app}
+	}
+
+	dimension := sanitize(key[len(key)-1])
+	chart := make([]string, 0, len(key)-1+len(labels))
+	for _, part := range key[:len(key)-1] {
+		chart = append(chart, sanitize(part))
+	}
+	if len(chart) == 0 {
+		chart = append(chart, dimension)
+	}
+	for _, label := range labels {
+		chart = append(chart, sanitize(label.Value))
+	}
+
+	return []string{s.app, strings.Join(chart, "_"), dimension}
+}
+
+// Replaces the dots, which separate the app, chart and dimension IDs
+func sanitize(part string) string {
+	return strings.Replace(part, ".", "_", -1)
+}
@@ -0,0 +1,84 @@
+package netdata
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestName(t *testing.T) {
+	s := &Sink{app: "myapp"}
+
+	cases := []struct {
+		key      []string
+		labels   []metrics.Label
+		expected []string
+	}{
+		{[]string{"api", "requests", "ok"}, nil, []string{"myapp", "api_requests", "ok"}},
+		{[]string{"requests"}, nil, []string{"myapp", "requests", "requests"}},
+		{[]string{"api", "v1.2"}, []metrics.Label{{Name: "a", Value: "b.c"}}, []string{"myapp", "api_b_c", "v1_2"}},
+		{nil, nil, []string{"myapp"}},
+	}
+	for _, c := range cases {
+		if name := s.name(c.key, c.labels); !reflect.DeepEqual(name, c.expected) {
+			t.Fatalf("bad name for %v: %v", c.key, name)
+		}
+	}
+}
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("127.0.0.1:8125", ""); err == nil {
+		t.Fatalf("expected error for missing app name")
+	}
+	if _, err := NewSink("127.0.0.1", "myapp"); err == nil {
+		t.Fatalf("expected error for invalid address")
+	}
+}
+
+func TestSink(t *testing.T) {
+	list, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	s, err := NewSink(list.LocalAddr().String(), "my.app")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	l := []metrics.Label{{Name: "a", Value: "b"}}
+	s.SetGaugeWithLabels([]string{"queue", "depth"}, 1, l)
+	s.EmitKey([]string{"queue", "key"}, 2)
+	s.IncrCounter([]string{"api", "requests"}, 3)
+	s.AddSample([]string{"api", "latency"}, 4)
+
+	list.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 1500)
+	n, err := list.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(buf[:n]))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	expected := []string{
+		"my_app.queue_b.depth:1.000000|g",
+		"my_app.queue.key:2.000000|g",
+		"my_app.api.requests:3.000000|c",
+		"my_app.api.latency:4.000000|ms",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("bad lines %v", lines)
+	}
+}
@@ -0,0 +1,221 @@
+package sdk
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/hugoluchessi/go-metrics"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// DefaultMeterName is the instrumentation scope name used by default
+const DefaultMeterName = "github.com/hugoluchessi/go-metrics"
+
+// Sink provides a MetricSink that records metrics on the OpenTelemetry
+// SDK, so they can be exported with any OpenTelemetry exporter.
+// Instruments are created lazily, on the first use of each key.
+type Sink struct {
+	meter     otelmetric.Meter
+	meterName string
+	descs     *metrics.Registry
+
+	mu         sync.Mutex
+	gauges     map[string]otelmetric.Float64Gauge
+	counters   map[string]otelmetric.Float64Counter
+	histograms map[string]otelmetric.Float64Histogram
+
+	// defaults holds the default labels of the registered metrics,
+	// looked up when their instrument is created
+	defaults map[string][]metrics.Label
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithMeterName sets the instrumentation scope name of the meter,
+// defaults to DefaultMeterName
+func WithMeterName(name string) Option {
+	return func(s *Sink) {
+		s.meterName = name
+	}
+}
+
+// WithRegistry sets the registry providing the description, unit and
+// default labels of the metrics, defaults to metrics.DefaultRegistry
+func WithRegistry(r *metrics.Registry) Option {
+	return func(s *Sink) {
+		s.descs = r
+	}
+}
+
+// NewSink creates a Sink recording metrics on the given meter provider
+func NewSink(mp *sdkmetric.MeterProvider, opts ...Option) *Sink {
+	s := &Sink{
+		meterName:  DefaultMeterName,
+		descs:      metrics.DefaultRegistry,
+		gauges:     make(map[string]otelmetric.Float64Gauge),
+		counters:   make(map[string]otelmetric.Float64Counter),
+		histograms: make(map[string]otelmetric.Float64Histogram),
+		defaults:   make(map[string][]metrics.Label),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.meter = mp.Meter(s.meterName)
+	return s
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	name := s.flattenKey(key)
+	gauge, err := s.gauge(key, name)
+	if err != nil {
+		log.Printf("[ERR] Error creating OpenTelemetry gauge! Err: %s", err)
+		return
+	}
+	gauge.Record(context.Background(), float64(val), s.attributes(name, labels))
+}
+
+// EmitKey emits a key value metric, recorded as a gauge
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	name := s.flattenKey(key)
+	counter, err := s.counter(key, name)
+	if err != nil {
+		log.Printf("[ERR] Error creating OpenTelemetry counter! Err: %s", err)
+		return
+	}
+	counter.Add(context.Background(), val, s.attributes(name, labels))
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels, recorded on a histogram
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	name := s.flattenKey(key)
+	histogram, err := s.histogram(key, name)
+	if err != nil {
+		log.Printf("[ERR] Error creating OpenTelemetry histogram! Err: %s", err)
+		return
+	}
+	histogram.Record(context.Background(), float64(val), s.attributes(name, labels))
+}
+
+// Returns the gauge for the given name, creating it if needed
+func (s *Sink) gauge(key []string, name string) (otelmetric.Float64Gauge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if gauge, ok := s.gauges[name]; ok {
+		return gauge, nil
+	}
+	desc := s.describe(key, name)
+	gauge, err := s.meter.Float64Gauge(name,
+		otelmetric.WithDescription(desc.Help), otelmetric.WithUnit(desc.Unit))
+	if err != nil {
+		return nil, err
+	}
+	s.gauges[name] = gauge
+	return gauge, nil
+}
+
+// Returns the counter for the given name, creating it if needed
+func (s *Sink) counter(key []string, name string) (otelmetric.Float64Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if counter, ok := s.counters[name]; ok {
+		return counter, nil
+	}
+	desc := s.describe(key, name)
+	counter, err := s.meter.Float64Counter(name,
+		otelmetric.WithDescription(desc.Help), otelmetric.WithUnit(desc.Unit))
+	if err != nil {
+		return nil, err
+	}
+	s.counters[name] = counter
+	return counter, nil
+}
+
+// Returns the histogram for the given name, creating it if needed
+func (s *Sink) histogram(key []string, name string) (otelmetric.Float64Histogram, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if histogram, ok := s.histograms[name]; ok {
+		return histogram, nil
+	}
+	desc := s.describe(key, name)
+	histogram, err := s.meter.Float64Histogram(name,
+		otelmetric.WithDescription(desc.Help), otelmetric.WithUnit(desc.Unit))
+	if err != nil {
+		return nil, err
+	}
+	s.histograms[name] = histogram
+	return histogram, nil
+}
+
+// Returns the registered descriptor of the key, storing its default
+// labels. Must be called with the lock held.
+func (s *Sink) describe(key []string, name string) metrics.Descriptor {
+	desc, ok := s.descs.Lookup(key)
+	if ok && len(desc.Labels) > 0 {
+		s.defaults[name] = desc.Labels
+	}
+	return desc
+}
+
+// Converts labels to OpenTelemetry attributes, adding the default labels
+// of the metric
+func (s *Sink) attributes(name string, labels []metrics.Label) otelmetric.MeasurementOption {
+	s.mu.Lock()
+	defaults := s.defaults[name]
+	s.mu.Unlock()
+	if len(defaults) > 0 {
+		labels = metrics.Descriptor{Labels: defaults}.MergeLabels(labels)
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(labels))
+	for _, label := range labels {
+		attrs = append(attrs, attribute.String(label.Name, label.Value))
+	}
+	return otelmetric.WithAttributes(attrs...)
+}
+
+// Flattens the key using "." as separator, as OpenTelemetry
+// instrument names do not allow spaces
+func (s *Sink) flattenKey(parts []string) string {
+	joined := strings.Join(parts, ".")
+	return strings.Replace(joined, " ", "_", -1)
+}
@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collect(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	result := make(map[string]metricdata.Aggregation)
+	for _, scope := range rm.ScopeMetrics {
+		if scope.Scope.Name != DefaultMeterName {
+			t.Fatalf("bad scope %s", scope.Scope.Name)
+		}
+		for _, m := range scope.Metrics {
+			result[m.Name] = m.Data
+		}
+	}
+	return result
+}
+
+func TestFlattenKey(t *testing.T) {
+	s := &Sink{}
+	if flat := s.flattenKey([]string{"a b", "c"}); flat != "a_b.c" {
+		t.Fatalf("bad flat %s", flat)
+	}
+}
+
+func TestSink(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	s := NewSink(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.SetGaugeWithLabels([]string{"gauge"}, 2, []metrics.Label{{Name: "a", Value: "b"}})
+	s.EmitKey([]string{"key"}, 3)
+	s.IncrCounter([]string{"counter"}, 4)
+	s.IncrCounter([]string{"counter"}, 5)
+	s.AddSample([]string{"sample"}, 6)
+	s.AddSample([]string{"sample"}, 7)
+
+	data := collect(t, reader)
+	if len(data) != 4 {
+		t.Fatalf("bad metrics %v", data)
+	}
+
+	gauge := data["gauge"].(metricdata.Gauge[float64]).DataPoints[0]
+	if gauge.Value != 2 {
+		t.Fatalf("bad gauge %v", gauge.Value)
+	}
+	if v, ok := gauge.Attributes.Value(attribute.Key("a")); !ok || v.AsString() != "b" {
+		t.Fatalf("bad attributes %v", gauge.Attributes)
+	}
+
+	if key := data["key"].(metricdata.Gauge[float64]).DataPoints[0]; key.Value != 3 {
+		t.Fatalf("bad key %v", key.Value)
+	}
+
+	if counter := data["counter"].(metricdata.Sum[float64]).DataPoints[0]; counter.Value != 9 {
+		t.Fatalf("bad counter %v", counter.Value)
+	}
+
+	sample := data["sample"].(metricdata.Histogram[float64]).DataPoints[0]
+	if sample.Count != 2 || sample.Sum != 13 {
+		t.Fatalf("bad sample count %d sum %v", sample.Count, sample.Sum)
+	}
+}
+
+func TestSink_WithMeterName(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	s := NewSink(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), WithMeterName("custom"))
+	s.IncrCounter([]string{"counter"}, 1)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Scope.Name != "custom" {
+		t.Fatalf("bad scopes %v", rm.ScopeMetrics)
+	}
+}
+
+func TestSink_Descriptors(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Register(metrics.Descriptor{
+		Name:   []string{"request", "duration"},
+		Type:   metrics.SampleMetric,
+		Unit:   "ms",
+		Help:   "Duration of the requests",
+		Labels: []metrics.Label{{Name: "a", Value: "default"}, {Name: "c", Value: "d"}},
+	})
+
+	reader := sdkmetric.NewManualReader()
+	s := NewSink(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), WithRegistry(r))
+	s.AddSampleWithLabels([]string{"request", "duration"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	m := rm.ScopeMetrics[0].Metrics[0]
+	if m.Description != "Duration of the requests" || m.Unit != "ms" {
+		t.Fatalf("bad description %q unit %q", m.Description, m.Unit)
+	}
+
+	attrs := m.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+	if v, _ := attrs.Value(attribute.Key("a")); v.AsString() != "b" {
+		t.Fatalf("bad attributes %v", attrs)
+	}
+	if v, _ := attrs.Value(attribute.Key("c")); v.AsString() != "d" {
+		t.Fatalf("bad attributes %v", attrs)
+	}
+}
@@ -0,0 +1,83 @@
+// Package otelstdout provides a sink writing the metrics exported by the
+// OpenTelemetry SDK to a writer, for local development without a collector.
+package otelstdout
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/endpoint"
+	"github.com/hugoluchessi/go-metrics/providers/opentelemetry/sdk"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// ExportInterval is the interval metrics are written at
+const ExportInterval = 10 * time.Second
+
+// Sink provides a MetricSink that records metrics on an OpenTelemetry SDK
+// meter provider, see the providers/opentelemetry/sdk package, exported by
+// the stdout exporter as JSON, or as a table to be read by humans
+type Sink struct {
+	*sdk.Sink
+
+	provider *sdkmetric.MeterProvider
+}
+
+// NewSink is used to create a new Sink writing to w every ExportInterval.
+// With prettyPrint the metrics are written as a table aligned with
+// text/tabwriter, one row per series, instead of JSON documents.
+func NewSink(w io.Writer, prettyPrint bool) *Sink {
+	var exporter sdkmetric.Exporter = &tableExporter{w: w}
+	if !prettyPrint {
+		// The stdout exporter does not fail to be created
+		exporter, _ = stdoutmetric.New(stdoutmetric.WithWriter(w))
+	}
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(ExportInterval))),
+	)
+
+	return &Sink{
+		Sink:     sdk.NewSink(provider),
+		provider: provider,
+	}
+}
+
+// The sink is created by endpoint.NewSinkFromURL for the "otelstdout"
+// scheme once this package is imported
+func init() {
+	err := endpoint.RegisterSink("otelstdout", func(u *url.URL) (metrics.Sinker, error) {
+		return NewSinkFromURL(u)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewSinkFromURL creates a Sink writing to stdout from a URL such as
+// "otelstdout://?pretty=true"
+func NewSinkFromURL(u *url.URL) (*Sink, error) {
+	pretty := false
+	if v := u.Query().Get("pretty"); v != "" {
+		var err error
+		if pretty, err = strconv.ParseBool(v); err != nil {
+			return nil, err
+		}
+	}
+	return NewSink(os.Stdout, pretty), nil
+}
+
+// Flush writes all the pending metrics
+func (s *Sink) Flush() error {
+	return s.provider.ForceFlush(context.Background())
+}
+
+// Shutdown is used to write the pending metrics and stop writing
+func (s *Sink) Shutdown() error {
+	return s.provider.Shutdown(context.Background())
+}
@@ -0,0 +1,102 @@
+package otelstdout
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/endpoint"
+)
+
+func TestSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSink(&buf, false)
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"queue", "size"}, 3, []metrics.Label{{Name: "queue", Value: "jobs"}})
+	s.IncrCounter([]string{"requests"}, 1)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("bad json %s: %v", buf.String(), err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"Name":"queue.size"`) || !strings.Contains(out, `"Name":"requests"`) {
+		t.Fatalf("bad output %s", out)
+	}
+	if strings.Count(strings.TrimSpace(out), "\n") != 0 {
+		t.Fatalf("output must be compact %s", out)
+	}
+}
+
+func TestSink_PrettyPrint(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSink(&buf, true)
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"queue", "size"}, 3, []metrics.Label{{Name: "queue", Value: "jobs"}})
+	s.IncrCounter([]string{"requests"}, 1)
+	s.AddSample([]string{"latency"}, 2)
+	s.AddSample([]string{"latency"}, 4)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || strings.Join(strings.Fields(lines[0]), " ") != "NAME TYPE LABELS VALUE" {
+		t.Fatalf("bad table %s", buf.String())
+	}
+	rows := make(map[string]bool)
+	for _, line := range lines[1:] {
+		rows[strings.Join(strings.Fields(line), " ")] = true
+	}
+	for _, row := range []string{
+		"queue.size gauge queue=jobs 3",
+		"requests sum - 1",
+		"latency histogram - count=2 sum=6 min=2 max=4",
+	} {
+		if !rows[row] {
+			t.Fatalf("missing row %q in %s", row, buf.String())
+		}
+	}
+
+	// Columns are aligned
+	column := strings.Index(lines[0], "TYPE")
+	for _, line := range lines[1:] {
+		if strings.Index(line, " "+strings.Fields(line)[1]) != column-1 {
+			t.Fatalf("columns not aligned %s", buf.String())
+		}
+	}
+}
+
+func TestNewSinkFromURL(t *testing.T) {
+	u, _ := url.Parse("otelstdout://?pretty=true")
+	s, err := NewSinkFromURL(u)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.Shutdown()
+
+	u, _ = url.Parse("otelstdout://?pretty=maybe")
+	if _, err := NewSinkFromURL(u); err == nil {
+		t.Fatalf("expected pretty error")
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	sink, err := endpoint.NewSinkFromURL("otelstdout://")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s, ok := sink.(*Sink)
+	if !ok {
+		t.Fatalf("bad sink type %T", sink)
+	}
+	s.Shutdown()
+}
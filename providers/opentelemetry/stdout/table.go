@@ -0,0 +1,112 @@
+package otelstdout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// tableExporter is a metric exporter writing each export as a table
+// aligned with text/tabwriter, one row per data point
+type tableExporter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	shutdown bool
+}
+
+// Temporality returns the default temporality of the SDK
+func (e *tableExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+// Aggregation returns the default aggregation of the SDK
+func (e *tableExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Export writes the metrics as a table
+func (e *tableExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.shutdown {
+		return sdkmetric.ErrExporterShutdown
+	}
+
+	tw := tabwriter.NewWriter(e.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tLABELS\tVALUE")
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			writeRows(tw, m)
+		}
+	}
+	return tw.Flush()
+}
+
+// ForceFlush does nothing, as the tables are written on export
+func (e *tableExporter) ForceFlush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Shutdown stops writing the exports
+func (e *tableExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return ctx.Err()
+}
+
+// writeRows writes a row for each data point of the metric, the sink
+// recording gauges, sums and histograms of float64 only
+func writeRows(w io.Writer, m metricdata.Metrics) {
+	switch data := m.Data.(type) {
+	case metricdata.Gauge[float64]:
+		for _, dp := range data.DataPoints {
+			fmt.Fprintf(w, "%s\tgauge\t%s\t%s\n", m.Name, formatAttributes(dp.Attributes), formatFloat(dp.Value))
+		}
+	case metricdata.Sum[float64]:
+		for _, dp := range data.DataPoints {
+			fmt.Fprintf(w, "%s\tsum\t%s\t%s\n", m.Name, formatAttributes(dp.Attributes), formatFloat(dp.Value))
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range data.DataPoints {
+			value := fmt.Sprintf("count=%d sum=%s", dp.Count, formatFloat(dp.Sum))
+			if min, ok := dp.Min.Value(); ok {
+				value += " min=" + formatFloat(min)
+			}
+			if max, ok := dp.Max.Value(); ok {
+				value += " max=" + formatFloat(max)
+			}
+			fmt.Fprintf(w, "%s\thistogram\t%s\t%s\n", m.Name, formatAttributes(dp.Attributes), value)
+		}
+	}
+}
+
+// formatAttributes joins the attributes as comma separated key=value
+// pairs, sorted by key, or "-" when there are none
+func formatAttributes(set attribute.Set) string {
+	if set.Len() == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, set.Len())
+	for iter := set.Iter(); iter.Next(); {
+		kv := iter.Attribute()
+		pairs = append(pairs, string(kv.Key)+"="+kv.Value.Emit())
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatFloat(val float64) string {
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
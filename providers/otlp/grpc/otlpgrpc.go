@@ -0,0 +1,136 @@
+// Package otlpgrpc provides a sink exporting metrics with OTLP over gRPC
+// to an OpenTelemetry collector, sharing the connections between sinks.
+package otlpgrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics/providers/opentelemetry/sdk"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DefaultExportInterval is the interval metrics are exported at
+const DefaultExportInterval = 10 * time.Second
+
+// Sink provides a MetricSink that records metrics on an OpenTelemetry SDK
+// meter provider, see the providers/opentelemetry/sdk package, exported
+// with OTLP over a gRPC connection taken from a ConnectionPool
+type Sink struct {
+	*sdk.Sink
+
+	provider *sdkmetric.MeterProvider
+}
+
+type config struct {
+	pool           *ConnectionPool
+	insecure       bool
+	headers        map[string]string
+	dialOpts       []grpc.DialOption
+	exportInterval time.Duration
+}
+
+// Option is used to configure a Sink
+type Option func(*config)
+
+// WithConnectionPool takes the connection from the given pool,
+// defaults to DefaultPool
+func WithConnectionPool(pool *ConnectionPool) Option {
+	return func(c *config) {
+		c.pool = pool
+	}
+}
+
+// WithInsecure disables TLS, ex: to send to a local collector
+func WithInsecure() Option {
+	return func(c *config) {
+		c.insecure = true
+	}
+}
+
+// WithHeaders sets the metadata sent with every export
+func WithHeaders(headers map[string]string) Option {
+	return func(c *config) {
+		c.headers = headers
+	}
+}
+
+// WithDialOptions adds options used when the connection is created. The
+// connection is then only shared with the sinks given the same options.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *config) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// WithExportInterval sets the interval metrics are exported at
+func WithExportInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.exportInterval = interval
+	}
+}
+
+// NewSink is used to create a new Sink exporting to the collector at
+// the target, ex: "localhost:4317". Sinks sending to the same target
+// share the connection of the pool.
+func NewSink(target string, opts ...Option) (*Sink, error) {
+	if target == "" {
+		return nil, fmt.Errorf("target must be provided")
+	}
+
+	c := &config{
+		pool:           DefaultPool,
+		exportInterval: DefaultExportInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	creds, key := credentials.NewTLS(&tls.Config{}), "tls"
+	if c.insecure {
+		creds, key = insecure.NewCredentials(), "insecure"
+	}
+	// The options are identified by their values, which are pointers
+	for _, opt := range c.dialOpts {
+		key += fmt.Sprintf(",%p", opt)
+	}
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, c.dialOpts...)
+	conn, err := c.pool.GetOrCreate(target, key, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %s", target, err)
+	}
+
+	exporterOpts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithGRPCConn(conn)}
+	if len(c.headers) > 0 {
+		exporterOpts = append(exporterOpts, otlpmetricgrpc.WithHeaders(c.headers))
+	}
+	exporter, err := otlpmetricgrpc.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(c.exportInterval))),
+	)
+	return &Sink{
+		Sink:     sdk.NewSink(provider),
+		provider: provider,
+	}, nil
+}
+
+// Flush exports all the pending metrics
+func (s *Sink) Flush() error {
+	return s.provider.ForceFlush(context.Background())
+}
+
+// Shutdown is used to export the pending metrics and stop exporting,
+// the connection is left open for the other sinks of the pool
+func (s *Sink) Shutdown() error {
+	return s.provider.Shutdown(context.Background())
+}
@@ -0,0 +1,128 @@
+package otlpgrpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// collector counts the metrics exported to it
+type collector struct {
+	collectorpb.UnimplementedMetricsServiceServer
+
+	mu      sync.Mutex
+	exports int
+}
+
+func (c *collector) Export(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exports++
+	return &collectorpb.ExportMetricsServiceResponse{}, nil
+}
+
+func startCollector(t *testing.T) (*collector, string) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	c := &collector{}
+	server := grpc.NewServer()
+	collectorpb.RegisterMetricsServiceServer(server, c)
+	go server.Serve(list)
+	t.Cleanup(server.Stop)
+	return c, list.Addr().String()
+}
+
+func TestSink_SharedConnection(t *testing.T) {
+	c, addr := startCollector(t)
+	pool := NewConnectionPool()
+	defer pool.Close()
+
+	a, err := NewSink(addr, WithConnectionPool(pool), WithInsecure())
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	b, err := NewSink(addr, WithConnectionPool(pool), WithInsecure())
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if pool.Len() != 1 {
+		t.Fatalf("bad connections %d", pool.Len())
+	}
+
+	a.IncrCounter([]string{"a"}, 1)
+	b.IncrCounter([]string{"b"}, 1)
+	if err := a.Shutdown(); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	// The connection must remain usable by the other sink
+	if err := b.Shutdown(); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.exports != 2 {
+		t.Fatalf("bad exports %d", c.exports)
+	}
+}
+
+func TestSink_NoSharedConnectionAcrossCredentials(t *testing.T) {
+	_, addr := startCollector(t)
+	pool := NewConnectionPool()
+	defer pool.Close()
+
+	plain, err := NewSink(addr, WithConnectionPool(pool), WithInsecure())
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer plain.Shutdown()
+	// Not shut down, its export would wait for the TLS handshake to fail
+	if _, err := NewSink(addr, WithConnectionPool(pool)); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	opts, err := NewSink(addr, WithConnectionPool(pool), WithInsecure(), WithDialOptions(grpc.WithUserAgent("test")))
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer opts.Shutdown()
+
+	if pool.Len() != 3 {
+		t.Fatalf("bad connections %d", pool.Len())
+	}
+}
+
+func TestConnectionPool(t *testing.T) {
+	pool := NewConnectionPool()
+	a, err := pool.GetOrCreate("localhost:4317", "insecure", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	b, _ := pool.GetOrCreate("localhost:4317", "insecure")
+	c, _ := pool.GetOrCreate("localhost:4318", "insecure", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	d, _ := pool.GetOrCreate("localhost:4317", "other", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if a != b || a == c || a == d || pool.Len() != 3 {
+		t.Fatalf("bad connections %p %p %p %p", a, b, c, d)
+	}
+
+	if err := pool.Close(); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if pool.Len() != 0 {
+		t.Fatalf("bad connections %d", pool.Len())
+	}
+
+	// Connections require transport credentials
+	if _, err := pool.GetOrCreate("localhost:4317", "insecure"); err == nil {
+		t.Fatalf("expected credentials error")
+	}
+	if _, err := NewSink(""); err == nil {
+		t.Fatalf("expected target error")
+	}
+}
@@ -0,0 +1,74 @@
+package otlpgrpc
+
+import (
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultPool is the pool the sinks take their connections from,
+// unless WithConnectionPool is used
+var DefaultPool = NewConnectionPool()
+
+// ConnectionPool shares gRPC client connections between the sinks
+// sending to the same target with the same options, so each collector
+// gets a single connection
+type ConnectionPool struct {
+	mu    sync.Mutex
+	conns map[poolKey]*grpc.ClientConn
+}
+
+// poolKey identifies the connections of a pool
+type poolKey struct {
+	target string
+	key    string
+}
+
+// NewConnectionPool creates an empty ConnectionPool
+func NewConnectionPool() *ConnectionPool {
+	return &ConnectionPool{conns: make(map[poolKey]*grpc.ClientConn)}
+}
+
+// GetOrCreate returns the connection to the target cached under the key,
+// creating it with the given options if there is none. Dial options cannot
+// be compared, so the key must identify them, ex: the transport security,
+// a TLS connection never being shared with a plaintext one. The
+// connections are owned by the pool, see Close.
+func (p *ConnectionPool) GetOrCreate(target, key string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	k := poolKey{target: target, key: key}
+	if conn, ok := p.conns[k]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[k] = conn
+	return conn, nil
+}
+
+// Len returns the number of connections of the pool
+func (p *ConnectionPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+// Close closes all the connections of the pool, once the sinks using
+// them are shut down. The pool can be used again afterwards.
+func (p *ConnectionPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var firstErr error
+	for k, conn := range p.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(p.conns, k)
+	}
+	return firstErr
+}
@@ -2,6 +2,7 @@ package prometheus
 
 import (
 	"fmt"
+	"log"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +26,15 @@ type SinkOptions struct {
 	// Expiration is the duration a metric is valid for, after which it will be
 	// untracked. If the value is zero, a metric is never expired.
 	Expiration time.Duration
+
+	// HistogramBuckets, when set, records the samples on histograms with
+	// these buckets instead of summaries, which allows attaching exemplars.
+	// prometheus.DefBuckets suits durations in seconds.
+	HistogramBuckets []float64
+
+	// Descriptors provides the help text and default labels of the
+	// registered metrics, defaults to metrics.DefaultRegistry
+	Descriptors *metrics.Registry
 }
 
 // Sink is the Prometheus implementation of the Sinker interface
@@ -32,10 +42,13 @@ type Sink struct {
 	mu         sync.Mutex
 	gauges     map[string]prometheus.Gauge
 	summaries  map[string]prometheus.Summary
+	histograms map[string]prometheus.Histogram
+	buckets    []float64
 	counters   map[string]prometheus.Counter
 	updates    map[string]time.Time
 	expiration time.Duration
 	registry   *prometheus.Registry
+	descs      *metrics.Registry
 }
 
 // NewSink creates a new Sink using the default options.
@@ -48,10 +61,16 @@ func NewSinkFrom(opts SinkOptions) (*Sink, error) {
 	sink := &Sink{
 		gauges:     make(map[string]prometheus.Gauge),
 		summaries:  make(map[string]prometheus.Summary),
+		histograms: make(map[string]prometheus.Histogram),
+		buckets:    opts.HistogramBuckets,
 		counters:   make(map[string]prometheus.Counter),
 		updates:    make(map[string]time.Time),
 		expiration: opts.Expiration,
 		registry:   prometheus.NewRegistry(),
+		descs:      opts.Descriptors,
+	}
+	if sink.descs == nil {
+		sink.descs = metrics.DefaultRegistry
 	}
 
 	c := &Collector{sink}
@@ -73,6 +92,21 @@ func (p *Sink) flattenKey(parts []string, labels []metrics.Label) (string, strin
 	return key, hash
 }
 
+// Returns the help text and the labels of a new metric, using its
+// descriptor when registered
+func (p *Sink) describe(parts []string, key string, labels []metrics.Label) (string, prometheus.Labels) {
+	desc, ok := p.descs.Lookup(parts)
+	if !ok {
+		return key, prometheusLabels(labels)
+	}
+
+	help := desc.Help
+	if help == "" {
+		help = key
+	}
+	return help, prometheusLabels(desc.MergeLabels(labels))
+}
+
 func prometheusLabels(labels []metrics.Label) prometheus.Labels {
 	l := make(prometheus.Labels)
 	for _, label := range labels {
@@ -90,18 +124,49 @@ func (p *Sink) SetGauge(parts []string, val float32) {
 func (p *Sink) SetGaugeWithLabels(parts []string, val float32, labels []metrics.Label) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.gauge(parts, labels).Set(float64(val))
+}
+
+// IncrGauge increases the value of a gauge by a given value
+func (p *Sink) IncrGauge(parts []string, val float32) {
+	p.IncrGaugeWithLabels(parts, val, nil)
+}
+
+// IncrGaugeWithLabels increases the value of a gauge by a given value with
+// labels. Prometheus gauges are absolute, the delta is added to the value
+// exposed, which starts at 0.
+func (p *Sink) IncrGaugeWithLabels(parts []string, val float32, labels []metrics.Label) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gauge(parts, labels).Add(float64(val))
+}
+
+// DecrGauge decreases the value of a gauge by a given value
+func (p *Sink) DecrGauge(parts []string, val float32) {
+	p.IncrGaugeWithLabels(parts, -val, nil)
+}
+
+// DecrGaugeWithLabels decreases the value of a gauge by a given value with labels
+func (p *Sink) DecrGaugeWithLabels(parts []string, val float32, labels []metrics.Label) {
+	p.IncrGaugeWithLabels(parts, -val, labels)
+}
+
+// gauge returns the gauge of the key and labels, creating it if needed,
+// and marks it as updated. The lock must be held.
+func (p *Sink) gauge(parts []string, labels []metrics.Label) prometheus.Gauge {
 	key, hash := p.flattenKey(parts, labels)
 	g, ok := p.gauges[hash]
 	if !ok {
+		help, constLabels := p.describe(parts, key, labels)
 		g = prometheus.NewGauge(prometheus.GaugeOpts{
 			Name:        key,
-			Help:        key,
-			ConstLabels: prometheusLabels(labels),
+			Help:        help,
+			ConstLabels: constLabels,
 		})
 		p.gauges[hash] = g
 	}
-	g.Set(float64(val))
 	p.updates[hash] = time.Now()
+	return g
 }
 
 // EmitKey is not implemented. Prometheus doesn’t offer a type for which an
@@ -117,19 +182,30 @@ func (p *Sink) IncrCounter(parts []string, val float32) {
 
 // IncrCounterWithLabels increases the value of a counter by a given value with labels
 func (p *Sink) IncrCounterWithLabels(parts []string, val float32, labels []metrics.Label) {
+	p.IncrCounterWithLabelsFloat64(parts, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (p *Sink) IncrCounterFloat64(parts []string, val float64) {
+	p.IncrCounterWithLabelsFloat64(parts, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (p *Sink) IncrCounterWithLabelsFloat64(parts []string, val float64, labels []metrics.Label) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	key, hash := p.flattenKey(parts, labels)
 	g, ok := p.counters[hash]
 	if !ok {
+		help, constLabels := p.describe(parts, key, labels)
 		g = prometheus.NewCounter(prometheus.CounterOpts{
 			Name:        key,
-			Help:        key,
-			ConstLabels: prometheusLabels(labels),
+			Help:        help,
+			ConstLabels: constLabels,
 		})
 		p.counters[hash] = g
 	}
-	g.Add(float64(val))
+	g.Add(val)
 	p.updates[hash] = time.Now()
 }
 
@@ -142,17 +218,67 @@ func (p *Sink) AddSample(parts []string, val float32) {
 func (p *Sink) AddSampleWithLabels(parts []string, val float32, labels []metrics.Label) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.buckets != nil {
+		p.histogram(parts, labels).Observe(float64(val))
+		return
+	}
 	key, hash := p.flattenKey(parts, labels)
 	g, ok := p.summaries[hash]
 	if !ok {
+		help, constLabels := p.describe(parts, key, labels)
 		g = prometheus.NewSummary(prometheus.SummaryOpts{
 			Name:        key,
-			Help:        key,
+			Help:        help,
 			MaxAge:      10 * time.Second,
-			ConstLabels: prometheusLabels(labels),
+			ConstLabels: constLabels,
 		})
 		p.summaries[hash] = g
 	}
 	g.Observe(float64(val))
 	p.updates[hash] = time.Now()
 }
+
+// AddSampleWithExemplar adds a sample metrics with an exemplar, exposed
+// in the OpenMetrics format. Exemplars require HistogramBuckets to be set,
+// they are ignored on summaries.
+func (p *Sink) AddSampleWithExemplar(parts []string, val float32, exemplar map[string]string) {
+	if p.buckets == nil {
+		p.AddSample(parts, val)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.histogram(parts, nil)
+	observeWithExemplar(h.(prometheus.ExemplarObserver), float64(val), exemplar)
+}
+
+// Returns the histogram of the key, creating it if needed.
+// Must be called with the lock held.
+func (p *Sink) histogram(parts []string, labels []metrics.Label) prometheus.Histogram {
+	key, hash := p.flattenKey(parts, labels)
+	h, ok := p.histograms[hash]
+	if !ok {
+		help, constLabels := p.describe(parts, key, labels)
+		h = prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        key,
+			Help:        help,
+			Buckets:     p.buckets,
+			ConstLabels: constLabels,
+		})
+		p.histograms[hash] = h
+	}
+	p.updates[hash] = time.Now()
+	return h
+}
+
+// Observes the value with the exemplar. The client panics on invalid
+// exemplars after observing the value, the panic is recovered and logged.
+func observeWithExemplar(o prometheus.ExemplarObserver, val float64, exemplar map[string]string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERR] Invalid Prometheus exemplar %v! Err: %v", exemplar, r)
+		}
+	}()
+	o.ObserveWithExemplar(val, prometheus.Labels(exemplar))
+}
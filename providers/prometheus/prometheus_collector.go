@@ -46,6 +46,15 @@ func (p *Collector) Collect(c chan<- prometheus.Metric) {
 			v.Collect(c)
 		}
 	}
+	for k, v := range p.Sink.histograms {
+		last := p.Sink.updates[k]
+		if expire && last.Add(p.Sink.expiration).Before(now) {
+			delete(p.Sink.updates, k)
+			delete(p.Sink.histograms, k)
+		} else {
+			v.Collect(c)
+		}
+	}
 	for k, v := range p.Sink.counters {
 		last := p.Sink.updates[k]
 		if expire && last.Add(p.Sink.expiration).Before(now) {
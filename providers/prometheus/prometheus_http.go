@@ -6,7 +6,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// HTTPHandlerFor creates a collector handler for http
+// HTTPHandlerFor creates a collector handler for http. The OpenMetrics
+// format, which carries the exemplars, is served when requested by the
+// scraper.
 func HTTPHandlerFor(s *Sink) http.Handler {
-	return promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})
+	return promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// Handler returns the handler exposing the metrics of the sink, to be
+// mounted by the caller, ex: http.Handle("/metrics", sink.Handler())
+func (p *Sink) Handler() http.Handler {
+	return HTTPHandlerFor(p)
 }
@@ -1,8 +1,12 @@
 package prometheus
 
 import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hugoluchessi/go-metrics"
 	dto "github.com/prometheus/client_model/go"
@@ -134,3 +138,149 @@ func AssertSummaryDTOMetric(t *testing.T, desc string, m *dto.Metric) {
 		t.Fatal("unexpected gauge desc")
 	}
 }
+
+func TestPrometheusSink_GaugeDelta(t *testing.T) {
+	p, _ := NewSink()
+	var _ metrics.GaugeDeltaSink = p
+
+	p.SetGauge([]string{"gauge"}, 10)
+	p.IncrGauge([]string{"gauge"}, 2)
+	p.DecrGauge([]string{"gauge"}, 5)
+	p.IncrGaugeWithLabels([]string{"gauge"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	p.DecrGaugeWithLabels([]string{"gauge"}, 3, []metrics.Label{{Name: "a", Value: "b"}})
+
+	families, _ := p.registry.Gather()
+	values := map[int]float64{}
+	for _, f := range families {
+		for _, m := range f.Metric {
+			values[len(m.Label)] = m.Gauge.GetValue()
+		}
+	}
+	if !reflect.DeepEqual(values, map[int]float64{0: 7, 1: -2}) {
+		t.Fatalf("bad gauges %v", values)
+	}
+}
+
+func TestPrometheusSink_Descriptors(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.Register(metrics.Descriptor{
+		Name:   []string{"counter", "described"},
+		Type:   metrics.CounterMetric,
+		Help:   "A described counter",
+		Labels: []metrics.Label{{Name: "a", Value: "default"}, {Name: "c", Value: "d"}},
+	})
+
+	p, _ := NewSinkFrom(SinkOptions{Descriptors: r})
+	p.IncrCounterWithLabels([]string{"counter", "described"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	p.IncrCounter([]string{"counter", "anonymous"}, 1)
+
+	families, _ := p.registry.Gather()
+	if len(families) != 2 {
+		t.Fatalf("bad families %v", families)
+	}
+	for _, f := range families {
+		switch f.GetName() {
+		case "counter_described":
+			if f.GetHelp() != "A described counter" {
+				t.Fatalf("bad help %s", f.GetHelp())
+			}
+			lbs := f.Metric[0].GetLabel()
+			if len(lbs) != 2 || lbs[0].GetValue() != "b" || lbs[1].GetValue() != "d" {
+				t.Fatalf("bad labels %v", lbs)
+			}
+		case "counter_anonymous":
+			if f.GetHelp() != "counter_anonymous" {
+				t.Fatalf("bad help %s", f.GetHelp())
+			}
+		default:
+			t.Fatalf("unexpected family %s", f.GetName())
+		}
+	}
+}
+
+func TestPrometheusSink_Exemplar(t *testing.T) {
+	p, _ := NewSinkFrom(SinkOptions{HistogramBuckets: []float64{1, 10}})
+	p.AddSampleWithExemplar([]string{"duration"}, 5, map[string]string{"traceID": "abc"})
+	p.AddSampleWithLabels([]string{"duration"}, 20, []metrics.Label{{Name: "a", Value: "b"}})
+
+	// Invalid exemplars are dropped, keeping the observation
+	p.AddSampleWithExemplar([]string{"duration"}, 0.5, map[string]string{"invalid-name": "x"})
+
+	families, _ := p.registry.Gather()
+	if len(families) != 1 || families[0].GetType() != dto.MetricType_HISTOGRAM {
+		t.Fatalf("bad families %v", families)
+	}
+
+	var plain *dto.Histogram
+	for _, m := range families[0].Metric {
+		if len(m.GetLabel()) == 0 {
+			plain = m.GetHistogram()
+		}
+	}
+	if plain == nil || plain.GetSampleCount() != 2 || plain.GetSampleSum() != 5.5 {
+		t.Fatalf("bad histogram %v", plain)
+	}
+	exemplar := plain.Bucket[1].GetExemplar()
+	if exemplar == nil || exemplar.GetValue() != 5 || exemplar.Label[0].GetValue() != "abc" {
+		t.Fatalf("bad exemplar %v", exemplar)
+	}
+}
+
+func TestPrometheusSink_ExemplarSummary(t *testing.T) {
+	p, _ := NewSink()
+	p.AddSampleWithExemplar([]string{"duration"}, 5, map[string]string{"traceID": "abc"})
+
+	families, _ := p.registry.Gather()
+	if len(families) != 1 || families[0].GetType() != dto.MetricType_SUMMARY {
+		t.Fatalf("exemplars must be ignored without histograms %v", families)
+	}
+}
+
+func TestHTTPHandlerFor_OpenMetrics(t *testing.T) {
+	p, _ := NewSinkFrom(SinkOptions{HistogramBuckets: []float64{10}})
+	p.AddSampleWithExemplar([]string{"duration"}, 5, map[string]string{"traceID": "abc"})
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	rec := httptest.NewRecorder()
+	HTTPHandlerFor(p).ServeHTTP(rec, req)
+
+	if body := rec.Body.String(); !strings.Contains(body, `duration_bucket{le="10.0"} 1 # {traceID="abc"} 5.0`) {
+		t.Fatalf("bad exposition %s", body)
+	}
+}
+
+func TestSink_Handler(t *testing.T) {
+	p, _ := NewSink()
+	p.SetGaugeWithLabels([]string{"queue", "size"}, 3, []metrics.Label{{Name: "queue", Value: "jobs"}})
+
+	rec := httptest.NewRecorder()
+	p.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if body := rec.Body.String(); !strings.Contains(body, `queue_size{queue="jobs"} 3`) {
+		t.Fatalf("bad exposition %s", body)
+	}
+}
+
+func TestNewSinkFromURL(t *testing.T) {
+	u, _ := url.Parse("prometheus://?expiration=5m&buckets=0.1,1,10")
+	p, err := NewSinkFromURL(u)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p.expiration != 5*time.Minute || !reflect.DeepEqual(p.buckets, []float64{0.1, 1, 10}) {
+		t.Fatalf("bad options %s %v", p.expiration, p.buckets)
+	}
+
+	u, _ = url.Parse("prometheus://")
+	if p, err = NewSinkFromURL(u); err != nil || p.expiration != DefaultSinkOptions.Expiration || p.buckets != nil {
+		t.Fatalf("bad defaults %v", err)
+	}
+
+	for _, bad := range []string{"prometheus://?expiration=foo", "prometheus://?buckets=1,a"} {
+		u, _ = url.Parse(bad)
+		if _, err := NewSinkFromURL(u); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
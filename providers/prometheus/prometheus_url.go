@@ -0,0 +1,37 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewSinkFromURL creates a Sink from a URL such as
+// "prometheus://?expiration=5m&buckets=0.01,0.1,1". The "expiration" query
+// parameter overrides DefaultSinkOptions.Expiration, "0" never expiring
+// the metrics, and "buckets" sets SinkOptions.HistogramBuckets.
+func NewSinkFromURL(u *url.URL) (*Sink, error) {
+	opts := DefaultSinkOptions
+	query := u.Query()
+
+	if v := query.Get("expiration"); v != "" {
+		expiration, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiration %q: %s", v, err)
+		}
+		opts.Expiration = expiration
+	}
+	if v := query.Get("buckets"); v != "" {
+		for _, bound := range strings.Split(v, ",") {
+			b, err := strconv.ParseFloat(strings.TrimSpace(bound), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bucket %q: %s", bound, err)
+			}
+			opts.HistogramBuckets = append(opts.HistogramBuckets, b)
+		}
+	}
+
+	return NewSinkFrom(opts)
+}
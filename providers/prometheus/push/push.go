@@ -0,0 +1,220 @@
+// Package push provides a sink pushing the metrics of a Prometheus sink
+// to a Pushgateway, for jobs which cannot be scraped, ex: batch jobs
+package push
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	httppush "github.com/hugoluchessi/go-metrics/providers/internal/push"
+	"github.com/hugoluchessi/go-metrics/providers/prometheus"
+	prompush "github.com/prometheus/client_golang/prometheus/push"
+)
+
+// DefaultPushInterval is the interval metrics are pushed at
+const DefaultPushInterval = 15 * time.Second
+
+// Sink aggregates metrics like the Prometheus sink and pushes them to a
+// Pushgateway, under the group identified by the job and the grouping
+// labels: /metrics/job/<job>/<label1>/<value1>/<label2>/<value2>
+type Sink struct {
+	*prometheus.Sink
+
+	pusher   *prompush.Pusher
+	method   string
+	interval time.Duration
+	options  prometheus.SinkOptions
+	grouping map[string]string
+	client   *http.Client
+
+	signature httppush.Signature
+
+	stopCh       chan struct{}
+	doneCh       chan struct{}
+	shutdownOnce sync.Once
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithGroupingLabels adds labels identifying the group of the metrics,
+// besides the job, ex: the instance
+func WithGroupingLabels(labels map[string]string) Option {
+	return func(s *Sink) {
+		for name, value := range labels {
+			s.grouping[name] = value
+		}
+	}
+}
+
+// WithMethod sets the HTTP method of the pushes: http.MethodPut, the
+// default, replaces all the metrics of the group, while http.MethodPost
+// only replaces the metrics with the same names
+func WithMethod(method string) Option {
+	return func(s *Sink) {
+		s.method = method
+	}
+}
+
+// WithPushInterval sets the interval metrics are pushed at, defaults to
+// DefaultPushInterval. An interval lower than or equal to 0 disables the
+// periodic pushes, metrics being pushed on Flush and Shutdown only.
+func WithPushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.interval = interval
+	}
+}
+
+// WithSinkOptions sets the options of the underlying Prometheus sink,
+// defaults to prometheus.DefaultSinkOptions
+func WithSinkOptions(opts prometheus.SinkOptions) Option {
+	return func(s *Sink) {
+		s.options = opts
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.client = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.signature.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.signature.SetSignatureEncoding(encoding) }
+}
+
+// NewSink creates a Sink pushing to the Pushgateway at the given URL,
+// ex: "http://pushgateway:9091", under the given job
+func NewSink(url, job string, opts ...Option) (*Sink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("pushgateway URL must be provided")
+	}
+	if job == "" {
+		return nil, fmt.Errorf("job must be provided")
+	}
+
+	s := &Sink{
+		method:   http.MethodPut,
+		interval: DefaultPushInterval,
+		options:  prometheus.DefaultSinkOptions,
+		grouping: make(map[string]string),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.method != http.MethodPut && s.method != http.MethodPost {
+		return nil, fmt.Errorf("push method must be PUT or POST, got %q", s.method)
+	}
+
+	sink, err := prometheus.NewSinkFrom(s.options)
+	if err != nil {
+		return nil, err
+	}
+	s.Sink = sink
+
+	s.pusher = prompush.New(url, job).Collector(&prometheus.Collector{Sink: sink})
+	names := make([]string, 0, len(s.grouping))
+	for name := range s.grouping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.pusher.Grouping(name, s.grouping[name])
+	}
+	if s.signature.SignatureSecret != nil {
+		sign, err := httppush.HMACSignature(s.signature.SignatureSecret, s.signature.SignatureAlgorithm, s.signature.SignatureEncoding)
+		if err != nil {
+			return nil, err
+		}
+		client := s.client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		s.pusher.Client(&signingClient{client: client, sign: sign})
+	} else if s.client != nil {
+		s.pusher.Client(s.client)
+	}
+
+	if s.interval > 0 {
+		go s.run()
+	} else {
+		close(s.doneCh)
+	}
+	return s, nil
+}
+
+// Flush pushes the metrics now
+func (s *Sink) Flush() error {
+	if s.method == http.MethodPost {
+		return s.pusher.Add()
+	}
+	return s.pusher.Push()
+}
+
+// Shutdown stops the periodic pushes and pushes the metrics a last time
+func (s *Sink) Shutdown() {
+	s.shutdownOnce.Do(func() {
+		close(s.stopCh)
+		<-s.doneCh
+		if err := s.Flush(); err != nil {
+			log.Printf("[ERR] Error pushing to pushgateway! Err: %s", err)
+		}
+	})
+}
+
+// run pushes the metrics at every interval until the sink is shut down
+func (s *Sink) run() {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				log.Printf("[ERR] Error pushing to pushgateway! Err: %s", err)
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// signingClient signs the requests of the pusher before sending them
+type signingClient struct {
+	client prompush.HTTPDoer
+	sign   httppush.RequestHook
+}
+
+// Do signs the request with its body, then sends it
+func (c *signingClient) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if err := c.sign(req, body); err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
@@ -0,0 +1,124 @@
+package push
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+type request struct {
+	method string
+	path   string
+	body   string
+}
+
+func newGateway(t *testing.T) (*httptest.Server, chan request) {
+	reqs := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("err: %v", err)
+		}
+		reqs <- request{r.Method, r.URL.Path, string(body)}
+	}))
+	return srv, reqs
+}
+
+func TestSink_Grouping(t *testing.T) {
+	srv, reqs := newGateway(t)
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, "batch", WithPushInterval(0),
+		WithGroupingLabels(map[string]string{"zone": "eu", "instance": "worker-1"}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.IncrCounter([]string{"jobs", "done"}, 3)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := <-reqs
+	if req.method != http.MethodPut {
+		t.Fatalf("bad method %s", req.method)
+	}
+	// The client orders the grouping labels randomly
+	if req.path != "/metrics/job/batch/instance/worker-1/zone/eu" && req.path != "/metrics/job/batch/zone/eu/instance/worker-1" {
+		t.Fatalf("bad path %s", req.path)
+	}
+	if !strings.Contains(req.body, "jobs_done") {
+		t.Fatalf("bad body %q", req.body)
+	}
+}
+
+func TestSink_Post(t *testing.T) {
+	srv, reqs := newGateway(t)
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, "batch", WithMethod(http.MethodPost), WithPushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.SetGauge([]string{"queue"}, 1)
+
+	select {
+	case req := <-reqs:
+		if req.method != http.MethodPost || req.path != "/metrics/job/batch" {
+			t.Fatalf("bad request %s %s", req.method, req.path)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no periodic push")
+	}
+
+	s.Shutdown()
+	s.Shutdown()
+}
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("", "job"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := NewSink("http://localhost:9091", ""); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := NewSink("http://localhost:9091", "job", WithMethod(http.MethodGet)); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("http://localhost:9091", "batch", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig, expected string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		sig, expected = r.Header.Get(signature.Header), hex.EncodeToString(mac.Sum(nil))
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, "batch", WithPushInterval(0), WithHMACSignature([]byte("secret"), signature.SHA256))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.IncrCounter([]string{"counter"}, 1)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.Shutdown()
+
+	if sig == "" || sig != expected {
+		t.Fatalf("bad signature %q, expected %q", sig, expected)
+	}
+}
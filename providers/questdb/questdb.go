@@ -0,0 +1,149 @@
+// Package questdb provides a sink writing metrics to QuestDB with the
+// InfluxDB line protocol over TCP
+package questdb
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/linewriter"
+)
+
+const (
+	// DefaultPort is the port of the line protocol TCP endpoint
+	DefaultPort = "9009"
+)
+
+// The metric types, stored in the "type" symbol
+const (
+	gaugeType   = "gauge"
+	keyType     = "kv"
+	counterType = "counter"
+	sampleType  = "sample"
+)
+
+var (
+	// tableEscaper escapes the table names, the line protocol having no
+	// escape for line breaks they are replaced
+	tableEscaper = strings.NewReplacer("\\", "\\\\", " ", "\\ ", ",", "\\,", "\n", "_", "\r", "_")
+
+	// symbolEscaper escapes the symbol names and values, as tableEscaper
+	symbolEscaper = strings.NewReplacer("\\", "\\\\", " ", "\\ ", ",", "\\,", "=", "\\=", "\n", "_", "\r", "_")
+)
+
+// Sink provides a MetricSink writing every metric as a row of a single
+// QuestDB table, where the metric name, the metric type and the labels
+// are symbols and the value a double column:
+//
+//	metrics,metric=http.requests,type=counter,code=200 value=1 1556813561098000000
+//
+// Lines are buffered and written over a TCP connection, reopened with a
+// backoff after errors. Metrics pushed while the queue is full are dropped.
+type Sink struct {
+	addr   string
+	table  string
+	writer *linewriter.Writer
+}
+
+// NewSink is used to create a new Sink writing to the table of the QuestDB
+// server at the address, ex: "localhost:9009", the port defaulting to
+// DefaultPort. The table is created by QuestDB on the first write.
+func NewSink(addr, table string) (*Sink, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("questdb address must be provided")
+	}
+	if table == "" {
+		return nil, fmt.Errorf("questdb table must be provided")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), DefaultPort)
+	}
+
+	writer, err := linewriter.New(linewriter.Config{Name: "questdb", Addr: addr})
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		addr:   addr,
+		table:  tableEscaper.Replace(table),
+		writer: writer,
+	}, nil
+}
+
+// Shutdown is used to stop writing to QuestDB, it blocks until the
+// queued metrics are sent. No metric must be pushed after shutting down.
+func (s *Sink) Shutdown() {
+	s.writer.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.pushMetric(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.pushMetric(keyType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.pushMetric(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.pushMetric(sampleType, key, float64(val), labels)
+}
+
+// Does a non-blocking push to the metrics queue
+func (s *Sink) pushMetric(metricType string, key []string, val float64, labels []metrics.Label) {
+	s.writer.Push(s.formatLine(metricType, key, val, labels))
+}
+
+// Formats a metric as a line of the table
+func (s *Sink) formatLine(metricType string, key []string, val float64, labels []metrics.Label) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString(s.table)
+	buf.WriteString(",metric=" + symbolEscaper.Replace(strings.Join(key, ".")))
+	buf.WriteString(",type=" + metricType)
+	for _, label := range labels {
+		if label.Name == "" || label.Value == "" {
+			continue
+		}
+		buf.WriteString("," + symbolEscaper.Replace(label.Name) + "=" + symbolEscaper.Replace(label.Value))
+	}
+	buf.WriteString(" value=" + strconv.FormatFloat(val, 'f', -1, 64))
+	buf.WriteString(" " + strconv.FormatInt(time.Now().UnixNano(), 10) + "\n")
+	return buf.String()
+}
@@ -0,0 +1,97 @@
+package questdb
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("", "metrics"); err == nil {
+		t.Fatalf("expected error for missing address")
+	}
+	if _, err := NewSink("localhost:9009", ""); err == nil {
+		t.Fatalf("expected error for missing table")
+	}
+}
+
+func TestNewSink_DefaultPort(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"localhost":      "localhost:9009",
+		"localhost:9000": "localhost:9000",
+		"[::1]":          "[::1]:9009",
+	} {
+		s, err := NewSink(addr, "metrics")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		s.Shutdown()
+		if s.addr != expected {
+			t.Fatalf("bad addr %s for %s", s.addr, addr)
+		}
+	}
+}
+
+func TestSink(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer list.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := list.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	s, err := NewSink(list.Addr().String(), "app metrics")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "host", Value: "web 1"}, {Name: "empty"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4.5)
+	s.Shutdown()
+
+	for _, expected := range []string{
+		`app\ metrics,metric=gauge.val,type=gauge,host=web\ 1 value=1 `,
+		`app\ metrics,metric=key,type=kv value=2 `,
+		`app\ metrics,metric=counter,type=counter value=3 `,
+		`app\ metrics,metric=sample,type=sample value=4.5 `,
+	} {
+		select {
+		case line := <-lines:
+			if !regexp.MustCompile("^" + regexp.QuoteMeta(expected) + `\d+$`).MatchString(line) {
+				t.Fatalf("bad line %q, expected %q", line, expected)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+func TestFormatLine_LineBreaks(t *testing.T) {
+	s := &Sink{table: tableEscaper.Replace("app\nmetrics")}
+	line := s.formatLine(counterType, []string{"requests\r\n"}, 1, []metrics.Label{
+		{Name: "env", Value: "prod\nx"},
+		{Name: "path", Value: `C:\a`},
+	})
+
+	expected := `app_metrics,metric=requests__,type=counter,env=prod_x,path=C:\\a value=1 `
+	if !regexp.MustCompile("^" + regexp.QuoteMeta(expected) + `\d+\n$`).MatchString(line) {
+		t.Fatalf("bad line %q, expected %q", line, expected)
+	}
+}
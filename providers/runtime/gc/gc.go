@@ -0,0 +1,93 @@
+// Package gc emits the details of the Go garbage collector cycles
+package gc
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// DefaultInterval is the collection interval used when the interval
+// given to StartCollector is not positive
+const DefaultInterval = 10 * time.Second
+
+var (
+	pauseKey  = []string{"go", "gc", "pause_ns"}
+	cyclesKey = []string{"go", "gc", "cycles_total"}
+)
+
+// StartCollector starts collecting the GC pauses every interval, returning
+// a function to stop it. Each new pause is emitted as a go.gc.pause_ns
+// sample and the number of new cycles is added to the go.gc.cycles_total
+// counter. runtime.MemStats only retains the last 256 pauses, so pauses
+// are lost when more cycles happen during an interval. The interval
+// defaults to DefaultInterval when not positive.
+func StartCollector(sink metrics.Sinker, interval time.Duration) func() {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	c := &collector{sink: sink}
+
+	// Only report the cycles happening from now on
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	c.lastNumGC = stats.NumGC
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.collect()
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stopCh)
+			<-doneCh
+		})
+	}
+}
+
+type collector struct {
+	sink      metrics.Sinker
+	lastNumGC uint32
+}
+
+// Emits the pauses of the cycles since the last collection
+func (c *collector) collect() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	c.emit(&stats)
+}
+
+func (c *collector) emit(stats *runtime.MemStats) {
+	num := stats.NumGC
+	if num == c.lastNumGC {
+		return
+	}
+	c.sink.IncrCounter(cyclesKey, float32(num-c.lastNumGC))
+
+	// Ensure we don't scan more than the 256 retained pauses
+	first := c.lastNumGC
+	if num-first > uint32(len(stats.PauseNs)) {
+		first = num - uint32(len(stats.PauseNs))
+	}
+
+	for i := first; i < num; i++ {
+		pause := stats.PauseNs[i%uint32(len(stats.PauseNs))]
+		c.sink.AddSample(pauseKey, float32(pause))
+	}
+	c.lastNumGC = num
+}
@@ -0,0 +1,98 @@
+package gc
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+type recorder struct {
+	metrics.BlackholeSink
+
+	mu      sync.Mutex
+	cycles  float32
+	samples int
+}
+
+func (r *recorder) IncrCounter(key []string, val float32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if strings.Join(key, ".") == "go.gc.cycles_total" {
+		r.cycles += val
+	}
+}
+
+func (r *recorder) AddSample(key []string, val float32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if strings.Join(key, ".") == "go.gc.pause_ns" {
+		r.samples++
+	}
+}
+
+func TestCollector(t *testing.T) {
+	r := &recorder{}
+	c := &collector{sink: r, lastNumGC: 10}
+
+	stats := &runtime.MemStats{NumGC: 13}
+	stats.PauseNs[10] = 100
+	stats.PauseNs[11] = 200
+	stats.PauseNs[12] = 300
+	c.emit(stats)
+
+	if r.cycles != 3 || r.samples != 3 {
+		t.Fatalf("bad cycles %v samples %d", r.cycles, r.samples)
+	}
+
+	// No new cycles, nothing emitted
+	c.emit(stats)
+	if r.cycles != 3 || r.samples != 3 {
+		t.Fatalf("bad cycles %v samples %d", r.cycles, r.samples)
+	}
+}
+
+func TestCollector_Wrap(t *testing.T) {
+	r := &recorder{}
+	c := &collector{sink: r}
+
+	// More than 256 cycles happened since the last collection
+	c.emit(&runtime.MemStats{NumGC: 1000})
+
+	if r.cycles != 1000 || r.samples != 256 {
+		t.Fatalf("bad cycles %v samples %d", r.cycles, r.samples)
+	}
+}
+
+func TestStartCollector(t *testing.T) {
+	r := &recorder{}
+	stop := StartCollector(r, time.Millisecond)
+
+	runtime.GC()
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.Lock()
+		samples := r.samples
+		r.mu.Unlock()
+		if samples > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no pause collected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stop()
+	stop()
+}
+
+func TestStartCollector_DefaultInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		stop := StartCollector(&recorder{}, interval)
+		stop()
+	}
+}
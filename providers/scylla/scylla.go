@@ -0,0 +1,226 @@
+// Package scylla provides a sink pushing custom metrics to the REST API
+// of a ScyllaDB node, so they are exposed on its Prometheus endpoint
+// along with the database metrics
+package scylla
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultPort is the port of the REST API of a Scylla node
+	DefaultPort = "10000"
+
+	// metricsPath is the path of the custom metrics endpoint
+	metricsPath = "/metrics"
+)
+
+// The types of the custom metrics
+const (
+	gaugeType     = "gauge"
+	counterType   = "counter"
+	histogramType = "histogram"
+)
+
+// forbiddenChars are replaced in the metric and label names, which must
+// be valid Prometheus names
+var forbiddenChars = regexp.MustCompile("[^a-zA-Z0-9_:]")
+
+// Sink provides a MetricSink that pushes metrics with PUT requests to
+// the /metrics endpoint of the REST API of a Scylla node. Key values are
+// sent as gauges and samples as histogram observations.
+type Sink struct {
+	prefix string
+	config push.Config
+	client *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithPrefix prepends a prefix to the metric names, ex: "myapp"
+// turns the "requests" key into "myapp_requests"
+func WithPrefix(prefix string) Option {
+	return func(s *Sink) {
+		s.prefix = prefix
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics, ex: to use TLS
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// metric is the representation of a custom metric
+type metric struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Value     float64           `json:"value"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// NewSink is used to create a new Sink pushing to the REST API of the
+// node, ex: "10.0.0.1", "10.0.0.1:10000" or "https://scylla-1:10000",
+// the port defaulting to DefaultPort
+func NewSink(nodeAddr string, opts ...Option) (*Sink, error) {
+	if nodeAddr == "" {
+		return nil, fmt.Errorf("node address must be provided")
+	}
+
+	url := nodeAddr
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	url = strings.TrimSuffix(url, "/")
+	if host := url[strings.Index(url, "://")+3:]; !strings.Contains(host, ":") {
+		url += ":" + DefaultPort
+	}
+
+	s := &Sink{
+		config: push.Config{
+			Name:        "scylla",
+			URL:         url + metricsPath,
+			Method:      http.MethodPut,
+			ContentType: "application/json",
+			Encode:      encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics, sent as a histogram observation
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels, sent as a histogram observation
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(histogramType, key, float64(val), labels)
+}
+
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	m := metric{
+		Name:      s.flattenKey(key),
+		Type:      metricType,
+		Value:     val,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if len(labels) > 0 {
+		m.Labels = make(map[string]string, len(labels))
+		for _, label := range labels {
+			m.Labels[forbiddenChars.ReplaceAllString(label.Name, "_")] = label.Value
+		}
+	}
+	s.client.Push(m)
+}
+
+// flattenKey joins the key with the prefix as a Prometheus metric name
+func (s *Sink) flattenKey(parts []string) string {
+	if s.prefix != "" {
+		parts = append([]string{s.prefix}, parts...)
+	}
+	return forbiddenChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+// encode formats the metrics as a custom metrics payload
+func encode(records []interface{}) ([]byte, error) {
+	return json.Marshal(struct {
+		Metrics []interface{} `json:"metrics"`
+	}{records})
+}
@@ -0,0 +1,112 @@
+package scylla
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink(""); err == nil {
+		t.Fatalf("expected error for missing node address")
+	}
+}
+
+func TestNewSink_URL(t *testing.T) {
+	for addr, url := range map[string]string{
+		"10.0.0.1":                "http://10.0.0.1:10000/metrics",
+		"10.0.0.1:8080":           "http://10.0.0.1:8080/metrics",
+		"https://scylla-1:10000/": "https://scylla-1:10000/metrics",
+	} {
+		s, err := NewSink(addr)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		s.Shutdown()
+		if s.config.URL != url {
+			t.Fatalf("bad url %s for %s", s.config.URL, addr)
+		}
+	}
+}
+
+func TestSink(t *testing.T) {
+	var method string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method + " " + r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, WithPrefix("myapp"), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "a.b", Value: "c"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample-ms"}, 4)
+	s.Flush()
+
+	if method != "PUT /metrics" {
+		t.Fatalf("bad request %s", method)
+	}
+
+	var got struct {
+		Metrics []metric `json:"metrics"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("bad body %s: %v", body, err)
+	}
+
+	expected := []metric{
+		{Name: "myapp_gauge_val", Type: gaugeType, Value: 1, Labels: map[string]string{"a_b": "c"}},
+		{Name: "myapp_key", Type: gaugeType, Value: 2},
+		{Name: "myapp_counter", Type: counterType, Value: 3},
+		{Name: "myapp_sample_ms", Type: histogramType, Value: 4},
+	}
+	for i := range got.Metrics {
+		if got.Metrics[i].Timestamp == 0 {
+			t.Fatalf("missing timestamp %v", got.Metrics[i])
+		}
+		got.Metrics[i].Timestamp = 0
+	}
+	if !reflect.DeepEqual(got.Metrics, expected) {
+		t.Fatalf("bad metrics %v", got.Metrics)
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("localhost", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
@@ -0,0 +1,29 @@
+// Package signature defines the HMAC signatures the sinks pushing to HTTP
+// endpoints can set on their requests.
+//
+// The WithHMACSignature option of these sinks signs each request with an
+// HMAC of its body, set on the X-Signature header. The algorithm is SHA1,
+// SHA256 or SHA512, defaulting to SHA256 when empty. The
+// WithSignatureEncoding option sets the encoding of the signature, Hex or
+// Base64, defaulting to Hex when empty. NewSink fails on any other
+// algorithm or encoding.
+package signature
+
+// Header is the header holding the signature of the request body
+const Header = "X-Signature"
+
+// The hash algorithms of the HMAC, SHA256 being the default
+const (
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+)
+
+// The encodings of the signature, Hex being the default
+const (
+	// Hex encodes the signature as lowercase hex
+	Hex = "hex"
+
+	// Base64 encodes the signature as standard base64
+	Base64 = "base64"
+)
@@ -0,0 +1,266 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+
+	// Registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// DefaultFlushInterval is the interval queued metrics are inserted at
+	DefaultFlushInterval = time.Second
+
+	// DefaultQueueSize is the number of metrics buffered before dropping
+	DefaultQueueSize = 4096
+
+	gaugeType   = "gauge"
+	counterType = "counter"
+	sampleType  = "sample"
+	keyType     = "key"
+
+	createTable = `CREATE TABLE IF NOT EXISTS metrics (
+		id INTEGER PRIMARY KEY,
+		ts INTEGER,
+		name TEXT,
+		type TEXT,
+		value REAL,
+		labels TEXT
+	)`
+
+	insertRow = `INSERT INTO metrics (ts, name, type, value, labels) VALUES (?, ?, ?, ?, ?)`
+)
+
+// Sink provides a MetricSink that inserts a row per metric call in the
+// "metrics" table of a SQLite database, for local debugging. The ts column
+// holds the time in milliseconds, the labels column a JSON object. Rows
+// are inserted in a background goroutine, a transaction per flush interval.
+type Sink struct {
+	db *sql.DB
+
+	flushInterval time.Duration
+	queueSize     int
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan row
+	done   chan struct{}
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithFlushInterval sets the interval queued metrics are inserted at,
+// defaults to DefaultFlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.flushInterval = interval
+	}
+}
+
+// WithQueueSize sets the number of metrics buffered, defaults to DefaultQueueSize
+func WithQueueSize(size int) Option {
+	return func(s *Sink) {
+		s.queueSize = size
+	}
+}
+
+// row is a metric to be inserted
+type row struct {
+	ts     int64
+	name   string
+	kind   string
+	value  float64
+	labels string
+}
+
+// NewSink is used to create a new Sink writing to the database at dbPath,
+// which is created along with the metrics table when missing
+func NewSink(dbPath string, opts ...Option) (*Sink, error) {
+	s := &Sink{
+		flushInterval: DefaultFlushInterval,
+		queueSize:     DefaultQueueSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.flushInterval <= 0 {
+		return nil, fmt.Errorf("flush interval must be positive, got %s", s.flushInterval)
+	}
+	if s.queueSize <= 0 {
+		return nil, fmt.Errorf("queue size must be positive, got %d", s.queueSize)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating metrics table: %s", err)
+	}
+	s.db = db
+
+	s.queue = make(chan row, s.queueSize)
+	s.done = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+// Shutdown inserts the queued metrics and closes the database
+func (s *Sink) Shutdown() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return s.db.Close()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(keyType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(sampleType, key, float64(val), labels)
+}
+
+// Queues the metric, dropping it if the queue is full or the sink is shut down
+func (s *Sink) push(kind string, key []string, val float64, labels []metrics.Label) {
+	r := row{
+		ts:    time.Now().UnixNano() / int64(time.Millisecond),
+		name:  strings.Join(key, "."),
+		kind:  kind,
+		value: val,
+	}
+	if len(labels) > 0 {
+		m := make(map[string]string, len(labels))
+		for _, label := range labels {
+			m[label.Name] = label.Value
+		}
+		encoded, err := json.Marshal(m)
+		if err != nil {
+			log.Printf("[ERR] Error encoding labels of metric %s for SQLite! Err: %s", r.name, err)
+			return
+		}
+		r.labels = string(encoded)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.queue <- r:
+	default:
+	}
+}
+
+// Inserts the queued metrics at every interval until the queue is closed
+func (s *Sink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var pending []row
+	for {
+		select {
+		case r, ok := <-s.queue:
+			if !ok {
+				s.insert(pending)
+				return
+			}
+			pending = append(pending, r)
+		case <-ticker.C:
+			s.insert(pending)
+			pending = pending[:0]
+		}
+	}
+}
+
+// Inserts the rows in a single transaction
+func (s *Sink) insert(rows []row) {
+	if len(rows) == 0 {
+		return
+	}
+	if err := s.insertTx(rows); err != nil {
+		log.Printf("[ERR] Error inserting %d metrics to SQLite! Err: %s", len(rows), err)
+	}
+}
+
+func (s *Sink) insertTx(rows []row) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(insertRow)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range rows {
+		var labels interface{}
+		if r.labels != "" {
+			labels = r.labels
+		}
+		if _, err := stmt.Exec(r.ts, r.name, r.kind, r.value, labels); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.db")
+	s, err := NewSink(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	s.SetGaugeWithLabels([]string{"foo", "bar"}, 1.5, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.AddSample([]string{"sample"}, 3)
+	s.EmitKey([]string{"key"}, 4)
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT ts, name, type, value, labels FROM metrics ORDER BY id")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer rows.Close()
+
+	type result struct {
+		name, kind string
+		value      float64
+		labels     sql.NullString
+	}
+	var results []result
+	for rows.Next() {
+		var r result
+		var ts int64
+		if err := rows.Scan(&ts, &r.name, &r.kind, &r.value, &r.labels); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if ts == 0 {
+			t.Fatalf("missing timestamp")
+		}
+		results = append(results, r)
+	}
+
+	expected := []result{
+		{"foo.bar", "gauge", 1.5, sql.NullString{String: `{"a":"b"}`, Valid: true}},
+		{"counter", "counter", 2, sql.NullString{}},
+		{"sample", "sample", 3, sql.NullString{}},
+		{"key", "key", 4, sql.NullString{}},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("bad rows: %v", results)
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatalf("bad row %d: %v", i, results[i])
+		}
+	}
+}
+
+func TestNewSink_Errors(t *testing.T) {
+	if _, err := NewSink(filepath.Join(t.TempDir(), "metrics.db"), WithFlushInterval(0)); err == nil {
+		t.Fatalf("expected flush interval error")
+	}
+	if _, err := NewSink(filepath.Join(t.TempDir(), "missing", "metrics.db")); err == nil {
+		t.Fatalf("expected database error")
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/hugoluchessi/go-metrics/providers/stackdriver
+
+go 1.21
+
+require (
+	github.com/hugoluchessi/go-metrics v0.0.0
+	golang.org/x/oauth2 v0.21.0
+)
+
+require (
+	cloud.google.com/go/compute/metadata v0.3.0 // indirect
+	golang.org/x/time v0.3.0 // indirect
+)
+
+replace github.com/hugoluchessi/go-metrics => ../..
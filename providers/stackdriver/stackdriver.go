@@ -0,0 +1,484 @@
+// Package stackdriver provides a sink pushing custom metrics to Google
+// Cloud Monitoring (Stackdriver) with its v3 API
+package stackdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// DefaultEndpoint is the Cloud Monitoring API endpoint
+	DefaultEndpoint = "https://monitoring.googleapis.com"
+
+	// MaxBatchSize is the maximum number of time series the API accepts
+	// in a single request
+	MaxBatchSize = 200
+
+	// customPrefix prefixes the types of the custom metrics
+	customPrefix = "custom.googleapis.com/"
+
+	// scope is the OAuth2 scope needed to write metrics
+	scope = "https://www.googleapis.com/auth/monitoring.write"
+)
+
+const (
+	gaugeKind      = "GAUGE"
+	cumulativeKind = "CUMULATIVE"
+)
+
+var (
+	// forbiddenChars are replaced in the metric type segments
+	forbiddenChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+	// forbiddenLabelChars are replaced in the label keys, which must be
+	// lower case
+	forbiddenLabelChars = regexp.MustCompile("[^a-z0-9_]")
+)
+
+// Sink provides a MetricSink that pushes custom metrics to Google Cloud
+// Monitoring, on the "global" monitored resource of the project. The
+// metric type is the key, joined with '/', under the namespace, ex:
+// "custom.googleapis.com/myapp/http/requests". Gauges and samples are sent
+// as GAUGE metrics, the samples pushed together being averaged, and
+// counters as CUMULATIVE metrics totalled since the sink was created.
+//
+// The descriptor of a metric type is created when it does not exist yet,
+// declaring the labels of the first metric seen. The types known to exist
+// are cached, so the descriptors are only looked up once.
+type Sink struct {
+	projectID string
+	namespace string
+	endpoint  string
+	start     time.Time
+
+	config push.Config
+	client *push.Client
+
+	// sign signs the descriptor requests, which are not pushed
+	sign push.RequestHook
+
+	// totals holds the running total of each counter series
+	totalsLock sync.Mutex
+	totals     map[string]float64
+
+	// types caches the metric types known to have a descriptor
+	typesLock sync.Mutex
+	types     map[string]struct{}
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithEndpoint overrides the Cloud Monitoring API endpoint
+func WithEndpoint(endpoint string) Option {
+	return func(s *Sink) {
+		s.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at, Cloud
+// Monitoring rejecting points of a series written more than once every
+// 5 seconds
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request,
+// capped to MaxBatchSize
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to call the API, it must
+// authenticate the requests itself, the credentials being ignored
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// NewSink is used to create a new Sink pushing the metrics of the project
+// under the namespace, authenticated with the credentials. The application
+// default credentials are used when they are nil, and their project when
+// the project ID is empty.
+func NewSink(projectID, namespace string, creds *google.Credentials, opts ...Option) (*Sink, error) {
+	s := &Sink{
+		projectID: projectID,
+		namespace: strings.Trim(namespace, "/"),
+		endpoint:  DefaultEndpoint,
+		start:     time.Now(),
+		totals:    make(map[string]float64),
+		types:     make(map[string]struct{}),
+		config: push.Config{
+			Name:        "stackdriver",
+			ContentType: "application/json",
+			BatchSize:   MaxBatchSize,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.config.BatchSize <= 0 || s.config.BatchSize > MaxBatchSize {
+		s.config.BatchSize = MaxBatchSize
+	}
+
+	if s.config.HTTPClient == nil {
+		if creds == nil {
+			var err error
+			creds, err = google.FindDefaultCredentials(context.Background(), scope)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find google credentials: %s", err)
+			}
+		}
+		s.config.HTTPClient = oauth2.NewClient(context.Background(), creds.TokenSource)
+		s.config.HTTPClient.Timeout = 10 * time.Second
+	}
+	if s.projectID == "" && creds != nil {
+		s.projectID = creds.ProjectID
+	}
+	if s.projectID == "" {
+		return nil, fmt.Errorf("project id must be provided")
+	}
+
+	if s.config.SignatureSecret != nil {
+		sign, err := push.HMACSignature(s.config.SignatureSecret, s.config.SignatureAlgorithm, s.config.SignatureEncoding)
+		if err != nil {
+			return nil, err
+		}
+		s.sign = sign
+	}
+
+	s.config.URL = s.projectURL() + "/timeSeries"
+	s.config.Encode = s.encode
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, gaugeKind, false, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(key, gaugeKind, false, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(key, cumulativeKind, false, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, gaugeKind, true, float64(val), labels)
+}
+
+// point is a value of a time series, as queued
+type point struct {
+	series     string
+	metricType string
+	kind       string
+	sample     bool
+	labels     map[string]string
+	value      float64
+	time       time.Time
+}
+
+func (s *Sink) push(key []string, kind string, sample bool, val float64, labels []metrics.Label) {
+	p := &point{
+		metricType: s.metricType(key),
+		kind:       kind,
+		sample:     sample,
+		labels:     make(map[string]string, len(labels)),
+		value:      val,
+		time:       time.Now(),
+	}
+	for _, label := range labels {
+		p.labels[labelKey(label.Name)] = label.Value
+	}
+	p.series = seriesKey(p.metricType, p.labels)
+
+	if kind == cumulativeKind {
+		s.totalsLock.Lock()
+		s.totals[p.series] += val
+		p.value = s.totals[p.series]
+		s.totalsLock.Unlock()
+	}
+	s.client.Push(p)
+}
+
+// metricType returns the custom metric type of a key
+func (s *Sink) metricType(key []string) string {
+	parts := make([]string, 0, len(key)+1)
+	if s.namespace != "" {
+		parts = append(parts, s.namespace)
+	}
+	for _, part := range key {
+		parts = append(parts, forbiddenChars.ReplaceAllString(part, "_"))
+	}
+	return customPrefix + strings.Join(parts, "/")
+}
+
+// labelKey sanitizes a label name, label keys must start with a lower
+// case letter
+func labelKey(name string) string {
+	name = forbiddenLabelChars.ReplaceAllString(strings.ToLower(name), "_")
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		name = "l" + name
+	}
+	return name
+}
+
+// seriesKey identifies the time series of a metric type and labels
+func seriesKey(metricType string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(metricType)
+	for _, k := range keys {
+		b.WriteByte(';')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
+
+func (s *Sink) projectURL() string {
+	return s.endpoint + "/v3/projects/" + s.projectID
+}
+
+type timeSeries struct {
+	Metric     metricLabels   `json:"metric"`
+	Resource   resourceLabels `json:"resource"`
+	MetricKind string         `json:"metricKind"`
+	ValueType  string         `json:"valueType"`
+	Points     []tsPoint      `json:"points"`
+}
+
+type metricLabels struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type resourceLabels struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+}
+
+type tsPoint struct {
+	Interval tsInterval `json:"interval"`
+	Value    tsValue    `json:"value"`
+}
+
+type tsInterval struct {
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime"`
+}
+
+type tsValue struct {
+	DoubleValue float64 `json:"doubleValue"`
+}
+
+// encode creates the time series of a batch, ensuring their descriptors
+// exist. A series only accepts a point per request, so the last point of
+// each series is kept, the samples being averaged.
+func (s *Sink) encode(records []interface{}) ([]byte, error) {
+	var order []string
+	last := make(map[string]*point)
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, record := range records {
+		p := record.(*point)
+		if _, ok := last[p.series]; !ok {
+			order = append(order, p.series)
+		}
+		last[p.series] = p
+		sums[p.series] += p.value
+		counts[p.series]++
+	}
+
+	resource := resourceLabels{Type: "global", Labels: map[string]string{"project_id": s.projectID}}
+	series := make([]timeSeries, 0, len(order))
+	for _, key := range order {
+		p := last[key]
+		if err := s.ensureDescriptor(p); err != nil {
+			log.Printf("[ERR] Error creating stackdriver metric descriptor %s! Err: %s", p.metricType, err)
+		}
+
+		value := p.value
+		if p.sample {
+			value = sums[key] / float64(counts[key])
+		}
+		interval := tsInterval{EndTime: p.time.UTC().Format(time.RFC3339Nano)}
+		if p.kind == cumulativeKind {
+			interval.StartTime = s.start.UTC().Format(time.RFC3339Nano)
+		}
+		series = append(series, timeSeries{
+			Metric:     metricLabels{Type: p.metricType, Labels: p.labels},
+			Resource:   resource,
+			MetricKind: p.kind,
+			ValueType:  "DOUBLE",
+			Points:     []tsPoint{{Interval: interval, Value: tsValue{DoubleValue: value}}},
+		})
+	}
+	return json.Marshal(map[string]interface{}{"timeSeries": series})
+}
+
+type descriptorLabel struct {
+	Key       string `json:"key"`
+	ValueType string `json:"valueType"`
+}
+
+type metricDescriptor struct {
+	Type       string            `json:"type"`
+	MetricKind string            `json:"metricKind"`
+	ValueType  string            `json:"valueType"`
+	Labels     []descriptorLabel `json:"labels,omitempty"`
+}
+
+// ensureDescriptor creates the descriptor of the metric type of the point
+// when it does not exist, caching the types known to exist
+func (s *Sink) ensureDescriptor(p *point) error {
+	s.typesLock.Lock()
+	_, ok := s.types[p.metricType]
+	s.typesLock.Unlock()
+	if ok {
+		return nil
+	}
+
+	url := s.projectURL() + "/metricDescriptors"
+	status, err := s.call(http.MethodGet, url+"/"+p.metricType, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		desc := metricDescriptor{Type: p.metricType, MetricKind: p.kind, ValueType: "DOUBLE"}
+		for k := range p.labels {
+			desc.Labels = append(desc.Labels, descriptorLabel{Key: k, ValueType: "STRING"})
+		}
+		sort.Slice(desc.Labels, func(i, j int) bool { return desc.Labels[i].Key < desc.Labels[j].Key })
+
+		body, err := json.Marshal(desc)
+		if err != nil {
+			return err
+		}
+		if status, err = s.call(http.MethodPost, url, body); err != nil {
+			return err
+		}
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("unexpected status: %d", status)
+	}
+
+	s.typesLock.Lock()
+	s.types[p.metricType] = struct{}{}
+	s.typesLock.Unlock()
+	return nil
+}
+
+// call executes a request on the API, returning the response status
+func (s *Sink) call(method, url string, body []byte) (int, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.sign != nil {
+		if err := s.sign(req, body); err != nil {
+			return 0, err
+		}
+	}
+	resp, err := s.config.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
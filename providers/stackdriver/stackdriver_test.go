@@ -0,0 +1,177 @@
+package stackdriver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+	"golang.org/x/oauth2/google"
+)
+
+func TestNewSink_ProjectID(t *testing.T) {
+	if _, err := NewSink("", "myapp", nil, WithHTTPClient(http.DefaultClient)); err == nil {
+		t.Fatalf("expected error for missing project id")
+	}
+
+	s, err := NewSink("", "myapp", &google.Credentials{ProjectID: "proj"}, WithHTTPClient(http.DefaultClient))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.Shutdown()
+	if s.config.URL != "https://monitoring.googleapis.com/v3/projects/proj/timeSeries" {
+		t.Fatalf("bad url %s", s.config.URL)
+	}
+}
+
+func TestMetricType(t *testing.T) {
+	s := &Sink{namespace: "myapp"}
+	if typ := s.metricType([]string{"http.server", "requests"}); typ != "custom.googleapis.com/myapp/http_server/requests" {
+		t.Fatalf("bad type %s", typ)
+	}
+	s.namespace = ""
+	if typ := s.metricType([]string{"a"}); typ != "custom.googleapis.com/a" {
+		t.Fatalf("bad type %s", typ)
+	}
+}
+
+func TestLabelKey(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Status-Code": "status_code",
+		"1st":         "l1st",
+		"":            "l",
+	} {
+		if key := labelKey(name); key != expected {
+			t.Fatalf("bad key for %q: %s", name, key)
+		}
+	}
+}
+
+type request struct {
+	method string
+	path   string
+	body   map[string]interface{}
+}
+
+func TestSink(t *testing.T) {
+	var lock sync.Mutex
+	var requests []request
+	known := map[string]bool{"/v3/projects/proj/metricDescriptors/custom.googleapis.com/myapp/gauge": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req := request{method: r.Method, path: r.URL.Path}
+		json.Unmarshal(body, &req.body)
+
+		lock.Lock()
+		requests = append(requests, req)
+		lock.Unlock()
+
+		if r.Method == http.MethodGet && !known[r.URL.Path] {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("proj", "myapp", nil, WithEndpoint(srv.URL), WithHTTPClient(srv.Client()), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.SetGaugeWithLabels([]string{"gauge"}, 2, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 1)
+	s.AddSample([]string{"sample"}, 3)
+	s.Flush()
+
+	lock.Lock()
+	defer lock.Unlock()
+	paths := []string{
+		"GET /v3/projects/proj/metricDescriptors/custom.googleapis.com/myapp/gauge",
+		"GET /v3/projects/proj/metricDescriptors/custom.googleapis.com/myapp/counter",
+		"POST /v3/projects/proj/metricDescriptors",
+		"GET /v3/projects/proj/metricDescriptors/custom.googleapis.com/myapp/sample",
+		"POST /v3/projects/proj/metricDescriptors",
+		"POST /v3/projects/proj/timeSeries",
+	}
+	if len(requests) != len(paths) {
+		t.Fatalf("bad requests %v", requests)
+	}
+	for i, path := range paths {
+		if requests[i].method+" "+requests[i].path != path {
+			t.Fatalf("bad request %d %s %s", i, requests[i].method, requests[i].path)
+		}
+	}
+	if kind := requests[2].body["metricKind"]; kind != cumulativeKind {
+		t.Fatalf("bad counter descriptor %v", requests[2].body)
+	}
+
+	series := requests[5].body["timeSeries"].([]interface{})
+	if len(series) != 3 {
+		t.Fatalf("bad series %v", series)
+	}
+	for i, expected := range []float64{2, 5, 2} {
+		ts := series[i].(map[string]interface{})
+		p := ts["points"].([]interface{})[0].(map[string]interface{})
+		if v := p["value"].(map[string]interface{})["doubleValue"]; v != expected {
+			t.Fatalf("bad value %d: %v", i, ts)
+		}
+		_, hasStart := p["interval"].(map[string]interface{})["startTime"]
+		if hasStart != (ts["metricKind"] == cumulativeKind) {
+			t.Fatalf("bad interval %d: %v", i, ts)
+		}
+	}
+	gauge := series[0].(map[string]interface{})
+	if labels := gauge["metric"].(map[string]interface{})["labels"]; labels.(map[string]interface{})["a"] != "b" {
+		t.Fatalf("bad labels %v", gauge)
+	}
+
+	// Known types are not looked up again
+	requests = nil
+	lock.Unlock()
+	s.SetGauge([]string{"gauge"}, 1)
+	s.Flush()
+	lock.Lock()
+	if len(requests) != 1 {
+		t.Fatalf("bad requests %v", requests)
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("proj", "myapp", nil, WithHTTPClient(http.DefaultClient), WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var lock sync.Mutex
+	var signatures []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		signatures = append(signatures, r.Header.Get("X-Signature"))
+		lock.Unlock()
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("proj", "myapp", nil, WithEndpoint(srv.URL), WithHTTPClient(srv.Client()), WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	lock.Lock()
+	defer lock.Unlock()
+	// The descriptor lookup and the time series are both signed
+	if len(signatures) != 2 || signatures[0] == "" || signatures[1] == "" {
+		t.Fatalf("bad signatures %v", signatures)
+	}
+}
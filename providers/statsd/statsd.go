@@ -2,10 +2,15 @@ package statsd
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hugoluchessi/go-metrics"
@@ -16,79 +21,392 @@ const (
 	// to send to statsd
 	statsdMaxLen = 1400
 
-	// We force flush the statsite metrics after this period of
-	// inactivity. Prevents stats from getting stuck in a buffer
-	// forever.
-	flushInterval = 100 * time.Millisecond
+	// udpHeaderLen is the size of the IP and UDP headers
+	// which are part of each packet
+	udpHeaderLen = 28
+
+	// DefaultFlushInterval is the period of inactivity after which the
+	// buffered metrics are force flushed. Prevents stats from getting
+	// stuck in a buffer forever.
+	DefaultFlushInterval = 100 * time.Millisecond
+
+	// DefaultReconnectBase is the delay before the first reconnect
+	DefaultReconnectBase = 500 * time.Millisecond
+
+	// DefaultReconnectMultiplier multiplies the reconnect delay after
+	// each consecutive failure
+	DefaultReconnectMultiplier = 2
+
+	// DefaultReconnectMax caps the reconnect delay
+	DefaultReconnectMax = 60 * time.Second
+
+	// DefaultQueueSize is the number of metrics queued before the
+	// queue is full
+	DefaultQueueSize = 4096
+
+	// DefaultWriteTimeout is how long pushing a metric blocks on a full
+	// queue when DropOnFull is not set
+	DefaultWriteTimeout = time.Second
+
+	// DefaultDialTimeout is how long connecting to the server may take
+	DefaultDialTimeout = 10 * time.Second
+
+	// DefaultConnWriteTimeout is how long writing to the connection may
+	// take, a write exceeding it being handled as a write error
+	DefaultConnWriteTimeout = 10 * time.Second
+
+	// reconnectJitter is the fraction of the reconnect delay randomly
+	// added or removed, so restarted services do not reconnect together
+	reconnectJitter = 0.1
 )
 
 // Sink provides a MetricSink that can be used
 // with a statsite or statsd metrics server. It uses
-// only UDP packets, while StatsiteSink uses TCP.
+// UDP packets, or a TCP stream when configured.
 type Sink struct {
-	addr        string
+	// dropped counts the metrics discarded as the queue was full, it is
+	// first to be 64-bit aligned for the atomic operations
+	dropped int64
+
+	addr         string
+	transport    string
+	tlsConfig    *tls.Config
+	tagFormat    TagFormat
+	sanitizer    KeySanitizer
+	sampleRate   float64
+	maxPacketLen int
+
+	// metricQueue is closed on Shutdown but never reassigned, so its
+	// depth can be read at any time. queueLock is held to push to it,
+	// and to close it, so nothing is pushed once queueClosed is set.
 	metricQueue chan string
+	queueLock   sync.RWMutex
+	queueClosed bool
+
+	// datagramPerMetric writes each metric on its own over UDP
+	datagramPerMetric bool
+
+	flushInterval    time.Duration
+	dialTimeout      time.Duration
+	connWriteTimeout time.Duration
+
+	// connected is 1 while the flushing holds a connection, probeCh
+	// ends its wait before reconnecting
+	connected int32
+	probeCh   chan struct{}
+
+	// quitCh is closed on Shutdown, before the queue, so a flushing
+	// waiting to reconnect makes a last attempt. doneCh is closed once
+	// the queue is drained, lost being the number of metrics discarded
+	// meanwhile as the server was unreachable.
+	quitCh       chan struct{}
+	doneCh       chan struct{}
+	lost         int
+	shutdownOnce sync.Once
+
+	// reportStopCh stops the self reports before the queue is closed
+	reportStopCh chan struct{}
+	reportWg     sync.WaitGroup
+
+	overflow     OverflowStrategy
+	blockTimeout time.Duration
+
+	reconnectBase       time.Duration
+	reconnectMultiplier float64
+	reconnectMax        time.Duration
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithMTU sizes the UDP packets to fit the given MTU, accumulating
+// metrics into a single datagram of up to mtu - 28 bytes (IP and UDP
+// headers) before flushing, avoiding fragmentation
+func WithMTU(mtu int) Option {
+	return func(s *Sink) {
+		s.maxPacketLen = mtu - udpHeaderLen
+	}
 }
 
-// NewSink is used to create a new Sink
-func NewSink(addr string) (*Sink, error) {
+// SinkConfig configures a Sink created with NewSinkWithConfig
+type SinkConfig struct {
+	// Transport is the protocol metrics are sent with, "udp" or "tcp".
+	// Defaults to "udp". Over TCP the connection is reopened after errors,
+	// UDP being connectionless write errors are only logged.
+	Transport string
+
+	// TLSConfig, when set, encrypts the TCP connection with TLS, the
+	// handshake being redone on each reconnection. Transport defaults to
+	// "tcp" with TLS, which does not apply to UDP.
+	TLSConfig *tls.Config
+
+	// ReconnectBase is the delay before reconnecting after an error,
+	// defaults to DefaultReconnectBase. The delay is multiplied by
+	// ReconnectMultiplier on each consecutive failure, up to ReconnectMax,
+	// and reset once metrics are written. A ±10% jitter is applied.
+	ReconnectBase time.Duration
+
+	// ReconnectMultiplier defaults to DefaultReconnectMultiplier,
+	// it must be at least 1
+	ReconnectMultiplier float64
+
+	// ReconnectMax defaults to DefaultReconnectMax
+	ReconnectMax time.Duration
+
+	// QueueSize is the number of metrics queued while they are sent,
+	// defaults to DefaultQueueSize
+	QueueSize int
+
+	// DropOnFull discards the metrics pushed while the queue is full.
+	// Otherwise pushing blocks until there is room in the queue or
+	// WriteTimeout elapses, the metric is then discarded and
+	// metrics.ErrQueueFull returned by the metrics.FallibleSinker
+	// methods, and logged by the others.
+	DropOnFull bool
+
+	// WriteTimeout defaults to DefaultWriteTimeout
+	WriteTimeout time.Duration
+
+	// DialTimeout bounds the connection to the server, defaults to
+	// DefaultDialTimeout, so an unreachable server does not block the
+	// flushing forever
+	DialTimeout time.Duration
+
+	// ConnWriteTimeout is the deadline of each write to the connection,
+	// defaults to DefaultConnWriteTimeout. A write exceeding it is
+	// handled as a write error, reopening the TCP connection.
+	ConnWriteTimeout time.Duration
+
+	// FlushInterval is how often the buffered metrics are sent, defaults
+	// to DefaultFlushInterval. A longer interval fills the packets better,
+	// a shorter one sends the metrics sooner.
+	FlushInterval time.Duration
+
+	// TagFormat is the wire format of the labels, defaults to TagFormatNone
+	TagFormat TagFormat
+
+	// Sanitizer rewrites the key parts, ex: StrictSanitizer. When nil only
+	// ':' and ' ' are replaced, which are always replaced as they break
+	// the protocol.
+	Sanitizer KeySanitizer
+
+	// SampleRate is the fraction of the samples sent, between 0 and 1,
+	// the server scaling them up. Defaults to 1, sending every sample.
+	SampleRate float64
+
+	// DatagramPerMetric writes each pushed metric in its own datagram
+	// instead of filling the packets up to the MTU, so a lost packet
+	// loses a single metric. It only applies to the udp transport.
+	DatagramPerMetric bool
+}
+
+// NewSink is used to create a new Sink. The address must be in
+// the host:port form, IPv6 hosts must be enclosed in brackets
+// (ex: "[::1]:8125"). Metrics pushed while the queue is full are dropped.
+func NewSink(addr string, opts ...Option) (*Sink, error) {
+	return NewSinkWithConfig(addr, SinkConfig{DropOnFull: true}, opts...)
+}
+
+// NewSinkWithConfig is used to create a new Sink with the given config,
+// see NewSink for the address format. Unless cfg.DropOnFull is set,
+// pushing blocks while the queue is full.
+func NewSinkWithConfig(addr string, cfg SinkConfig, opts ...Option) (*Sink, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid statsd address %q: %s", addr, err)
+	}
+
+	transport := cfg.Transport
+	if transport == "" {
+		transport = "udp"
+		if cfg.TLSConfig != nil {
+			transport = "tcp"
+		}
+	}
+	if transport != "udp" && transport != "tcp" {
+		return nil, fmt.Errorf("invalid statsd transport %q, must be udp or tcp", cfg.Transport)
+	}
+	if transport == "udp" && cfg.TLSConfig != nil {
+		return nil, fmt.Errorf("statsd TLS requires the tcp transport")
+	}
+
+	if cfg.TagFormat < TagFormatNone || cfg.TagFormat > TagFormatTelegraf {
+		return nil, fmt.Errorf("invalid statsd tag format %d", cfg.TagFormat)
+	}
+	sampleRate := cfg.SampleRate
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("sample rate must be between 0 and 1, got %v", cfg.SampleRate)
+	}
+
+	flushInterval := cfg.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	if flushInterval < 0 {
+		return nil, fmt.Errorf("flush interval must be positive, got %s", cfg.FlushInterval)
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize == 0 {
+		queueSize = DefaultQueueSize
+	}
+	if queueSize < 0 {
+		return nil, fmt.Errorf("queue size must be positive, got %d", cfg.QueueSize)
+	}
+
 	s := &Sink{
-		addr:        addr,
-		metricQueue: make(chan string, 4096),
+		addr:                addr,
+		transport:           transport,
+		tlsConfig:           cfg.TLSConfig,
+		tagFormat:           cfg.TagFormat,
+		sanitizer:           cfg.Sanitizer,
+		sampleRate:          sampleRate,
+		maxPacketLen:        statsdMaxLen,
+		datagramPerMetric:   cfg.DatagramPerMetric && transport == "udp",
+		metricQueue:         make(chan string, queueSize),
+		flushInterval:       flushInterval,
+		dialTimeout:         cfg.DialTimeout,
+		connWriteTimeout:    cfg.ConnWriteTimeout,
+		probeCh:             make(chan struct{}, 1),
+		quitCh:              make(chan struct{}),
+		doneCh:              make(chan struct{}),
+		reportStopCh:        make(chan struct{}),
+		reconnectBase:       cfg.ReconnectBase,
+		reconnectMultiplier: cfg.ReconnectMultiplier,
+		reconnectMax:        cfg.ReconnectMax,
+	}
+	if s.reconnectBase <= 0 {
+		s.reconnectBase = DefaultReconnectBase
+	}
+	if s.reconnectMultiplier == 0 {
+		s.reconnectMultiplier = DefaultReconnectMultiplier
+	}
+	if s.reconnectMax <= 0 {
+		s.reconnectMax = DefaultReconnectMax
+	}
+	if s.dialTimeout <= 0 {
+		s.dialTimeout = DefaultDialTimeout
+	}
+	if s.connWriteTimeout <= 0 {
+		s.connWriteTimeout = DefaultConnWriteTimeout
+	}
+	if s.reconnectMultiplier < 1 {
+		return nil, fmt.Errorf("reconnect multiplier must be at least 1, got %v", s.reconnectMultiplier)
+	}
+	if s.reconnectMax < s.reconnectBase {
+		return nil, fmt.Errorf("reconnect max %s is lower than the base %s", s.reconnectMax, s.reconnectBase)
+	}
+	if !cfg.DropOnFull {
+		s.overflow = Block
+		s.blockTimeout = cfg.WriteTimeout
+		if s.blockTimeout <= 0 {
+			s.blockTimeout = DefaultWriteTimeout
+		}
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	if s.maxPacketLen <= 0 {
+		return nil, fmt.Errorf("mtu must be greater than %d bytes", udpHeaderLen)
+	}
+
 	go s.flushMetrics()
 	return s, nil
 }
 
-// Shutdown is used to stop flushing to statsd
+// Shutdown is used to stop flushing to statsd, it blocks until the
+// queued metrics are sent
 func (s *Sink) Shutdown() {
-	close(s.metricQueue)
+	s.ShutdownContext(context.Background())
+}
+
+// ShutdownContext stops flushing to statsd once the queued metrics are
+// sent, returning the context error if it is done before. While the
+// server is unreachable a last connection is attempted, an error
+// reporting the number of discarded metrics being returned when it
+// fails. The metrics pushed after shutting down are dropped, counted in
+// DroppedTotal, the metrics.FallibleSinker methods returning
+// metrics.ErrSinkShutdown.
+func (s *Sink) ShutdownContext(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		if s.reportStopCh != nil {
+			close(s.reportStopCh)
+			s.reportWg.Wait()
+		}
+		if s.quitCh != nil {
+			close(s.quitCh)
+		}
+		s.queueLock.Lock()
+		s.queueClosed = true
+		close(s.metricQueue)
+		s.queueLock.Unlock()
+	})
+	select {
+	case <-s.doneCh:
+		if s.lost > 0 {
+			return fmt.Errorf("statsd unreachable, %d queued metrics discarded", s.lost)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // SetGauge sets a value on a gauge
 func (s *Sink) SetGauge(key []string, val float32) {
-	flatKey := s.flattenKey(key)
-	s.pushMetric(fmt.Sprintf("%s:%f|g\n", flatKey, val))
+	s.pushMetric(s.formatMetric(key, nil, float64(val), "g", 1))
 }
 
 // SetGaugeWithLabels sets a value on a gauge with labels
 func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
-	flatKey := s.flattenKeyLabels(key, labels)
-	s.pushMetric(fmt.Sprintf("%s:%f|g\n", flatKey, val))
+	s.pushMetric(s.formatMetric(key, labels, float64(val), "g", 1))
 }
 
 // EmitKey emits a key value metric
 func (s *Sink) EmitKey(key []string, val float32) {
-	flatKey := s.flattenKey(key)
-	s.pushMetric(fmt.Sprintf("%s:%f|kv\n", flatKey, val))
+	s.pushMetric(s.formatMetric(key, nil, float64(val), "kv", 1))
 }
 
 // IncrCounter increases the value of a counter by a given value
 func (s *Sink) IncrCounter(key []string, val float32) {
-	flatKey := s.flattenKey(key)
-	s.pushMetric(fmt.Sprintf("%s:%f|c\n", flatKey, val))
+	s.pushMetric(s.formatMetric(key, nil, float64(val), "c", 1))
 }
 
 // IncrCounterWithLabels increases the value of a counter by a given value with labels
 func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
-	flatKey := s.flattenKeyLabels(key, labels)
-	s.pushMetric(fmt.Sprintf("%s:%f|c\n", flatKey, val))
+	s.pushMetric(s.formatMetric(key, labels, float64(val), "c", 1))
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.pushMetric(s.formatMetric(key, nil, val, "c", 1))
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.pushMetric(s.formatMetric(key, labels, val, "c", 1))
 }
 
 // AddSample adds a sample metrics
 func (s *Sink) AddSample(key []string, val float32) {
-	flatKey := s.flattenKey(key)
-	s.pushMetric(fmt.Sprintf("%s:%f|ms\n", flatKey, val))
+	s.AddSampleWithLabels(key, val, nil)
 }
 
 // AddSampleWithLabels adds a sample metrics with labels
 func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
-	flatKey := s.flattenKeyLabels(key, labels)
-	s.pushMetric(fmt.Sprintf("%s:%f|ms\n", flatKey, val))
+	if s.sampled() {
+		s.pushMetric(s.formatMetric(key, labels, float64(val), "ms", s.sampleRate))
+	}
 }
 
 // Flattens the key for formatting, removes spaces
 func (s *Sink) flattenKey(parts []string) string {
+	if s.sanitizer != nil {
+		parts = s.sanitizer.Sanitize(parts)
+	}
 	joined := strings.Join(parts, ".")
 	return strings.Map(func(r rune) rune {
 		switch r {
@@ -102,90 +420,257 @@ func (s *Sink) flattenKey(parts []string) string {
 	}, joined)
 }
 
-// Flattens the key along with labels for formatting, removes spaces
-func (s *Sink) flattenKeyLabels(parts []string, labels []metrics.Label) string {
-	for _, label := range labels {
-		parts = append(parts, label.Value)
+// IncrCounterWithRate increases the value of a counter sampled at the given
+// rate, which is sent along. It implements metrics.RateAnnotator.
+func (s *Sink) IncrCounterWithRate(key []string, val float64, labels []metrics.Label, rate float64) {
+	s.pushMetric(s.formatMetric(key, labels, val, "c", rate))
+}
+
+// AddSampleWithRate adds a sample sampled at the given rate, which is sent
+// along, combined with the sample rate of the sink. It implements
+// metrics.RateAnnotator.
+func (s *Sink) AddSampleWithRate(key []string, val float32, labels []metrics.Label, rate float64) {
+	if s.sampled() {
+		if s.sampleRate > 0 {
+			rate *= s.sampleRate
+		}
+		s.pushMetric(s.formatMetric(key, labels, float64(val), "ms", rate))
 	}
-	return s.flattenKey(parts)
 }
 
-// Does a non-blocking push to the metrics queue
-func (s *Sink) pushMetric(m string) {
-	select {
-	case s.metricQueue <- m:
-	default:
+// Returns whether a sample must be sent, following the sample rate
+func (s *Sink) sampled() bool {
+	return s.sampleRate <= 0 || s.sampleRate >= 1 || rand.Float64() < s.sampleRate
+}
+
+// TrySetGaugeWithLabels sets a value on a gauge with labels,
+// returning metrics.ErrQueueFull if the metric was dropped
+func (s *Sink) TrySetGaugeWithLabels(key []string, val float32, labels []metrics.Label) error {
+	return s.tryPushMetric(s.formatMetric(key, labels, float64(val), "g", 1))
+}
+
+// TryEmitKey emits a key value metric,
+// returning metrics.ErrQueueFull if the metric was dropped
+func (s *Sink) TryEmitKey(key []string, val float32) error {
+	return s.tryPushMetric(s.formatMetric(key, nil, float64(val), "kv", 1))
+}
+
+// TryIncrCounterWithLabels increases the value of a counter by a given value with labels,
+// returning metrics.ErrQueueFull if the metric was dropped
+func (s *Sink) TryIncrCounterWithLabels(key []string, val float32, labels []metrics.Label) error {
+	return s.tryPushMetric(s.formatMetric(key, labels, float64(val), "c", 1))
+}
+
+// TryAddSampleWithLabels adds a sample metrics with labels,
+// returning metrics.ErrQueueFull if the metric was dropped
+func (s *Sink) TryAddSampleWithLabels(key []string, val float32, labels []metrics.Label) error {
+	if !s.sampled() {
+		return nil
 	}
+	return s.tryPushMetric(s.formatMetric(key, labels, float64(val), "ms", s.sampleRate))
 }
 
 // Flushes metrics
 func (s *Sink) flushMetrics() {
+	defer close(s.doneCh)
+
 	var sock net.Conn
 	var err error
 	var wait <-chan time.Time
-	ticker := time.NewTicker(flushInterval)
+	var pending string
+	var final bool
+	delay := s.reconnectBase
+	ticker := time.NewTicker(s.flushInterval)
 	defer ticker.Stop()
 
 CONNECT:
-	// Create a buffer
+	// Create a buffer, holding the metric dequeued while shutting down
 	buf := bytes.NewBuffer(nil)
+	buf.WriteString(pending)
+	pending = ""
 
 	// Attempt to connect
-	sock, err = net.Dial("udp", s.addr)
+	sock, err = s.dial()
 	if err != nil {
 		log.Printf("[ERR] Error connecting to statsd! Err: %s", err)
+		if final {
+			s.lost += countMetrics(buf)
+			goto DISCARD
+		}
 		goto WAIT
 	}
+	atomic.StoreInt32(&s.connected, 1)
 
 	for {
 		select {
 		case metric, ok := <-s.metricQueue:
-			// Get a metric from the queue
+			// Get a metric from the queue, sending the buffer
+			// once the queue is closed and drained
 			if !ok {
+				if buf.Len() > 0 {
+					if _, err := s.write(sock, buf.Bytes()); err != nil {
+						log.Printf("[ERR] Error flushing to statsd! Err: %s", err)
+						if final {
+							s.lost += countMetrics(buf)
+						}
+					}
+				}
 				goto QUIT
 			}
 
-			// Check if this would overflow the packet size
-			if len(metric)+buf.Len() > statsdMaxLen {
-				_, err := sock.Write(buf.Bytes())
+			// Check if this would overflow the packet size, a
+			// metric is never split between two writes
+			if buf.Len() > 0 && len(metric)+buf.Len() > s.maxPacketLen {
+				_, err := s.write(sock, buf.Bytes())
+				if err != nil && final {
+					s.lost += countMetrics(buf)
+				}
 				buf.Reset()
 				if err != nil {
 					log.Printf("[ERR] Error writing to statsd! Err: %s", err)
-					goto WAIT
+					if s.mustReconnect(sock) {
+						if final {
+							goto DISCARD
+						}
+						goto WAIT
+					}
+				} else {
+					delay = s.reconnectBase
 				}
 			}
 
-			// Append to the buffer
+			// Append to the buffer, or write the metric on its own
 			buf.WriteString(metric)
+			if s.datagramPerMetric {
+				if _, err := s.write(sock, buf.Bytes()); err != nil {
+					log.Printf("[ERR] Error writing to statsd! Err: %s", err)
+					if final {
+						s.lost++
+					}
+				} else {
+					delay = s.reconnectBase
+				}
+				buf.Reset()
+			}
 
 		case <-ticker.C:
 			if buf.Len() == 0 {
 				continue
 			}
 
-			_, err := sock.Write(buf.Bytes())
+			_, err := s.write(sock, buf.Bytes())
+			if err != nil && final {
+				s.lost += countMetrics(buf)
+			}
 			buf.Reset()
 			if err != nil {
 				log.Printf("[ERR] Error flushing to statsd! Err: %s", err)
-				goto WAIT
+				if s.mustReconnect(sock) {
+					if final {
+						goto DISCARD
+					}
+					goto WAIT
+				}
+			} else {
+				delay = s.reconnectBase
 			}
 		}
 	}
 
 WAIT:
-	// Wait for a while
-	wait = time.After(time.Duration(5) * time.Second)
+	// Wait for a while, longer after each consecutive failure,
+	// unless a health check probe succeeds meanwhile
+	atomic.StoreInt32(&s.connected, 0)
+	select {
+	case <-s.probeCh:
+	default:
+	}
+	wait = time.After(jitter(delay))
+	delay = s.nextDelay(delay)
 	for {
 		select {
-		// Dequeue the messages to avoid backlog
-		case _, ok := <-s.metricQueue:
+		// Dequeue the messages to avoid backlog, until shutting down
+		case metric, ok := <-s.metricQueue:
 			if !ok {
 				goto QUIT
 			}
+			select {
+			case <-s.quitCh:
+				pending, final = metric, true
+				goto CONNECT
+			default:
+			}
+		case <-s.quitCh:
+			final = true
+			goto CONNECT
 		case <-wait:
 			goto CONNECT
+		case <-s.probeCh:
+			delay = s.reconnectBase
+			goto CONNECT
 		}
 	}
+DISCARD:
+	// The last attempt failed while shutting down, the queue is
+	// drained and its metrics counted as lost
+	for range s.metricQueue {
+		s.lost++
+	}
+	log.Printf("[ERR] Discarded %d statsd metrics on shutdown, the server being unreachable", s.lost)
 QUIT:
-	s.metricQueue = nil
+	if sock != nil {
+		sock.Close()
+	}
+}
+
+// Returns the number of metrics in the buffer, each ending with a newline
+func countMetrics(buf *bytes.Buffer) int {
+	return bytes.Count(buf.Bytes(), []byte{'\n'})
+}
+
+// Opens the connection, doing the TLS handshake when configured,
+// within the dial timeout
+func (s *Sink) dial() (net.Conn, error) {
+	if s.tlsConfig != nil {
+		// Avoid returning a nil *tls.Conn as a non nil net.Conn
+		dialer := &net.Dialer{Timeout: s.dialTimeout}
+		conn, err := tls.DialWithDialer(dialer, s.transport, s.addr, s.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+	return net.DialTimeout(s.transport, s.addr, s.dialTimeout)
+}
+
+// Writes to the connection within the write timeout
+func (s *Sink) write(sock net.Conn, b []byte) (int, error) {
+	if err := sock.SetWriteDeadline(time.Now().Add(s.connWriteTimeout)); err != nil {
+		return 0, err
+	}
+	return sock.Write(b)
+}
+
+// Closes the connection after a write error and returns true when it
+// must be reopened. UDP sockets are kept, as they are not connected.
+func (s *Sink) mustReconnect(sock net.Conn) bool {
+	if s.transport == "udp" {
+		return false
+	}
+	sock.Close()
+	return true
+}
+
+// Returns the reconnect delay following the given one
+func (s *Sink) nextDelay(delay time.Duration) time.Duration {
+	next := time.Duration(float64(delay) * s.reconnectMultiplier)
+	if next > s.reconnectMax || next < delay {
+		return s.reconnectMax
+	}
+	return next
+}
+
+// Randomly adds or removes up to reconnectJitter of the delay
+func jitter(delay time.Duration) time.Duration {
+	return delay + time.Duration((rand.Float64()*2-1)*reconnectJitter*float64(delay))
 }
@@ -0,0 +1,33 @@
+package statsd
+
+import (
+	"context"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// The following methods implement metrics.ContextSinker: with the Block
+// overflow strategy, they give up waiting for room in the queue once the
+// context is done, ex: when its deadline is reached
+
+// SetGaugeWithContext sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithContext(ctx context.Context, key []string, val float32, labels []metrics.Label) {
+	s.pushMetricContext(ctx, s.formatMetric(key, labels, float64(val), "g", 1))
+}
+
+// EmitKeyWithContext emits a key value metric
+func (s *Sink) EmitKeyWithContext(ctx context.Context, key []string, val float32) {
+	s.pushMetricContext(ctx, s.formatMetric(key, nil, float64(val), "kv", 1))
+}
+
+// IncrCounterWithContext increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithContext(ctx context.Context, key []string, val float64, labels []metrics.Label) {
+	s.pushMetricContext(ctx, s.formatMetric(key, labels, val, "c", 1))
+}
+
+// AddSampleWithContext adds a sample metrics with labels
+func (s *Sink) AddSampleWithContext(ctx context.Context, key []string, val float32, labels []metrics.Label) {
+	if s.sampled() {
+		s.pushMetricContext(ctx, s.formatMetric(key, labels, float64(val), "ms", s.sampleRate))
+	}
+}
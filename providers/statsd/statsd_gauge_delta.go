@@ -0,0 +1,30 @@
+package statsd
+
+import (
+	"fmt"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// The following methods implement metrics.GaugeDeltaSink with the
+// "+n" and "-n" gauge syntax, the server changing the gauge by n
+
+// IncrGauge increases the value of a gauge by a given value
+func (s *Sink) IncrGauge(key []string, val float32) {
+	s.IncrGaugeWithLabels(key, val, nil)
+}
+
+// IncrGaugeWithLabels increases the value of a gauge by a given value with labels
+func (s *Sink) IncrGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.pushMetric(s.formatLine(key, labels, fmt.Sprintf("%+f", val), "g", 1))
+}
+
+// DecrGauge decreases the value of a gauge by a given value
+func (s *Sink) DecrGauge(key []string, val float32) {
+	s.DecrGaugeWithLabels(key, val, nil)
+}
+
+// DecrGaugeWithLabels decreases the value of a gauge by a given value with labels
+func (s *Sink) DecrGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.pushMetric(s.formatLine(key, labels, fmt.Sprintf("%+f", -val), "g", 1))
+}
@@ -0,0 +1,134 @@
+package statsd
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// OverflowStrategy defines what happens to metrics pushed
+// when the metrics queue is full
+type OverflowStrategy int
+
+const (
+	// DropNewest discards the metric being pushed
+	DropNewest OverflowStrategy = iota
+
+	// DropOldest discards the oldest queued metric, making room
+	// for the metric being pushed
+	DropOldest
+
+	// Block blocks the caller until there is room in the queue or
+	// the block timeout is reached, discarding the metric on timeout.
+	// Discarded metrics are reported like with the Error strategy.
+	Block
+
+	// Error discards the metric being pushed, like DropNewest, but
+	// reports it: the metrics.FallibleSinker methods return
+	// metrics.ErrQueueFull and the other methods log the dropped metric
+	Error
+)
+
+// WithOverflowStrategy sets the strategy used when the queue is full,
+// defaults to DropNewest
+func WithOverflowStrategy(strategy OverflowStrategy) Option {
+	return func(s *Sink) {
+		s.overflow = strategy
+	}
+}
+
+// WithBlockTimeout sets how long the Block strategy waits for room in
+// the queue. A zero timeout blocks until there is room.
+func WithBlockTimeout(timeout time.Duration) Option {
+	return func(s *Sink) {
+		s.blockTimeout = timeout
+	}
+}
+
+// Pushes to the metrics queue following the overflow strategy,
+// returning an error if the metric was discarded
+func (s *Sink) tryPushMetric(m string) error {
+	return s.tryPushMetricContext(context.Background(), m)
+}
+
+// Pushes to the metrics queue like tryPushMetric, the Block strategy
+// also giving up when the context is done. The discarded metrics are
+// counted in DroppedTotal.
+func (s *Sink) tryPushMetricContext(ctx context.Context, m string) error {
+	err := s.queueMetric(ctx, m)
+	if err != nil {
+		atomic.AddInt64(&s.dropped, 1)
+	}
+	return err
+}
+
+// Pushes to the metrics queue following the overflow strategy
+func (s *Sink) queueMetric(ctx context.Context, m string) error {
+	s.queueLock.RLock()
+	defer s.queueLock.RUnlock()
+	if s.queueClosed {
+		return metrics.ErrSinkShutdown
+	}
+
+	select {
+	case s.metricQueue <- m:
+		return nil
+	default:
+	}
+
+	switch s.overflow {
+	case DropOldest:
+		for {
+			select {
+			case s.metricQueue <- m:
+				return nil
+			default:
+			}
+
+			// Make room, unless the queue was drained meanwhile
+			select {
+			case <-s.metricQueue:
+				atomic.AddInt64(&s.dropped, 1)
+			default:
+			}
+		}
+
+	case Block:
+		var timeout <-chan time.Time
+		if s.blockTimeout > 0 {
+			timer := time.NewTimer(s.blockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case s.metricQueue <- m:
+			return nil
+		case <-timeout:
+			return metrics.ErrQueueFull
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+	default:
+		return metrics.ErrQueueFull
+	}
+}
+
+// Pushes to the metrics queue following the overflow strategy
+func (s *Sink) pushMetric(m string) {
+	s.pushMetricContext(context.Background(), m)
+}
+
+// Pushes to the metrics queue following the overflow strategy, the
+// Block strategy giving up when the context is done. The metrics pushed
+// after shutting down are only counted.
+func (s *Sink) pushMetricContext(ctx context.Context, m string) {
+	err := s.tryPushMetricContext(ctx, m)
+	if err != nil && err != metrics.ErrSinkShutdown && (s.overflow == Error || s.overflow == Block) {
+		log.Printf("[ERR] Error queueing metric to statsd! Err: %s", err)
+	}
+}
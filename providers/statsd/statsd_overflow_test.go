@@ -0,0 +1,201 @@
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestStatsd_OverflowDropOldest(t *testing.T) {
+	q := make(chan string, 2)
+	s := &Sink{metricQueue: q, overflow: DropOldest}
+
+	s.pushMetric("first")
+	s.pushMetric("second")
+	if err := s.tryPushMetric("third"); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+
+	if out := <-q; out != "second" {
+		t.Fatalf("bad val %v", out)
+	}
+	if out := <-q; out != "third" {
+		t.Fatalf("bad val %v", out)
+	}
+}
+
+func TestStatsd_OverflowBlock(t *testing.T) {
+	q := make(chan string, 1)
+	q <- "full"
+	s := &Sink{metricQueue: q, overflow: Block}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-q
+	}()
+
+	if err := s.tryPushMetric("blocked"); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if out := <-q; out != "blocked" {
+		t.Fatalf("bad val %v", out)
+	}
+}
+
+func TestStatsd_OverflowBlockTimeout(t *testing.T) {
+	q := make(chan string, 1)
+	q <- "full"
+	s := &Sink{metricQueue: q, overflow: Block, blockTimeout: 10 * time.Millisecond}
+
+	start := time.Now()
+	if err := s.tryPushMetric("blocked"); err != metrics.ErrQueueFull {
+		t.Fatalf("expected full queue, got %v", err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatalf("did not block")
+	}
+	if out := <-q; out != "full" {
+		t.Fatalf("bad val %v", out)
+	}
+}
+
+func TestStatsd_OverflowBlockContext(t *testing.T) {
+	q := make(chan string, 1)
+	q <- "full"
+	s := &Sink{metricQueue: q, overflow: Block, blockTimeout: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.tryPushMetricContext(ctx, "blocked"); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// The context methods give up too
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	s.IncrCounterWithContext(ctx, []string{"a"}, 1, nil)
+	if !strings.Contains(buf.String(), "deadline exceeded") {
+		t.Fatalf("expected dropped metric to be logged, got %s", buf.String())
+	}
+
+	<-q
+	s.SetGaugeWithContext(ctx, []string{"g"}, 2, nil)
+	if out := <-q; out != "g:2.000000|g\n" {
+		t.Fatalf("bad val %q", out)
+	}
+}
+
+func TestStatsd_OverflowError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	q := make(chan string, 1)
+	q <- "full"
+	s := &Sink{metricQueue: q, overflow: Error}
+
+	if err := s.TryIncrCounterWithLabels([]string{"a"}, 1, nil); err != metrics.ErrQueueFull {
+		t.Fatalf("expected full queue, got %v", err)
+	}
+
+	s.IncrCounter([]string{"a"}, 1)
+	if !strings.Contains(buf.String(), "queue is full") {
+		t.Fatalf("expected dropped metric to be logged, got %s", buf.String())
+	}
+
+	// DropNewest does not log
+	buf.Reset()
+	s.overflow = DropNewest
+	s.IncrCounter([]string{"a"}, 1)
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected log %s", buf.String())
+	}
+}
+
+func TestStatsd_PushAfterShutdown(t *testing.T) {
+	for _, strategy := range []OverflowStrategy{DropNewest, DropOldest, Block, Error} {
+		s, err := NewSink("127.0.0.1:7527", WithOverflowStrategy(strategy))
+		if err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		s.Shutdown()
+
+		s.IncrCounter([]string{"a"}, 1)
+		if err := s.TryIncrCounterWithLabels([]string{"a"}, 1, nil); err != metrics.ErrSinkShutdown {
+			t.Fatalf("expected shut down sink for %v, got %v", strategy, err)
+		}
+		if s.DroppedTotal() != 2 {
+			t.Fatalf("bad dropped total for %v: %d", strategy, s.DroppedTotal())
+		}
+	}
+}
+
+func TestStatsd_WithOverflowStrategy(t *testing.T) {
+	s, err := NewSink("127.0.0.1:7527", WithOverflowStrategy(Block), WithBlockTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	if s.overflow != Block || s.blockTimeout != time.Second {
+		t.Fatalf("options not applied")
+	}
+}
+
+func TestStatsd_QueueConfig(t *testing.T) {
+	s, err := NewSink("127.0.0.1:7527")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+	if s.overflow != DropNewest || cap(s.metricQueue) != DefaultQueueSize {
+		t.Fatalf("bad defaults %v %d", s.overflow, cap(s.metricQueue))
+	}
+
+	s, err = NewSinkWithConfig("127.0.0.1:7527", SinkConfig{QueueSize: 10})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+	if s.overflow != Block || s.blockTimeout != DefaultWriteTimeout || cap(s.metricQueue) != 10 {
+		t.Fatalf("bad config %v %s %d", s.overflow, s.blockTimeout, cap(s.metricQueue))
+	}
+
+	s, err = NewSinkWithConfig("127.0.0.1:7527", SinkConfig{DropOnFull: true, WriteTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+	if s.overflow != DropNewest {
+		t.Fatalf("bad overflow %v", s.overflow)
+	}
+
+	if _, err := NewSinkWithConfig("127.0.0.1:7527", SinkConfig{QueueSize: -1}); err == nil {
+		t.Fatalf("expected queue size error")
+	}
+}
+
+func TestStatsd_WriteTimeout(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	q := make(chan string, 1)
+	q <- "full"
+	s := &Sink{metricQueue: q, overflow: Block, blockTimeout: 10 * time.Millisecond}
+
+	if err := s.TrySetGaugeWithLabels([]string{"a"}, 1, nil); err != metrics.ErrQueueFull {
+		t.Fatalf("expected full queue, got %v", err)
+	}
+	s.SetGauge([]string{"a"}, 1)
+	if !strings.Contains(buf.String(), "queue is full") {
+		t.Fatalf("expected dropped metric to be logged, got %s", buf.String())
+	}
+}
@@ -0,0 +1,52 @@
+package statsd
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ProbePath is the path the ProbeHandler is usually served at
+const ProbePath = "/debug/metrics/probe"
+
+// ProbeHealthCheck checks whether the server is reachable while the sink
+// waits to reconnect after an error, by opening a test connection. On
+// success the sink reconnects at once, instead of waiting for the end of
+// the reconnect delay. It returns nil when the sink is connected.
+func (s *Sink) ProbeHealthCheck() error {
+	if atomic.LoadInt32(&s.connected) == 1 {
+		return nil
+	}
+
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	select {
+	case s.probeCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// ProbeHandler returns a handler running ProbeHealthCheck, so operators
+// can force a reconnect attempt, ex:
+//
+//	http.Handle(statsd.ProbePath, sink.ProbeHandler())
+//
+// It responds 503 Service Unavailable with the error when the probe fails.
+func (s *Sink) ProbeHandler() http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost && req.Method != http.MethodGet {
+			resp.Header().Set("Allow", "GET, POST")
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := s.ProbeHealthCheck(); err != nil {
+			http.Error(resp, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		resp.Write([]byte("ok\n"))
+	})
+}
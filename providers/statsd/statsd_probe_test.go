@@ -0,0 +1,81 @@
+package statsd
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatsd_ProbeHealthCheck(t *testing.T) {
+	// Reserve a port nothing listens on yet
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	addr := list.Addr().String()
+	list.Close()
+
+	s, err := NewSinkWithConfig(addr, SinkConfig{
+		Transport:     "tcp",
+		ReconnectBase: time.Hour,
+		ReconnectMax:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	rec := httptest.NewRecorder()
+	s.ProbeHandler().ServeHTTP(rec, httptest.NewRequest("POST", ProbePath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("bad status %d", rec.Code)
+	}
+
+	list, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	rec = httptest.NewRecorder()
+	s.ProbeHandler().ServeHTTP(rec, httptest.NewRequest("POST", ProbePath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("bad status %d: %s", rec.Code, rec.Body)
+	}
+
+	// The first connection is the probe, the sink reconnects without
+	// waiting for the reconnect delay
+	probe, err := list.Accept()
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	probe.Close()
+	conn, err := list.Accept()
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 100 && atomic.LoadInt32(&s.connected) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.IncrCounter([]string{"counter"}, 1)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if line != "counter:1.000000|c\n" {
+		t.Fatalf("bad line %q", line)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ProbeHandler().ServeHTTP(rec, httptest.NewRequest("DELETE", ProbePath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("bad status %d", rec.Code)
+	}
+}
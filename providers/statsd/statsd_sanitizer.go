@@ -0,0 +1,53 @@
+package statsd
+
+import (
+	"regexp"
+	"strings"
+)
+
+// KeySanitizer rewrites the key parts of the metrics before they are
+// joined, to remove the characters the server does not accept
+type KeySanitizer interface {
+	Sanitize(parts []string) []string
+}
+
+// StrictSanitizer replaces every character outside [a-zA-Z0-9_.-],
+// including non-ASCII runes, with '_'
+type StrictSanitizer struct{}
+
+// Sanitize returns the sanitized key parts
+func (StrictSanitizer) Sanitize(parts []string) []string {
+	sanitized := make([]string, len(parts))
+	for i, part := range parts {
+		sanitized[i] = strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+				r == '_', r == '.', r == '-':
+				return r
+			default:
+				return '_'
+			}
+		}, part)
+	}
+	return sanitized
+}
+
+// RegexpSanitizer replaces the matches of a regular expression with '_'
+type RegexpSanitizer struct {
+	re *regexp.Regexp
+}
+
+// NewRegexpSanitizer creates a RegexpSanitizer replacing the matches of re,
+// ex: regexp.MustCompile(`[^a-z0-9]`)
+func NewRegexpSanitizer(re *regexp.Regexp) *RegexpSanitizer {
+	return &RegexpSanitizer{re: re}
+}
+
+// Sanitize returns the sanitized key parts
+func (s *RegexpSanitizer) Sanitize(parts []string) []string {
+	sanitized := make([]string, len(parts))
+	for i, part := range parts {
+		sanitized[i] = s.re.ReplaceAllString(part, "_")
+	}
+	return sanitized
+}
@@ -0,0 +1,37 @@
+package statsd
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestStatsd_StrictSanitizer(t *testing.T) {
+	s := &Sink{sanitizer: StrictSanitizer{}}
+	labels := []metrics.Label{{Name: "path", Value: "/users/{id}"}}
+
+	if key := s.flattenKeyLabels([]string{"http", "réq=1", "a-b_c"}, labels); key != "http.r_q_1.a-b_c._users__id_" {
+		t.Fatalf("bad key %s", key)
+	}
+}
+
+func TestStatsd_RegexpSanitizer(t *testing.T) {
+	s := &Sink{sanitizer: NewRegexpSanitizer(regexp.MustCompile(`[^a-z.]+`))}
+	if key := s.flattenKey([]string{"api", "v2/Users"}); key != "api.v_sers" {
+		t.Fatalf("bad key %s", key)
+	}
+
+	// Characters breaking the protocol are replaced whatever the sanitizer
+	s = &Sink{sanitizer: NewRegexpSanitizer(regexp.MustCompile(`x`))}
+	if key := s.flattenKey([]string{"a:b c"}); key != "a_b_c" {
+		t.Fatalf("bad key %s", key)
+	}
+}
+
+func TestStatsd_DefaultSanitizer(t *testing.T) {
+	s := &Sink{}
+	if key := s.flattenKey([]string{"a/b", "c:d e"}); key != "a/b.c_d_e" {
+		t.Fatalf("bad key %s", key)
+	}
+}
@@ -0,0 +1,46 @@
+package statsd
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// QueueDepth returns the number of metrics waiting to be sent
+func (s *Sink) QueueDepth() int {
+	return len(s.metricQueue)
+}
+
+// DroppedTotal returns the number of metrics discarded as the queue was full
+func (s *Sink) DroppedTotal() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// SelfReport sets the "statsite.queue_depth" and "statsite.dropped_total"
+// gauges on the given sink at each interval, which may be the sink itself,
+// so the health of the metrics pipeline shows up in dashboards. Reporting
+// stops when the sink is shut down.
+func (s *Sink) SelfReport(interval time.Duration, sink metrics.Sinker) error {
+	if interval <= 0 {
+		return fmt.Errorf("self report interval must be positive, got %s", interval)
+	}
+
+	s.reportWg.Add(1)
+	go func() {
+		defer s.reportWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sink.SetGauge([]string{"statsite", "queue_depth"}, float32(s.QueueDepth()))
+				sink.SetGauge([]string{"statsite", "dropped_total"}, float32(s.DroppedTotal()))
+			case <-s.reportStopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
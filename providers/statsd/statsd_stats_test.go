@@ -0,0 +1,86 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics/providers/inmem"
+)
+
+func TestStatsd_QueueStats(t *testing.T) {
+	q := make(chan string, 2)
+	s := &Sink{metricQueue: q}
+
+	s.pushMetric("first")
+	s.pushMetric("second")
+	s.pushMetric("third")
+	if depth := s.QueueDepth(); depth != 2 {
+		t.Fatalf("bad depth %d", depth)
+	}
+	if dropped := s.DroppedTotal(); dropped != 1 {
+		t.Fatalf("bad dropped %d", dropped)
+	}
+
+	// Making room counts the discarded metric
+	s.overflow = DropOldest
+	s.pushMetric("fourth")
+	if dropped := s.DroppedTotal(); dropped != 2 {
+		t.Fatalf("bad dropped %d", dropped)
+	}
+
+	<-q
+	if depth := s.QueueDepth(); depth != 1 {
+		t.Fatalf("bad depth %d", depth)
+	}
+}
+
+func TestStatsd_SelfReport(t *testing.T) {
+	s, err := NewSinkWithConfig("127.0.0.1:7530", SinkConfig{QueueSize: 1})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for i := 0; i < 100; i++ {
+		s.IncrCounter([]string{"a"}, 1)
+	}
+	dropped := s.DroppedTotal()
+
+	inm := inmem.NewSink(time.Minute, time.Hour)
+	if err := s.SelfReport(0, inm); err == nil {
+		t.Fatalf("expected interval error")
+	}
+	if err := s.SelfReport(5*time.Millisecond, inm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	s.Shutdown()
+
+	gauges := inm.Snapshot().Gauges
+	if _, ok := gauges["statsite.queue_depth"]; !ok {
+		t.Fatalf("missing queue depth %v", gauges)
+	}
+	if g, ok := gauges["statsite.dropped_total"]; !ok || g.Value < float32(dropped) {
+		t.Fatalf("bad dropped total %v, expected at least %d", g, dropped)
+	}
+}
+
+func TestStatsd_QueueDepthAfterShutdown(t *testing.T) {
+	s, err := NewSink("127.0.0.1:7530")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s.IncrCounter([]string{"a"}, 1)
+
+	// The depth may be read while the sink shuts down, and after
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.QueueDepth()
+		}
+	}()
+	s.Shutdown()
+	<-done
+	if depth := s.QueueDepth(); depth != 0 {
+		t.Fatalf("bad depth %d", depth)
+	}
+}
@@ -0,0 +1,100 @@
+package statsd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// TagFormat defines how the labels of the metrics are sent
+type TagFormat int
+
+const (
+	// TagFormatNone is the plain statsd format, which has no tags:
+	// the label values are folded into the metric key,
+	// ex: "requests.GET.200:1.000000|c"
+	TagFormatNone TagFormat = iota
+
+	// TagFormatDogStatsD appends the labels as DogStatsD tags,
+	// ex: "requests:1.000000|c|#method:GET,code:200"
+	TagFormatDogStatsD
+
+	// TagFormatTelegraf appends the labels to the key as Telegraf
+	// influx tags, ex: "requests,method=GET,code=200:1.000000|c"
+	TagFormatTelegraf
+)
+
+// Flattens the key along with labels for formatting, removes spaces.
+// Labels are only part of the key with TagFormatNone and TagFormatTelegraf.
+func (s *Sink) flattenKeyLabels(parts []string, labels []metrics.Label) string {
+	switch s.tagFormat {
+	case TagFormatDogStatsD:
+		return s.flattenKey(parts)
+
+	case TagFormatTelegraf:
+		b := &strings.Builder{}
+		b.WriteString(s.flattenKey(parts))
+		for _, label := range labels {
+			b.WriteByte(',')
+			b.WriteString(sanitizeTag(label.Name))
+			b.WriteByte('=')
+			b.WriteString(sanitizeTag(label.Value))
+		}
+		return b.String()
+
+	default:
+		for _, label := range labels {
+			parts = append(parts, label.Value)
+		}
+		return s.flattenKey(parts)
+	}
+}
+
+// Formats a metric line following the tag format. The tagged formats
+// have no key value type, key values are sent as gauges. The rate the
+// metric was sampled at is sent when it is below 1.
+func (s *Sink) formatMetric(key []string, labels []metrics.Label, val float64, metricType string, rate float64) string {
+	return s.formatLine(key, labels, fmt.Sprintf("%f", val), metricType, rate)
+}
+
+// Formats a metric line with an already formatted value, see formatMetric
+func (s *Sink) formatLine(key []string, labels []metrics.Label, val string, metricType string, rate float64) string {
+	if metricType == "kv" && s.tagFormat != TagFormatNone {
+		metricType = "g"
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(s.flattenKeyLabels(key, labels))
+	b.WriteString(":" + val + "|" + metricType)
+
+	if rate > 0 && rate < 1 {
+		fmt.Fprintf(b, "|@%g", rate)
+	}
+	if s.tagFormat == TagFormatDogStatsD && len(labels) > 0 {
+		b.WriteString("|#")
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(sanitizeTag(label.Name))
+			b.WriteByte(':')
+			b.WriteString(sanitizeTag(label.Value))
+		}
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// sanitizeTag replaces the characters separating the tags and fields
+// of the tagged formats
+func sanitizeTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '|', '=', ':', ' ', '\n':
+			return '_'
+		default:
+			return r
+		}
+	}, tag)
+}
@@ -0,0 +1,78 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestStatsd_TagFormat(t *testing.T) {
+	labels := []metrics.Label{{Name: "method", Value: "GET"}, {Name: "path", Value: "/a,b|c"}}
+
+	for _, tc := range []struct {
+		format   TagFormat
+		key      []string
+		labels   []metrics.Label
+		val      float64
+		typ      string
+		expected string
+	}{
+		{TagFormatNone, []string{"gauge"}, labels, 1, "g", "gauge.GET./a,b|c:1.000000|g\n"},
+		{TagFormatNone, []string{"key"}, nil, 2, "kv", "key:2.000000|kv\n"},
+		{TagFormatNone, []string{"sample"}, labels[:1], 4, "ms", "sample.GET:4.000000|ms|@0.25\n"},
+		{TagFormatDogStatsD, []string{"gauge"}, labels, 1, "g", "gauge:1.000000|g|#method:GET,path:/a_b_c\n"},
+		{TagFormatDogStatsD, []string{"key"}, nil, 2, "kv", "key:2.000000|g\n"},
+		{TagFormatDogStatsD, []string{"counter"}, nil, 3, "c", "counter:3.000000|c\n"},
+		{TagFormatDogStatsD, []string{"sample"}, labels[:1], 4, "ms", "sample:4.000000|ms|@0.25|#method:GET\n"},
+		{TagFormatTelegraf, []string{"http", "requests"}, labels, 1, "c", "http.requests,method=GET,path=/a_b_c:1.000000|c\n"},
+		{TagFormatTelegraf, []string{"key"}, nil, 2, "kv", "key:2.000000|g\n"},
+		{TagFormatTelegraf, []string{"sample"}, labels[:1], 4, "ms", "sample,method=GET:4.000000|ms|@0.25\n"},
+	} {
+		s := &Sink{tagFormat: tc.format}
+		rate := 1.0
+		if tc.typ == "ms" {
+			rate = 0.25
+		}
+		if line := s.formatMetric(tc.key, tc.labels, tc.val, tc.typ, rate); line != tc.expected {
+			t.Fatalf("bad line %q, expected %q", line, tc.expected)
+		}
+	}
+}
+
+func TestStatsd_RateAnnotator(t *testing.T) {
+	q := make(chan string, 2)
+	s := &Sink{metricQueue: q, tagFormat: TagFormatDogStatsD}
+	var _ metrics.RateAnnotator = s
+
+	s.IncrCounterWithRate([]string{"counter"}, 1, []metrics.Label{{Name: "a", Value: "b"}}, 0.1)
+	s.AddSampleWithRate([]string{"sample"}, 2, nil, 0.5)
+
+	if out := <-q; out != "counter:1.000000|c|@0.1|#a:b\n" {
+		t.Fatalf("bad line %q", out)
+	}
+	if out := <-q; out != "sample:2.000000|ms|@0.5\n" {
+		t.Fatalf("bad line %q", out)
+	}
+}
+
+func TestStatsd_GaugeDelta(t *testing.T) {
+	q := make(chan string, 4)
+	s := &Sink{metricQueue: q, tagFormat: TagFormatDogStatsD}
+	var _ metrics.GaugeDeltaSink = s
+
+	s.IncrGauge([]string{"gauge"}, 2)
+	s.IncrGaugeWithLabels([]string{"gauge"}, 1.5, []metrics.Label{{Name: "a", Value: "b"}})
+	s.DecrGauge([]string{"gauge"}, 3)
+	s.DecrGaugeWithLabels([]string{"gauge"}, -1, nil)
+
+	for _, expected := range []string{
+		"gauge:+2.000000|g\n",
+		"gauge:+1.500000|g|#a:b\n",
+		"gauge:-3.000000|g\n",
+		"gauge:+1.000000|g\n",
+	} {
+		if out := <-q; out != expected {
+			t.Fatalf("bad line %q, expected %q", out, expected)
+		}
+	}
+}
@@ -3,7 +3,16 @@ package statsd
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,6 +46,40 @@ func TestStatsd_PushFullQueue(t *testing.T) {
 	}
 }
 
+// listenUDP starts a statsd test server, returning its connection
+func listenUDP(t *testing.T, network, addr string) *net.UDPConn {
+	udpAddr, err := net.ResolveUDPAddr(network, addr)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	list, err := net.ListenUDP(network, udpAddr)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	return list
+}
+
+// readLines reads a packet from the test server, splitting its lines
+func readLines(t *testing.T, list *net.UDPConn) []string {
+	list.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 1500)
+	n, err := list.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+
+	var lines []string
+	reader := bufio.NewReader(bytes.NewReader(buf[:n]))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
 func TestStatsd_Conn(t *testing.T) {
 	addr := "127.0.0.1:7524"
 	done := make(chan bool)
@@ -56,58 +99,58 @@ func TestStatsd_Conn(t *testing.T) {
 
 		line, err := reader.ReadString('\n')
 		if err != nil {
-			t.Fatalf("unexpected err %s", err)
+			t.Errorf("unexpected err %s", err)
 		}
 		if line != "gauge.val:1.000000|g\n" {
-			t.Fatalf("bad line %s", line)
+			t.Errorf("bad line %s", line)
 		}
 
 		line, err = reader.ReadString('\n')
 		if err != nil {
-			t.Fatalf("unexpected err %s", err)
+			t.Errorf("unexpected err %s", err)
 		}
 		if line != "gauge_labels.val.label:2.000000|g\n" {
-			t.Fatalf("bad line %s", line)
+			t.Errorf("bad line %s", line)
 		}
 
 		line, err = reader.ReadString('\n')
 		if err != nil {
-			t.Fatalf("unexpected err %s", err)
+			t.Errorf("unexpected err %s", err)
 		}
 		if line != "key.other:3.000000|kv\n" {
-			t.Fatalf("bad line %s", line)
+			t.Errorf("bad line %s", line)
 		}
 
 		line, err = reader.ReadString('\n')
 		if err != nil {
-			t.Fatalf("unexpected err %s", err)
+			t.Errorf("unexpected err %s", err)
 		}
 		if line != "counter.me:4.000000|c\n" {
-			t.Fatalf("bad line %s", line)
+			t.Errorf("bad line %s", line)
 		}
 
 		line, err = reader.ReadString('\n')
 		if err != nil {
-			t.Fatalf("unexpected err %s", err)
+			t.Errorf("unexpected err %s", err)
 		}
 		if line != "counter_labels.me.label:5.000000|c\n" {
-			t.Fatalf("bad line %s", line)
+			t.Errorf("bad line %s", line)
 		}
 
 		line, err = reader.ReadString('\n')
 		if err != nil {
-			t.Fatalf("unexpected err %s", err)
+			t.Errorf("unexpected err %s", err)
 		}
 		if line != "sample.slow_thingy:6.000000|ms\n" {
-			t.Fatalf("bad line %s", line)
+			t.Errorf("bad line %s", line)
 		}
 
 		line, err = reader.ReadString('\n')
 		if err != nil {
-			t.Fatalf("unexpected err %s", err)
+			t.Errorf("unexpected err %s", err)
 		}
 		if line != "sample_labels.slow_thingy.label:7.000000|ms\n" {
-			t.Fatalf("bad line %s", line)
+			t.Errorf("bad line %s", line)
 		}
 
 		done <- true
@@ -132,3 +175,502 @@ func TestStatsd_Conn(t *testing.T) {
 		t.Fatalf("timeout")
 	}
 }
+
+func TestStatsd_DatagramPerMetric(t *testing.T) {
+	list := listenUDP(t, "udp", "127.0.0.1:0")
+	defer list.Close()
+
+	s, err := NewSinkWithConfig(list.LocalAddr().String(), SinkConfig{DatagramPerMetric: true})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"a"}, 1)
+	s.IncrCounter([]string{"b"}, 2)
+	for _, expect := range []string{"a:1.000000|c\n", "b:2.000000|c\n"} {
+		if lines := readLines(t, list); len(lines) != 1 || lines[0] != expect {
+			t.Fatalf("bad lines %v", lines)
+		}
+	}
+}
+
+func TestStatsd_ConnIPv6(t *testing.T) {
+	addr := "[::1]:7526"
+	list, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback, Port: 7526})
+	if err != nil {
+		t.Skipf("IPv6 is not available: %s", err)
+	}
+	defer list.Close()
+
+	s, err := NewSink(addr)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounterWithLabels([]string{"counter", "ipv6"}, float32(1), []metrics.Label{{Name: "addr", Value: "::1"}})
+
+	// Colons are sanitized from the key, not the address
+	lines := readLines(t, list)
+	if len(lines) != 1 || lines[0] != "counter.ipv6.__1:1.000000|c\n" {
+		t.Fatalf("bad lines %v", lines)
+	}
+}
+
+func TestStatsd_InvalidAddr(t *testing.T) {
+	invalid := []string{"::1:8125", "127.0.0.1", "[::1]"}
+	for _, addr := range invalid {
+		if _, err := NewSink(addr); err == nil {
+			t.Fatalf("expected error for %s", addr)
+		}
+	}
+}
+
+func TestStatsd_InvalidMTU(t *testing.T) {
+	if _, err := NewSink("127.0.0.1:7525", WithMTU(udpHeaderLen)); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestStatsd_MTU(t *testing.T) {
+	addr := "127.0.0.1:7525"
+	list, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 7525})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	// Each metric is 13 bytes long, so only two fit in a packet
+	s, err := NewSink(addr, WithMTU(udpHeaderLen+30))
+	if err != nil {
+		t.Fatalf("bad error")
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"a"}, float32(1))
+	s.IncrCounter([]string{"b"}, float32(1))
+	s.IncrCounter([]string{"c"}, float32(1))
+
+	list.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 1500)
+	n, err := list.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if n > 30 {
+		t.Fatalf("packet exceeds mtu: %d bytes", n)
+	}
+	if packet := string(buf[:n]); packet != "a:1.000000|c\nb:1.000000|c\n" {
+		t.Fatalf("bad packet %s", packet)
+	}
+
+	n, err = list.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if packet := string(buf[:n]); !strings.HasPrefix(packet, "c:1.000000|c\n") {
+		t.Fatalf("bad packet %s", packet)
+	}
+}
+
+func TestStatsd_TryPushFullQueue(t *testing.T) {
+	q := make(chan string, 1)
+	s := &Sink{metricQueue: q}
+
+	if err := s.TryIncrCounterWithLabels([]string{"a"}, 1, nil); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if err := s.TryIncrCounterWithLabels([]string{"b"}, 1, nil); err != metrics.ErrQueueFull {
+		t.Fatalf("expected full queue, got %v", err)
+	}
+	if out := <-q; out != "a:1.000000|c\n" {
+		t.Fatalf("bad val %v", out)
+	}
+}
+
+func TestStatsd_TCP(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	s, err := NewSinkWithConfig(list.Addr().String(), SinkConfig{Transport: "tcp"})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGauge([]string{"gauge", "val"}, 1)
+	s.IncrCounterWithLabels([]string{"counter"}, 2, []metrics.Label{{Name: "a", Value: "b"}})
+
+	conn, err := list.Accept()
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	for _, expected := range []string{"gauge.val:1.000000|g\n", "counter.b:2.000000|c\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unexpected err %s", err)
+		}
+		if line != expected {
+			t.Fatalf("bad line %q", line)
+		}
+	}
+}
+
+// selfSignedTLS returns a server config with a certificate for 127.0.0.1,
+// and a client config trusting it
+func selfSignedTLS(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "statsd"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: pool}
+}
+
+func TestStatsd_TLS(t *testing.T) {
+	serverConfig, clientConfig := selfSignedTLS(t)
+	list, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	// The transport defaults to tcp with TLS
+	s, err := NewSinkWithConfig(list.Addr().String(), SinkConfig{TLSConfig: clientConfig})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGauge([]string{"gauge", "val"}, 1)
+
+	conn, err := list.Accept()
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if line != "gauge.val:1.000000|g\n" {
+		t.Fatalf("bad line %q", line)
+	}
+
+	if _, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{Transport: "udp", TLSConfig: clientConfig}); err == nil {
+		t.Fatalf("expected TLS over UDP error")
+	}
+}
+
+func TestStatsd_InvalidTransport(t *testing.T) {
+	if _, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{Transport: "sctp"}); err == nil {
+		t.Fatalf("expected transport error")
+	}
+
+	s, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+	if s.transport != "udp" {
+		t.Fatalf("bad default transport %s", s.transport)
+	}
+}
+
+func TestStatsd_FlushInterval(t *testing.T) {
+	if _, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{FlushInterval: -time.Second}); err == nil {
+		t.Fatalf("expected flush interval error")
+	}
+
+	s, err := NewSink("127.0.0.1:8125")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	s.Shutdown()
+	if s.flushInterval != DefaultFlushInterval {
+		t.Fatalf("bad default flush interval %s", s.flushInterval)
+	}
+
+	list, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 7529})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	s, err = NewSinkWithConfig("127.0.0.1:7529", SinkConfig{FlushInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	start := time.Now()
+	s.IncrCounter([]string{"a"}, float32(1))
+	list.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 1500)
+	n, err := list.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	if packet := string(buf[:n]); packet != "a:1.000000|c\n" {
+		t.Fatalf("bad packet %s", packet)
+	}
+	if elapsed := time.Since(start); elapsed >= DefaultFlushInterval {
+		t.Fatalf("flushed after %s", elapsed)
+	}
+}
+
+func TestStatsd_ConnTimeouts(t *testing.T) {
+	s, err := NewSink("127.0.0.1:8125")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+	if s.dialTimeout != DefaultDialTimeout || s.connWriteTimeout != DefaultConnWriteTimeout {
+		t.Fatalf("bad defaults %s %s", s.dialTimeout, s.connWriteTimeout)
+	}
+
+	s, err = NewSinkWithConfig("127.0.0.1:8125", SinkConfig{ConnWriteTimeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	// Nothing reads the pipe, so the write blocks until its deadline
+	client, server := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.write(client, []byte("a:1|c\n"))
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+			t.Fatalf("expected timeout error, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("write did not time out")
+	}
+}
+
+func TestStatsd_ReconnectBackoff(t *testing.T) {
+	s, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{
+		ReconnectBase:       time.Second,
+		ReconnectMultiplier: 3,
+		ReconnectMax:        10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	delay := s.reconnectBase
+	for _, expected := range []time.Duration{3 * time.Second, 9 * time.Second, 10 * time.Second, 10 * time.Second} {
+		delay = s.nextDelay(delay)
+		if delay != expected {
+			t.Fatalf("bad delay %s, expected %s", delay, expected)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("bad jitter %s", d)
+		}
+	}
+}
+
+func TestStatsd_ReconnectDefaults(t *testing.T) {
+	s, err := NewSink("127.0.0.1:8125")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+	if s.reconnectBase != DefaultReconnectBase || s.reconnectMultiplier != DefaultReconnectMultiplier || s.reconnectMax != DefaultReconnectMax {
+		t.Fatalf("bad defaults %s %v %s", s.reconnectBase, s.reconnectMultiplier, s.reconnectMax)
+	}
+
+	if _, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{ReconnectMultiplier: 0.5}); err == nil {
+		t.Fatalf("expected multiplier error")
+	}
+	if _, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{ReconnectBase: time.Minute, ReconnectMax: time.Second}); err == nil {
+		t.Fatalf("expected max error")
+	}
+}
+
+func TestStatsd_TCPReconnect(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer list.Close()
+
+	s, err := NewSinkWithConfig(list.Addr().String(), SinkConfig{
+		Transport:     "tcp",
+		ReconnectBase: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	defer s.Shutdown()
+
+	// The first connection is closed by the server, the sink reconnects
+	conn, err := list.Accept()
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	conn.Close()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				s.SetGauge([]string{"gauge"}, 1)
+			}
+		}
+	}()
+
+	list.(*net.TCPListener).SetDeadline(time.Now().Add(3 * time.Second))
+	conn, err = list.Accept()
+	if err != nil {
+		t.Fatalf("sink did not reconnect: %s", err)
+	}
+	defer conn.Close()
+}
+
+func TestStatsd_ShutdownDrains(t *testing.T) {
+	addr := "127.0.0.1:7528"
+	list := listenUDP(t, "udp", addr)
+	defer list.Close()
+
+	s, err := NewSink(addr)
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	s.IncrCounter([]string{"a"}, 1)
+	s.IncrCounter([]string{"b"}, 2)
+	s.IncrCounter([]string{"c"}, 3)
+	if err := s.ShutdownContext(context.Background()); err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	s.Shutdown()
+
+	if lines := readLines(t, list); len(lines) != 3 || lines[2] != "c:3.000000|c\n" {
+		t.Fatalf("bad lines %v", lines)
+	}
+}
+
+func TestStatsd_ShutdownContext(t *testing.T) {
+	// The sink is not flushing, so the queue is never drained
+	s := &Sink{metricQueue: make(chan string, 1), doneCh: make(chan struct{})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.ShutdownContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+}
+
+func TestStatsd_ShutdownUnreachable(t *testing.T) {
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected err %s", err)
+	}
+	addr := list.Addr().String()
+	list.Close()
+
+	s := &Sink{
+		addr:                addr,
+		transport:           "tcp",
+		maxPacketLen:        statsdMaxLen,
+		metricQueue:         make(chan string, 3),
+		flushInterval:       DefaultFlushInterval,
+		dialTimeout:         time.Second,
+		connWriteTimeout:    time.Second,
+		probeCh:             make(chan struct{}, 1),
+		quitCh:              make(chan struct{}),
+		doneCh:              make(chan struct{}),
+		reconnectBase:       time.Minute,
+		reconnectMultiplier: 1,
+		reconnectMax:        time.Minute,
+	}
+	s.IncrCounter([]string{"a"}, 1)
+	s.IncrCounter([]string{"b"}, 2)
+	s.IncrCounter([]string{"c"}, 3)
+
+	// Shut down before flushing, so the queued metrics are still there
+	// once waiting to reconnect
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.ShutdownContext(ctx); err != context.Canceled {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	go s.flushMetrics()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = s.ShutdownContext(ctx)
+	if err == nil || !strings.Contains(err.Error(), "3 queued metrics discarded") {
+		t.Fatalf("bad err %v", err)
+	}
+}
+
+func TestStatsd_SampleRate(t *testing.T) {
+	q := make(chan string, 1000)
+	s := &Sink{metricQueue: q, sampleRate: 0.5}
+	for i := 0; i < 1000; i++ {
+		s.AddSample([]string{"sample"}, 1)
+	}
+	if n := len(q); n < 350 || n > 650 {
+		t.Fatalf("bad number of samples %d", n)
+	}
+
+	if _, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{SampleRate: 1.5}); err == nil {
+		t.Fatalf("expected sample rate error")
+	}
+	if _, err := NewSinkWithConfig("127.0.0.1:8125", SinkConfig{TagFormat: 3}); err == nil {
+		t.Fatalf("expected tag format error")
+	}
+}
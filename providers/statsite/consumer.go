@@ -0,0 +1,160 @@
+// Package statsite consumes the metrics aggregated by statsite, for
+// services acting on them locally, ex: to take auto-scaling decisions.
+// Metrics are sent to statsite by the statsd sink.
+package statsite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// typePrefixes maps the key prefixes of the statsite output to the
+// metric types passed to the handler
+var typePrefixes = map[string]string{
+	"counts": "counter",
+	"timers": "timer",
+	"gauges": "gauge",
+	"sets":   "set",
+	"kv":     "kv",
+}
+
+// StartConsumer opens a TCP listener at the address, accepting the
+// connections of a statsite stream sink, ex: a command piping its output
+// to netcat. Each "key|value|timestamp" line is parsed and passed to the
+// handler, with the type read from the key prefix: "counter", "timer",
+// "gauge", "set" or "kv", or "" when the key has no known prefix, which is
+// then kept. The handler is called from the goroutine of each connection.
+// Closing the returned Closer stops the listener and its connections, it
+// returns once the handler is no longer called.
+func StartConsumer(addr string, handler func(key, typ string, val float64)) (io.Closer, error) {
+	if handler == nil {
+		return nil, fmt.Errorf("statsite consumer handler must be provided")
+	}
+	list, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &consumer{list: list, conns: make(map[net.Conn]struct{})}
+	c.wg.Add(1)
+	go c.accept(handler)
+	return c, nil
+}
+
+// consumer accepts the statsite connections until it is closed
+type consumer struct {
+	list net.Listener
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// Close stops the listener, closing the open connections
+func (c *consumer) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	err := c.list.Close()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	return err
+}
+
+// accept starts consuming each connection until the listener is closed
+func (c *consumer) accept(handler func(key, typ string, val float64)) {
+	defer c.wg.Done()
+	for {
+		conn, err := c.list.Accept()
+		if err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if !closed {
+				log.Printf("[ERR] Error accepting statsite connection! Err: %s", err)
+			}
+			return
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return
+		}
+		c.conns[conn] = struct{}{}
+		c.wg.Add(1)
+		c.mu.Unlock()
+
+		go func() {
+			defer c.wg.Done()
+			err := consume(conn, handler)
+
+			c.mu.Lock()
+			delete(c.conns, conn)
+			closed := c.closed
+			c.mu.Unlock()
+			if err != nil && !closed {
+				log.Printf("[ERR] Error reading statsite connection! Err: %s", err)
+			}
+		}()
+	}
+}
+
+// consume reads the lines of a connection until it is closed
+func consume(conn net.Conn, handler func(key, typ string, val float64)) error {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, typ, val, err := parseLine(line)
+		if err != nil {
+			log.Printf("[WARN] Skipping statsite line %q: %s", line, err)
+			continue
+		}
+		handler(key, typ, val)
+	}
+	return scanner.Err()
+}
+
+// parseLine parses a line of the statsite output, ex:
+// "timers.api.latency.mean|12.500000|1556813561"
+func parseLine(line string) (string, string, float64, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) != 3 {
+		return "", "", 0, fmt.Errorf("expected key|value|timestamp")
+	}
+
+	val, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("bad value: %s", err)
+	}
+	if _, err := strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return "", "", 0, fmt.Errorf("bad timestamp: %s", err)
+	}
+
+	key, typ := fields[0], ""
+	if i := strings.IndexByte(key, '.'); i > 0 {
+		if prefixType, ok := typePrefixes[key[:i]]; ok {
+			key, typ = key[i+1:], prefixType
+		}
+	}
+	if key == "" {
+		return "", "", 0, fmt.Errorf("empty key")
+	}
+	return key, typ, val, nil
+}
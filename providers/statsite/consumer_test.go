@@ -0,0 +1,107 @@
+package statsite
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	for line, expected := range map[string]string{
+		"counts.api.hits|42.000000|1556813561":    "api.hits counter 42",
+		"timers.api.latency.mean|12.5|1556813561": "api.latency.mean timer 12.5",
+		"gauges.queue|3|1556813561":               "queue gauge 3",
+		"sets.users|7|1556813561":                 "users set 7",
+		"kv.build|1|1556813561":                   "build kv 1",
+		"custom.metric|1|1556813561":              "custom.metric  1",
+		"noprefix|2|1556813561":                   "noprefix  2",
+	} {
+		key, typ, val, err := parseLine(line)
+		if err != nil {
+			t.Fatalf("err for %q: %v", line, err)
+		}
+		if out := fmt.Sprintf("%s %s %v", key, typ, val); out != expected {
+			t.Fatalf("bad parse of %q: %q", line, out)
+		}
+	}
+
+	for _, line := range []string{"a|1", "a|b|1", "a|1|b", "|1|1", "a|1|1|1", "counts.|1|1"} {
+		if _, _, _, err := parseLine(line); err == nil {
+			t.Fatalf("expected error for %q", line)
+		}
+	}
+}
+
+func TestStartConsumer(t *testing.T) {
+	if _, err := StartConsumer("127.0.0.1:0", nil); err == nil {
+		t.Fatalf("expected error for missing handler")
+	}
+
+	list, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	addr := list.Addr().String()
+	list.Close()
+
+	received := make(chan string, 10)
+	consumer, err := StartConsumer(addr, func(key, typ string, val float64) {
+		received <- fmt.Sprintf("%s %s %v", key, typ, val)
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer consumer.Close()
+	if _, err := StartConsumer(addr, func(string, string, float64) {}); err == nil {
+		t.Fatalf("expected error for address in use")
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	fmt.Fprint(conn, "counts.hits|2.000000|1556813561\nbad line\ngauges.queue|1.500000|1556813561\n")
+	conn.Close()
+
+	for _, expected := range []string{"hits counter 2", "queue gauge 1.5"} {
+		select {
+		case out := <-received:
+			if out != expected {
+				t.Fatalf("bad metric %q, expected %q", out, expected)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+func TestStartConsumer_Close(t *testing.T) {
+	c, err := StartConsumer("127.0.0.1:0", func(string, string, float64) {})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	addr := c.(*consumer).list.Addr().String()
+
+	// An open connection is closed along with the listener
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "counts.hits|2.000000|1556813561\n")
+
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("timed out closing the consumer")
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Fatalf("expected the listener to be closed")
+	}
+}
@@ -0,0 +1,216 @@
+// Package statsrelay provides a sink sharding metrics across statsd
+// servers with consistent hashing, as the statsrelay proxy does
+package statsrelay
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/statsd"
+)
+
+const (
+	// DefaultReplicas is the number of points of each host on the hash
+	// ring, spreading the keys evenly across the hosts
+	DefaultReplicas = 100
+
+	// DefaultPoolSize is the number of connections to each host
+	DefaultPoolSize = 1
+)
+
+// Sink provides a MetricSink sending each metric to one of several statsd
+// servers, chosen by hashing its key on a consistent hash ring. All the
+// label combinations of a key go to the same server, so it aggregates them
+// alone, and adding or removing a server only moves the keys of its share
+// of the ring. Each server is sent to by a pool of statsd sinks.
+type Sink struct {
+	hosts []*host
+	ring  []point
+}
+
+// host holds the statsd sinks of a server, used in turn
+type host struct {
+	addr  string
+	pool  []*statsd.Sink
+	count uint32
+}
+
+// point is a point of the hash ring, owned by a host
+type point struct {
+	hash uint32
+	host *host
+}
+
+type config struct {
+	statsd   statsd.SinkConfig
+	replicas int
+	poolSize int
+}
+
+// Option is used to configure a Sink
+type Option func(*config)
+
+// WithStatsdConfig sets the configuration of the statsd sinks, ex: to
+// send over TCP. Defaults to dropping the metrics when the queue is full.
+func WithStatsdConfig(cfg statsd.SinkConfig) Option {
+	return func(c *config) {
+		c.statsd = cfg
+	}
+}
+
+// WithReplicas sets the number of points of each host on the hash ring,
+// defaults to DefaultReplicas
+func WithReplicas(n int) Option {
+	return func(c *config) {
+		c.replicas = n
+	}
+}
+
+// WithPoolSize sets the number of connections to each host, defaults to
+// DefaultPoolSize. With several connections the metrics of a key may reach
+// the server out of order.
+func WithPoolSize(n int) Option {
+	return func(c *config) {
+		c.poolSize = n
+	}
+}
+
+// NewSink is used to create a new Sink sharding metrics across the statsd
+// servers at the addresses, in the host:port form
+func NewSink(hosts []string, opts ...Option) (*Sink, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("at least one statsd host must be provided")
+	}
+	c := &config{
+		statsd:   statsd.SinkConfig{DropOnFull: true},
+		replicas: DefaultReplicas,
+		poolSize: DefaultPoolSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.replicas < 1 || c.poolSize < 1 {
+		return nil, fmt.Errorf("replicas and pool size must be positive, got %d and %d", c.replicas, c.poolSize)
+	}
+
+	s := &Sink{}
+	seen := make(map[string]bool, len(hosts))
+	for _, addr := range hosts {
+		if seen[addr] {
+			s.Shutdown()
+			return nil, fmt.Errorf("duplicate statsd host %q", addr)
+		}
+		seen[addr] = true
+
+		h := &host{addr: addr}
+		s.hosts = append(s.hosts, h)
+		for i := 0; i < c.poolSize; i++ {
+			sink, err := statsd.NewSinkWithConfig(addr, c.statsd)
+			if err != nil {
+				s.Shutdown()
+				return nil, fmt.Errorf("bad statsd host %q: %s", addr, err)
+			}
+			h.pool = append(h.pool, sink)
+		}
+		for i := 0; i < c.replicas; i++ {
+			s.ring = append(s.ring, point{hash: hash(addr + "#" + strconv.Itoa(i)), host: h})
+		}
+	}
+	sort.Slice(s.ring, func(i, j int) bool {
+		return s.ring[i].hash < s.ring[j].hash
+	})
+	return s, nil
+}
+
+// Shutdown is used to stop sending to the servers, blocking until the
+// queued metrics are sent
+func (s *Sink) Shutdown() {
+	for _, h := range s.hosts {
+		for _, sink := range h.pool {
+			sink.Shutdown()
+		}
+	}
+}
+
+// Host returns the address of the server the metrics of the key are sent to
+func (s *Sink) Host(key []string) string {
+	return s.host(key).addr
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.sink(key).SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.sink(key).SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.sink(key).EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.sink(key).IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.sink(key).IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.sink(key).IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.sink(key).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.sink(key).AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.sink(key).AddSampleWithLabels(key, val, labels)
+}
+
+// sink returns the next statsd sink of the pool of the host of the key
+func (s *Sink) sink(key []string) *statsd.Sink {
+	h := s.host(key)
+	if len(h.pool) == 1 {
+		return h.pool[0]
+	}
+	return h.pool[atomic.AddUint32(&h.count, 1)%uint32(len(h.pool))]
+}
+
+// host returns the owner of the first point of the ring after the hash
+// of the key, wrapping around
+func (s *Sink) host(key []string) *host {
+	k := hash(strings.Join(key, "."))
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return s.ring[i].hash >= k
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].host
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
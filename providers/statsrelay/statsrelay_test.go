@@ -0,0 +1,110 @@
+package statsrelay
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/statsd"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink(nil); err == nil {
+		t.Fatalf("expected error for missing hosts")
+	}
+	if _, err := NewSink([]string{"127.0.0.1:8125", "127.0.0.1:8125"}); err == nil {
+		t.Fatalf("expected error for duplicate hosts")
+	}
+	if _, err := NewSink([]string{"127.0.0.1:8125", "localhost"}); err == nil {
+		t.Fatalf("expected error for bad host")
+	}
+	if _, err := NewSink([]string{"127.0.0.1:8125"}, WithPoolSize(0)); err == nil {
+		t.Fatalf("expected error for empty pool")
+	}
+	if _, err := NewSink([]string{"127.0.0.1:8125"}, WithStatsdConfig(statsd.SinkConfig{Transport: "sctp"})); err == nil {
+		t.Fatalf("expected error for bad statsd config")
+	}
+}
+
+func TestSink_ConsistentHashing(t *testing.T) {
+	two, err := NewSink([]string{"127.0.0.1:8125", "127.0.0.1:8126"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer two.Shutdown()
+	three, err := NewSink([]string{"127.0.0.1:8125", "127.0.0.1:8126", "127.0.0.1:8127"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer three.Shutdown()
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := []string{"key", fmt.Sprint(i)}
+		counts[two.Host(key)]++
+
+		// Adding a host only moves keys to it
+		if host := three.Host(key); host != two.Host(key) && host != "127.0.0.1:8127" {
+			t.Fatalf("key %v moved from %s to %s", key, two.Host(key), host)
+		}
+	}
+	for host, n := range counts {
+		if n < 300 {
+			t.Fatalf("unbalanced ring %s got %d keys: %v", host, n, counts)
+		}
+	}
+}
+
+func TestSink(t *testing.T) {
+	var addrs []string
+	lines := make(chan string, 100)
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		defer conn.Close()
+		addr := conn.LocalAddr().String()
+		addrs = append(addrs, addr)
+
+		go func() {
+			buf := make([]byte, 1500)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				for _, line := range strings.Split(strings.TrimSpace(string(buf[:n])), "\n") {
+					lines <- addr + " " + line
+				}
+			}
+		}()
+	}
+
+	s, err := NewSink(addrs, WithPoolSize(2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		key := []string{"counter", fmt.Sprint(i)}
+		s.IncrCounterWithLabels(key, 1, []metrics.Label{{Name: "a", Value: "b"}})
+		expected[fmt.Sprintf("%s counter.%d.b:1.000000|c", s.Host(key), i)] = true
+	}
+	s.Shutdown()
+
+	for len(expected) > 0 {
+		select {
+		case line := <-lines:
+			if !expected[line] {
+				t.Fatalf("unexpected line %q", line)
+			}
+			delete(expected, line)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %v", expected)
+		}
+	}
+}
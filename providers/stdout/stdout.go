@@ -0,0 +1,202 @@
+package stdout
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// Format is the format metrics are written with
+type Format int
+
+const (
+	// FormatStatsd writes metrics as statsd lines, labels
+	// being appended as DogStatsD tags:
+	//
+	//	name:value|type|#label:value
+	FormatStatsd Format = iota
+
+	// FormatInfluxLine writes metrics using the InfluxDB line protocol,
+	// labels being tags:
+	//
+	//	name,label=value value=1 1556813561098000000
+	FormatInfluxLine
+
+	// FormatJSON writes metrics as JSON objects, one per line
+	FormatJSON
+)
+
+const (
+	gaugeType   = "g"
+	keyType     = "kv"
+	counterType = "c"
+	sampleType  = "ms"
+)
+
+var (
+	// statsdSanitizer replaces the statsd separators and line breaks
+	statsdSanitizer = strings.NewReplacer(" ", "_", ":", "_", "|", "_", ",", "_", "\n", "_", "\r", "_")
+
+	// influxEscaper escapes the line protocol separators, the line
+	// protocol having no escape sequence for line breaks
+	influxEscaper = strings.NewReplacer("\\", "\\\\", " ", "\\ ", ",", "\\,", "=", "\\=", "\n", "_", "\r", "_")
+)
+
+// Sink provides a MetricSink that writes metrics to stdout, one per line,
+// to be collected along with the logs in container environments without
+// a sidecar agent. It is meant for debugging and low volume usage.
+type Sink struct {
+	format Format
+	prefix string
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewSink is used to create a new Sink writing metrics in the given
+// format. The prefix, if not empty, is prepended to the metric names.
+func NewSink(format Format, prefix string) *Sink {
+	return &Sink{
+		format: format,
+		prefix: prefix,
+		w:      os.Stdout,
+	}
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.write(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.write(keyType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.write(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.write(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.write(sampleType, key, float64(val), labels)
+}
+
+// Formats and writes a metric line with a single call,
+// so concurrent lines are not interleaved
+func (s *Sink) write(metricType string, key []string, val float64, labels []metrics.Label) {
+	if s.prefix != "" {
+		key = append([]string{s.prefix}, key...)
+	}
+
+	buf := &bytes.Buffer{}
+	switch s.format {
+	case FormatInfluxLine:
+		s.formatInflux(buf, key, val, labels)
+	case FormatJSON:
+		// Only NaN and infinite values fail to encode
+		if err := s.formatJSON(buf, metricType, key, val, labels); err != nil {
+			log.Printf("[ERR] Error encoding metric to JSON! Err: %s", err)
+			return
+		}
+	default:
+		s.formatStatsd(buf, metricType, key, val, labels)
+	}
+	buf.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		log.Printf("[ERR] Error writing metric to stdout! Err: %s", err)
+	}
+}
+
+func (s *Sink) formatStatsd(buf *bytes.Buffer, metricType string, key []string, val float64, labels []metrics.Label) {
+	buf.WriteString(statsdSanitizer.Replace(strings.Join(key, ".")))
+	buf.WriteString(":" + formatFloat(val) + "|" + metricType)
+	for i, label := range labels {
+		if i == 0 {
+			buf.WriteString("|#")
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(statsdSanitizer.Replace(label.Name) + ":" + statsdSanitizer.Replace(label.Value))
+	}
+}
+
+func (s *Sink) formatInflux(buf *bytes.Buffer, key []string, val float64, labels []metrics.Label) {
+	buf.WriteString(influxEscaper.Replace(strings.Join(key, ".")))
+	for _, label := range labels {
+		buf.WriteString("," + influxEscaper.Replace(label.Name) + "=" + influxEscaper.Replace(label.Value))
+	}
+	buf.WriteString(" value=" + formatFloat(val) + " " + strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+// jsonMetric is the JSON representation of a metric
+type jsonMetric struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`
+	Value     float64           `json:"value"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+func (s *Sink) formatJSON(buf *bytes.Buffer, metricType string, key []string, val float64, labels []metrics.Label) error {
+	m := jsonMetric{
+		Name:      strings.Join(key, "."),
+		Type:      metricType,
+		Value:     val,
+		Timestamp: time.Now().UTC(),
+	}
+	if len(labels) > 0 {
+		m.Labels = make(map[string]string, len(labels))
+		for _, label := range labels {
+			m.Labels[label.Name] = label.Value
+		}
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+func formatFloat(val float64) string {
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
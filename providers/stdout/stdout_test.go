@@ -0,0 +1,99 @@
+package stdout
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func emit(s *Sink) []string {
+	buf := &bytes.Buffer{}
+	s.w = buf
+
+	l := []metrics.Label{{Name: "a", Value: "b"}, {Name: "c", Value: "d e"}}
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1.5, l)
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4)
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestSink_Statsd(t *testing.T) {
+	lines := emit(NewSink(FormatStatsd, "app"))
+
+	expected := []string{
+		"app.gauge.val:1.5|g|#a:b,c:d_e",
+		"app.key:2|kv",
+		"app.counter:3|c",
+		"app.sample:4|ms",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected %q got %q", expected, lines)
+	}
+}
+
+func TestSink_InfluxLine(t *testing.T) {
+	lines := emit(NewSink(FormatInfluxLine, ""))
+
+	expected := []string{
+		"gauge.val,a=b,c=d\\ e value=1.5 ",
+		"key value=2 ",
+		"counter value=3 ",
+		"sample value=4 ",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("bad lines %q", lines)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, expected[i]) || len(line) == len(expected[i]) {
+			t.Fatalf("expected %q got %q", expected[i], line)
+		}
+	}
+}
+
+func TestSink_JSON(t *testing.T) {
+	lines := emit(NewSink(FormatJSON, "app"))
+	if len(lines) != 4 {
+		t.Fatalf("bad lines %q", lines)
+	}
+
+	var m jsonMetric
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("bad line %s: %v", lines[0], err)
+	}
+	if m.Name != "app.gauge.val" || m.Type != "g" || m.Value != 1.5 || m.Labels["c"] != "d e" || m.Timestamp.IsZero() {
+		t.Fatalf("bad metric %v", m)
+	}
+}
+
+func TestSink_JSONInvalidValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewSink(FormatJSON, "")
+	s.w = buf
+
+	s.SetGauge([]string{"gauge"}, float32(math.NaN()))
+	if buf.Len() != 0 {
+		t.Fatalf("invalid values must not be written %q", buf.String())
+	}
+}
+
+func TestSink_LineBreaks(t *testing.T) {
+	l := []metrics.Label{{Name: "path", Value: "C:\\a\nb\r"}}
+	for format, expected := range map[Format]string{
+		FormatStatsd:     "a_b:1|g|#path:C_\\a_b_\n",
+		FormatInfluxLine: "a_b,path=C:\\\\a_b_ value=1 ",
+	} {
+		buf := &bytes.Buffer{}
+		s := NewSink(format, "")
+		s.w = buf
+
+		s.SetGaugeWithLabels([]string{"a\nb"}, 1, l)
+		if !strings.HasPrefix(buf.String(), expected) || strings.Count(buf.String(), "\n") != 1 {
+			t.Fatalf("expected %q got %q", expected, buf.String())
+		}
+	}
+}
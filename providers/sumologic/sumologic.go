@@ -0,0 +1,207 @@
+package sumologic
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	gaugeType   = "gauge"
+	counterType = "count"
+	sampleType  = "timer"
+)
+
+// Sink provides a MetricSink that pushes metrics to a Sumo Logic HTTP
+// Logs and Metrics Source in the Carbon 2.0 format. The metric name, its
+// type and the labels are sent as intrinsic tags, which identify the time
+// series, while the tags set with WithMetaTags are sent as meta tags.
+type Sink struct {
+	metaTags []metrics.Label
+	config   push.Config
+	client   *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithMetaTags sets the meta tags sent along with every metric
+func WithMetaTags(tags ...metrics.Label) Option {
+	return func(s *Sink) {
+		s.metaTags = append(s.metaTags, tags...)
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// NewSink is used to create a new Sink pushing to the given HTTP source URL
+func NewSink(httpSourceURL string, opts ...Option) (*Sink, error) {
+	if httpSourceURL == "" {
+		return nil, fmt.Errorf("http source url must be provided")
+	}
+
+	s := &Sink{
+		config: push.Config{
+			Name:        "sumologic",
+			URL:         httpSourceURL,
+			ContentType: "application/vnd.sumologic.carbon2",
+			Encode:      encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(sampleType, key, float64(val), labels)
+}
+
+// Formats the metric as a Carbon 2.0 line:
+//
+//	metric=<name> mtype=<type> <labels>  <meta tags> <value> <timestamp>
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("metric=" + s.flattenKey(key) + " mtype=" + metricType)
+	writeTags(buf, labels)
+
+	if len(s.metaTags) > 0 {
+		buf.WriteByte(' ')
+		writeTags(buf, s.metaTags)
+	}
+
+	fmt.Fprintf(buf, " %s %d", strconv.FormatFloat(val, 'f', -1, 64), time.Now().Unix())
+	s.client.Push(buf.String())
+}
+
+// Writes the tags as space separated key=value pairs, each preceded by a space
+func writeTags(buf *bytes.Buffer, tags []metrics.Label) {
+	for _, tag := range tags {
+		buf.WriteString(" " + strings.Map(sanitize, tag.Name) + "=" + strings.Map(sanitize, tag.Value))
+	}
+}
+
+// Flattens the key for formatting, removes spaces
+func (s *Sink) flattenKey(parts []string) string {
+	return strings.Map(sanitize, strings.Join(parts, "."))
+}
+
+// sanitize replaces the characters used as separators on the format
+func sanitize(r rune) rune {
+	switch r {
+	case ' ', '=', '\n':
+		return '_'
+	default:
+		return r
+	}
+}
+
+// encode formats the metrics one per line
+func encode(records []interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, r := range records {
+		buf.WriteString(r.(string))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
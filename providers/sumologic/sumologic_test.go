@@ -0,0 +1,93 @@
+package sumologic
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_MissingURL(t *testing.T) {
+	if _, err := NewSink(""); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestFlattenKey(t *testing.T) {
+	s := &Sink{}
+	if flat := s.flattenKey([]string{"a b", "c=d"}); flat != "a_b.c_d" {
+		t.Fatalf("bad flat %s", flat)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var body []byte
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, WithFlushInterval(time.Hour),
+		WithMetaTags(metrics.Label{Name: "env", Value: "prod"}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1.5, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.AddSample([]string{"sample"}, 3)
+	s.Flush()
+
+	if contentType != "application/vnd.sumologic.carbon2" {
+		t.Fatalf("bad content type %s", contentType)
+	}
+
+	expected := []string{
+		"metric=gauge.val mtype=gauge a=b  env=prod 1.5 ",
+		"metric=counter mtype=count  env=prod 2 ",
+		"metric=sample mtype=timer  env=prod 3 ",
+	}
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("bad lines %q", lines)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, expected[i]) || len(line) == len(expected[i]) {
+			t.Fatalf("expected %q got %q", expected[i], line)
+		}
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("http://localhost", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
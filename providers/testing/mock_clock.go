@@ -0,0 +1,44 @@
+package testing
+
+import (
+	"sync"
+	"time"
+)
+
+// MockClock is a metrics.Clock whose time only changes when it is
+// advanced or set, so tests do not depend on the system time
+type MockClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewMockClock creates a MockClock at the given time
+func NewMockClock(now time.Time) *MockClock {
+	return &MockClock{now: now}
+}
+
+// Now returns the time of the clock
+func (c *MockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the time elapsed since t on the clock
+func (c *MockClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Advance moves the clock forward by d
+func (c *MockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set sets the time of the clock
+func (c *MockClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
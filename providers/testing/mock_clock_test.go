@@ -0,0 +1,26 @@
+package testing
+
+import (
+	gotesting "testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestMockClock(t *gotesting.T) {
+	start := time.Unix(1000, 0)
+	var c metrics.Clock = NewMockClock(start)
+	if !c.Now().Equal(start) || c.Since(start) != 0 {
+		t.Fatalf("bad time %v", c.Now())
+	}
+
+	mock := c.(*MockClock)
+	mock.Advance(time.Minute)
+	if c.Since(start) != time.Minute {
+		t.Fatalf("bad since %v", c.Since(start))
+	}
+	mock.Set(start.Add(-time.Hour))
+	if c.Since(start) != -time.Hour {
+		t.Fatalf("bad since %v", c.Since(start))
+	}
+}
@@ -0,0 +1,151 @@
+//go:build go1.14
+// +build go1.14
+
+// Package testing provides a sink logging metrics to a test, so their
+// values show up with "go test -v" and can be asserted on.
+package testing
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	gotesting "testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// record is a metric received by a TSink
+type record struct {
+	kind   string
+	key    string
+	val    float64
+	labels []metrics.Label
+}
+
+func (r record) String() string {
+	return fmt.Sprintf("%s %s=%v labels=%v", r.kind, r.key, r.val, r.labels)
+}
+
+// TSink provides a MetricSink logging every metric to a test. The
+// received metrics are also dumped when the test ends.
+type TSink struct {
+	t gotesting.TB
+
+	mu      sync.Mutex
+	records []record
+}
+
+// NewTSink is used to create a new TSink logging to the given test
+func NewTSink(t gotesting.TB) *TSink {
+	s := &TSink{t: t}
+	t.Cleanup(s.dump)
+	return s
+}
+
+// SetGauge sets a value on a gauge
+func (s *TSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *TSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.record("gauge", key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *TSink) EmitKey(key []string, val float32) {
+	s.record("key", key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *TSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *TSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.record("counter", key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *TSink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *TSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.record("counter", key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *TSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *TSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.record("sample", key, float64(val), labels)
+}
+
+// AssertLastGauge fails the test unless the last value the gauge was
+// set to, whatever its labels, is the given value
+func (s *TSink) AssertLastGauge(key []string, val float32) {
+	s.t.Helper()
+	s.assertLast("gauge", key, float64(val))
+}
+
+// AssertLastCounter fails the test unless the last increment of the
+// counter, whatever its labels, is the given value
+func (s *TSink) AssertLastCounter(key []string, val float64) {
+	s.t.Helper()
+	s.assertLast("counter", key, val)
+}
+
+func (s *TSink) assertLast(kind string, key []string, val float64) {
+	s.t.Helper()
+	name := strings.Join(key, ".")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.records) - 1; i >= 0; i-- {
+		r := s.records[i]
+		if r.kind != kind || r.key != name {
+			continue
+		}
+		if r.val != val {
+			s.t.Errorf("bad %s %s: got %v, expected %v", kind, name, r.val, val)
+		}
+		return
+	}
+	s.t.Errorf("no %s %s received", kind, name)
+}
+
+// Logs the metric and retains it for the assertions
+func (s *TSink) record(kind string, key []string, val float64, labels []metrics.Label) {
+	r := record{
+		kind:   kind,
+		key:    strings.Join(key, "."),
+		val:    val,
+		labels: append([]metrics.Label(nil), labels...),
+	}
+
+	s.mu.Lock()
+	s.records = append(s.records, r)
+	s.mu.Unlock()
+
+	s.t.Logf("metric: %s=%v labels=%v", r.key, r.val, r.labels)
+}
+
+// Logs every received metric, on test end
+func (s *TSink) dump() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d metrics received", len(s.records))
+	for _, r := range s.records {
+		b.WriteString("\n\t")
+		b.WriteString(r.String())
+	}
+	s.t.Log(b.String())
+}
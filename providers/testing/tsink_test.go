@@ -0,0 +1,79 @@
+//go:build go1.14
+// +build go1.14
+
+package testing
+
+import (
+	"fmt"
+	"strings"
+	gotesting "testing"
+
+	"github.com/hugoluchessi/go-metrics"
+)
+
+// recorder is a TB capturing logs and errors
+type recorder struct {
+	gotesting.TB
+	logs     []string
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func (r *recorder) Log(args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprint(args...))
+}
+
+func (r *recorder) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTSink(t *gotesting.T) {
+	r := &recorder{}
+	s := NewTSink(r)
+
+	s.SetGaugeWithLabels([]string{"foo", "bar"}, 42, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 1)
+	s.IncrCounterFloat64([]string{"counter"}, 2.5)
+	s.AddSample([]string{"sample"}, 3)
+
+	if r.logs[0] != "metric: foo.bar=42 labels=[{a b}]" {
+		t.Fatalf("bad log: %s", r.logs[0])
+	}
+
+	s.AssertLastGauge([]string{"foo", "bar"}, 42)
+	s.AssertLastCounter([]string{"counter"}, 2.5)
+	if len(r.errors) != 0 {
+		t.Fatalf("bad errors: %v", r.errors)
+	}
+
+	s.AssertLastCounter([]string{"counter"}, 1)
+	s.AssertLastGauge([]string{"missing"}, 1)
+	if len(r.errors) != 2 || !strings.Contains(r.errors[1], "no gauge missing") {
+		t.Fatalf("bad errors: %v", r.errors)
+	}
+
+	if len(r.cleanups) != 1 {
+		t.Fatalf("bad cleanups: %d", len(r.cleanups))
+	}
+	r.cleanups[0]()
+	dump := r.logs[len(r.logs)-1]
+	if !strings.HasPrefix(dump, "4 metrics received") || !strings.Contains(dump, "sample sample=3") {
+		t.Fatalf("bad dump: %s", dump)
+	}
+}
+
+func TestTSink_T(t *gotesting.T) {
+	s := NewTSink(t)
+	s.SetGauge([]string{"foo"}, 1)
+	s.AssertLastGauge([]string{"foo"}, 1)
+}
@@ -0,0 +1,207 @@
+package vercel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultEndpoint is the Speed Insights vitals endpoint
+	DefaultEndpoint = "https://vitals.vercel-insights.com/v1/vitals"
+
+	// DefaultFlushInterval is the interval metrics are pushed at
+	DefaultFlushInterval = 30 * time.Second
+
+	gaugeType   = "gauge"
+	counterType = "counter"
+	sampleType  = "sample"
+)
+
+// Sink provides a MetricSink that pushes custom metrics to the Vercel
+// Speed Insights API, encoded as a JSON array. Rate limited (429) pushes
+// are retried with exponential back-off, honoring Retry-After.
+type Sink struct {
+	projectID string
+	config    push.Config
+	client    *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithEndpoint overrides DefaultEndpoint
+func WithEndpoint(endpoint string) Option {
+	return func(s *Sink) {
+		s.config.URL = endpoint
+	}
+}
+
+// WithFlushInterval sets the interval metrics are pushed at,
+// defaults to DefaultFlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// metric is the Speed Insights representation of a custom metric
+type metric struct {
+	ProjectID string            `json:"projectId"`
+	EventName string            `json:"event_name"`
+	Type      string            `json:"type"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// NewSink is used to create a new Sink pushing the metrics of the
+// given project, authenticated with the API key
+func NewSink(apiKey, projectID string, opts ...Option) (*Sink, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("api key must be provided")
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("project id must be provided")
+	}
+
+	s := &Sink{
+		projectID: projectID,
+		config: push.Config{
+			Name:          "vercel",
+			URL:           DefaultEndpoint,
+			ContentType:   "application/json",
+			Header:        http.Header{"Authorization": {"Bearer " + apiKey}},
+			FlushInterval: DefaultFlushInterval,
+			Encode:        encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(gaugeType, key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric, sent as a gauge
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(gaugeType, key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(counterType, key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(counterType, key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(sampleType, key, float64(val), labels)
+}
+
+func (s *Sink) push(metricType string, key []string, val float64, labels []metrics.Label) {
+	m := metric{
+		ProjectID: s.projectID,
+		EventName: s.flattenKey(key),
+		Type:      metricType,
+		Value:     val,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if len(labels) > 0 {
+		m.Tags = make(map[string]string, len(labels))
+		for _, label := range labels {
+			m.Tags[label.Name] = label.Value
+		}
+	}
+	s.client.Push(m)
+}
+
+// Flattens the key for formatting, removes spaces
+func (s *Sink) flattenKey(parts []string) string {
+	return strings.Replace(strings.Join(parts, "."), " ", "_", -1)
+}
+
+// encode formats the metrics as a JSON array
+func encode(records []interface{}) ([]byte, error) {
+	return json.Marshal(records)
+}
@@ -0,0 +1,144 @@
+package vercel
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("", "project"); err == nil {
+		t.Fatalf("expected error for missing api key")
+	}
+	if _, err := NewSink("key", ""); err == nil {
+		t.Fatalf("expected error for missing project id")
+	}
+}
+
+func TestNewSink_Defaults(t *testing.T) {
+	s, err := NewSink("key", "project")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	if s.config.URL != DefaultEndpoint || s.config.FlushInterval != 30*time.Second {
+		t.Fatalf("bad config %v", s.config)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var auth string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("key", "project", WithEndpoint(srv.URL), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge", "val"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.EmitKey([]string{"key"}, 2)
+	s.IncrCounter([]string{"counter"}, 3)
+	s.AddSample([]string{"sample"}, 4)
+	s.Flush()
+
+	if auth != "Bearer key" {
+		t.Fatalf("bad authorization %s", auth)
+	}
+
+	var got []metric
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("bad body %s: %v", body, err)
+	}
+
+	expected := []metric{
+		{EventName: "gauge.val", Type: "gauge", Value: 1, Tags: map[string]string{"a": "b"}},
+		{EventName: "key", Type: "gauge", Value: 2},
+		{EventName: "counter", Type: "counter", Value: 3},
+		{EventName: "sample", Type: "sample", Value: 4},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d metrics got %d", len(expected), len(got))
+	}
+	for i, e := range expected {
+		m := got[i]
+		if m.EventName != e.EventName || m.Type != e.Type || m.Value != e.Value || m.ProjectID != "project" || m.Timestamp == 0 {
+			t.Fatalf("expected %v got %v", e, m)
+		}
+		if len(m.Tags) != len(e.Tags) || m.Tags["a"] != e.Tags["a"] {
+			t.Fatalf("bad tags %v", m.Tags)
+		}
+	}
+}
+
+func TestSink_RateLimited(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, time.Now())
+		if len(attempts) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("key", "project", WithEndpoint(srv.URL), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 3 {
+		t.Fatalf("bad attempts %d", len(attempts))
+	}
+	// The wait doubles after each rate limited attempt
+	if first, second := attempts[1].Sub(attempts[0]), attempts[2].Sub(attempts[1]); second < first+first/2 {
+		t.Fatalf("expected exponential back-off, waited %s then %s", first, second)
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("key", "project", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink("key", "project", WithEndpoint(srv.URL), WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
@@ -0,0 +1,217 @@
+package warp10
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/internal/push"
+)
+
+const (
+	// DefaultFlushInterval is the interval metrics are pushed at
+	DefaultFlushInterval = 10 * time.Second
+
+	// updatePath is the path of the ingestion endpoint
+	updatePath = "/api/v0/update"
+
+	// tokenHeader is the header holding the write token
+	tokenHeader = "X-Warp10-Token"
+)
+
+// Sink provides a MetricSink that pushes metrics to Warp 10, formatted
+// as GTS input lines ("<timestamp>// <classname>{<labels>} <value>").
+// Timestamps are in microseconds, the default time unit of Warp 10, and
+// counters are sent as their increments.
+type Sink struct {
+	config push.Config
+	client *push.Client
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithFlushInterval sets the interval metrics are pushed at,
+// defaults to DefaultFlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(s *Sink) {
+		s.config.FlushInterval = interval
+	}
+}
+
+// WithBatchSize sets the maximum number of metrics in a single request
+func WithBatchSize(size int) Option {
+	return func(s *Sink) {
+		s.config.BatchSize = size
+	}
+}
+
+// WithMaxRetries sets how many times a failed push is retried
+func WithMaxRetries(retries int) Option {
+	return func(s *Sink) {
+		s.config.MaxRetries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to push metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sink) {
+		s.config.HTTPClient = client
+	}
+}
+
+// WithHMACSignature signs the requests with an HMAC, see the signature package
+func WithHMACSignature(secret []byte, algorithm string) Option {
+	return func(s *Sink) { s.config.SetHMACSignature(secret, algorithm) }
+}
+
+// WithSignatureEncoding sets the signature encoding, see the signature package
+func WithSignatureEncoding(encoding string) Option {
+	return func(s *Sink) { s.config.SetSignatureEncoding(encoding) }
+}
+
+// NewSink is used to create a new Sink pushing to the Warp 10 instance at
+// the given endpoint, ex: "https://warp10.example.com", authenticated with
+// the write token. The /api/v0/update path is appended to the endpoint.
+func NewSink(endpoint, token string, opts ...Option) (*Sink, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("endpoint must be provided")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("write token must be provided")
+	}
+
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, updatePath) {
+		url += updatePath
+	}
+
+	s := &Sink{
+		config: push.Config{
+			Name:          "warp10",
+			URL:           url,
+			ContentType:   "text/plain",
+			Header:        http.Header{tokenHeader: {token}},
+			FlushInterval: DefaultFlushInterval,
+			Encode:        encode,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	client, err := push.New(s.config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return s, nil
+}
+
+// Flush pushes all the pending metrics
+func (s *Sink) Flush() {
+	s.client.Flush()
+}
+
+// Shutdown is used to push the pending metrics and stop pushing
+func (s *Sink) Shutdown() {
+	s.client.Shutdown()
+}
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, float64(val), labels)
+}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {
+	s.push(key, float64(val), nil)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {
+	s.push(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.push(key, float64(val), labels)
+}
+
+func (s *Sink) push(key []string, val float64, labels []metrics.Label) {
+	s.client.Push(formatLine(time.Now(), key, val, labels))
+}
+
+// formatLine formats a metric as a GTS input line, without location
+// and elevation
+func formatLine(t time.Time, key []string, val float64, labels []metrics.Label) string {
+	var b strings.Builder
+	b.WriteString(strconv.FormatInt(t.UnixNano()/int64(time.Microsecond), 10))
+	b.WriteString("// ")
+	b.WriteString(escape(strings.Join(key, ".")))
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(escape(label.Name))
+		b.WriteByte('=')
+		b.WriteString(escape(label.Value))
+	}
+	b.WriteString("} ")
+	b.WriteString(strconv.FormatFloat(val, 'g', -1, 64))
+	return b.String()
+}
+
+// escape percent-encodes the characters of the GTS input format found
+// in class names, label names and values
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '%', ',', '{', '}', '=', ' ', '\n', '\r', '\t':
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// encode formats the metrics as newline separated GTS input lines
+func encode(records []interface{}) ([]byte, error) {
+	var b strings.Builder
+	for _, record := range records {
+		b.WriteString(record.(string))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,113 @@
+package warp10
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoluchessi/go-metrics"
+	"github.com/hugoluchessi/go-metrics/providers/signature"
+)
+
+func TestNewSink_Invalid(t *testing.T) {
+	if _, err := NewSink("", "token"); err == nil {
+		t.Fatalf("expected error for missing endpoint")
+	}
+	if _, err := NewSink("http://localhost:8080", ""); err == nil {
+		t.Fatalf("expected error for missing token")
+	}
+}
+
+func TestNewSink_URL(t *testing.T) {
+	for _, endpoint := range []string{"http://localhost:8080", "http://localhost:8080/", "http://localhost:8080/api/v0/update"} {
+		s, err := NewSink(endpoint, "token")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		s.Shutdown()
+
+		if s.config.URL != "http://localhost:8080/api/v0/update" {
+			t.Fatalf("bad url %s", s.config.URL)
+		}
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	ts := time.Unix(1, 500000000)
+	line := formatLine(ts, []string{"http", "requests"}, 1.5, []metrics.Label{
+		{Name: "path", Value: "/a b"},
+		{Name: "q", Value: "x=1,y={2}%"},
+	})
+	expected := "1500000// http.requests{path=/a%20b,q=x%3D1%2Cy%3D%7B2%7D%25} 1.5"
+	if line != expected {
+		t.Fatalf("bad line %s", line)
+	}
+
+	if line := formatLine(ts, []string{"up"}, 1, nil); line != "1500000// up{} 1" {
+		t.Fatalf("bad line %s", line)
+	}
+}
+
+func TestSink(t *testing.T) {
+	var token, path string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Warp10-Token")
+		path = r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, "token", WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.SetGaugeWithLabels([]string{"gauge"}, 1, []metrics.Label{{Name: "a", Value: "b"}})
+	s.IncrCounter([]string{"counter"}, 2)
+	s.AddSample([]string{"sample"}, 3)
+	s.Flush()
+
+	if token != "token" || path != "/api/v0/update" {
+		t.Fatalf("bad request %s %s", token, path)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("bad body %s", body)
+	}
+	for i, suffix := range []string{"// gauge{a=b} 1", "// counter{} 2", "// sample{} 3"} {
+		if !strings.HasSuffix(lines[i], suffix) {
+			t.Fatalf("bad line %s", lines[i])
+		}
+	}
+}
+
+func TestNewSink_HMACSignature(t *testing.T) {
+	if _, err := NewSink("http://localhost", "token", WithHMACSignature([]byte("secret"), "md4")); err == nil {
+		t.Fatalf("expected error for unsupported algorithm")
+	}
+
+	var sig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig = r.Header.Get(signature.Header)
+	}))
+	defer srv.Close()
+
+	s, err := NewSink(srv.URL, "token", WithFlushInterval(time.Hour),
+		WithHMACSignature([]byte("secret"), signature.SHA256), WithSignatureEncoding(signature.Base64))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer s.Shutdown()
+
+	s.IncrCounter([]string{"counter"}, 1)
+	s.Flush()
+
+	if sig == "" {
+		t.Fatalf("missing signature")
+	}
+}
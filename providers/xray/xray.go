@@ -0,0 +1,149 @@
+package xray
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+const (
+	// DefaultNamespace is the metadata namespace metrics are added to
+	DefaultNamespace = "metrics"
+
+	gaugeType   = "gauge"
+	counterType = "counter"
+	sampleType  = "sample"
+	keyType     = "key"
+)
+
+// Sink provides a MetricSink that records metrics on the X-Ray segment
+// or subsegment found in the context, so they are searchable with the
+// trace. Each metric sets an annotation named after its key, holding the
+// last value recorded in the segment, and its labels are set as string
+// annotations. The metric type, value and labels are added as metadata
+// under the namespace. Calls without a segment in their context are ignored.
+type Sink struct {
+	namespace string
+}
+
+// Option is used to configure a Sink
+type Option func(*Sink)
+
+// WithNamespace sets the metadata namespace, defaults to DefaultNamespace
+func WithNamespace(namespace string) Option {
+	return func(s *Sink) {
+		s.namespace = namespace
+	}
+}
+
+// metadata is the representation of a metric in the segment metadata
+type metadata struct {
+	Type   string            `json:"type"`
+	Value  float64           `json:"value"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NewSink is used to create a new Sink. It implements
+// metrics.ContextSinker, use WithContext to get a metrics.Sinker
+// recording on the segment of a request.
+func NewSink(opts ...Option) *Sink {
+	s := &Sink{namespace: DefaultNamespace}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WithContext returns a sink recording the metrics on the segment of ctx
+func (s *Sink) WithContext(ctx context.Context) *metrics.ContextSink {
+	return metrics.NewContextSink(s, ctx)
+}
+
+// SetGaugeWithContext sets a value on a gauge
+func (s *Sink) SetGaugeWithContext(ctx context.Context, key []string, val float32, labels []metrics.Label) {
+	s.record(ctx, gaugeType, key, float64(val), labels)
+}
+
+// EmitKeyWithContext emits a key value metric
+func (s *Sink) EmitKeyWithContext(ctx context.Context, key []string, val float32) {
+	s.record(ctx, keyType, key, float64(val), nil)
+}
+
+// IncrCounterWithContext increases the value of a counter by a given value
+func (s *Sink) IncrCounterWithContext(ctx context.Context, key []string, val float64, labels []metrics.Label) {
+	s.record(ctx, counterType, key, val, labels)
+}
+
+// AddSampleWithContext adds a sample metrics
+func (s *Sink) AddSampleWithContext(ctx context.Context, key []string, val float32, labels []metrics.Label) {
+	s.record(ctx, sampleType, key, float64(val), labels)
+}
+
+// The following methods have no context, hence no segment, and are
+// ignored. They let the Sink be used where a metrics.Sinker is expected.
+
+// SetGauge sets a value on a gauge
+func (s *Sink) SetGauge(key []string, val float32) {}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *Sink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {}
+
+// EmitKey emits a key value metric
+func (s *Sink) EmitKey(key []string, val float32) {}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *Sink) IncrCounter(key []string, val float32) {}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *Sink) IncrCounterFloat64(key []string, val float64) {}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *Sink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []metrics.Label) {}
+
+// AddSample adds a sample metrics
+func (s *Sink) AddSample(key []string, val float32) {}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *Sink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {}
+
+// Adds the metric annotations and metadata to the segment of ctx
+func (s *Sink) record(ctx context.Context, metricType string, key []string, val float64, labels []metrics.Label) {
+	seg := xray.GetSegment(ctx)
+	if seg == nil {
+		return
+	}
+
+	name := strings.Join(key, ".")
+	m := metadata{Type: metricType, Value: val}
+	if err := seg.AddAnnotation(annotationKey(name), val); err != nil {
+		log.Printf("[ERR] Error annotating X-Ray segment with metric %s! Err: %s", name, err)
+		return
+	}
+	if len(labels) > 0 {
+		m.Labels = make(map[string]string, len(labels))
+		for _, label := range labels {
+			m.Labels[label.Name] = label.Value
+			seg.AddAnnotation(annotationKey(label.Name), label.Value)
+		}
+	}
+	seg.AddMetadataToNamespace(s.namespace, name, m)
+}
+
+// annotationKey replaces the characters not allowed in annotation keys,
+// which are made of letters, digits and underscores
+func annotationKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,52 @@
+package xray
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/hugoluchessi/go-metrics"
+)
+
+func TestSink(t *testing.T) {
+	s := NewSink(WithNamespace("app"))
+	ctx, seg := xray.BeginSegment(context.Background(), "test")
+
+	sink := s.WithContext(ctx)
+	sink.SetGaugeWithLabels([]string{"queue", "depth"}, 3, []metrics.Label{{Name: "queue-name", Value: "jobs"}})
+	sink.IncrCounter([]string{"requests"}, 1)
+	sink.IncrCounter([]string{"requests"}, 2)
+	sink.EmitKey([]string{"key"}, 4)
+	sink.AddSample([]string{"latency"}, 5)
+
+	seg.RLock()
+	defer seg.RUnlock()
+	for key, expected := range map[string]interface{}{
+		"queue_depth": float64(3),
+		"queue_name":  "jobs",
+		"requests":    float64(2),
+		"key":         float64(4),
+		"latency":     float64(5),
+	} {
+		if val := seg.Annotations[key]; val != expected {
+			t.Fatalf("bad annotation %s: %v", key, seg.Annotations)
+		}
+	}
+
+	m, ok := seg.Metadata["app"]["queue.depth"].(metadata)
+	if !ok || m.Type != gaugeType || m.Value != 3 || m.Labels["queue-name"] != "jobs" {
+		t.Fatalf("bad metadata: %v", seg.Metadata)
+	}
+	if m := seg.Metadata["app"]["requests"].(metadata); m.Type != counterType || m.Labels != nil {
+		t.Fatalf("bad metadata: %v", seg.Metadata)
+	}
+}
+
+func TestSink_NoSegment(t *testing.T) {
+	s := NewSink()
+	s.SetGaugeWithContext(context.Background(), []string{"foo"}, 1, nil)
+	s.SetGauge([]string{"foo"}, 1)
+
+	var _ metrics.Sinker = s
+	var _ metrics.ContextSinker = s
+}
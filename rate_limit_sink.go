@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultLimiterTTL is how long the limiter of a key unused is kept
+const DefaultLimiterTTL = 10 * time.Minute
+
+// RateLimitSink caps the rate of the calls forwarded to an inner sink,
+// ex: so a misbehaving service does not saturate its statsd agent. The
+// calls exceeding the limit are dropped, or wait for the limit with
+// WithBlocking. The limit is global, per key with WithPerKeyLimit, or
+// both when a global limiter is also given.
+type RateLimitSink struct {
+	dropped   int64 // accessed atomically, kept first for alignment
+	lastSweep int64 // accessed atomically, in unix nanoseconds
+
+	inner    Sinker
+	limiter  *rate.Limiter
+	blocking bool
+
+	perKey   bool
+	keyLimit rate.Limit
+	keyBurst int
+	ttl      time.Duration
+	limiters sync.Map // flattened key to *keyLimiter
+}
+
+// keyLimiter is the limiter of a key and the time it was last used
+type keyLimiter struct {
+	lastSeen int64 // accessed atomically, in unix nanoseconds
+	limiter  *rate.Limiter
+}
+
+// RateLimitOption is used to configure a RateLimitSink
+type RateLimitOption func(*RateLimitSink)
+
+// WithBlocking makes the calls exceeding the limit wait for it,
+// instead of being dropped
+func WithBlocking() RateLimitOption {
+	return func(s *RateLimitSink) {
+		s.blocking = true
+	}
+}
+
+// WithPerKeyLimit limits each key, without its labels, to its own rate
+// and burst, its limiter being created on first use
+func WithPerKeyLimit(limit rate.Limit, burst int) RateLimitOption {
+	return func(s *RateLimitSink) {
+		s.perKey = true
+		s.keyLimit = limit
+		s.keyBurst = burst
+	}
+}
+
+// WithLimiterTTL sets how long the limiter of a key unused is kept,
+// defaults to DefaultLimiterTTL
+func WithLimiterTTL(ttl time.Duration) RateLimitOption {
+	return func(s *RateLimitSink) {
+		s.ttl = ttl
+	}
+}
+
+// NewRateLimitSink creates a RateLimitSink, the limiter caps the rate of
+// all the calls, a nil limiter leaving them to the per key limits
+func NewRateLimitSink(inner Sinker, limiter *rate.Limiter, opts ...RateLimitOption) *RateLimitSink {
+	s := &RateLimitSink{
+		inner:     inner,
+		limiter:   limiter,
+		ttl:       DefaultLimiterTTL,
+		lastSweep: time.Now().UnixNano(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// DroppedTotal returns the number of calls dropped so far
+func (s *RateLimitSink) DroppedTotal() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// SetGauge sets a value on a gauge
+func (s *RateLimitSink) SetGauge(key []string, val float32) {
+	if s.allow(key) {
+		s.inner.SetGauge(key, val)
+	}
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *RateLimitSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	if s.allow(key) {
+		s.inner.SetGaugeWithLabels(key, val, labels)
+	}
+}
+
+// EmitKey emits a key value metric
+func (s *RateLimitSink) EmitKey(key []string, val float32) {
+	if s.allow(key) {
+		s.inner.EmitKey(key, val)
+	}
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *RateLimitSink) IncrCounter(key []string, val float32) {
+	if s.allow(key) {
+		s.inner.IncrCounter(key, val)
+	}
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *RateLimitSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	if s.allow(key) {
+		s.inner.IncrCounterWithLabels(key, val, labels)
+	}
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *RateLimitSink) IncrCounterFloat64(key []string, val float64) {
+	if s.allow(key) {
+		AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+	}
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *RateLimitSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	if s.allow(key) {
+		AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+	}
+}
+
+// AddSample adds a sample metrics
+func (s *RateLimitSink) AddSample(key []string, val float32) {
+	if s.allow(key) {
+		s.inner.AddSample(key, val)
+	}
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *RateLimitSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	if s.allow(key) {
+		s.inner.AddSampleWithLabels(key, val, labels)
+	}
+}
+
+// allow reports whether a call may be forwarded, waiting for the limits
+// when blocking, and counts the calls dropped. The per-key limit is checked
+// first, so the calls of a noisy key do not spend the global budget.
+func (s *RateLimitSink) allow(key []string) bool {
+	if (!s.perKey || s.take(s.keyLimiter(key))) && s.take(s.limiter) {
+		return true
+	}
+	atomic.AddInt64(&s.dropped, 1)
+	return false
+}
+
+// take takes a token from the limiter, a nil limiter not limiting
+func (s *RateLimitSink) take(limiter *rate.Limiter) bool {
+	if limiter == nil {
+		return true
+	}
+	if s.blocking {
+		// Fails only when the burst does not allow a single call
+		return limiter.Wait(context.Background()) == nil
+	}
+	return limiter.Allow()
+}
+
+// keyLimiter returns the limiter of the key, creating it on first use,
+// and evicts the limiters unused for the TTL
+func (s *RateLimitSink) keyLimiter(key []string) *rate.Limiter {
+	now := time.Now().UnixNano()
+	s.sweep(now)
+
+	k := strings.Join(key, ".")
+	v, ok := s.limiters.Load(k)
+	if !ok {
+		v, _ = s.limiters.LoadOrStore(k, &keyLimiter{
+			lastSeen: now,
+			limiter:  rate.NewLimiter(s.keyLimit, s.keyBurst),
+		})
+	}
+	l := v.(*keyLimiter)
+	atomic.StoreInt64(&l.lastSeen, now)
+	return l.limiter
+}
+
+// sweep evicts the limiters unused for the TTL, at most once per TTL
+func (s *RateLimitSink) sweep(now int64) {
+	last := atomic.LoadInt64(&s.lastSweep)
+	if now-last < int64(s.ttl) || !atomic.CompareAndSwapInt64(&s.lastSweep, last, now) {
+		return
+	}
+
+	s.limiters.Range(func(k, v interface{}) bool {
+		if now-atomic.LoadInt64(&v.(*keyLimiter).lastSeen) >= int64(s.ttl) {
+			s.limiters.Delete(k)
+		}
+		return true
+	})
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitSink_Global(t *testing.T) {
+	inner := &MockSink{}
+	s := NewRateLimitSink(inner, rate.NewLimiter(rate.Every(time.Hour), 3))
+
+	emitAll(s)
+	if len(inner.vals) != 3 || inner.vals[2] != 3 {
+		t.Fatalf("bad vals: %v", inner.vals)
+	}
+	if s.DroppedTotal() != 4 {
+		t.Fatalf("bad dropped total %d", s.DroppedTotal())
+	}
+}
+
+func TestRateLimitSink_PerKey(t *testing.T) {
+	inner := &MockFloat64Sink{}
+	s := NewRateLimitSink(inner, nil, WithPerKeyLimit(rate.Every(time.Hour), 1))
+
+	for i := 0; i < 3; i++ {
+		s.IncrCounter([]string{"noisy"}, 1)
+		s.SetGauge([]string{"quiet", "gauge"}, float32(i))
+	}
+	s.IncrCounterWithLabelsFloat64([]string{"other"}, 1, nil)
+
+	if len(inner.keys) != 3 || inner.keys[1][0] != "quiet" {
+		t.Fatalf("bad keys: %v", inner.keys)
+	}
+	if s.DroppedTotal() != 4 {
+		t.Fatalf("bad dropped total %d", s.DroppedTotal())
+	}
+}
+
+func TestRateLimitSink_PerKeyBeforeGlobal(t *testing.T) {
+	inner := &MockSink{}
+	global := rate.NewLimiter(rate.Every(time.Hour), 2)
+	s := NewRateLimitSink(inner, global, WithPerKeyLimit(rate.Every(time.Hour), 1))
+
+	// The calls dropped by the per-key limit leave the global budget
+	for i := 0; i < 5; i++ {
+		s.IncrCounter([]string{"noisy"}, 1)
+	}
+	s.IncrCounter([]string{"quiet"}, 1)
+
+	if len(inner.keys) != 2 || inner.keys[1][0] != "quiet" {
+		t.Fatalf("bad keys: %v", inner.keys)
+	}
+	if s.DroppedTotal() != 4 {
+		t.Fatalf("bad dropped total %d", s.DroppedTotal())
+	}
+}
+
+func TestRateLimitSink_Eviction(t *testing.T) {
+	s := NewRateLimitSink(&MockSink{}, nil, WithPerKeyLimit(rate.Every(time.Hour), 1), WithLimiterTTL(time.Minute))
+
+	s.IncrCounter([]string{"a"}, 1)
+	s.IncrCounter([]string{"b"}, 1)
+
+	// Age the limiter of "a" and the last sweep
+	v, _ := s.limiters.Load("a")
+	atomic.AddInt64(&v.(*keyLimiter).lastSeen, -int64(2*time.Minute))
+	atomic.AddInt64(&s.lastSweep, -int64(2*time.Minute))
+
+	s.IncrCounter([]string{"b"}, 1)
+	if _, ok := s.limiters.Load("a"); ok {
+		t.Fatalf("limiter of a must be evicted")
+	}
+	if _, ok := s.limiters.Load("b"); !ok {
+		t.Fatalf("limiter of b must be kept")
+	}
+
+	// A new limiter is created for an evicted key
+	s.IncrCounter([]string{"a"}, 1)
+	if s.DroppedTotal() != 1 {
+		t.Fatalf("bad dropped total %d", s.DroppedTotal())
+	}
+}
+
+func TestRateLimitSink_Blocking(t *testing.T) {
+	inner := &MockSink{}
+	s := NewRateLimitSink(inner, rate.NewLimiter(rate.Every(10*time.Millisecond), 1), WithBlocking())
+
+	start := time.Now()
+	s.IncrCounter([]string{"a"}, 1)
+	s.IncrCounter([]string{"a"}, 2)
+	if time.Since(start) < 5*time.Millisecond {
+		t.Fatalf("did not block")
+	}
+	if len(inner.vals) != 2 || s.DroppedTotal() != 0 {
+		t.Fatalf("bad vals: %v", inner.vals)
+	}
+
+	// A burst of 0 never allows a call
+	s = NewRateLimitSink(inner, rate.NewLimiter(rate.Every(time.Hour), 0), WithBlocking())
+	s.IncrCounter([]string{"a"}, 3)
+	if len(inner.vals) != 2 || s.DroppedTotal() != 1 {
+		t.Fatalf("bad vals: %v", inner.vals)
+	}
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Format is the format of a metrics stream read by SinkFromReader
+type Format int
+
+const (
+	// FormatStatsd reads statsd lines, with optional sample rates and
+	// DogStatsD tags, which become labels:
+	//
+	//	name:value|type|@rate|#label:value,label:value
+	//
+	// Gauges ("g") are set to the value, signed values included, counters
+	// ("c") are scaled up by their rate, timers, histograms and
+	// distributions ("ms", "h", "d") are samples and "kv" are key values.
+	FormatStatsd Format = iota
+)
+
+// SinkFromReader reads metrics from r in the given format, ex: a tailed
+// log file, emitting each one to the inner sink. The key of the metrics
+// is their name split on ".". Malformed lines are logged and skipped.
+// It returns once r is exhausted, with the error of r, if any.
+func SinkFromReader(r io.Reader, format Format, inner Sinker) error {
+	if format != FormatStatsd {
+		return fmt.Errorf("metrics: unknown format %d", format)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := emitStatsdLine(inner, line); err != nil {
+			log.Printf("[WARN] Skipping metric line %q: %s", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// emitStatsdLine parses a statsd line and emits its metric to the sink
+func emitStatsdLine(sink Sinker, line string) error {
+	sections := strings.Split(line, "|")
+	if len(sections) < 2 {
+		return fmt.Errorf("missing metric type")
+	}
+
+	sep := strings.LastIndex(sections[0], ":")
+	if sep <= 0 {
+		return fmt.Errorf("missing metric name or value")
+	}
+	key := strings.Split(sections[0][:sep], ".")
+	val, err := strconv.ParseFloat(sections[0][sep+1:], 64)
+	if err != nil {
+		return fmt.Errorf("invalid value: %s", err)
+	}
+
+	rate := 1.0
+	var labels []Label
+	for _, section := range sections[2:] {
+		switch {
+		case strings.HasPrefix(section, "@"):
+			rate, err = strconv.ParseFloat(section[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return fmt.Errorf("invalid sample rate %q", section[1:])
+			}
+		case strings.HasPrefix(section, "#"):
+			for _, tag := range strings.Split(section[1:], ",") {
+				name, value := tag, ""
+				if i := strings.Index(tag, ":"); i >= 0 {
+					name, value = tag[:i], tag[i+1:]
+				}
+				labels = append(labels, Label{Name: name, Value: value})
+			}
+		}
+	}
+
+	switch sections[1] {
+	case "g":
+		sink.SetGaugeWithLabels(key, float32(val), labels)
+	case "c":
+		AsFloat64Sink(sink).IncrCounterWithLabelsFloat64(key, val/rate, labels)
+	case "ms", "h", "d":
+		sink.AddSampleWithLabels(key, float32(val), labels)
+	case "kv":
+		sink.EmitKey(key, float32(val))
+	default:
+		return fmt.Errorf("unknown metric type %q", sections[1])
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSinkFromReader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	stream := strings.Join([]string{
+		"app.gauge:1|g",
+		"app.key:2|kv",
+		"app.counter:2|c|@0.5|#method:GET,canary",
+		"",
+		"app.timer:4|ms|#code:200",
+		"app.hist:5|h",
+		"malformed",
+		"app.bad:x|c",
+		"app.set:6|s",
+	}, "\n")
+
+	m := &MockFloat64Sink{}
+	if err := SinkFromReader(strings.NewReader(stream), FormatStatsd, m); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if !reflect.DeepEqual(m.keys, [][]string{{"app", "gauge"}, {"app", "key"}, {"app", "counter"}, {"app", "timer"}, {"app", "hist"}}) {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 4, 5}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.vals64, []float64{4}) {
+		t.Fatalf("bad counter %v", m.vals64)
+	}
+	if !reflect.DeepEqual(m.labels[2], []Label{{"method", "GET"}, {"canary", ""}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+	if !reflect.DeepEqual(m.labels[3], []Label{{"code", "200"}}) {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+	if n := strings.Count(buf.String(), "Skipping metric line"); n != 3 {
+		t.Fatalf("bad skipped lines %d: %s", n, buf.String())
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestSinkFromReader_Errors(t *testing.T) {
+	if err := SinkFromReader(errReader{}, FormatStatsd, &MockSink{}); err == nil || err.Error() != "read failed" {
+		t.Fatalf("bad err %v", err)
+	}
+	if err := SinkFromReader(strings.NewReader(""), Format(42), &MockSink{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MetricType is the type of a described metric
+type MetricType int
+
+const (
+	// GaugeMetric is a metric set with SetGauge
+	GaugeMetric MetricType = iota
+
+	// CounterMetric is a metric increased with IncrCounter
+	CounterMetric
+
+	// SampleMetric is a metric added with AddSample or MeasureSince
+	SampleMetric
+
+	// KeyMetric is a metric emitted with EmitKey
+	KeyMetric
+)
+
+// String returns the name of the metric type
+func (t MetricType) String() string {
+	switch t {
+	case GaugeMetric:
+		return "gauge"
+	case CounterMetric:
+		return "counter"
+	case SampleMetric:
+		return "sample"
+	case KeyMetric:
+		return "key"
+	default:
+		return fmt.Sprintf("MetricType(%d)", int(t))
+	}
+}
+
+// Descriptor declares a metric upfront, so sinks supporting metadata
+// can export its help text and unit along with the values
+type Descriptor struct {
+	// Name is the key the metric is emitted with, as received by
+	// the sinks: including the service name or hostname prefixes
+	Name []string
+
+	// Type is the type of the metric
+	Type MetricType
+
+	// Unit is the unit of the values, ex: "ms" or "bytes"
+	Unit string
+
+	// Help describes the metric
+	Help string
+
+	// Labels are added to every value of the metric, the labels
+	// given when emitting take precedence over these
+	Labels []Label
+}
+
+// Registry stores the descriptors of the declared metrics.
+// It is safe for concurrent use.
+type Registry struct {
+	mu          sync.RWMutex
+	descriptors map[string]Descriptor
+}
+
+// DefaultRegistry is the registry used by Register and read by the sinks
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{descriptors: make(map[string]Descriptor)}
+}
+
+// Register stores the descriptor. Registering a name again with the same
+// type keeps the first descriptor, a different type returns an error.
+func (r *Registry) Register(desc Descriptor) error {
+	if len(desc.Name) == 0 {
+		return fmt.Errorf("metric descriptor has no name")
+	}
+
+	name := registryKey(desc.Name)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.descriptors[name]; ok {
+		if existing.Type != desc.Type {
+			return fmt.Errorf("metric %s already registered as a %s, not a %s",
+				strings.Join(desc.Name, "."), existing.Type, desc.Type)
+		}
+		return nil
+	}
+
+	desc.Name = append([]string(nil), desc.Name...)
+	desc.Labels = append([]Label(nil), desc.Labels...)
+	r.descriptors[name] = desc
+	return nil
+}
+
+// Lookup returns the descriptor registered for the key
+func (r *Registry) Lookup(key []string) (Descriptor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	desc, ok := r.descriptors[registryKey(key)]
+	return desc, ok
+}
+
+// Descriptors returns all the registered descriptors, sorted by name
+func (r *Registry) Descriptors() []Descriptor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.descriptors))
+	for name := range r.descriptors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	descs := make([]Descriptor, 0, len(names))
+	for _, name := range names {
+		descs = append(descs, r.descriptors[name])
+	}
+	return descs
+}
+
+// Register stores the descriptor on the DefaultRegistry
+func Register(desc Descriptor) error {
+	return DefaultRegistry.Register(desc)
+}
+
+// MustRegister stores the descriptor on the DefaultRegistry, panicking on error
+func MustRegister(desc Descriptor) {
+	if err := Register(desc); err != nil {
+		panic(err)
+	}
+}
+
+// LookupDescriptor returns the descriptor registered for the key on the DefaultRegistry
+func LookupDescriptor(key []string) (Descriptor, bool) {
+	return DefaultRegistry.Lookup(key)
+}
+
+// MergeLabels returns the descriptor default labels followed by the given
+// labels, dropping the defaults overridden by a label of the same name
+func (d Descriptor) MergeLabels(labels []Label) []Label {
+	if len(d.Labels) == 0 {
+		return labels
+	}
+
+	merged := make([]Label, 0, len(d.Labels)+len(labels))
+DEFAULTS:
+	for _, def := range d.Labels {
+		for _, label := range labels {
+			if label.Name == def.Name {
+				continue DEFAULTS
+			}
+		}
+		merged = append(merged, def)
+	}
+	return append(merged, labels...)
+}
+
+// registryKey joins the key parts, "\x00" can not be part of a metric name
+func registryKey(key []string) string {
+	return strings.Join(key, "\x00")
+}
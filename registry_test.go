@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+
+	desc := Descriptor{
+		Name:   []string{"db", "queries"},
+		Type:   CounterMetric,
+		Unit:   "queries",
+		Help:   "Number of database queries",
+		Labels: []Label{{Name: "db", Value: "main"}},
+	}
+	if err := r.Register(desc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	got, ok := r.Lookup([]string{"db", "queries"})
+	if !ok || !reflect.DeepEqual(got, desc) {
+		t.Fatalf("bad descriptor %#v", got)
+	}
+	if _, ok := r.Lookup([]string{"db.queries"}); ok {
+		t.Fatalf("key parts must not be joined")
+	}
+
+	// Same type keeps the first registration
+	if err := r.Register(Descriptor{Name: []string{"db", "queries"}, Type: CounterMetric, Help: "other"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if got, _ := r.Lookup([]string{"db", "queries"}); got.Help != desc.Help {
+		t.Fatalf("bad help %s", got.Help)
+	}
+
+	// Mismatched type is an error
+	if err := r.Register(Descriptor{Name: []string{"db", "queries"}, Type: GaugeMetric}); err == nil {
+		t.Fatalf("expected type mismatch error")
+	}
+
+	if err := r.Register(Descriptor{Type: GaugeMetric}); err == nil {
+		t.Fatalf("expected missing name error")
+	}
+}
+
+func TestRegistry_Descriptors(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Descriptor{Name: []string{"b"}, Type: GaugeMetric})
+	r.Register(Descriptor{Name: []string{"a"}, Type: SampleMetric})
+
+	descs := r.Descriptors()
+	if len(descs) != 2 || descs[0].Name[0] != "a" || descs[1].Name[0] != "b" {
+		t.Fatalf("bad descriptors %v", descs)
+	}
+}
+
+func TestRegister_Default(t *testing.T) {
+	defer func(r *Registry) { DefaultRegistry = r }(DefaultRegistry)
+	DefaultRegistry = NewRegistry()
+
+	MustRegister(Descriptor{Name: []string{"requests"}, Type: CounterMetric})
+	if _, ok := LookupDescriptor([]string{"requests"}); !ok {
+		t.Fatalf("missing descriptor")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic")
+		}
+	}()
+	MustRegister(Descriptor{Name: []string{"requests"}, Type: GaugeMetric})
+}
+
+func TestDescriptor_MergeLabels(t *testing.T) {
+	desc := Descriptor{Labels: []Label{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}}
+
+	merged := desc.MergeLabels([]Label{{Name: "b", Value: "3"}, {Name: "c", Value: "4"}})
+	expected := []Label{{Name: "a", Value: "1"}, {Name: "b", Value: "3"}, {Name: "c", Value: "4"}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("bad labels %v", merged)
+	}
+
+	labels := []Label{{Name: "c", Value: "4"}}
+	if merged := (Descriptor{}).MergeLabels(labels); !reflect.DeepEqual(merged, labels) {
+		t.Fatalf("bad labels %v", merged)
+	}
+}
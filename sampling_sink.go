@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// RateAnnotator is implemented by sinks able to send the rate a metric
+// was sampled at, so the backend scales it up, ex: the statsd "|@rate"
+// suffix. Gauges need no scaling and are not annotated.
+type RateAnnotator interface {
+	IncrCounterWithRate(key []string, val float64, labels []Label, rate float64)
+	AddSampleWithRate(key []string, val float32, labels []Label, rate float64)
+}
+
+// SamplingSink forwards a random fraction of the metrics to an inner
+// sink, following the rate of their type, to reduce the load of high
+// volume metrics on the backends
+type SamplingSink struct {
+	inner    Sinker
+	rates    [KeyMetric + 1]float64
+	annotate bool
+}
+
+// SamplingOption is used to configure a SamplingSink
+type SamplingOption func(*SamplingSink)
+
+// WithTypeRate sets the rate of the metrics of the given type,
+// overriding the rate of the sink
+func WithTypeRate(metricType MetricType, rate float64) SamplingOption {
+	return func(s *SamplingSink) {
+		if metricType >= GaugeMetric && metricType <= KeyMetric {
+			s.rates[metricType] = rate
+		}
+	}
+}
+
+// WithRateAnnotation sends the rate of the sampled counters and samples
+// when the inner sink implements RateAnnotator
+func WithRateAnnotation() SamplingOption {
+	return func(s *SamplingSink) {
+		s.annotate = true
+	}
+}
+
+// NewSamplingSink creates a SamplingSink forwarding metrics at the given
+// rate, in (0, 1], 1 forwarding every metric
+func NewSamplingSink(inner Sinker, rate float64, opts ...SamplingOption) (*SamplingSink, error) {
+	s := &SamplingSink{inner: inner}
+	for i := range s.rates {
+		s.rates[i] = rate
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for i, r := range s.rates {
+		if r <= 0 || r > 1 {
+			return nil, fmt.Errorf("metrics: %s sampling rate must be in (0, 1], got %v", MetricType(i), r)
+		}
+	}
+	return s, nil
+}
+
+// Rate returns the sampling rate of the metrics of the given type
+func (s *SamplingSink) Rate(metricType MetricType) float64 {
+	if metricType < GaugeMetric || metricType > KeyMetric {
+		return 1
+	}
+	return s.rates[metricType]
+}
+
+// SetGauge sets a value on a gauge
+func (s *SamplingSink) SetGauge(key []string, val float32) {
+	if s.sampled(GaugeMetric) {
+		s.inner.SetGauge(key, val)
+	}
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *SamplingSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	if s.sampled(GaugeMetric) {
+		s.inner.SetGaugeWithLabels(key, val, labels)
+	}
+}
+
+// EmitKey emits a key value metric
+func (s *SamplingSink) EmitKey(key []string, val float32) {
+	if s.sampled(KeyMetric) {
+		s.inner.EmitKey(key, val)
+	}
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *SamplingSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), nil)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *SamplingSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.IncrCounterWithLabelsFloat64(key, float64(val), labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *SamplingSink) IncrCounterFloat64(key []string, val float64) {
+	s.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *SamplingSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	if !s.sampled(CounterMetric) {
+		return
+	}
+	rate := s.rates[CounterMetric]
+	if ra, ok := s.inner.(RateAnnotator); ok && s.annotate && rate < 1 {
+		ra.IncrCounterWithRate(key, val, labels, rate)
+		return
+	}
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *SamplingSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *SamplingSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	if !s.sampled(SampleMetric) {
+		return
+	}
+	rate := s.rates[SampleMetric]
+	if ra, ok := s.inner.(RateAnnotator); ok && s.annotate && rate < 1 {
+		ra.AddSampleWithRate(key, val, labels, rate)
+		return
+	}
+	s.inner.AddSampleWithLabels(key, val, labels)
+}
+
+// sampled draws whether a metric of the given type is forwarded
+func (s *SamplingSink) sampled(metricType MetricType) bool {
+	rate := s.rates[metricType]
+	return rate >= 1 || rand.Float64() < rate
+}
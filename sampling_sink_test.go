@@ -0,0 +1,93 @@
+package metrics
+
+import "testing"
+
+// MockRateSink records the rates passed to the RateAnnotator methods
+type MockRateSink struct {
+	MockSink
+	rates []float64
+}
+
+func (m *MockRateSink) IncrCounterWithRate(key []string, val float64, labels []Label, rate float64) {
+	m.rates = append(m.rates, rate)
+	m.IncrCounterWithLabels(key, float32(val), labels)
+}
+func (m *MockRateSink) AddSampleWithRate(key []string, val float32, labels []Label, rate float64) {
+	m.rates = append(m.rates, rate)
+	m.AddSampleWithLabels(key, val, labels)
+}
+
+func TestSamplingSink(t *testing.T) {
+	m := &MockSink{}
+	s, err := NewSamplingSink(m, 1)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	emitAll(s)
+	s.IncrCounterFloat64([]string{"counter"}, 8)
+	if len(m.vals) != 8 {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if m.labels[1][0].Value != "b" {
+		t.Fatalf("bad labels %v", m.labels)
+	}
+}
+
+func TestSamplingSink_TypeRates(t *testing.T) {
+	m := &MockSink{}
+	s, err := NewSamplingSink(m, 0.5, WithTypeRate(GaugeMetric, 1), WithTypeRate(SampleMetric, 0.1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s.Rate(GaugeMetric) != 1 || s.Rate(CounterMetric) != 0.5 || s.Rate(SampleMetric) != 0.1 || s.Rate(KeyMetric) != 0.5 {
+		t.Fatalf("bad rates %v", s.rates)
+	}
+
+	for i := 0; i < 1000; i++ {
+		s.SetGauge([]string{"gauge"}, 1)
+	}
+	if len(m.vals) != 1000 {
+		t.Fatalf("bad gauges %d", len(m.vals))
+	}
+
+	m.vals = nil
+	for i := 0; i < 1000; i++ {
+		s.IncrCounter([]string{"counter"}, 1)
+	}
+	if n := len(m.vals); n < 400 || n > 600 {
+		t.Fatalf("bad counters %d", n)
+	}
+
+	for _, rate := range []float64{0, -1, 1.5} {
+		if _, err := NewSamplingSink(m, rate); err == nil {
+			t.Fatalf("expected error for rate %v", rate)
+		}
+	}
+	if _, err := NewSamplingSink(m, 1, WithTypeRate(CounterMetric, 0)); err == nil {
+		t.Fatalf("expected error for counter rate")
+	}
+}
+
+func TestSamplingSink_RateAnnotation(t *testing.T) {
+	m := &MockRateSink{}
+	s, _ := NewSamplingSink(m, 0.999999, WithRateAnnotation(), WithTypeRate(GaugeMetric, 0.999999))
+	for i := 0; i < 10; i++ {
+		s.IncrCounter([]string{"counter"}, 1)
+		s.AddSample([]string{"sample"}, 1)
+		s.SetGauge([]string{"gauge"}, 1)
+	}
+	if len(m.rates) < 18 || m.rates[0] != 0.999999 {
+		t.Fatalf("bad rates %v", m.rates)
+	}
+	if len(m.vals) < 27 {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+
+	// Without the option the rate is not sent
+	m = &MockRateSink{}
+	s, _ = NewSamplingSink(m, 0.999999)
+	s.IncrCounter([]string{"counter"}, 1)
+	if len(m.rates) != 0 {
+		t.Fatalf("bad rates %v", m.rates)
+	}
+}
@@ -1,5 +1,13 @@
 package metrics
 
+import "errors"
+
+// ErrQueueFull is returned by sinks which failed to queue a metric
+var ErrQueueFull = errors.New("metrics: queue is full")
+
+// ErrSinkShutdown is returned by sinks which were shut down
+var ErrSinkShutdown = errors.New("metrics: sink is shut down")
+
 // Sinker interface is used to transmit metrics information
 // to an external system
 type Sinker interface {
@@ -19,6 +27,15 @@ type Sinker interface {
 	AddSampleWithLabels(key []string, val float32, labels []Label)
 }
 
+// FallibleSinker is implemented by sinks which are able to report
+// metrics they failed to deliver, ex: when their queue is full
+type FallibleSinker interface {
+	TrySetGaugeWithLabels(key []string, val float32, labels []Label) error
+	TryEmitKey(key []string, val float32) error
+	TryIncrCounterWithLabels(key []string, val float32, labels []Label) error
+	TryAddSampleWithLabels(key []string, val float32, labels []Label) error
+}
+
 // BlackholeSink is used to just blackhole messages
 type BlackholeSink struct{}
 
@@ -37,6 +54,12 @@ func (*BlackholeSink) IncrCounter(key []string, val float32) {}
 // IncrCounterWithLabels increases the value of a counter by a given value with labels
 func (*BlackholeSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {}
 
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (*BlackholeSink) IncrCounterFloat64(key []string, val float64) {}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (*BlackholeSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {}
+
 // AddSample adds a sample metrics
 func (*BlackholeSink) AddSample(key []string, val float32) {}
 
@@ -77,6 +100,18 @@ func (fh FanoutSink) IncrCounterWithLabels(key []string, val float32, labels []L
 	}
 }
 
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (fh FanoutSink) IncrCounterFloat64(key []string, val float64) {
+	fh.IncrCounterWithLabelsFloat64(key, val, nil)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (fh FanoutSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	for _, s := range fh {
+		AsFloat64Sink(s).IncrCounterWithLabelsFloat64(key, val, labels)
+	}
+}
+
 // AddSample adds a sample metrics
 func (fh FanoutSink) AddSample(key []string, val float32) {
 	fh.AddSampleWithLabels(key, val, nil)
@@ -88,3 +123,11 @@ func (fh FanoutSink) AddSampleWithLabels(key []string, val float32, labels []Lab
 		s.AddSampleWithLabels(key, val, labels)
 	}
 }
+
+// AddSampleWithExemplar adds a sample metrics with an exemplar, on the
+// sinks implementing ExemplarSink, the others receive the sample alone
+func (fh FanoutSink) AddSampleWithExemplar(key []string, val float32, exemplar map[string]string) {
+	for _, s := range fh {
+		AddSampleWithExemplar(s, key, val, exemplar)
+	}
+}
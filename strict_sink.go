@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// StrictEnv is the environment variable enabling StrictFromEnv
+const StrictEnv = "METRICS_STRICT"
+
+// StrictError describes a metric misuse detected by StrictSink
+type StrictError struct {
+	Method string
+	Key    []string
+	Reason string
+}
+
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("metrics: %s(%v): %s", e.Method, e.Key, e.Reason)
+}
+
+// StrictSink panics on structural misuse of metrics, which usually
+// indicates a programming error: empty keys, duplicated label names
+// and NaN or infinite values. It is meant to be used during development,
+// use RecoverStrict to convert the panics into errors.
+type StrictSink struct {
+	inner Sinker
+
+	// RequireLabels makes the *WithLabels methods panic when called with
+	// nil labels. It is disabled by default since MetricService forwards
+	// calls without labels to the *WithLabels methods.
+	RequireLabels bool
+}
+
+// NewStrictSink creates a StrictSink validating calls before
+// forwarding them to the inner sink
+func NewStrictSink(inner Sinker) *StrictSink {
+	return &StrictSink{inner: inner}
+}
+
+// StrictFromEnv wraps the sink with a StrictSink when the
+// METRICS_STRICT environment variable is set to 1
+func StrictFromEnv(inner Sinker) Sinker {
+	if os.Getenv(StrictEnv) == "1" {
+		return NewStrictSink(inner)
+	}
+	return inner
+}
+
+// RecoverStrict calls fn, returning the StrictError it panicked with, if any.
+// Panics not caused by a StrictSink are propagated.
+func RecoverStrict(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			serr, ok := r.(*StrictError)
+			if !ok {
+				panic(r)
+			}
+			err = serr
+		}
+	}()
+	fn()
+	return nil
+}
+
+// SetGauge sets a value on a gauge
+func (s *StrictSink) SetGauge(key []string, val float32) {
+	s.check("SetGauge", key, float64(val))
+	s.inner.SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *StrictSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.checkLabels("SetGaugeWithLabels", key, float64(val), labels)
+	s.inner.SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *StrictSink) EmitKey(key []string, val float32) {
+	s.check("EmitKey", key, float64(val))
+	s.inner.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *StrictSink) IncrCounter(key []string, val float32) {
+	s.check("IncrCounter", key, float64(val))
+	s.inner.IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *StrictSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.checkLabels("IncrCounterWithLabels", key, float64(val), labels)
+	s.inner.IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *StrictSink) IncrCounterFloat64(key []string, val float64) {
+	s.check("IncrCounterFloat64", key, val)
+	AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *StrictSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	s.checkLabels("IncrCounterWithLabelsFloat64", key, val, labels)
+	AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *StrictSink) AddSample(key []string, val float32) {
+	s.check("AddSample", key, float64(val))
+	s.inner.AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *StrictSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.checkLabels("AddSampleWithLabels", key, float64(val), labels)
+	s.inner.AddSampleWithLabels(key, val, labels)
+}
+
+func (s *StrictSink) check(method string, key []string, val float64) {
+	if len(key) == 0 {
+		panic(&StrictError{Method: method, Key: key, Reason: "empty key"})
+	}
+
+	if math.IsNaN(val) {
+		panic(&StrictError{Method: method, Key: key, Reason: "value is NaN"})
+	}
+	if math.IsInf(val, 0) {
+		panic(&StrictError{Method: method, Key: key, Reason: fmt.Sprintf("value is %v", val)})
+	}
+}
+
+func (s *StrictSink) checkLabels(method string, key []string, val float64, labels []Label) {
+	s.check(method, key, val)
+
+	if labels == nil && s.RequireLabels {
+		panic(&StrictError{Method: method, Key: key, Reason: "nil labels"})
+	}
+
+	seen := make(map[string]struct{}, len(labels))
+	for _, label := range labels {
+		if _, ok := seen[label.Name]; ok {
+			panic(&StrictError{Method: method, Key: key, Reason: fmt.Sprintf("duplicate label %q", label.Name)})
+		}
+		seen[label.Name] = struct{}{}
+	}
+}
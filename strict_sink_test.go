@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStrictSink_Valid(t *testing.T) {
+	m := &MockSink{}
+	s := NewStrictSink(m)
+
+	err := RecoverStrict(func() {
+		s.SetGauge([]string{"key"}, 1)
+		s.SetGaugeWithLabels([]string{"key"}, 1, []Label{{"a", "b"}, {"c", "d"}})
+		s.EmitKey([]string{"key"}, 1)
+		s.IncrCounter([]string{"key"}, 1)
+		s.IncrCounterWithLabels([]string{"key"}, 1, nil)
+		s.AddSample([]string{"key"}, 1)
+		s.AddSampleWithLabels([]string{"key"}, 1, []Label{})
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(m.keys) != 7 {
+		t.Fatalf("calls were not forwarded %v", m.keys)
+	}
+}
+
+func TestStrictSink_Misuse(t *testing.T) {
+	inf := float32(math.Inf(1))
+	nan := float32(math.NaN())
+
+	cases := []struct {
+		Fn     func(s *StrictSink)
+		Reason string
+	}{
+		{func(s *StrictSink) { s.SetGauge(nil, 1) }, "empty key"},
+		{func(s *StrictSink) { s.EmitKey([]string{}, 1) }, "empty key"},
+		{func(s *StrictSink) { s.IncrCounter([]string{"key"}, nan) }, "NaN"},
+		{func(s *StrictSink) { s.AddSample([]string{"key"}, inf) }, "+Inf"},
+		{func(s *StrictSink) { s.AddSample([]string{"key"}, -inf) }, "-Inf"},
+		{func(s *StrictSink) {
+			s.SetGaugeWithLabels([]string{"key"}, 1, []Label{{"a", "b"}, {"a", "c"}})
+		}, `duplicate label "a"`},
+		{func(s *StrictSink) {
+			s.RequireLabels = true
+			s.IncrCounterWithLabels([]string{"key"}, 1, nil)
+		}, "nil labels"},
+	}
+
+	for _, tt := range cases {
+		m := &MockSink{}
+		s := NewStrictSink(m)
+		err := RecoverStrict(func() { tt.Fn(s) })
+		if err == nil {
+			t.Fatalf("expected error %s", tt.Reason)
+		}
+		if !strings.Contains(err.Error(), tt.Reason) {
+			t.Fatalf("expected %s got %v", tt.Reason, err)
+		}
+		if len(m.keys) != 0 {
+			t.Fatalf("invalid call was forwarded")
+		}
+	}
+}
+
+func TestRecoverStrict_OtherPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("expected panic to propagate, got %v", r)
+		}
+	}()
+	RecoverStrict(func() { panic("boom") })
+}
+
+func TestStrictFromEnv(t *testing.T) {
+	m := &MockSink{}
+
+	os.Setenv(StrictEnv, "")
+	if _, ok := StrictFromEnv(m).(*StrictSink); ok {
+		t.Fatalf("strict sink must be disabled")
+	}
+
+	os.Setenv(StrictEnv, "1")
+	defer os.Unsetenv(StrictEnv)
+	if _, ok := StrictFromEnv(m).(*StrictSink); !ok {
+		t.Fatalf("strict sink must be enabled")
+	}
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// timeoutDropsKey is the counter incremented on the inner sink
+// for every call abandoned by a TimeoutSink
+var timeoutDropsKey = []string{"timeout_drops_total"}
+
+// DefaultSinkTimeout is the timeout of a TimeoutSink created with a
+// timeout which is not positive
+const DefaultSinkTimeout = time.Second
+
+// timeoutMaxPending bounds the number of inner sink calls running at
+// once, which is the number of goroutines leaked by slow calls
+const timeoutMaxPending = 1024
+
+// TimeoutSink prevents a slow sink from blocking its callers. Each call
+// to the inner sink runs on its own goroutine, the caller waiting at most
+// the timeout for it to finish. Calls exceeding the timeout are abandoned
+// and counted on the timeout_drops_total counter of the inner sink. Calls
+// are also dropped when too many of them are pending.
+type TimeoutSink struct {
+	drops uint64 // accessed atomically, kept first for alignment
+
+	inner   Sinker
+	timeout time.Duration
+	pending chan struct{}
+}
+
+// NewTimeoutSink creates a TimeoutSink, the timeout defaulting to
+// DefaultSinkTimeout when it is not positive
+func NewTimeoutSink(inner Sinker, timeout time.Duration) *TimeoutSink {
+	if timeout <= 0 {
+		timeout = DefaultSinkTimeout
+	}
+	return &TimeoutSink{
+		inner:   inner,
+		timeout: timeout,
+		pending: make(chan struct{}, timeoutMaxPending),
+	}
+}
+
+// Drops returns the number of calls abandoned so far
+func (s *TimeoutSink) Drops() uint64 {
+	return atomic.LoadUint64(&s.drops)
+}
+
+// SetGauge sets a value on a gauge
+func (s *TimeoutSink) SetGauge(key []string, val float32) {
+	key = copyKey(key)
+	s.run(func() { s.inner.SetGauge(key, val) })
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *TimeoutSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	key, labels = copyKey(key), copyLabels(labels)
+	s.run(func() { s.inner.SetGaugeWithLabels(key, val, labels) })
+}
+
+// EmitKey emits a key value metric
+func (s *TimeoutSink) EmitKey(key []string, val float32) {
+	key = copyKey(key)
+	s.run(func() { s.inner.EmitKey(key, val) })
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *TimeoutSink) IncrCounter(key []string, val float32) {
+	key = copyKey(key)
+	s.run(func() { s.inner.IncrCounter(key, val) })
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *TimeoutSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	key, labels = copyKey(key), copyLabels(labels)
+	s.run(func() { s.inner.IncrCounterWithLabels(key, val, labels) })
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *TimeoutSink) IncrCounterFloat64(key []string, val float64) {
+	key = copyKey(key)
+	s.run(func() { AsFloat64Sink(s.inner).IncrCounterFloat64(key, val) })
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *TimeoutSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	key, labels = copyKey(key), copyLabels(labels)
+	s.run(func() { AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels) })
+}
+
+// AddSample adds a sample metrics
+func (s *TimeoutSink) AddSample(key []string, val float32) {
+	key = copyKey(key)
+	s.run(func() { s.inner.AddSample(key, val) })
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *TimeoutSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	key, labels = copyKey(key), copyLabels(labels)
+	s.run(func() { s.inner.AddSampleWithLabels(key, val, labels) })
+}
+
+// run calls fn on a goroutine, waiting at most the timeout for it. The
+// calls copy their key and labels first, as fn may outlive the call.
+func (s *TimeoutSink) run(fn func()) {
+	done, ok := s.spawn(fn)
+	if !ok {
+		s.drop()
+		return
+	}
+
+	timedOut := make(chan struct{})
+	watchdog := time.AfterFunc(s.timeout, func() { close(timedOut) })
+	select {
+	case <-done:
+		watchdog.Stop()
+	case <-timedOut:
+		s.drop()
+	}
+}
+
+// spawn runs fn on a goroutine, unless too many calls are pending
+func (s *TimeoutSink) spawn(fn func()) (<-chan struct{}, bool) {
+	select {
+	case s.pending <- struct{}{}:
+	default:
+		return nil, false
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer func() { <-s.pending }()
+		fn()
+		close(done)
+	}()
+	return done, true
+}
+
+// drop counts an abandoned call, reporting it to the inner
+// sink without waiting for it, since it is likely to be slow
+func (s *TimeoutSink) drop() {
+	atomic.AddUint64(&s.drops, 1)
+	s.spawn(func() { s.inner.IncrCounter(timeoutDropsKey, 1) })
+}
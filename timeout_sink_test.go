@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SlowSink blocks every call until it is released
+type SlowSink struct {
+	MockSink
+	mu      sync.Mutex
+	release chan struct{}
+}
+
+func (s *SlowSink) IncrCounter(key []string, val float32) {
+	if key[0] != timeoutDropsKey[0] {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.MockSink.IncrCounter(key, val)
+}
+
+func TestTimeoutSink(t *testing.T) {
+	m := &MockSink{}
+	s := NewTimeoutSink(m, time.Second)
+	emitAll(s)
+
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if s.Drops() != 0 {
+		t.Fatalf("bad drops %d", s.Drops())
+	}
+}
+
+func TestTimeoutSink_DefaultTimeout(t *testing.T) {
+	m := &MockSink{}
+	for _, timeout := range []time.Duration{0, -time.Second} {
+		s := NewTimeoutSink(m, timeout)
+		if s.timeout != DefaultSinkTimeout {
+			t.Fatalf("bad timeout %s for %s", s.timeout, timeout)
+		}
+		s.IncrCounter([]string{"counter"}, 1)
+		if s.Drops() != 0 {
+			t.Fatalf("bad drops %d", s.Drops())
+		}
+	}
+	if len(m.keys) != 2 {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+}
+
+func TestTimeoutSink_Timeout(t *testing.T) {
+	m := &SlowSink{release: make(chan struct{})}
+	s := NewTimeoutSink(m, 10*time.Millisecond)
+
+	start := time.Now()
+	s.IncrCounter([]string{"counter"}, 1)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("call must be abandoned after the timeout, took %s", elapsed)
+	}
+	if s.Drops() != 1 {
+		t.Fatalf("bad drops %d", s.Drops())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		n := len(m.keys)
+		m.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("drop was not counted on the inner sink")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	m.mu.Lock()
+	if !reflect.DeepEqual(m.keys[0], timeoutDropsKey) {
+		t.Fatalf("bad keys %v", m.keys)
+	}
+	m.mu.Unlock()
+	close(m.release)
+}
+
+func TestTimeoutSink_AbandonedCallCopies(t *testing.T) {
+	m := &SlowSink{release: make(chan struct{})}
+	s := NewTimeoutSink(m, 10*time.Millisecond)
+
+	// The caller reuses its key once the call is abandoned
+	key := []string{"counter"}
+	s.IncrCounter(key, 1)
+	key[0] = "modified"
+	close(m.release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		n := len(m.keys)
+		m.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("abandoned call did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if k[0] == "modified" {
+			t.Fatalf("abandoned call used the reused key %v", m.keys)
+		}
+	}
+}
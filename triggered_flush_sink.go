@@ -0,0 +1,77 @@
+package metrics
+
+// TriggeredFlushSink buffers every metric call in memory until Flush
+// submits them all to the inner sink, or Abort discards them. It allows
+// submitting the metrics of a job run as a whole when the job completes.
+// The buffer is unbounded, every call is retained until then.
+type TriggeredFlushSink struct {
+	inner Sinker
+	batch Batch
+}
+
+// NewTriggeredFlushSink creates a TriggeredFlushSink
+func NewTriggeredFlushSink(inner Sinker) *TriggeredFlushSink {
+	return &TriggeredFlushSink{inner: inner}
+}
+
+// Flush submits the buffered metrics to the inner sink, emptying the
+// buffer. Inner sinks implementing AtomicSinker apply them atomically,
+// and the first failure is returned for FallibleSinker inner sinks.
+func (s *TriggeredFlushSink) Flush() error {
+	return s.batch.Commit(s.inner)
+}
+
+// Abort discards the buffered metrics
+func (s *TriggeredFlushSink) Abort() {
+	s.batch.Reset()
+}
+
+// Len returns the number of buffered metrics
+func (s *TriggeredFlushSink) Len() int {
+	return s.batch.Len()
+}
+
+// SetGauge sets a value on a gauge
+func (s *TriggeredFlushSink) SetGauge(key []string, val float32) {
+	s.batch.SetGauge(key, val)
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *TriggeredFlushSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	s.batch.SetGaugeWithLabels(key, val, labels)
+}
+
+// EmitKey emits a key value metric
+func (s *TriggeredFlushSink) EmitKey(key []string, val float32) {
+	s.batch.EmitKey(key, val)
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *TriggeredFlushSink) IncrCounter(key []string, val float32) {
+	s.batch.IncrCounter(key, val)
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *TriggeredFlushSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	s.batch.IncrCounterWithLabels(key, val, labels)
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *TriggeredFlushSink) IncrCounterFloat64(key []string, val float64) {
+	s.batch.IncrCounterFloat64(key, val)
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *TriggeredFlushSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	s.batch.IncrCounterWithLabelsFloat64(key, val, labels)
+}
+
+// AddSample adds a sample metrics
+func (s *TriggeredFlushSink) AddSample(key []string, val float32) {
+	s.batch.AddSample(key, val)
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *TriggeredFlushSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	s.batch.AddSampleWithLabels(key, val, labels)
+}
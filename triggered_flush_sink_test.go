@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTriggeredFlushSink_Flush(t *testing.T) {
+	m := &MockSink{}
+	s := NewTriggeredFlushSink(m)
+	emitAll(s)
+
+	if len(m.vals) != 0 {
+		t.Fatalf("metrics must be buffered until Flush %v", m.vals)
+	}
+	if s.Len() != 7 {
+		t.Fatalf("bad len %d", s.Len())
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(m.vals, []float32{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("bad vals %v", m.vals)
+	}
+	if !reflect.DeepEqual(m.keys[2], []string{"key"}) || !reflect.DeepEqual(m.labels[6], []Label{{"a", "b"}}) {
+		t.Fatalf("bad metrics %v %v", m.keys, m.labels)
+	}
+
+	// The buffer is emptied, a second Flush submits nothing
+	if err := s.Flush(); err != nil || len(m.vals) != 7 {
+		t.Fatalf("bad flush %v %v", err, m.vals)
+	}
+}
+
+func TestTriggeredFlushSink_ReusedSlices(t *testing.T) {
+	m := &MockSink{}
+	s := NewTriggeredFlushSink(m)
+
+	// The job reuses one key and label slice for every call
+	key := []string{"job", ""}
+	labels := []Label{{"step", ""}}
+	for _, step := range []string{"fetch", "parse", "store"} {
+		key[1], labels[0].Value = step, step
+		s.IncrCounterWithLabels(key, 1, labels)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i, step := range []string{"fetch", "parse", "store"} {
+		if !reflect.DeepEqual(m.keys[i], []string{"job", step}) || !reflect.DeepEqual(m.labels[i], []Label{{"step", step}}) {
+			t.Fatalf("bad metric %d %v %v", i, m.keys[i], m.labels[i])
+		}
+	}
+}
+
+func TestTriggeredFlushSink_Abort(t *testing.T) {
+	m := &MockSink{}
+	s := NewTriggeredFlushSink(m)
+	emitAll(s)
+	s.Abort()
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(m.vals) != 0 || s.Len() != 0 {
+		t.Fatalf("aborted metrics must be discarded %v", m.vals)
+	}
+}
+
+func TestTriggeredFlushSink_Float64(t *testing.T) {
+	m := &MockFloat64Sink{}
+	s := NewTriggeredFlushSink(m)
+	s.IncrCounterFloat64([]string{"counter"}, 0.1)
+	s.IncrCounterWithLabelsFloat64([]string{"counter"}, 1e-9, []Label{{"a", "b"}})
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(m.vals64, []float64{0.1, 1e-9}) {
+		t.Fatalf("bad vals %v", m.vals64)
+	}
+}
+
+func TestTriggeredFlushSink_Fallible(t *testing.T) {
+	s := NewTriggeredFlushSink(&MockFallibleSink{err: ErrQueueFull})
+	s.EmitKey([]string{"key"}, 1)
+	if err := s.Flush(); err != ErrQueueFull {
+		t.Fatalf("expected ErrQueueFull got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// NamingConvention validates the keys and labels of the metrics
+// against the naming rules of a backend
+type NamingConvention interface {
+	ValidateKey(key []string) error
+	ValidateLabel(label Label) error
+}
+
+// ValidatingSink drops the metrics whose key or labels break a naming
+// convention, logging why, so invalid metrics are found before the
+// backend silently rejects them. The violations are logged once per key,
+// so a bad emitter does not flood the logs, and counted in DroppedTotal.
+type ValidatingSink struct {
+	dropped int64 // accessed atomically, kept first for alignment
+
+	inner      Sinker
+	convention NamingConvention
+	logged     sync.Map // flattened key of the logged violations
+}
+
+// NewValidatingSink creates a ValidatingSink forwarding the valid metrics
+// to the inner sink, ex: NewValidatingSink(sink, PrometheusConvention{})
+func NewValidatingSink(inner Sinker, convention NamingConvention) *ValidatingSink {
+	return &ValidatingSink{inner: inner, convention: convention}
+}
+
+// DroppedTotal returns the number of metrics dropped so far
+func (s *ValidatingSink) DroppedTotal() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// SetGauge sets a value on a gauge
+func (s *ValidatingSink) SetGauge(key []string, val float32) {
+	if s.valid(key, nil) {
+		s.inner.SetGauge(key, val)
+	}
+}
+
+// SetGaugeWithLabels sets a value on a gauge with labels
+func (s *ValidatingSink) SetGaugeWithLabels(key []string, val float32, labels []Label) {
+	if s.valid(key, labels) {
+		s.inner.SetGaugeWithLabels(key, val, labels)
+	}
+}
+
+// EmitKey emits a key value metric
+func (s *ValidatingSink) EmitKey(key []string, val float32) {
+	if s.valid(key, nil) {
+		s.inner.EmitKey(key, val)
+	}
+}
+
+// IncrCounter increases the value of a counter by a given value
+func (s *ValidatingSink) IncrCounter(key []string, val float32) {
+	if s.valid(key, nil) {
+		s.inner.IncrCounter(key, val)
+	}
+}
+
+// IncrCounterWithLabels increases the value of a counter by a given value with labels
+func (s *ValidatingSink) IncrCounterWithLabels(key []string, val float32, labels []Label) {
+	if s.valid(key, labels) {
+		s.inner.IncrCounterWithLabels(key, val, labels)
+	}
+}
+
+// IncrCounterFloat64 increases the value of a counter by a given value
+func (s *ValidatingSink) IncrCounterFloat64(key []string, val float64) {
+	if s.valid(key, nil) {
+		AsFloat64Sink(s.inner).IncrCounterFloat64(key, val)
+	}
+}
+
+// IncrCounterWithLabelsFloat64 increases the value of a counter by a given value with labels
+func (s *ValidatingSink) IncrCounterWithLabelsFloat64(key []string, val float64, labels []Label) {
+	if s.valid(key, labels) {
+		AsFloat64Sink(s.inner).IncrCounterWithLabelsFloat64(key, val, labels)
+	}
+}
+
+// AddSample adds a sample metrics
+func (s *ValidatingSink) AddSample(key []string, val float32) {
+	if s.valid(key, nil) {
+		s.inner.AddSample(key, val)
+	}
+}
+
+// AddSampleWithLabels adds a sample metrics with labels
+func (s *ValidatingSink) AddSampleWithLabels(key []string, val float32, labels []Label) {
+	if s.valid(key, labels) {
+		s.inner.AddSampleWithLabels(key, val, labels)
+	}
+}
+
+// valid validates the key and labels, counting the invalid metrics and
+// logging the first violation of each key
+func (s *ValidatingSink) valid(key []string, labels []Label) bool {
+	err := s.convention.ValidateKey(key)
+	for i := 0; err == nil && i < len(labels); i++ {
+		err = s.convention.ValidateLabel(labels[i])
+	}
+	if err == nil {
+		return true
+	}
+
+	atomic.AddInt64(&s.dropped, 1)
+	if _, logged := s.logged.LoadOrStore(strings.Join(key, "."), struct{}{}); !logged {
+		log.Printf("[ERR] Dropping metric %v: %s", key, err)
+	}
+	return false
+}
+
+var (
+	prometheusNameRe  = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	prometheusLabelRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// PrometheusConvention follows the Prometheus data model: the key parts
+// joined with '_' must be a valid metric name, the label names must be
+// valid and not reserved, starting with "__", and the values UTF-8
+type PrometheusConvention struct{}
+
+// ValidateKey validates the metric name of the key
+func (PrometheusConvention) ValidateKey(key []string) error {
+	if name := strings.Join(key, "_"); !prometheusNameRe.MatchString(name) {
+		return fmt.Errorf("invalid prometheus metric name %q", name)
+	}
+	return nil
+}
+
+// ValidateLabel validates the label name and value
+func (PrometheusConvention) ValidateLabel(label Label) error {
+	if !prometheusLabelRe.MatchString(label.Name) || strings.HasPrefix(label.Name, "__") {
+		return fmt.Errorf("invalid prometheus label name %q", label.Name)
+	}
+	if !utf8.ValidString(label.Value) {
+		return fmt.Errorf("invalid prometheus label value %q", label.Value)
+	}
+	return nil
+}
+
+// StatsdConvention rejects the characters of the statsd protocol, and
+// of the DogStatsD tags, in the keys and labels, and the empty parts
+type StatsdConvention struct{}
+
+// statsdReserved are the characters delimiting the statsd lines
+const statsdReserved = ":|@#,\n "
+
+// ValidateKey validates the key parts
+func (StatsdConvention) ValidateKey(key []string) error {
+	if len(key) == 0 {
+		return fmt.Errorf("empty statsd key")
+	}
+	for _, part := range key {
+		if part == "" || strings.ContainsAny(part, statsdReserved) {
+			return fmt.Errorf("invalid statsd key part %q", part)
+		}
+	}
+	return nil
+}
+
+// ValidateLabel validates the label name and value
+func (StatsdConvention) ValidateLabel(label Label) error {
+	if label.Name == "" || strings.ContainsAny(label.Name, statsdReserved) {
+		return fmt.Errorf("invalid statsd tag name %q", label.Name)
+	}
+	if strings.ContainsAny(label.Value, statsdReserved) {
+		return fmt.Errorf("invalid statsd tag value %q", label.Value)
+	}
+	return nil
+}
+
+// InfluxTagConvention follows the InfluxDB line protocol: the labels are
+// tags, which must have a name and a value, the names "time", "_field"
+// and "_measurement" being reserved. Newlines are rejected everywhere.
+type InfluxTagConvention struct{}
+
+// ValidateKey validates the measurement name of the key
+func (InfluxTagConvention) ValidateKey(key []string) error {
+	if name := strings.Join(key, "."); name == "" || strings.Contains(name, "\n") {
+		return fmt.Errorf("invalid influx measurement %q", name)
+	}
+	return nil
+}
+
+// ValidateLabel validates the tag name and value
+func (InfluxTagConvention) ValidateLabel(label Label) error {
+	switch label.Name {
+	case "", "time", "_field", "_measurement":
+		return fmt.Errorf("invalid influx tag name %q", label.Name)
+	}
+	if strings.Contains(label.Name, "\n") {
+		return fmt.Errorf("invalid influx tag name %q", label.Name)
+	}
+	if label.Value == "" || strings.Contains(label.Value, "\n") {
+		return fmt.Errorf("invalid influx tag value %q", label.Value)
+	}
+	return nil
+}
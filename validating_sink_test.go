@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidatingSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	inner := &MockSink{}
+	s := NewValidatingSink(inner, PrometheusConvention{})
+
+	emitAll(s)
+	s.IncrCounterWithLabels([]string{"http.requests"}, 8, nil)
+	s.SetGaugeWithLabels([]string{"gauge"}, 9, []Label{{"__name__", "x"}})
+	s.AddSampleWithLabels([]string{"sample"}, 10, []Label{{"a", "b"}, {"bad-name", "c"}})
+	s.AddSampleWithLabels([]string{"sample"}, 11, []Label{{"bad-name", "d"}})
+
+	if len(inner.vals) != 7 || inner.vals[6] != 7 {
+		t.Fatalf("bad vals: %v", inner.vals)
+	}
+	if s.DroppedTotal() != 4 {
+		t.Fatalf("bad dropped total %d", s.DroppedTotal())
+	}
+
+	// Logged once per key
+	if n := strings.Count(buf.String(), "Dropping metric"); n != 3 {
+		t.Fatalf("bad log %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `invalid prometheus label name "bad-name"`) {
+		t.Fatalf("bad log %s", buf.String())
+	}
+}
+
+func TestNamingConventions(t *testing.T) {
+	for _, tc := range []struct {
+		convention NamingConvention
+		key        []string
+		label      Label
+		validKey   bool
+		validLabel bool
+	}{
+		{PrometheusConvention{}, []string{"http", "requests"}, Label{"code", "200"}, true, true},
+		{PrometheusConvention{}, []string{"1st"}, Label{"__reserved", "x"}, false, false},
+		{PrometheusConvention{}, []string{"a:b"}, Label{"a", "\xff"}, true, false},
+		{StatsdConvention{}, []string{"http", "requests"}, Label{"code", "200"}, true, true},
+		{StatsdConvention{}, []string{"a:b"}, Label{"a", "b|c"}, false, false},
+		{StatsdConvention{}, []string{"a", ""}, Label{"", "b"}, false, false},
+		{StatsdConvention{}, nil, Label{"a", ""}, false, true},
+		{InfluxTagConvention{}, []string{"cpu", "load"}, Label{"host", "web-1"}, true, true},
+		{InfluxTagConvention{}, []string{""}, Label{"host", ""}, false, false},
+		{InfluxTagConvention{}, []string{"a\nb"}, Label{"time", "now"}, false, false},
+	} {
+		if err := tc.convention.ValidateKey(tc.key); (err == nil) != tc.validKey {
+			t.Fatalf("bad %T key %q: %v", tc.convention, tc.key, err)
+		}
+		if err := tc.convention.ValidateLabel(tc.label); (err == nil) != tc.validLabel {
+			t.Fatalf("bad %T label %v: %v", tc.convention, tc.label, err)
+		}
+	}
+}